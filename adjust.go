@@ -0,0 +1,87 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/esimov/caire/filters"
+)
+
+// Adjustments holds the color adjustments applied to the resized image before encoding.
+// Each value is in the [-1, 1] range, where zero leaves the image unaltered.
+type Adjustments struct {
+	Brightness float64
+	Contrast   float64
+	Saturation float64
+}
+
+// IsZero reports whether the adjustments leave the image unaltered.
+func (a Adjustments) IsZero() bool {
+	return a == Adjustments{}
+}
+
+// table returns the lookup table applying the brightness and contrast adjustments.
+func (a Adjustments) table() *[256]uint8 {
+	var lut [256]uint8
+	for i := range lut {
+		v := (float64(i)-128)*(1+a.Contrast) + 128 + a.Brightness*255
+		lut[i] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+	}
+	return &lut
+}
+
+// saturate mixes the color with its luminance, the same way as the grayscale conversion.
+func (a Adjustments) saturate(r, g, b uint8) (uint8, uint8, uint8) {
+	l := int32(filters.Luma(r, g, b))
+	s := int32(math.Round((1 + a.Saturation) * 256))
+
+	mix := func(c uint8) uint8 {
+		v := l + ((int32(c)-l)*s)>>8
+		if v < 0 {
+			return 0
+		} else if v > 255 {
+			return 255
+		}
+		return uint8(v)
+	}
+	return mix(r), mix(g), mix(b)
+}
+
+// Apply returns a new image with the color adjustments applied.
+// The pixels are processed in a flat loop over the pixel buffer.
+func (a Adjustments) Apply(src *image.NRGBA) *image.NRGBA {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	lut := a.table()
+
+	for y := 0; y < height; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+width*4]
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+width*4]
+
+		for i := 0; i+3 < len(row); i += 4 {
+			r, g, b := lut[row[i]], lut[row[i+1]], lut[row[i+2]]
+			if a.Saturation != 0 {
+				r, g, b = a.saturate(r, g, b)
+			}
+			out[i], out[i+1], out[i+2], out[i+3] = r, g, b, row[i+3]
+		}
+	}
+	return dst
+}
+
+// applyPalette returns a new palette with the color adjustments applied.
+// It's used for adjusting the paletted images, like the Gif frames.
+func (a Adjustments) applyPalette(pal color.Palette) color.Palette {
+	lut := a.table()
+	res := make(color.Palette, len(pal))
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		r, g, b := lut[n.R], lut[n.G], lut[n.B]
+		if a.Saturation != 0 {
+			r, g, b = a.saturate(r, g, b)
+		}
+		res[i] = color.NRGBA{R: r, G: g, B: b, A: n.A}
+	}
+	return res
+}
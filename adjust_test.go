@@ -0,0 +1,33 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjustments_ShouldAdjustColors(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	for x := 0; x < imgWidth; x++ {
+		for y := 0; y < imgHeight; y++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	assert.True(Adjustments{}.IsZero())
+	assert.Equal(img.Pix, Adjustments{}.Apply(img).Pix)
+
+	res := Adjustments{Brightness: 0.1}.Apply(img)
+	assert.Equal(color.NRGBA{R: 226, G: 126, B: 76, A: 255}, res.NRGBAAt(0, 0))
+
+	res = Adjustments{Contrast: 1}.Apply(img)
+	assert.Equal(color.NRGBA{R: 255, G: 72, B: 0, A: 255}, res.NRGBAAt(0, 0))
+
+	// Removing the saturation should be equivalent with the grayscale conversion.
+	res = Adjustments{Saturation: -1}.Apply(img)
+	gray := p.Grayscale(img)
+	assert.Equal(gray.Pix, res.Pix)
+}
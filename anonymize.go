@@ -0,0 +1,66 @@
+package caire
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/utils"
+	pigo "github.com/esimov/pigo/core"
+)
+
+// anonymizeFaces detects the human faces in the resized image and blurs or pixelates them.
+func (p *Processor) anonymizeFaces(img *image.NRGBA) *image.NRGBA {
+	if p.FaceDetector == nil {
+		return img
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	c := NewCarver(width, height)
+
+	cParams := pigo.CascadeParams{
+		MinSize:     20,
+		MaxSize:     utils.Max(width, height),
+		ShiftFactor: 0.1,
+		ScaleFactor: 1.1,
+
+		ImageParams: pigo.ImageParams{
+			Pixels: c.rgbToGrayscale(img),
+			Rows:   height,
+			Cols:   width,
+			Dim:    width,
+		},
+	}
+	dets := p.FaceDetector.RunCascade(cParams, p.FaceAngle)
+	dets = p.FaceDetector.ClusterDetections(dets, 0.2)
+
+	dst := image.NewNRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, image.Point{}, draw.Src)
+
+	for _, face := range dets {
+		if face.Q <= 5.0 {
+			continue
+		}
+		// Extend the detection area a little, to cover the whole face.
+		scale := int(float64(face.Scale) * 0.6)
+		rect := image.Rect(
+			face.Col-scale,
+			face.Row-scale,
+			face.Col+scale,
+			face.Row+scale,
+		).Intersect(dst.Bounds())
+		if rect.Empty() {
+			continue
+		}
+
+		var res *image.NRGBA
+		if p.PixelateFaces {
+			blocks := utils.Max(rect.Dx()/10, 1)
+			res = imaging.Resize(dst.SubImage(rect), utils.Max(rect.Dx()/blocks, 1), 0, imaging.Box)
+			res = imaging.Resize(res, rect.Dx(), rect.Dy(), imaging.NearestNeighbor)
+		} else {
+			res = imaging.Blur(dst.SubImage(rect), float64(rect.Dx())/8)
+		}
+		draw.Draw(dst, rect, res, image.Point{}, draw.Src)
+	}
+	return dst
+}
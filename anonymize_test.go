@@ -0,0 +1,41 @@
+package caire
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pigo "github.com/esimov/pigo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymize_ShouldBlurTheFaces(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.Open(filepath.Join("./testdata", "sample.jpg"))
+	if err != nil {
+		t.Fatalf("could not load sample image: %v", err)
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("error decoding image: %v", err)
+	}
+
+	for _, pixelate := range []bool{false, true} {
+		proc := &Processor{BlurFaces: true, PixelateFaces: pixelate}
+		proc.FaceDetector, err = pigo.NewPigo().Unpack(cascadeFile)
+		if err != nil {
+			t.Fatalf("error unpacking the cascade file: %v", err)
+		}
+
+		img := proc.imgToNRGBA(src)
+		res := proc.anonymizeFaces(img)
+		assert.Equal(img.Bounds(), res.Bounds())
+		// The image corners are far from the face.
+		assert.Equal(img.NRGBAAt(0, 0), res.NRGBAAt(0, 0))
+		assert.NotEqual(img.Pix, res.Pix)
+	}
+}
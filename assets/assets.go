@@ -0,0 +1,220 @@
+// Package assets resolves the model files used by the optional machine learning features,
+// like the face detection cascade, the pupil localization cascade or the segmentation models.
+//
+// An asset is looked up in the embedded data first, then in the user cache directory,
+// and finally it's downloaded from its URL. The downloaded assets are verified against
+// their SHA-256 checksum prior to being stored in the cache, so the large models don't
+// need to be bundled into the binary, but they remain available on demand.
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/esimov/caire/utils"
+)
+
+// The names of the known assets.
+const (
+	// FaceFinder is the face detection cascade, embedded into the binary.
+	FaceFinder = "facefinder"
+	// Puploc is the pupil localization cascade.
+	Puploc = "puploc"
+	// Segmentation is the ONNX model used for the subject segmentation.
+	Segmentation = "segmentation.onnx"
+)
+
+// ErrNotFound is returned when an asset is neither embedded, nor registered with an URL.
+var ErrNotFound = errors.New("asset not found")
+
+// ChecksumError is returned when the content of an asset doesn't match its checksum.
+type ChecksumError struct {
+	Name, Expected, Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for the %s asset: expected %s, got %s", e.Name, e.Expected, e.Actual)
+}
+
+// Asset describes the location of a model file.
+type Asset struct {
+	Name string
+	// URL is the location of the asset: a http(s) URL or a local file path.
+	URL string
+	// SHA256 is the hex encoded checksum of the asset content.
+	// It's mandatory for the assets downloaded from a remote URL.
+	SHA256 string
+}
+
+// ParseAsset parses an asset definition provided as name=location, where the location is a file
+// path or an URL. The checksum can be attached to the location as a fragment (ex. #sha256=...).
+func ParseAsset(s string) (Asset, error) {
+	name, loc, ok := strings.Cut(s, "=")
+	if !ok || name == "" || loc == "" {
+		return Asset{}, fmt.Errorf("invalid asset definition: %q (expected name=location)", s)
+	}
+	a := Asset{Name: name, URL: loc}
+	if loc, sum, ok := strings.Cut(loc, "#sha256="); ok {
+		a.URL, a.SHA256 = loc, strings.ToLower(sum)
+	}
+	return a, nil
+}
+
+// DefaultCacheDir returns the directory where the downloaded assets are cached (ex. ~/.cache/caire).
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "caire")
+	}
+	return filepath.Join(dir, "caire")
+}
+
+// Loader resolves the assets from the embedded data, the cache directory or their URL.
+// A Loader is safe for concurrent use by multiple goroutines.
+type Loader struct {
+	// CacheDir is the directory of the downloaded assets. The cache is disabled if empty.
+	CacheDir string
+	// Fetcher is used for downloading the assets. If nil, a fetcher with the default settings is used.
+	Fetcher *utils.Fetcher
+
+	mu       sync.Mutex
+	assets   map[string]Asset
+	embedded map[string][]byte
+	loaded   map[string][]byte
+}
+
+// NewLoader returns a loader caching the downloaded assets in the default cache directory.
+func NewLoader() *Loader {
+	return &Loader{CacheDir: DefaultCacheDir()}
+}
+
+// Embed registers an asset bundled into the binary.
+func (l *Loader) Embed(name string, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.embedded == nil {
+		l.embedded = make(map[string][]byte)
+	}
+	l.embedded[name] = data
+}
+
+// Register registers the location of an asset. It takes precedence over the embedded data,
+// so it can be used for replacing the bundled models.
+func (l *Loader) Register(a Asset) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.assets == nil {
+		l.assets = make(map[string]Asset)
+	}
+	l.assets[a.Name] = a
+	delete(l.loaded, a.Name)
+}
+
+// Load returns the content of the asset. The loaded assets are kept in memory.
+func (l *Loader) Load(ctx context.Context, name string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if data, ok := l.loaded[name]; ok {
+		return data, nil
+	}
+	a, registered := l.assets[name]
+	if !registered {
+		if data, ok := l.embedded[name]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	data, err := l.load(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the %s asset: %w", name, err)
+	}
+	if l.loaded == nil {
+		l.loaded = make(map[string][]byte)
+	}
+	l.loaded[name] = data
+
+	return data, nil
+}
+
+// load reads the asset from the local file, the cache directory, or downloads it.
+func (l *Loader) load(ctx context.Context, a Asset) ([]byte, error) {
+	u, err := url.Parse(a.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		data, err := os.ReadFile(a.URL)
+		if err != nil {
+			return nil, err
+		}
+		return data, verify(a, data)
+	}
+	if a.SHA256 == "" {
+		return nil, errors.New("the checksum of the remote assets is mandatory")
+	}
+
+	var cached string
+	if l.CacheDir != "" {
+		// The file name includes the checksum, so the cache is invalidated when the asset is updated.
+		cached = filepath.Join(l.CacheDir, a.Name+"-"+a.SHA256[:min(len(a.SHA256), 16)])
+		if data, err := os.ReadFile(cached); err == nil && verify(a, data) == nil {
+			return data, nil
+		}
+	}
+
+	f := l.Fetcher
+	if f == nil {
+		f = utils.NewFetcher()
+	}
+	tmp, err := f.Fetch(ctx, a.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	data, err := io.ReadAll(tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(a, data); err != nil {
+		return nil, err
+	}
+
+	if cached != "" {
+		if err := os.MkdirAll(l.CacheDir, 0755); err != nil {
+			return nil, err
+		}
+		// The file is replaced atomically, so the concurrent runs never read a partially written asset.
+		part := cached + ".part"
+		if err := os.WriteFile(part, data, 0644); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(part, cached); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// verify checks the content of the asset against its checksum, if it's provided.
+func verify(a Asset, data []byte) error {
+	if a.SHA256 == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, a.SHA256) {
+		return &ChecksumError{Name: a.Name, Expected: a.SHA256, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader_ShouldResolveTheAssets(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	content := []byte("cascade")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	l := &Loader{CacheDir: t.TempDir()}
+	l.Embed(FaceFinder, []byte("embedded"))
+
+	data, err := l.Load(ctx, FaceFinder)
+	assert.NoError(err)
+	assert.Equal([]byte("embedded"), data)
+
+	_, err = l.Load(ctx, Puploc)
+	assert.True(errors.Is(err, ErrNotFound))
+
+	// The remote assets should be verified.
+	l.Register(Asset{Name: Puploc, URL: ts.URL})
+	_, err = l.Load(ctx, Puploc)
+	assert.Error(err)
+
+	l.Register(Asset{Name: Puploc, URL: ts.URL, SHA256: "00ff"})
+	_, err = l.Load(ctx, Puploc)
+	var cerr *ChecksumError
+	assert.True(errors.As(err, &cerr))
+
+	asset, err := ParseAsset(Puploc + "=" + ts.URL + "#sha256=" + checksum)
+	assert.NoError(err)
+	l.Register(asset)
+	data, err = l.Load(ctx, Puploc)
+	assert.NoError(err)
+	assert.Equal(content, data)
+	assert.Equal(2, requests)
+
+	// A new loader should find the asset in the cache.
+	l2 := &Loader{CacheDir: l.CacheDir}
+	l2.Register(asset)
+	data, err = l2.Load(ctx, Puploc)
+	assert.NoError(err)
+	assert.Equal(content, data)
+	assert.Equal(2, requests)
+
+	// The registered assets take precedence over the embedded data.
+	path := filepath.Join(t.TempDir(), "facefinder")
+	assert.NoError(os.WriteFile(path, content, 0644))
+	l.Register(Asset{Name: FaceFinder, URL: path})
+	data, err = l.Load(ctx, FaceFinder)
+	assert.NoError(err)
+	assert.Equal(content, data)
+
+	_, err = ParseAsset("facefinder")
+	assert.Error(err)
+}
@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// AxisDecision holds the outcome of the automatic carving axis detection.
+type AxisDecision struct {
+	// Axis is the dimension which is reduced: "width" or "height".
+	Axis string
+	// Width and Height is the size of the resized image.
+	Width, Height int
+	// WidthDistortion and HeightDistortion are the estimated distortions
+	// of the two candidate plans (the mean energy of the removed pixels).
+	WidthDistortion, HeightDistortion float64
+}
+
+// AxisDecision returns the decision of the last automatic carving axis detection.
+// It returns nil if the AutoAxis option has not been used.
+func (p *Processor) AxisDecision() *AxisDecision {
+	return p.axisDecision
+}
+
+// detectAxis decides whether removing vertical or horizontal seams causes less distortion
+// for reaching the pixel budget defined by TargetPixels or TargetRatio, then it updates
+// the new image width or height accordingly.
+func (p *Processor) detectAxis(img *image.NRGBA) error {
+	if p.Percentage || p.Square {
+		return errors.New("the auto axis option cannot be combined with the percentage or square options")
+	}
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+
+	budget := float64(p.TargetPixels)
+	if p.TargetRatio > 0 {
+		budget = p.TargetRatio * float64(dx*dy)
+	}
+	if budget <= 0 || budget >= float64(dx*dy) {
+		return errors.New("the auto axis option requires a pixel budget or ratio smaller than the image size")
+	}
+
+	width := int(math.Round(budget / float64(dy)))
+	height := int(math.Round(budget / float64(dx)))
+
+	d := &AxisDecision{
+		WidthDistortion:  p.estimateDistortion(img, width, 0),
+		HeightDistortion: p.estimateDistortion(img, 0, height),
+	}
+	if d.WidthDistortion <= d.HeightDistortion {
+		d.Axis, d.Width, d.Height = "width", width, dy
+		p.NewWidth, p.NewHeight = width, 0
+	} else {
+		d.Axis, d.Width, d.Height = "height", dx, height
+		p.NewWidth, p.NewHeight = 0, height
+	}
+	p.axisDecision = d
+
+	return nil
+}
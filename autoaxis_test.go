@@ -0,0 +1,36 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoAxis_ShouldChooseTheLessDistortedAxis(t *testing.T) {
+	assert := assert.New(t)
+
+	// The left half of the image is textured, while the right half is flat,
+	// so the vertical seams can be removed without any distortion.
+	img := texturedImage(60, 60)
+	fillGray(img, image.Rect(30, 0, 60, 60), 128)
+
+	proc := &Processor{
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		AutoAxis:       true,
+		TargetRatio:    0.8,
+	}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+
+	d := proc.AxisDecision()
+	assert.NotNil(d)
+	assert.Equal("width", d.Axis)
+	assert.Less(d.WidthDistortion, d.HeightDistortion)
+	assert.Equal(image.Rect(0, 0, 48, 60), res.Bounds())
+
+	proc.TargetRatio = 1.5
+	_, err = proc.Resize(img)
+	assert.Error(err)
+}
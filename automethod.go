@@ -0,0 +1,186 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/utils"
+)
+
+// The resizing methods compared by the AutoMethod option.
+const (
+	MethodCarve = "carve"
+	MethodScale = "scale"
+	MethodCrop  = "crop"
+)
+
+const (
+	// maxMethodAnalysisSize is the maximum size of the image used for comparing the resizing methods.
+	maxMethodAnalysisSize = 256
+	// methodMargin is the score by which a method has to outperform the simpler ones to be chosen.
+	// The methods are considered in the order of their cost and the risk of artifacts: scale, crop, carve.
+	methodMargin = 0.02
+)
+
+// MethodDecision holds the outcome of the automatic choice between carving, scaling and cropping.
+type MethodDecision struct {
+	// Method is the chosen method: "carve", "scale" or "crop".
+	Method string
+	// Width and Height is the size of the resized image.
+	Width, Height int
+	// CarveScore, ScaleScore and CropScore are the scores of the candidates in the [0, 1] range:
+	// the share of the image saliency retained, multiplied by the aspect ratio preservation.
+	CarveScore, ScaleScore, CropScore float64
+}
+
+// MethodDecision returns the decision of the last automatic method choice.
+// It returns nil if the AutoMethod option has not been used.
+func (p *Processor) MethodDecision() *MethodDecision {
+	return p.method
+}
+
+// chooseMethod produces the carved, the scaled and the cropped candidates at a reduced resolution,
+// scores them, then resizes the image at full resolution with the best scoring method.
+//
+// The scaled image keeps all the content, but its aspect ratio is altered, so it's scored by the
+// ratio of the two aspect ratios. The crop keeps the aspect ratio, while it loses the saliency
+// outside of the crop rectangle. The carved image loses the saliency of the removed seams.
+// The simpler methods are preferred on close scores, since they don't produce artifacts.
+func (p *Processor) chooseMethod(img *image.NRGBA) (image.Image, error) {
+	if p.Percentage || p.Square || p.AutoAxis {
+		return nil, errors.New("the auto option cannot be combined with the percentage, square or auto axis options")
+	}
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	width, height := p.NewWidth, p.NewHeight
+	if width == 0 {
+		width = dx
+	}
+	if height == 0 {
+		height = dy
+	}
+	if width < minImageSize || height < minImageSize {
+		return nil, errors.New("the auto option requires a new width or height")
+	}
+
+	d := &MethodDecision{Width: width, Height: height}
+	if err := p.scoreMethods(img, d); err != nil {
+		return nil, err
+	}
+	d.Method = MethodScale
+	best := d.ScaleScore
+	if d.CropScore > best+methodMargin {
+		d.Method, best = MethodCrop, d.CropScore
+	}
+	if d.CarveScore > best+methodMargin {
+		d.Method = MethodCarve
+	}
+	p.method = d
+
+	// The scaling and the cropping are not tracking the seams.
+	switch d.Method {
+	case MethodScale:
+		p.tracker = nil
+		return imaging.Resize(img, width, height, imaging.Lanczos), nil
+	case MethodCrop:
+		p.tracker = nil
+		crop, err := p.SuggestCrop(img, float64(width)/float64(height))
+		if err != nil {
+			return nil, err
+		}
+		return imaging.Resize(imaging.Crop(img, crop.Rect()), width, height, imaging.Lanczos), nil
+	}
+
+	p.choosing = true
+	defer func() { p.choosing = false }()
+	return p.resizeLocked(img)
+}
+
+// scoreMethods scores the candidates obtained over a downscaled copy of the image.
+func (p *Processor) scoreMethods(img *image.NRGBA, d *MethodDecision) error {
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	scale := math.Min(1, float64(maxMethodAnalysisSize)/float64(utils.Max(dx, dy)))
+	size := func(v int) int {
+		return utils.Max(minImageSize, int(math.Round(float64(v)*scale)))
+	}
+	small := img
+	if scale < 1 {
+		small = imaging.Resize(img, size(dx), size(dy), imaging.Box)
+	}
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+
+	// The saliency is measured on the same energy map as the one used by the seam carver.
+	backend := p.getBackend()
+	c := NewCarver(sw, sh)
+	energy := backend.sobel(c, small, float64(p.SobelThreshold))
+	if p.BlurRadius > 0 {
+		energy = backend.blur(c, energy, uint32(p.BlurRadius))
+	}
+	// The edge detector produces spurious energy along the image borders, which is ignored.
+	border := 3 + p.BlurRadius
+	saliency := func(x, y int) float64 {
+		if x < border || y < border || x >= sw-border || y >= sh-border {
+			return 0
+		}
+		return float64(energy.Pix[energy.PixOffset(x, y)])
+	}
+	var total float64
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			total += saliency(x, y)
+		}
+	}
+
+	srcRatio, dstRatio := float64(dx)/float64(dy), float64(d.Width)/float64(d.Height)
+	d.ScaleScore = math.Min(srcRatio, dstRatio) / math.Max(srcRatio, dstRatio)
+
+	crop, err := p.SuggestCrop(small, dstRatio)
+	if err != nil {
+		return err
+	}
+	d.CropScore = crop.Score
+
+	// The carving is simulated without the masks and the energy rules, defined at full resolution.
+	est := &Processor{
+		SobelThreshold: p.SobelThreshold,
+		BlurRadius:     p.BlurRadius,
+		ChannelWeights: p.ChannelWeights,
+		ForwardEnergy:  p.ForwardEnergy,
+		FaceDetect:     p.FaceDetect,
+		FaceDetector:   p.FaceDetector,
+		TrackCoords:    true,
+	}
+	if p.NewWidth > 0 {
+		est.NewWidth = size(d.Width)
+	}
+	if p.NewHeight > 0 {
+		est.NewHeight = size(d.Height)
+	}
+	xyMode := resizeXY
+	resizeXY = est.NewWidth != 0 && est.NewHeight != 0
+	defer func() { resizeXY = xyMode }()
+
+	est.GuiDebug = image.NewNRGBA(small.Bounds())
+	if _, err := est.resizeLocked(small); err != nil {
+		// The carving is not possible, for example it would deform the detected faces.
+		d.CarveScore = 0
+		return nil
+	}
+	d.CarveScore = 1
+	if total > 0 {
+		// Each removed pixel of the rescaled image covers multiple pixels of the analyzed image.
+		var removed float64
+		t := est.tracker
+		if t == nil {
+			return nil
+		}
+		for _, seam := range t.removed {
+			for _, pt := range seam {
+				removed += saliency(pt.X, pt.Y) * t.scaleX * t.scaleY
+			}
+		}
+		d.CarveScore = math.Max(0, 1-removed/total)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// subjectsImage returns a flat image with a noisy square subject at each of the provided columns.
+func subjectsImage(width, height int, subjects ...int) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 120, G: 160, B: 200, A: 255}}, image.Point{}, draw.Src)
+	for _, x0 := range subjects {
+		for y := height/2 - 20; y < height/2+20; y++ {
+			for x := x0; x < x0+40; x++ {
+				v := uint8(rnd.Intn(256))
+				img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestAutoMethod_ShouldChooseTheMethod(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		name   string
+		img    *image.NRGBA
+		width  int
+		method string
+	}{
+		{"centered subject", subjectsImage(200, 100, 80), 120, MethodCrop},
+		{"subjects at the sides", subjectsImage(200, 100, 10, 150), 120, MethodCarve},
+		{"small reduction", subjectsImage(200, 100, 0, 40, 80, 120, 160), 196, MethodScale},
+	} {
+		p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: tc.width, AutoMethod: true}
+		res, err := p.Resize(tc.img)
+		assert.NoError(err, tc.name)
+		assert.Equal(image.Rect(0, 0, tc.width, 100), res.Bounds(), tc.name)
+
+		d := p.MethodDecision()
+		if assert.NotNil(d, tc.name) {
+			assert.Equal(tc.method, d.Method, "%s: %+v", tc.name, d)
+			assert.Equal(tc.width, d.Width)
+			assert.Equal(100, d.Height)
+		}
+	}
+}
+
+func TestAutoMethod_ShouldRejectTheIncompatibleOptions(t *testing.T) {
+	p := &Processor{NewWidth: 50, NewHeight: 50, Square: true, AutoMethod: true}
+	_, err := p.Resize(subjectsImage(200, 100, 80))
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package caire
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// maxAutoTuneRetries is the maximum number of retries with adjusted parameters.
+	maxAutoTuneRetries = 2
+	// autoTuneThreshold is the distortion above which the result is considered poor.
+	autoTuneThreshold = 0.2
+	// autoTuneFaceMargin is the extra margin added around the detected faces on the last retry,
+	// relative to the size of the protected area.
+	autoTuneFaceMargin = 0.3
+)
+
+// TuneDecision holds the outcome of the automatic parameter tuning.
+type TuneDecision struct {
+	// Attempts is the number of resize operations performed, including the first one.
+	Attempts int
+	// ForwardEnergy and FaceMargin are the parameters of the configuration finally used.
+	ForwardEnergy bool
+	FaceMargin    float64
+	// Distortion is the distortion of the retained result (the mean energy of the removed pixels).
+	Distortion float64
+}
+
+// TuneDecision returns the outcome of the last automatic parameter tuning.
+// It returns nil if the AutoTune option has not been used.
+func (p *Processor) TuneDecision() *TuneDecision {
+	return p.tuneDecision
+}
+
+// autoTune resizes the image, then in case the distortion of the result is too high, it retries
+// using the forward energy, then additionally enlarging the area protected around the detected faces.
+// The result with the lowest distortion is retained.
+func (p *Processor) autoTune(img *image.NRGBA) (image.Image, error) {
+	configs := []TuneDecision{
+		{ForwardEnergy: p.ForwardEnergy},
+		{ForwardEnergy: true},
+		{ForwardEnergy: true, FaceMargin: autoTuneFaceMargin},
+	}[:maxAutoTuneRetries+1]
+
+	newWidth, newHeight, forwardEnergy := p.NewWidth, p.NewHeight, p.ForwardEnergy
+	p.tuning = true
+	defer func() {
+		p.NewWidth, p.NewHeight, p.ForwardEnergy = newWidth, newHeight, forwardEnergy
+		p.faceMargin, p.tuning = 0, false
+	}()
+
+	var (
+		best     image.Image
+		decision *TuneDecision
+		attempts int
+	)
+	for _, cfg := range configs {
+		// The percentage and the auto axis options are updating the new image size.
+		p.NewWidth, p.NewHeight = newWidth, newHeight
+		p.ForwardEnergy, p.faceMargin = cfg.ForwardEnergy, cfg.FaceMargin
+
+		res, err := p.resizeLocked(img)
+		if err != nil {
+			return nil, err
+		}
+		attempts++
+		cfg.Distortion = p.resultDistortion(img)
+
+		if decision == nil || cfg.Distortion < decision.Distortion {
+			best, decision = res, &cfg
+		}
+		if cfg.Distortion <= autoTuneThreshold {
+			break
+		}
+	}
+	decision.Attempts = attempts
+	p.tuneDecision = decision
+
+	return best, nil
+}
+
+// resultDistortion returns the distortion of the last resize operation, expressed as the mean
+// energy of the removed pixels in the [0, 1] range, measured on the energy map of the source image.
+func (p *Processor) resultDistortion(img *image.NRGBA) float64 {
+	if p.tracker == nil || len(p.tracker.removed) == 0 {
+		return 0
+	}
+	est := &Processor{
+		SobelThreshold: p.SobelThreshold,
+		BlurRadius:     p.BlurRadius,
+		ChannelWeights: p.ChannelWeights,
+		Tileable:       p.Tileable,
+	}
+	energy, err := NewCarver(img.Bounds().Dx(), img.Bounds().Dy()).ComputeSeams(est, img)
+	if err != nil {
+		return math.Inf(1)
+	}
+
+	var sum float64
+	var pixels int
+	for _, seam := range p.tracker.removed {
+		for _, pt := range seam {
+			sum += float64(energy.NRGBAAt(pt.X, pt.Y).R) / 0xff
+			pixels++
+		}
+	}
+	return sum / float64(pixels)
+}
+
+// grow enlarges the rectangle on each side with the margin relative to its size.
+func grow(rect image.Rectangle, margin float64) image.Rectangle {
+	dx := int(float64(rect.Dx()) * margin / 2)
+	dy := int(float64(rect.Dy()) * margin / 2)
+	return image.Rect(rect.Min.X-dx, rect.Min.Y-dy, rect.Max.X+dx, rect.Max.Y+dy)
+}
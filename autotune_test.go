@@ -0,0 +1,39 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoTune_ShouldRetryOnPoorResults(t *testing.T) {
+	assert := assert.New(t)
+
+	// A uniform image can be carved without any distortion.
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	fillGray(img, img.Bounds(), 128)
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 30, AutoTune: true}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 30, 30), res.Bounds())
+	d := proc.TuneDecision()
+	assert.Equal(1, d.Attempts)
+	assert.False(d.ForwardEnergy)
+	assert.LessOrEqual(d.Distortion, autoTuneThreshold)
+
+	// A highly textured image is distorted whatever seams are removed.
+	img = texturedImage(40, 30)
+	proc = &Processor{SobelThreshold: 2, NewWidth: 30, AutoTune: true}
+	res, err = proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 30, 30), res.Bounds())
+
+	d = proc.TuneDecision()
+	assert.Equal(maxAutoTuneRetries+1, d.Attempts)
+	assert.Greater(d.Distortion, autoTuneThreshold)
+	// The options are restored after tuning.
+	assert.Equal(30, proc.NewWidth)
+	assert.False(proc.ForwardEnergy)
+	assert.Zero(proc.faceMargin)
+}
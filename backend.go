@@ -0,0 +1,93 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/esimov/caire/filters"
+)
+
+// Backend defines the computation backend used for generating the energy map.
+type Backend int
+
+const (
+	// BackendCPU computes the energy map on the CPU. This is the default backend.
+	BackendCPU Backend = iota
+	// BackendOpenCL computes the energy map on the GPU through OpenCL.
+	// It is available only when the library is compiled with the opencl build tag.
+	BackendOpenCL
+)
+
+// String returns the name of the backend.
+func (b Backend) String() string {
+	switch b {
+	case BackendCPU:
+		return "cpu"
+	case BackendOpenCL:
+		return "opencl"
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// ParseBackend returns the backend type corresponding to the provided name.
+func ParseBackend(name string) (Backend, error) {
+	for _, b := range []Backend{BackendCPU, BackendOpenCL} {
+		if b.String() == name {
+			return b, nil
+		}
+	}
+	return BackendCPU, fmt.Errorf("unsupported backend: %s", name)
+}
+
+// energyBackend is the interface implemented by the computation backends. It defines
+// the operations needed for generating the energy map and the cumulative minimum energy.
+type energyBackend interface {
+	grayscale(c *Carver, img *image.NRGBA) []uint8
+	sobel(c *Carver, img *image.NRGBA, threshold float64) *image.NRGBA
+	blur(c *Carver, img *image.NRGBA, radius uint32) *image.NRGBA
+	accumulate(c *Carver)
+}
+
+// cpuBackend is the default computation backend.
+type cpuBackend struct{}
+
+func (cpuBackend) grayscale(c *Carver, img *image.NRGBA) []uint8 {
+	return c.rgbToGrayscale(img)
+}
+
+func (cpuBackend) sobel(c *Carver, img *image.NRGBA, threshold float64) *image.NRGBA {
+	return c.SobelDetector(img, threshold)
+}
+
+func (cpuBackend) blur(c *Carver, img *image.NRGBA, radius uint32) *image.NRGBA {
+	return c.StackBlur(img, radius)
+}
+
+func (cpuBackend) accumulate(c *Carver) {
+	c.accumulateEnergy()
+}
+
+// fixedBackend is the CPU backend computing the energy map with the integer arithmetic only.
+// It's used with the FixedPoint option, regardless of the selected backend.
+type fixedBackend struct {
+	cpuBackend
+}
+
+func (fixedBackend) grayscale(c *Carver, img *image.NRGBA) []uint8 {
+	return filters.LuminanceFixed(nil, img)
+}
+
+func (fixedBackend) sobel(c *Carver, img *image.NRGBA, threshold float64) *image.NRGBA {
+	return filters.SobelFixed(img, int(threshold), c.runner)
+}
+
+// getBackend returns the computation backend of the current operation.
+func (p *Processor) getBackend() energyBackend {
+	if p.FixedPoint {
+		return fixedBackend{}
+	}
+	if p.backend == nil {
+		return cpuBackend{}
+	}
+	return p.backend
+}
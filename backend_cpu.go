@@ -0,0 +1,19 @@
+//go:build !opencl
+
+package caire
+
+import "fmt"
+
+// openCLAvailable reports whether the OpenCL backend is included in the build.
+const openCLAvailable = false
+
+// newBackend returns the computation backend associated with the provided backend type.
+func newBackend(b Backend) (energyBackend, error) {
+	switch b {
+	case BackendCPU:
+		return cpuBackend{}, nil
+	case BackendOpenCL:
+		return nil, fmt.Errorf("the %v backend is not available: rebuild caire with the opencl build tag", b)
+	}
+	return nil, fmt.Errorf("unsupported backend: %v", b)
+}
@@ -0,0 +1,367 @@
+//go:build opencl
+
+package caire
+
+/*
+#cgo CFLAGS: -DCL_TARGET_OPENCL_VERSION=120
+#cgo !darwin LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+
+#include <stdlib.h>
+
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// kernelSource contains the OpenCL kernels used for computing the energy map.
+// The kernels are the exact counterparts of the CPU implementation,
+// except the blur kernel, which uses a separable tent filter
+// (the convolution approximated by the stackblur algorithm).
+const kernelSource = `
+__kernel void grayscale(__global const uchar *src, __global uchar *dst, const int n) {
+	int i = get_global_id(0);
+	if (i >= n) {
+		return;
+	}
+	float r = src[i*4+0] * 257.0f;
+	float g = src[i*4+1] * 257.0f;
+	float b = src[i*4+2] * 257.0f;
+	dst[i] = (uchar)((0.299f*r + 0.587f*g + 0.114f*b) / 256.0f);
+}
+
+__kernel void sobel(__global const uchar *src, __global uchar *dst, const int width, const int n, const float threshold) {
+	const int kx[9] = {-1, 0, 1, -2, 0, 2, -1, 0, 1};
+	const int ky[9] = {-1, -2, -1, 0, 0, 0, 1, 2, 1};
+
+	int i = get_global_id(0);
+	if (i >= n) {
+		return;
+	}
+	int sx = 0, sy = 0;
+	for (int y = 0; y < 3; y++) {
+		for (int x = 0; x < 3; x++) {
+			int idx = i + width*y + x;
+			if (idx < n) {
+				int r = src[idx*4];
+				sx += r * kx[y*3+x];
+				sy += r * ky[y*3+x];
+			}
+		}
+	}
+	float m = min(sqrt((float)(sx*sx + sy*sy)), 255.0f);
+	uchar v = m > threshold ? (uchar)m : 0;
+	vstore4((uchar4)(v, v, v, 255), i, dst);
+}
+
+__kernel void blur(__global const uchar *src, __global uchar *dst, const int width, const int height, const int radius, const int vertical) {
+	int i = get_global_id(0);
+	if (i >= width*height) {
+		return;
+	}
+	int x = i % width, y = i / width;
+	float4 sum = (float4)(0.0f);
+	float wsum = 0.0f;
+
+	for (int k = -radius; k <= radius; k++) {
+		int idx;
+		if (vertical) {
+			idx = clamp(y+k, 0, height-1)*width + x;
+		} else {
+			idx = y*width + clamp(x+k, 0, width-1);
+		}
+		float w = (float)(radius + 1 - abs(k));
+		sum += w * convert_float4(vload4(idx, src));
+		wsum += w;
+	}
+	vstore4(convert_uchar4_sat_rte(sum / wsum), i, dst);
+}
+
+__kernel void accumulate(__global float *energy, const int width, const int y) {
+	int x = get_global_id(0);
+	if (x >= width) {
+		return;
+	}
+	int prev = (y-1)*width;
+	float m = energy[prev+x];
+	if (x > 0) {
+		m = min(m, energy[prev+x-1]);
+	}
+	if (x < width-1) {
+		m = min(m, energy[prev+x+1]);
+	}
+	energy[y*width+x] += m;
+}
+`
+
+// openCLBackend computes the energy map on the GPU through OpenCL.
+type openCLBackend struct {
+	mu      sync.Mutex
+	ctx     C.cl_context
+	queue   C.cl_command_queue
+	program C.cl_program
+	kernels map[string]C.cl_kernel
+}
+
+var (
+	clOnce    sync.Once
+	clBackend *openCLBackend
+	clErr     error
+)
+
+// maxDeviceIndex is the largest buffer index addressable by the 32 bit integers of the kernels.
+// The energy of the larger images (ex. the stitched panoramas) is computed on the CPU instead.
+const maxDeviceIndex = math.MaxInt32
+
+// openCLAvailable reports whether the OpenCL backend is included in the build.
+const openCLAvailable = true
+
+// newBackend returns the computation backend associated with the provided backend type.
+func newBackend(b Backend) (energyBackend, error) {
+	switch b {
+	case BackendCPU:
+		return cpuBackend{}, nil
+	case BackendOpenCL:
+		// The OpenCL context is initialized only once and it's shared between the processors.
+		clOnce.Do(func() {
+			clBackend, clErr = initOpenCL()
+		})
+		if clErr != nil {
+			return nil, clErr
+		}
+		return clBackend, nil
+	}
+	return nil, fmt.Errorf("unsupported backend: %v", b)
+}
+
+// initOpenCL creates the OpenCL context on the first available GPU device
+// (or on any other device in case no GPU is found) and compiles the kernels.
+func initOpenCL() (*openCLBackend, error) {
+	var (
+		platform C.cl_platform_id
+		device   C.cl_device_id
+		status   C.cl_int
+	)
+
+	if status = C.clGetPlatformIDs(1, &platform, nil); status != C.CL_SUCCESS {
+		return nil, clError("clGetPlatformIDs", status)
+	}
+	if status = C.clGetDeviceIDs(platform, C.CL_DEVICE_TYPE_GPU, 1, &device, nil); status != C.CL_SUCCESS {
+		if status = C.clGetDeviceIDs(platform, C.CL_DEVICE_TYPE_ALL, 1, &device, nil); status != C.CL_SUCCESS {
+			return nil, clError("clGetDeviceIDs", status)
+		}
+	}
+
+	b := &openCLBackend{
+		kernels: make(map[string]C.cl_kernel),
+	}
+	b.ctx = C.clCreateContext(nil, 1, &device, nil, nil, &status)
+	if status != C.CL_SUCCESS {
+		return nil, clError("clCreateContext", status)
+	}
+	b.queue = C.clCreateCommandQueue(b.ctx, device, 0, &status)
+	if status != C.CL_SUCCESS {
+		return nil, clError("clCreateCommandQueue", status)
+	}
+
+	src := C.CString(kernelSource)
+	defer C.free(unsafe.Pointer(src))
+
+	b.program = C.clCreateProgramWithSource(b.ctx, 1, &src, nil, &status)
+	if status != C.CL_SUCCESS {
+		return nil, clError("clCreateProgramWithSource", status)
+	}
+	if status = C.clBuildProgram(b.program, 1, &device, nil, nil, nil); status != C.CL_SUCCESS {
+		return nil, clError("clBuildProgram", status)
+	}
+
+	for _, name := range []string{"grayscale", "sobel", "blur", "accumulate"} {
+		cname := C.CString(name)
+		kernel := C.clCreateKernel(b.program, cname, &status)
+		C.free(unsafe.Pointer(cname))
+
+		if status != C.CL_SUCCESS {
+			return nil, clError("clCreateKernel", status)
+		}
+		b.kernels[name] = kernel
+	}
+	return b, nil
+}
+
+func (b *openCLBackend) grayscale(c *Carver, img *image.NRGBA) []uint8 {
+	if len(img.Pix) > maxDeviceIndex {
+		return cpuBackend{}.grayscale(c, img)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := img.Bounds().Dx() * img.Bounds().Dy()
+	gray := make([]uint8, n)
+
+	src := b.newBuffer(C.CL_MEM_READ_ONLY|C.CL_MEM_COPY_HOST_PTR, len(img.Pix), unsafe.Pointer(&img.Pix[0]))
+	defer C.clReleaseMemObject(src)
+	dst := b.newBuffer(C.CL_MEM_WRITE_ONLY, n, nil)
+	defer C.clReleaseMemObject(dst)
+
+	k := b.kernels["grayscale"]
+	setKernelArg(k, 0, &src)
+	setKernelArg(k, 1, &dst)
+	setKernelArg(k, 2, cint(n))
+
+	b.run(k, n)
+	b.read(dst, unsafe.Pointer(&gray[0]), n)
+
+	return gray
+}
+
+func (b *openCLBackend) sobel(c *Carver, img *image.NRGBA, threshold float64) *image.NRGBA {
+	if len(img.Pix) > maxDeviceIndex {
+		return cpuBackend{}.sobel(c, img, threshold)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	n := width * height
+	res := image.NewNRGBA(img.Bounds())
+
+	src := b.newBuffer(C.CL_MEM_READ_ONLY|C.CL_MEM_COPY_HOST_PTR, len(img.Pix), unsafe.Pointer(&img.Pix[0]))
+	defer C.clReleaseMemObject(src)
+	dst := b.newBuffer(C.CL_MEM_WRITE_ONLY, len(res.Pix), nil)
+	defer C.clReleaseMemObject(dst)
+
+	k := b.kernels["sobel"]
+	setKernelArg(k, 0, &src)
+	setKernelArg(k, 1, &dst)
+	setKernelArg(k, 2, cint(width))
+	setKernelArg(k, 3, cint(n))
+	thr := C.cl_float(threshold)
+	setKernelArg(k, 4, &thr)
+
+	b.run(k, n)
+	b.read(dst, unsafe.Pointer(&res.Pix[0]), len(res.Pix))
+
+	return res
+}
+
+func (b *openCLBackend) blur(c *Carver, img *image.NRGBA, radius uint32) *image.NRGBA {
+	if len(img.Pix) > maxDeviceIndex {
+		return cpuBackend{}.blur(c, img, radius)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	res := image.NewNRGBA(img.Bounds())
+
+	src := b.newBuffer(C.CL_MEM_READ_WRITE|C.CL_MEM_COPY_HOST_PTR, len(img.Pix), unsafe.Pointer(&img.Pix[0]))
+	defer C.clReleaseMemObject(src)
+	tmp := b.newBuffer(C.CL_MEM_READ_WRITE, len(img.Pix), nil)
+	defer C.clReleaseMemObject(tmp)
+
+	// The tent filter is separable, so the blur is applied
+	// first horizontally then vertically over the intermediary result.
+	k := b.kernels["blur"]
+	for pass, buf := range [][2]C.cl_mem{{src, tmp}, {tmp, src}} {
+		setKernelArg(k, 0, &buf[0])
+		setKernelArg(k, 1, &buf[1])
+		setKernelArg(k, 2, cint(width))
+		setKernelArg(k, 3, cint(height))
+		setKernelArg(k, 4, cint(int(radius)))
+		setKernelArg(k, 5, cint(pass))
+
+		b.run(k, width*height)
+	}
+	b.read(src, unsafe.Pointer(&res.Pix[0]), len(res.Pix))
+
+	return res
+}
+
+func (b *openCLBackend) accumulate(c *Carver) {
+	if len(c.Points) > maxDeviceIndex {
+		cpuBackend{}.accumulate(c)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	energy := make([]float32, len(c.Points))
+	for i, v := range c.Points {
+		energy[i] = float32(v)
+	}
+	size := len(energy) * int(unsafe.Sizeof(energy[0]))
+
+	buf := b.newBuffer(C.CL_MEM_READ_WRITE|C.CL_MEM_COPY_HOST_PTR, size, unsafe.Pointer(&energy[0]))
+	defer C.clReleaseMemObject(buf)
+
+	// Each row depends on the previous one, so we are enqueueing a kernel for each row.
+	// The command queue is in-order, which means that the rows are processed sequentially.
+	k := b.kernels["accumulate"]
+	for y := 1; y < c.Height; y++ {
+		setKernelArg(k, 0, &buf)
+		setKernelArg(k, 1, cint(c.Width))
+		setKernelArg(k, 2, cint(y))
+
+		b.run(k, c.Width)
+	}
+	b.read(buf, unsafe.Pointer(&energy[0]), size)
+
+	for i, v := range energy {
+		c.Points[i] = float64(v)
+	}
+}
+
+// newBuffer allocates a new memory buffer on the device.
+func (b *openCLBackend) newBuffer(flags C.cl_mem_flags, size int, ptr unsafe.Pointer) C.cl_mem {
+	var status C.cl_int
+
+	mem := C.clCreateBuffer(b.ctx, flags, C.size_t(size), ptr, &status)
+	if status != C.CL_SUCCESS {
+		panic(clError("clCreateBuffer", status))
+	}
+	return mem
+}
+
+// run enqueues the kernel for execution over n work items.
+func (b *openCLBackend) run(k C.cl_kernel, n int) {
+	global := C.size_t(n)
+	if status := C.clEnqueueNDRangeKernel(b.queue, k, 1, nil, &global, nil, 0, nil, nil); status != C.CL_SUCCESS {
+		panic(clError("clEnqueueNDRangeKernel", status))
+	}
+}
+
+// read copies the content of the device buffer into the host memory. The call is blocking.
+func (b *openCLBackend) read(mem C.cl_mem, dst unsafe.Pointer, size int) {
+	if status := C.clEnqueueReadBuffer(b.queue, mem, C.CL_TRUE, 0, C.size_t(size), dst, 0, nil, nil); status != C.CL_SUCCESS {
+		panic(clError("clEnqueueReadBuffer", status))
+	}
+}
+
+// setKernelArg sets the value of the kernel argument at the provided index.
+func setKernelArg[T any](k C.cl_kernel, idx int, val *T) {
+	if status := C.clSetKernelArg(k, C.cl_uint(idx), C.size_t(unsafe.Sizeof(*val)), unsafe.Pointer(val)); status != C.CL_SUCCESS {
+		panic(clError("clSetKernelArg", status))
+	}
+}
+
+// cint converts a Go integer to an OpenCL integer kernel argument.
+func cint(v int) *C.cl_int {
+	i := C.cl_int(v)
+	return &i
+}
+
+// clError returns the error associated with a failed OpenCL call.
+func clError(fn string, status C.cl_int) error {
+	return fmt.Errorf("opencl: %s failed with error code %d", fn, int(status))
+}
@@ -0,0 +1,127 @@
+package caire
+
+import (
+	"image"
+	"math"
+
+	"github.com/esimov/caire/filters"
+)
+
+const (
+	// minBackgroundFrames is the number of the buffered frames from which the statistics are used.
+	minBackgroundFrames = 3
+	// backgroundBias is the amount the bias of the static background is lowered below the
+	// neutral value, which attracts the seams without ignoring the edges of the background.
+	backgroundBias = 48
+)
+
+// backgroundModel detects the static background of a stream of frames from the statistics
+// of the recent frames. A small ring buffer holds the luminance of the last frames, while the
+// sums of the values and of their squares are updated incrementally, providing the temporal
+// variance of each pixel. The pixels differing from their temporal median (the background
+// estimate) or fluctuating over the buffered frames belong to the moving subjects.
+type backgroundModel struct {
+	size   image.Point
+	frames [][]uint8
+	// next is the index of the buffer slot receiving the next frame.
+	next int
+	// count is the number of the buffered frames.
+	count   int
+	sum, sq []float64
+	values  []uint8
+}
+
+// newBackgroundModel returns a background model buffering the provided number of frames.
+func newBackgroundModel(frames int) *backgroundModel {
+	return &backgroundModel{frames: make([][]uint8, max(frames, minBackgroundFrames))}
+}
+
+// reset drops the buffered frames, for example on the cuts of a video.
+func (bg *backgroundModel) reset() {
+	bg.count, bg.next = 0, 0
+	bg.size = image.Point{}
+}
+
+// add buffers the luminance of the frame, evicting the oldest frame of the full buffer.
+// The buffer is reset when the size of the frames changes.
+func (bg *backgroundModel) add(img *image.NRGBA) {
+	b := img.Bounds()
+	if b.Size() != bg.size {
+		bg.reset()
+		bg.size = b.Size()
+		n := b.Dx() * b.Dy()
+		bg.sum, bg.sq = make([]float64, n), make([]float64, n)
+		for i := range bg.frames {
+			bg.frames[i] = make([]uint8, n)
+		}
+	}
+
+	lum := bg.frames[bg.next]
+	full := bg.count == len(bg.frames)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			i, k := img.PixOffset(b.Min.X+x, b.Min.Y+y), y*b.Dx()+x
+			if full {
+				old := float64(lum[k])
+				bg.sum[k] -= old
+				bg.sq[k] -= old * old
+			}
+			v := filters.Luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+			lum[k] = v
+			bg.sum[k] += float64(v)
+			bg.sq[k] += float64(v) * float64(v)
+		}
+	}
+	bg.next = (bg.next + 1) % len(bg.frames)
+	bg.count = min(bg.count+1, len(bg.frames))
+}
+
+// median returns the temporal median of the pixel over the buffered frames.
+func (bg *backgroundModel) median(k int) uint8 {
+	values := bg.values[:0]
+	for i := 0; i < bg.count; i++ {
+		v := bg.frames[i][k]
+		// Insertion sort, since the buffer holds a few frames only.
+		j := len(values)
+		values = append(values, v)
+		for ; j > 0 && values[j-1] > v; j-- {
+			values[j] = values[j-1]
+		}
+		values[j] = v
+	}
+	bg.values = values
+	return values[len(values)/2]
+}
+
+// biasMap returns the energy bias map of the last buffered frame: the static background is
+// favored by the seams, while the moving subjects are protected. It returns nil until enough
+// frames are buffered for the statistics to be reliable.
+func (bg *backgroundModel) biasMap() *image.NRGBA {
+	if bg.count < minBackgroundFrames {
+		return nil
+	}
+	w, h := bg.size.X, bg.size.Y
+	last := bg.frames[(bg.next+len(bg.frames)-1)%len(bg.frames)]
+	n := float64(bg.count)
+
+	motion := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for k := range last {
+		mean := bg.sum[k] / n
+		dev := math.Sqrt(math.Max(0, bg.sq[k]/n-mean*mean))
+		diff := math.Abs(float64(last[k]) - float64(bg.median(k)))
+
+		// Map the deviation over the noise level to the [0, 255] range, like the motion of the reference frame.
+		v := uint8(math.Round(math.Max(0, math.Min(1, (math.Max(diff, dev)-motionNoise)/(motionFull-motionNoise))) * 0xff))
+		motion.Pix[k*4], motion.Pix[k*4+1], motion.Pix[k*4+2], motion.Pix[k*4+3] = v, v, v, 0xff
+	}
+	motion = NewCarver(w, h).StackBlur(motion, motionBlur)
+
+	bias := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(bias.Pix); i += 4 {
+		// The blurred motion is stretched, so the interior of the moving subjects is fully protected.
+		m := math.Min(1, 2*float64(motion.Pix[i])/0xff)
+		v := uint8(math.Round(biasNeutral - backgroundBias + m*(0xff-biasNeutral+backgroundBias)))
+		bias.Pix[i], bias.Pix[i+1], bias.Pix[i+2], bias.Pix[i+3] = v, v, v, 0xff
+	}
+	return bias
+}
@@ -0,0 +1,83 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// backgroundFrame returns a frame of a fixed camera: a flat square moving over a static textured background.
+func backgroundFrame(width, height, pos int) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(90 + rnd.Intn(40))
+			c := color.NRGBA{R: v, G: v, B: v, A: 255}
+			if x >= pos && x < pos+24 && y >= 20 && y < 70 {
+				c = color.NRGBA{R: 240, G: 220, B: 40, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// squareWidth returns the number of the square pixels in the row of the frame.
+func squareWidth(img *image.NRGBA, y int) int {
+	var n int
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		if c := img.NRGBAAt(x, y); c.R > 200 && c.B < 100 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBackground_ShouldDetectTheMovingSubjects(t *testing.T) {
+	assert := assert.New(t)
+
+	bg := newBackgroundModel(15)
+	bg.add(backgroundFrame(160, 90, 10))
+	bg.add(backgroundFrame(160, 90, 14))
+	assert.Nil(bg.biasMap())
+
+	for pos := 18; pos < 90; pos += 4 {
+		bg.add(backgroundFrame(160, 90, pos))
+	}
+	assert.Equal(15, bg.count)
+	bias := bg.biasMap()
+	if assert.NotNil(bias) {
+		// The square is protected, while the background is favored by the seams.
+		assert.Equal(uint8(0xff), bias.NRGBAAt(98, 45).R)
+		assert.Equal(uint8(biasNeutral-backgroundBias), bias.NRGBAAt(140, 45).R)
+		assert.Equal(uint8(biasNeutral-backgroundBias), bias.NRGBAAt(10, 80).R)
+	}
+
+	// The statistics are dropped when the size changes.
+	bg.add(backgroundFrame(120, 90, 40))
+	assert.Equal(1, bg.count)
+	assert.Nil(bg.biasMap())
+}
+
+func TestBackground_ShouldKeepTheMovingSubjects(t *testing.T) {
+	assert := assert.New(t)
+
+	carve := func(frames int) *image.NRGBA {
+		lc := NewLiveCarver(130, 90, Processor{SobelThreshold: 2})
+		lc.BackgroundFrames = frames
+		var res *image.NRGBA
+		for pos := 10; pos < 90; pos += 4 {
+			var err error
+			res, err = lc.Carve(backgroundFrame(160, 90, pos))
+			assert.NoError(err)
+		}
+		return res
+	}
+	// The flat square has a lower energy than the textured background, so it's carved by default.
+	assert.Less(squareWidth(carve(0), 45), 24)
+	assert.Equal(24, squareWidth(carve(15), 45))
+}
@@ -0,0 +1,45 @@
+package caire
+
+import "runtime"
+
+// BuildCapabilities describes the features enabled in the current build of the library,
+// so the orchestration layers can route the jobs to the appropriately built binaries.
+type BuildCapabilities struct {
+	// Backends lists the computation backends compiled in. The OpenCL backend still
+	// requires an OpenCL device at runtime.
+	Backends []string `json:"backends"`
+	// GPU reports whether a GPU backend is compiled in.
+	GPU bool `json:"gpu"`
+	// GUI reports whether the preview window is available (not available in the headless builds).
+	GUI bool `json:"gui"`
+	// FaceDetection reports whether the face detection is available.
+	FaceDetection bool `json:"face_detection"`
+	// Formats lists the supported image formats, used both for decoding and encoding.
+	Formats []string `json:"formats"`
+	// WebP and HEIC report the support of these formats, which require external codecs.
+	WebP bool `json:"webp"`
+	HEIC bool `json:"heic"`
+	// GoVersion, OS and Arch identify the toolchain and the platform of the build.
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// Capabilities reports the features enabled in the current build.
+func Capabilities() BuildCapabilities {
+	backends := []string{BackendCPU.String()}
+	if openCLAvailable {
+		backends = append(backends, BackendOpenCL.String())
+	}
+	return BuildCapabilities{
+		Backends: backends,
+		GPU:      openCLAvailable,
+		GUI:      previewAvailable,
+		// The face detection is implemented in pure Go, using the embedded cascade.
+		FaceDetection: true,
+		Formats:       []string{FormatJPEG, FormatPNG, FormatBMP, FormatGIF},
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+	}
+}
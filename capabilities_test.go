@@ -0,0 +1,25 @@
+package caire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities_ShouldReportTheBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	caps := Capabilities()
+	assert.Contains(caps.Backends, "cpu")
+	assert.Equal(openCLAvailable, caps.GPU)
+	assert.Equal(previewAvailable, caps.GUI)
+	assert.True(caps.FaceDetection)
+	assert.False(caps.WebP)
+	assert.False(caps.HEIC)
+
+	for _, format := range caps.Formats {
+		f, err := ParseFormat(format)
+		assert.NoError(err)
+		assert.Equal(format, f)
+	}
+}
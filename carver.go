@@ -7,7 +7,7 @@ import (
 	"image/draw"
 	"math"
 
-	"github.com/esimov/caire/utils"
+	"github.com/esimov/caire/filters"
 	pigo "github.com/esimov/pigo/core"
 )
 
@@ -30,6 +30,21 @@ type Carver struct {
 	Seams  []Seam
 	Width  int
 	Height int
+
+	// HistorySize is the number of the last seam removals retained by RemoveSeam, which can be
+	// reverted with Undo, for example by the interactive tools scrubbing through the carving.
+	// If zero, the removals are not retained.
+	HistorySize int
+
+	// parents holds the direction of the parent pixels, recorded by the forward energy accumulation.
+	parents []int8
+	// workers is the number of goroutines used for computing the energy map.
+	workers int
+	// fixed reports whether the energy is on the integer scale of the FixedPoint option.
+	fixed bool
+	// undo holds the retained seam removals, the most recent being the last one,
+	// while redo holds the removals reverted by Undo.
+	undo, redo []removedSeam
 }
 
 // Seam struct contains the seam pixel coordinates.
@@ -41,10 +56,9 @@ type Seam struct {
 // NewCarver returns an initialized Carver structure.
 func NewCarver(width, height int) *Carver {
 	return &Carver{
-		make([]float64, width*height),
-		nil,
-		width,
-		height,
+		Points: make([]float64, width*height),
+		Width:  width,
+		Height: height,
 	}
 }
 
@@ -73,45 +87,48 @@ func (c *Carver) ComputeSeams(p *Processor, img *image.NRGBA) (*image.NRGBA, err
 	p.GuiDebug = image.NewNRGBA(img.Bounds())
 
 	width, height := img.Bounds().Dx(), img.Bounds().Dy()
-	sobel = c.SobelDetector(img, float64(p.SobelThreshold))
+	if width < minImageSize || height < minImageSize {
+		return nil, fmt.Errorf("the image is too small to be carved: %dx%d", width, height)
+	}
+	// The carver can be reused for the successive steps, in which case it's fitted to the image.
+	if width != c.Width || height != c.Height {
+		c.Width, c.Height = width, height
+		c.Points = make([]float64, width*height)
+	}
+	defer p.startEnergy()()
+
+	backend := p.getBackend()
+	sobelKey, energyKey := p.energyCacheKeys()
+
+	var cached bool
+	if sobel, cached = p.loadEnergy(sobelKey, width, height); !cached {
+		// The tileable mode and the per-channel gradients are computed only on CPU.
+		switch {
+		case p.Tileable:
+			sobel = c.tileableSobel(img, float64(p.SobelThreshold), p.ChannelWeights, p.FixedPoint)
+		case p.ChannelWeights != [3]float64{} && p.FixedPoint:
+			sobel = filters.WeightedSobelFixed(img, p.SobelThreshold, p.ChannelWeights, c.runner)
+		case p.ChannelWeights != [3]float64{}:
+			sobel = c.WeightedSobelDetector(img, float64(p.SobelThreshold), p.ChannelWeights)
+		default:
+			sobel = backend.sobel(c, img, float64(p.SobelThreshold))
+		}
+		p.storeEnergy(sobelKey, sobel)
+	}
 
 	dets := []pigo.Detection{}
 
 	if p.FaceDetector != nil && p.FaceDetect && detAttempts < maxFaceDetAttempts {
-		var ratio float64
-
-		if width < height {
-			ratio = float64(width) / float64(height)
-		} else {
-			ratio = float64(height) / float64(width)
-		}
-		minSize := float64(utils.Min(width, height)) * ratio / 3
-
-		// Transform the image to pixel array.
-		pixels := c.rgbToGrayscale(img)
-
-		cParams := pigo.CascadeParams{
-			MinSize:     int(minSize),
-			MaxSize:     utils.Min(width, height),
-			ShiftFactor: 0.1,
-			ScaleFactor: 1.1,
-
-			ImageParams: pigo.ImageParams{
-				Pixels: pixels,
-				Rows:   height,
-				Cols:   width,
-				Dim:    width,
-			},
-		}
 		if p.vRes {
 			p.FaceAngle = 0.2
 		}
-		// Run the classifier over the obtained leaf nodes and return the detection results.
-		// The result contains quadruplets representing the row, column, scale and detection score.
-		dets = p.FaceDetector.RunCascade(cParams, p.FaceAngle)
 
-		// Calculate the intersection over union (IoU) of two clusters.
-		dets = p.FaceDetector.ClusterDetections(dets, 0.1)
+		// Reuse the detection results of the previous runs over the same image, if they are cached.
+		key := p.faceCache.key(img, p.FaceAngle)
+		if dets, cached = p.faceCache.lookup(key); !cached {
+			dets = p.detectFaces(c, img)
+			p.faceCache.store(key, dets)
+		}
 
 		if len(dets) == 0 {
 			// Retry detecting faces for a certain amount of time.
@@ -172,6 +189,13 @@ func (c *Carver) ComputeSeams(p *Processor, img *image.NRGBA) (*image.NRGBA, err
 		}
 	}
 
+	// Boost or reduce the energy of the regions defined by the energy rules.
+	p.applyEnergyBias(sobel)
+	// Protect the bands already crossed by the maximum number of seams.
+	p.applySeamBands(sobel)
+	// Protect the color blocks which would lose more than the allowed share of their area.
+	p.applyColorBlocks(sobel)
+
 	// Iterate over the detected faces and fill out the rectangles with white.
 	// We need to trick the sobel detector to consider them as important image parts.
 	for _, face := range dets {
@@ -181,14 +205,8 @@ func (c *Carver) ComputeSeams(p *Processor, img *image.NRGBA) (*image.NRGBA, err
 				"cannot resize the image to the specified dimension without face deformation.\n",
 				"\tRemove the face detection option in case you still wish to resize the image.")
 		}
-		if face.Q > 5.0 {
-			scale := int(float64(face.Scale) / 1.7)
-			rect := image.Rect(
-				face.Col-scale,
-				face.Row-scale,
-				face.Col+scale,
-				face.Row+scale,
-			)
+		if face.Q > minFaceQuality {
+			rect := grow(faceRect(face), p.faceMargin)
 			draw.Draw(sobel, rect, &image.Uniform{color.White}, image.Point{}, draw.Src)
 			draw.Draw(p.GuiDebug, rect, &image.Uniform{color.White}, image.Point{}, draw.Src)
 		}
@@ -205,24 +223,64 @@ func (c *Carver) ComputeSeams(p *Processor, img *image.NRGBA) (*image.NRGBA, err
 		}
 	}
 
+	// The blurred energy map can be reused only if the sobel image has not been altered.
+	altered := (len(p.MaskPath) > 0 && p.Mask != nil) || (len(p.RMaskPath) > 0 && p.RMask != nil) ||
+		p.biasMap != nil || p.bands != nil || p.blocks != nil || len(dets) > 0 || len(energySeams) > 0
+	if altered {
+		energyKey = ""
+	}
+
 	if p.BlurRadius > 0 {
-		srcImg = c.StackBlur(sobel, uint32(p.BlurRadius))
+		if srcImg, cached = p.loadEnergy(energyKey, width, height); !cached {
+			if p.Tileable {
+				srcImg = c.tileableBlur(backend, sobel, uint32(p.BlurRadius))
+			} else {
+				srcImg = backend.blur(c, sobel, uint32(p.BlurRadius))
+			}
+			p.storeEnergy(energyKey, srcImg)
+		}
 	} else {
 		srcImg = sobel
 	}
 
-	for x := 0; x < c.Width; x++ {
-		for y := 0; y < c.Height; y++ {
-			r, _, _, a := srcImg.At(x, y).RGBA()
-			c.set(x, y, float64(r)/float64(a))
+	// With the fixed point arithmetic the energy is kept on the integer [0, 255] scale,
+	// so the cumulative energies are exact integers, which are added in any order.
+	c.fixed = p.FixedPoint
+	c.parallel(c.Height, minParallelChunk/max(1, c.Width), func(start, end int) {
+		for y := start; y < end; y++ {
+			for x := 0; x < c.Width; x++ {
+				if c.fixed {
+					c.set(x, y, float64(srcImg.Pix[srcImg.PixOffset(x, y)]))
+					continue
+				}
+				r, _, _, a := srcImg.At(x, y).RGBA()
+				c.set(x, y, float64(r)/float64(a))
+			}
 		}
+	})
+
+	switch {
+	case p.Tileable:
+		c.accumulateEnergyToroidal()
+	case p.useForwardEnergy():
+		c.accumulateForwardEnergy(backend.grayscale(c, img))
+	default:
+		backend.accumulate(c)
+	}
+
+	// The watchdog stops the seam search on invalid energies, which would produce meaningless seams.
+	if err := c.checkEnergy(); err != nil {
+		return nil, err
 	}
+	return srcImg, nil
+}
 
+// accumulateEnergy traverses the image from top to bottom and computes the minimum energy level.
+// For each pixel in a row we compute the energy of the current pixel
+// plus the energy of one of the three possible pixels above it.
+func (c *Carver) accumulateEnergy() {
 	var left, middle, right float64
 
-	// Traverse the image from top to bottom and compute the minimum energy level.
-	// For each pixel in a row we compute the energy of the current pixel
-	// plus the energy of one of the three possible pixels above it.
 	for y := 1; y < c.Height; y++ {
 		for x := 1; x < c.Width-1; x++ {
 			left = c.get(x-1, y-1)
@@ -238,17 +296,20 @@ func (c *Carver) ComputeSeams(p *Processor, img *image.NRGBA) (*image.NRGBA, err
 		right := c.get(0, y) + math.Min(c.get(c.Width-1, y-1), c.get(c.Width-2, y-1))
 		c.set(c.Width-1, y, right)
 	}
-	return srcImg, nil
 }
 
 // FindLowestEnergySeams find the lowest vertical energy seam.
 func (c *Carver) FindLowestEnergySeams(p *Processor) []Seam {
+	return c.appendLowestEnergySeam(p, make([]Seam, 0))
+}
+
+// appendLowestEnergySeam is like FindLowestEnergySeams, but it appends the seam to the provided slice.
+func (c *Carver) appendLowestEnergySeam(p *Processor, seams []Seam) []Seam {
 	// Find the lowest cost seam from the energy matrix starting from the last row.
 	var (
 		min = math.MaxFloat64
 		px  int
 	)
-	seams := make([]Seam, 0)
 
 	// Find the pixel on the last row with the minimum cumulative energy and use this as the starting pixel
 	for x := 0; x < c.Width; x++ {
@@ -259,6 +320,11 @@ func (c *Carver) FindLowestEnergySeams(p *Processor) []Seam {
 		}
 	}
 
+	px = p.jitterStart(c, px)
+	if c.parents != nil {
+		return c.traceForwardSeam(px)
+	}
+
 	seams = append(seams, Seam{X: px, Y: c.Height - 1})
 	var left, middle, right float64
 
@@ -266,6 +332,20 @@ func (c *Carver) FindLowestEnergySeams(p *Processor) []Seam {
 	// and add that one which has the lowest cumulative energy.
 	for y := c.Height - 2; y >= 0; y-- {
 		middle = c.get(px, y)
+		// In tileable mode the leftmost and rightmost pixels are neighbours.
+		if p.Tileable && c.Width > 2 {
+			lx, rx := (px-1+c.Width)%c.Width, (px+1)%c.Width
+			left, right = c.get(lx, y), c.get(rx, y)
+			min := math.Min(math.Min(left, middle), right)
+
+			if min == left {
+				px = lx
+			} else if min == right {
+				px = rx
+			}
+			seams = append(seams, Seam{X: px, Y: y})
+			continue
+		}
 		// Leftmost seam, no child to the left
 		if px == 0 {
 			right = c.get(px+1, y)
@@ -303,7 +383,11 @@ func (c *Carver) FindLowestEnergySeams(p *Processor) []Seam {
 }
 
 // RemoveSeam remove the least important columns based on the stored energy (seams) level.
+// The removal is retained for Undo, if the carver has a history size.
 func (c *Carver) RemoveSeam(img *image.NRGBA, seams []Seam, debug bool) *image.NRGBA {
+	if c.HistorySize > 0 {
+		c.retain(img, seams)
+	}
 	bounds := img.Bounds()
 	// Reduce the image width with one pixel on each iteration.
 	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()-1, bounds.Dy()))
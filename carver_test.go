@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -431,3 +432,72 @@ func findNonZeroValue(points []float64) bool {
 	}
 	return found
 }
+
+func TestCarver_ShouldParseBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	b, err := ParseBackend("cpu")
+	assert.NoError(err)
+	assert.Equal(BackendCPU, b)
+
+	_, err = ParseBackend("cuda")
+	assert.Error(err)
+}
+
+func TestCarver_WeightedSobelShouldMatchChannelGradients(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	for x := 0; x < imgWidth; x++ {
+		for y := 0; y < imgHeight; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 25), G: uint8(y * 25), A: 255})
+		}
+	}
+	c := NewCarver(imgWidth, imgHeight)
+
+	// Weighting only the red channel should be equivalent with the default sobel detector.
+	assert.Equal(c.SobelDetector(img, 4).Pix, c.WeightedSobelDetector(img, 4, [3]float64{1, 0, 0}).Pix)
+
+	// The blue channel is uniform, so no edges should be detected.
+	blue := c.WeightedSobelDetector(img, 4, [3]float64{0, 0, 1})
+	for i := 0; i < len(blue.Pix); i += 4 {
+		assert.Zero(blue.Pix[i])
+	}
+}
+
+func TestCarver_ShouldComputeTheFixedPointEnergy(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*73 + i*i*31)
+	}
+	for _, forward := range []bool{false, true} {
+		p := &Processor{SobelThreshold: 2, BlurRadius: 2, ForwardEnergy: forward, FixedPoint: true}
+		assert.IsType(fixedBackend{}, p.getBackend())
+
+		// The cumulative energies are exact integers.
+		c := NewCarver(60, 40)
+		_, err := c.ComputeSeams(p, img)
+		assert.NoError(err)
+		for _, v := range c.Points {
+			assert.Equal(math.Trunc(v), v)
+		}
+		assert.Len(c.FindLowestEnergySeams(p), 40)
+	}
+
+	// The full and the coarse-to-fine seam searches produce the same results on each run.
+	for _, fast := range []bool{false, true} {
+		var prev []uint8
+		for i := 0; i < 2; i++ {
+			p := &Processor{NewWidth: 45, SobelThreshold: 2, BlurRadius: 2, FastMode: fast, FixedPoint: true}
+			res, err := p.Resize(img)
+			assert.NoError(err)
+			assert.Equal(image.Rect(0, 0, 45, 40), res.Bounds())
+			if prev != nil {
+				assert.Equal(prev, res.(*image.NRGBA).Pix)
+			}
+			prev = res.(*image.NRGBA).Pix
+		}
+	}
+}
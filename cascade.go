@@ -0,0 +1,42 @@
+package caire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/esimov/caire/assets"
+	pigo "github.com/esimov/pigo/core"
+)
+
+// DefaultAssets is the asset loader used by the processors not having their own loader.
+// The face detection cascade is embedded, the other models are loaded on demand.
+var DefaultAssets = assets.NewLoader()
+
+func init() {
+	DefaultAssets.Embed(assets.FaceFinder, cascadeFile)
+}
+
+// unpackCascade resolves the face detection cascade through the asset loader and unpacks it.
+// The embedded cascade is used if the loader of the processor doesn't provide one.
+// The same cascade is unpacked only once.
+func (p *Processor) unpackCascade() (*pigo.Pigo, error) {
+	loader := p.Assets
+	if loader == nil {
+		loader = DefaultAssets
+	}
+	data, err := loader.Load(p.getContext(), assets.FaceFinder)
+	if errors.Is(err, assets.ErrNotFound) {
+		data, err = cascadeFile, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cachedCascade(data, func(data []byte) (*pigo.Pigo, error) {
+		det, err := pigo.NewPigo().Unpack(data)
+		if err != nil {
+			return nil, fmt.Errorf("error unpacking the cascade file: %v", err)
+		}
+		return det, nil
+	})
+}
@@ -0,0 +1,55 @@
+package caire
+
+import "slices"
+
+// Clone returns an independent copy of the processor, which can be configured and used from
+// another goroutine. A processor is not safe for concurrent use, since it keeps the state of the
+// current operation, so the services handling the requests concurrently should clone a template
+// processor for each request instead of sharing it. The options are copied, while the images
+// (the masks and the reference frame), the face detector and the services (assets, cache, tracer,
+// spinner) are shared between the copies, since they are only read during the processing.
+//
+// The seam carver relies on package level state, which is why the carving operations of the
+// different processors are serialized, while the decoding of the images is running concurrently.
+func (p *Processor) Clone() *Processor {
+	c := *p
+	c.EnergyRules = slices.Clone(p.EnergyRules)
+	c.Regions = slices.Clone(p.Regions)
+	if p.Grid != nil {
+		grid := *p.Grid
+		grid.X, grid.Y = slices.Clone(p.Grid.X), slices.Clone(p.Grid.Y)
+		c.Grid = &grid
+	}
+	if p.Watermark != nil {
+		wm := *p.Watermark
+		c.Watermark = &wm
+	}
+	if p.Jitter != nil {
+		jitter := *p.Jitter
+		c.Jitter = &jitter
+	}
+	// The copies are recording their own snapshots.
+	c.DebugSnapshot = p.DebugSnapshot.clone()
+
+	// The state of the previous operations is not carried over.
+	c.vRes, c.palette, c.backend, c.tracker = false, nil, nil, nil
+	c.maskWatch, c.rmaskWatch, c.removedSeams, c.biasMap, c.bands = nil, nil, nil, nil, nil
+	c.energyLog, c.progress = nil, nil
+	c.axisDecision, c.tuneDecision, c.tuning, c.faceMargin = nil, nil, false, 0
+	c.method, c.choosing = nil, false
+	c.onStep, c.framesErr, c.warnings = nil, nil, nil
+	c.faceCache, c.energyHash, c.jitterRand, c.partial = nil, "", nil, nil
+	c.deadline, c.ctx, c.traceCtx, c.cancel = nil, nil, nil, nil
+	c.subject, c.noop, c.blocks, c.inPlace, c.energyTime = nil, false, nil, nil, nil
+
+	return &c
+}
+
+// lockCarving serializes the carving operations, since the seam carver relies on package level
+// state. It returns the unlock function. The lock is not reentrant: the operations running while
+// it's held (ex. the cells of a grid, or the retries of the auto tuning) call resizeLocked instead
+// of Resize, so the processors shared between goroutines can't slip through the lock.
+func lockCarving() func() {
+	processMu.Lock()
+	return processMu.Unlock
+}
@@ -0,0 +1,120 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone_ShouldCopyTheOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Processor{
+		NewWidth: 20,
+		Regions:  []Region{{Name: "logo", Rect: image.Rect(0, 0, 5, 5), Priority: PriorityMustKeep}},
+		Grid:     &Grid{X: []int{10}},
+		Jitter:   &Jitter{Seed: 1, Intensity: 0.5},
+	}
+	p.biasMap = image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	p.warnings = []Warning{{Code: WarnUniform}}
+
+	c := p.Clone()
+	assert.Equal(p.NewWidth, c.NewWidth)
+	assert.Equal(p.Regions, c.Regions)
+	assert.Equal(p.Grid, c.Grid)
+	assert.Nil(c.biasMap)
+	assert.Empty(c.Warnings())
+
+	c.NewWidth = 10
+	c.Regions[0].Priority = PriorityMustRemove
+	c.Grid.X[0] = 5
+	c.Jitter.Seed = 2
+	assert.Equal(20, p.NewWidth)
+	assert.Equal(PriorityMustKeep, p.Regions[0].Priority)
+	assert.Equal(10, p.Grid.X[0])
+	assert.Equal(int64(1), p.Jitter.Seed)
+}
+
+func TestClone_ShouldProcessConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	tmpl := &Processor{SobelThreshold: 2, BlurRadius: 1, OutputFormat: FormatPNG}
+
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, 6)
+	errs := make([]error, len(outputs))
+	for i := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := tmpl.Clone()
+			// The enlargement uses the shared seams table.
+			p.NewWidth = 34 + 2*i
+			errs[i] = p.Process(bytes.NewReader(src.Bytes()), &outputs[i])
+		}()
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		assert.NoError(errs[i])
+		out, err := png.Decode(bytes.NewReader(outputs[i].Bytes()))
+		assert.NoError(err)
+		assert.Equal(34+2*i, out.Bounds().Dx())
+
+		// The result is the same as the sequential processing.
+		var want bytes.Buffer
+		p := tmpl.Clone()
+		p.NewWidth = 34 + 2*i
+		assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &want))
+		assert.Equal(want.Bytes(), outputs[i].Bytes())
+	}
+}
+
+func TestClone_ShouldSerializeTheSharedProcessor(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 36}
+
+	// The first resizing is paused at its first seam, while holding the carving lock.
+	paused, resume := make(chan struct{}), make(chan struct{})
+	var steps atomic.Int32
+	p.onStep = func(*image.NRGBA, SeamInfo) error {
+		if steps.Add(1) == 1 {
+			close(paused)
+			<-resume
+		}
+		return nil
+	}
+	first := make(chan error)
+	go func() {
+		_, err := p.Resize(img)
+		first <- err
+	}()
+	<-paused
+
+	// The second resizing sharing the processor waits for the lock.
+	second := make(chan error)
+	go func() {
+		_, err := p.Resize(img)
+		second <- err
+	}()
+	select {
+	case <-second:
+		assert.Fail("the shared processor was not serialized")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(resume)
+	assert.NoError(<-first)
+	assert.NoError(<-second)
+}
@@ -0,0 +1,190 @@
+//go:build linux && !headless
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The V4L2 constants used for the memory mapped streaming capture.
+const (
+	v4l2BufTypeVideoCapture = 1
+	v4l2MemoryMmap          = 1
+	v4l2FieldAny            = 0
+	// v4l2PixFmtYUYV is the packed 4:2:2 format supported by practically all the webcams.
+	v4l2PixFmtYUYV = 'Y' | 'U'<<8 | 'Y'<<16 | 'V'<<24
+
+	// captureBuffers is the number of the buffers queued to the driver.
+	captureBuffers = 4
+)
+
+// v4l2PixFormat mirrors the struct v4l2_pix_format.
+type v4l2PixFormat struct {
+	width, height, pixelformat, field         uint32
+	bytesperline, sizeimage, colorspace, priv uint32
+	flags, ycbcrEnc, quantization, xferFunc   uint32
+}
+
+// v4l2Format mirrors the struct v4l2_format. The union of the formats is 200 bytes long
+// and it's aligned as a pointer, since some of the formats are holding pointers.
+type v4l2Format struct {
+	typ uint32
+	fmt struct {
+		_   [0]uintptr
+		pix v4l2PixFormat
+		_   [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+	}
+}
+
+// v4l2RequestBuffers mirrors the struct v4l2_requestbuffers.
+type v4l2RequestBuffers struct {
+	count, typ, memory, capabilities uint32
+	flags                            uint8
+	_                                [3]uint8
+}
+
+// v4l2Buffer mirrors the struct v4l2_buffer. The m union holds the offset of the memory mapped buffer.
+type v4l2Buffer struct {
+	index, typ, bytesused, flags, field uint32
+	timestamp                           syscall.Timeval
+	timecode                            [16]byte
+	sequence, memory                    uint32
+	offset                              uintptr
+	length, reserved2, requestFD        uint32
+}
+
+// The ioctl request codes are encoding the direction, the size of the argument, the type and the number.
+var (
+	vidiocSFmt     = iowr(5, unsafe.Sizeof(v4l2Format{}))
+	vidiocReqBufs  = iowr(8, unsafe.Sizeof(v4l2RequestBuffers{}))
+	vidiocQueryBuf = iowr(9, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocQBuf     = iowr(15, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocDQBuf    = iowr(17, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocStreamOn = iow(18, unsafe.Sizeof(int32(0)))
+)
+
+func iowr(nr, size uintptr) uintptr { return 3<<30 | size<<16 | 'V'<<8 | nr }
+func iow(nr, size uintptr) uintptr  { return 1<<30 | size<<16 | 'V'<<8 | nr }
+
+// webcam captures the frames of a V4L2 device using memory mapped buffers.
+type webcam struct {
+	f             *os.File
+	width, height int
+	bytesPerLine  int
+	buffers       [][]byte
+}
+
+// openWebcam opens the device and starts the streaming, requesting the provided frame size.
+// The driver might adjust the size to the closest one supported by the device.
+func openWebcam(device string, width, height int) (source, error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	cam := &webcam{f: f}
+	if err := cam.start(width, height); err != nil {
+		cam.Close()
+		return nil, fmt.Errorf("unable to start the capture on %s: %w", device, err)
+	}
+	return cam, nil
+}
+
+func (cam *webcam) ioctl(req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, cam.f.Fd(), req, uintptr(arg))
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		}
+		return errno
+	}
+}
+
+// start negotiates the format, maps the buffers and starts the streaming.
+func (cam *webcam) start(width, height int) error {
+	format := v4l2Format{typ: v4l2BufTypeVideoCapture}
+	format.fmt.pix = v4l2PixFormat{
+		width:       uint32(width),
+		height:      uint32(height),
+		pixelformat: v4l2PixFmtYUYV,
+		field:       v4l2FieldAny,
+	}
+	if err := cam.ioctl(vidiocSFmt, unsafe.Pointer(&format)); err != nil {
+		return err
+	}
+	if format.fmt.pix.pixelformat != v4l2PixFmtYUYV {
+		return errors.New("the device doesn't support the YUYV pixel format")
+	}
+	cam.width, cam.height = int(format.fmt.pix.width), int(format.fmt.pix.height)
+	cam.bytesPerLine = max(int(format.fmt.pix.bytesperline), cam.width*2)
+
+	req := v4l2RequestBuffers{count: captureBuffers, typ: v4l2BufTypeVideoCapture, memory: v4l2MemoryMmap}
+	if err := cam.ioctl(vidiocReqBufs, unsafe.Pointer(&req)); err != nil {
+		return err
+	}
+	for i := uint32(0); i < req.count; i++ {
+		buf := v4l2Buffer{index: i, typ: v4l2BufTypeVideoCapture, memory: v4l2MemoryMmap}
+		if err := cam.ioctl(vidiocQueryBuf, unsafe.Pointer(&buf)); err != nil {
+			return err
+		}
+		data, err := syscall.Mmap(int(cam.f.Fd()), int64(buf.offset), int(buf.length),
+			syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return err
+		}
+		cam.buffers = append(cam.buffers, data)
+		if err := cam.ioctl(vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+			return err
+		}
+	}
+	typ := int32(v4l2BufTypeVideoCapture)
+	return cam.ioctl(vidiocStreamOn, unsafe.Pointer(&typ))
+}
+
+// Next waits for the next frame and converts it to RGB.
+func (cam *webcam) Next() (*image.NRGBA, error) {
+	buf := v4l2Buffer{typ: v4l2BufTypeVideoCapture, memory: v4l2MemoryMmap}
+	if err := cam.ioctl(vidiocDQBuf, unsafe.Pointer(&buf)); err != nil {
+		return nil, err
+	}
+	img := yuyvToNRGBA(cam.buffers[buf.index], cam.width, cam.height, cam.bytesPerLine)
+	// Give back the buffer to the driver once the frame is copied.
+	if err := cam.ioctl(vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Close releases the buffers and the device, which stops the streaming.
+func (cam *webcam) Close() error {
+	for _, b := range cam.buffers {
+		syscall.Munmap(b)
+	}
+	return cam.f.Close()
+}
+
+// yuyvToNRGBA converts the packed YUYV frame, where each pair of pixels is sharing the chroma samples.
+func yuyvToNRGBA(data []byte, width, height, stride int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := data[y*stride:]
+		for x := 0; x+1 < width; x += 2 {
+			i := x * 2
+			y0, cb, y1, cr := row[i], row[i+1], row[i+2], row[i+3]
+			o := img.PixOffset(x, y)
+			r, g, b := color.YCbCrToRGB(y0, cb, cr)
+			img.Pix[o], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = r, g, b, 0xff
+			r, g, b = color.YCbCrToRGB(y1, cb, cr)
+			img.Pix[o+4], img.Pix[o+5], img.Pix[o+6], img.Pix[o+7] = r, g, b, 0xff
+		}
+	}
+	return img
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !headless
+
+package main
+
+import "errors"
+
+// openWebcam is supported only on Linux, the other platforms can use the synthetic pattern.
+func openWebcam(device string, width, height int) (source, error) {
+	return nil, errors.New("the webcam capture is supported only on Linux, use the -pattern flag")
+}
@@ -0,0 +1,190 @@
+//go:build !headless
+
+// Command caire-live is a demo of the soft real-time seam carving: it captures the frames of
+// a webcam, carves them to the target aspect ratio and displays the result in a window.
+// The number of seams removed from a frame is limited by a small budget and the seams
+// of the previous frame are reused for searching the seams of the current frame, which
+// keeps the carved video temporally coherent.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/io/system"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/giowidget"
+	"github.com/esimov/caire/utils"
+)
+
+var (
+	device        = flag.String("device", "/dev/video0", "Webcam device")
+	captureWidth  = flag.Int("capture-width", 640, "Requested width of the captured frames")
+	captureHeight = flag.Int("capture-height", 480, "Requested height of the captured frames")
+	aspect        = flag.String("aspect", "1:1", "Aspect ratio of the carved frames, ex. 1:1, 9:16 or 1.5")
+	seamBudget    = flag.Int("seams", 32, "Maximum number of seams removed from a frame (the rest is scaled)")
+	bandwidth     = flag.Int("band", 8, "Half width of the band around the previous seam in which the seam is searched")
+	background    = flag.Int("background", 0, "Number of the recent frames used for detecting the static background (0 disables it)")
+	sobelThresh   = flag.Int("sobel", 2, "Sobel filter threshold")
+	usePattern    = flag.Bool("pattern", false, "Carve a synthetic animated pattern instead of the webcam frames")
+	showSource    = flag.Bool("source", false, "Show the captured frame next to the carved frame")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	ratio, err := parseAspect(*aspect)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	var src source
+	if *usePattern {
+		src = newPattern(*captureWidth, *captureHeight)
+	} else if src, err = openWebcam(*device, *captureWidth, *captureHeight); err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	go func() {
+		if err := run(src, ratio); err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		os.Exit(0)
+	}()
+	app.Main()
+}
+
+// run captures and carves the frames until the window is closed.
+func run(src source, ratio float64) error {
+	defer src.Close()
+
+	frame, err := src.Next()
+	if err != nil {
+		return err
+	}
+	width, height := targetSize(frame.Bounds().Size(), ratio)
+	lc := caire.NewLiveCarver(width, height, caire.Processor{SobelThreshold: *sobelThresh})
+	lc.SeamBudget, lc.Bandwidth, lc.BackgroundFrames = *seamBudget, *bandwidth, *background
+
+	winWidth := width
+	if *showSource {
+		winWidth += frame.Bounds().Dx()
+	}
+	w := app.NewWindow(
+		app.Title("Caire Live"),
+		app.Size(unit.Dp(float32(winWidth)), unit.Dp(float32(max(height, frame.Bounds().Dy())))),
+	)
+
+	var (
+		carved, source giowidget.Preview
+		fps            atomic.Value
+		done           = make(chan struct{})
+	)
+	defer close(done)
+	fps.Store("")
+
+	// The frames are captured and carved in a separate goroutine, the window is redrawn after each frame.
+	errc := make(chan error, 1)
+	go func() {
+		frames, since := 0, time.Now()
+		for {
+			res, err := lc.Carve(frame)
+			if err != nil {
+				errc <- err
+				return
+			}
+			carved.Update(res, caire.SeamInfo{})
+			source.Update(frame, caire.SeamInfo{})
+			w.Invalidate()
+
+			if frames++; time.Since(since) >= time.Second {
+				fps.Store(fmt.Sprintf("%.1f fps", float64(frames)/time.Since(since).Seconds()))
+				frames, since = 0, time.Now()
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if frame, err = src.Next(); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	var (
+		ops   op.Ops
+		title string
+	)
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case e := <-w.Events():
+			switch e := e.(type) {
+			case system.FrameEvent:
+				if t := fps.Load().(string); t != title {
+					title = t
+					w.Option(app.Title(fmt.Sprintf("Caire Live - %dx%d - %s", width, height, title)))
+				}
+				gtx := layout.NewContext(&ops, e)
+				if *showSource {
+					layout.Flex{}.Layout(gtx,
+						layout.Flexed(1, carved.Layout),
+						layout.Flexed(1, source.Layout),
+					)
+				} else {
+					carved.Layout(gtx)
+				}
+				e.Frame(gtx.Ops)
+			case system.DestroyEvent:
+				return e.Err
+			}
+		}
+	}
+}
+
+// parseAspect parses the aspect ratio expressed as width:height or as a number.
+func parseAspect(s string) (float64, error) {
+	var (
+		ratio float64
+		err   error
+	)
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		var fw, fh float64
+		if fw, err = strconv.ParseFloat(w, 64); err == nil {
+			if fh, err = strconv.ParseFloat(h, 64); err == nil && fh != 0 {
+				ratio = fw / fh
+			}
+		}
+	} else {
+		ratio, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil || ratio <= 0 || math.IsInf(ratio, 0) || math.IsNaN(ratio) {
+		return 0, errors.New("invalid aspect ratio: " + s)
+	}
+	return ratio, nil
+}
+
+// targetSize returns the largest size having the aspect ratio which fits in the frame.
+func targetSize(size image.Point, ratio float64) (int, int) {
+	if float64(size.X)/float64(size.Y) > ratio {
+		return max(int(math.Round(float64(size.Y)*ratio)), 2), size.Y
+	}
+	return size.X, max(int(math.Round(float64(size.X)/ratio)), 2)
+}
@@ -0,0 +1,70 @@
+//go:build !headless
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"time"
+)
+
+// source provides the frames to be carved.
+type source interface {
+	Next() (*image.NRGBA, error)
+	Close() error
+}
+
+// pattern is a synthetic source animating a few shapes over a gradient background,
+// which can be used for trying out the live carving on the machines without a webcam.
+type pattern struct {
+	width, height int
+	start         time.Time
+	frameTime     time.Duration
+	last          time.Time
+}
+
+// newPattern returns a synthetic source generating the frames at 30 fps.
+func newPattern(width, height int) *pattern {
+	return &pattern{width: width, height: height, start: time.Now(), frameTime: time.Second / 30}
+}
+
+// Next returns the next frame, waiting for the frame time like a real device.
+func (p *pattern) Next() (*image.NRGBA, error) {
+	if wait := p.frameTime - time.Since(p.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	p.last = time.Now()
+	t := time.Since(p.start).Seconds()
+
+	img := image.NewNRGBA(image.Rect(0, 0, p.width, p.height))
+	// The moving subjects: a disc swinging horizontally and a square bouncing vertically.
+	cx := float64(p.width) * (0.5 + 0.3*math.Sin(t))
+	cy := float64(p.height) * 0.5
+	r := float64(p.height) / 6
+	sx := int(float64(p.width) * 0.2)
+	sy := int(float64(p.height) * (0.5 + 0.3*math.Sin(t*1.7)))
+	side := p.height / 8
+
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			c := color.NRGBA{
+				R: uint8(40 + 60*y/p.height),
+				G: uint8(60 + 80*y/p.height),
+				B: uint8(120 + 100*y/p.height),
+				A: 0xff,
+			}
+			if dx, dy := float64(x)-cx, float64(y)-cy; dx*dx+dy*dy < r*r {
+				c = color.NRGBA{R: 0xf0, G: 0xb0, B: 0x30, A: 0xff}
+			}
+			if x >= sx-side && x < sx+side && y >= sy-side && y < sy+side {
+				c = color.NRGBA{R: 0xe0, G: 0x30, B: 0x40, A: 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// Close implements the source interface.
+func (p *pattern) Close() error { return nil }
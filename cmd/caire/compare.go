@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runCompare compares two images and prints a report about their differences.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	seamsA := fs.String("seams-a", "", "Seams SVG overlay of the first image (generated with -seams-svg)")
+	seamsB := fs.String("seams-b", "", "Seams SVG overlay of the second image (generated with -seams-svg)")
+	minSSIM := fs.Float64("min-ssim", 0, "Exit with an error if the SSIM is below this value")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire compare [options] <a.jpg> <b.jpg>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a, err := decodeImage(fs.Arg(0))
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	b, err := decodeImage(fs.Arg(1))
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	fmt.Printf("%-16s %s (%dx%d)\n", "Image A:", fs.Arg(0), a.Bounds().Dx(), a.Bounds().Dy())
+	fmt.Printf("%-16s %s (%dx%d)\n", "Image B:", fs.Arg(1), b.Bounds().Dx(), b.Bounds().Dy())
+
+	cmp, err := caire.Compare(a, b)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	fmt.Printf("%-16s %.4f%%\n", "Changed pixels:", cmp.ChangedPixels*100)
+	fmt.Printf("%-16s %.4f\n", "Mean difference:", cmp.MeanDiff)
+	fmt.Printf("%-16s %d\n", "Max difference:", cmp.MaxDiff)
+	fmt.Printf("%-16s %.6f\n", "SSIM:", cmp.SSIM)
+
+	if *seamsA != "" && *seamsB != "" {
+		sa, err := readSeams(*seamsA)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		sb, err := readSeams(*seamsB)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		fmt.Printf("%-16s %.4f\n", "Seam overlap:", caire.SeamOverlap(sa, sb))
+	}
+
+	if cmp.SSIM < *minSSIM {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("The SSIM is below the required minimum of %v", *minSSIM), utils.ErrorMessage))
+	}
+}
+
+// decodeImage opens and decodes the image file.
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the image: %v", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the image %s: %v", path, err)
+	}
+	return img, nil
+}
+
+// readSeams reads the seams from the SVG overlay file.
+func readSeams(path string) ([][]image.Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return caire.ParseSeamsSVG(f)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runSuggestCrop prints the crop rectangle retaining the most salient content as JSON.
+func runSuggestCrop(args []string) {
+	fs := flag.NewFlagSet("suggest-crop", flag.ExitOnError)
+	source := fs.String("in", "", "Source image")
+	destination := fs.String("out", "", "Save the cropped image to the provided file")
+	ratio := fs.String("ratio", "1:1", "Aspect ratio of the crop as W:H or decimal number")
+	blurRadius := fs.Int("blur", 4, "Blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Sobel filter threshold")
+	faceDetect := fs.Bool("face", false, "Keep the detected faces")
+	faceAngle := fs.Float64("angle", 0.0, "Face rotation angle")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire suggest-crop -in <image> -ratio <W:H> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	r, err := caire.ParseRatio(*ratio)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	img, err := decodeImage(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	proc := &caire.Processor{
+		BlurRadius:     *blurRadius,
+		SobelThreshold: *sobelThreshold,
+		FaceDetect:     *faceDetect,
+		FaceAngle:      *faceAngle,
+	}
+	crop, err := proc.SuggestCrop(img, r)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(crop); err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	if *destination != "" {
+		rect := crop.Rect().Add(img.Bounds().Min)
+		if err := imaging.Save(imaging.Crop(img, rect), *destination); err != nil {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to save the cropped image: %v", err), utils.ErrorMessage))
+		}
+		fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage), *source, *destination)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/server"
+	"github.com/esimov/caire/utils"
+)
+
+// runDaemon starts a long-running process controlled through JSON commands sent over a Unix socket.
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", "", "Path of the Unix socket to listen on")
+	blurRadius := fs.Int("blur", 4, "Default blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Default sobel filter threshold")
+	faceDetect := fs.Bool("face", false, "Use face detection by default")
+	faceAngle := fs.Float64("angle", 0.0, "Default face rotation angle")
+	maxPixels := fs.Int64("max-pixels", caire.DefaultMaxPixels, "Reject the images having more pixels (-1 disables the limit)")
+	maxDecodeMem := fs.Int64("max-decode-mem", 0, "Memory budget of the decoded image in MB: the larger JPEGs are downscaled while decoding, the other images are rejected (0 disables the limit)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire daemon -socket <path> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *socket == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Remove the socket file left behind by a previous run.
+	if fi, err := os.Stat(*socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(*socket)
+	}
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to listen on the socket: %v", err), utils.ErrorMessage))
+	}
+
+	d := server.NewDaemon(caire.Processor{
+		BlurRadius:     *blurRadius,
+		SobelThreshold: *sobelThreshold,
+		FaceDetect:     *faceDetect,
+		FaceAngle:      *faceAngle,
+		MaxPixels:      *maxPixels,
+		MaxDecodeMem:   *maxDecodeMem << 20,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "⚡ CAIRE daemon listening on %s\n", *socket)
+	if err := d.Serve(ctx, l); err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+}
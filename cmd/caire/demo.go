@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/server"
+	"github.com/esimov/caire/utils"
+)
+
+// runDemo starts an HTTP server hosting the web interface for trying the resizing options.
+func runDemo(args []string) {
+	fs := flag.NewFlagSet("demo", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	blurRadius := fs.Int("blur", 4, "Default blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Default sobel filter threshold")
+	maxPixels := fs.Int64("max-pixels", caire.DefaultMaxPixels, "Reject the images having more pixels (-1 disables the limit)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire demo [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	wk := server.NewWorker(caire.Processor{
+		BlurRadius:     *blurRadius,
+		SobelThreshold: *sobelThreshold,
+		MaxPixels:      *maxPixels,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	srv := &http.Server{Addr: *addr, Handler: server.NewDemo(wk)}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	// The wildcard addresses are reported as localhost, so the link can be opened right away.
+	host := *addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Fprintf(os.Stderr, "⚡ CAIRE demo available at http://%s\n", host)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+}
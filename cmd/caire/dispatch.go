@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/server"
+	"github.com/esimov/caire/utils"
+)
+
+// runDispatch distributes the images from a directory between the workers.
+func runDispatch(args []string) {
+	fs := flag.NewFlagSet("dispatch", flag.ExitOnError)
+	source := fs.String("in", "", "Source directory")
+	destination := fs.String("out", "", "Destination directory")
+	workerList := fs.String("workers", "", "Comma separated list of worker addresses")
+	conc := fs.Int("conc", 1, "Number of concurrent requests per worker")
+	blurRadius := fs.Int("blur", 4, "Blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Sobel filter threshold")
+	newWidth := fs.Int("width", 0, "New width")
+	newHeight := fs.Int("height", 0, "New height")
+	percentage := fs.Bool("perc", false, "Reduce image by percentage")
+	square := fs.Bool("square", false, "Reduce image to square dimensions")
+	faceDetect := fs.Bool("face", false, "Use face detection")
+	faceAngle := fs.Float64("angle", 0.0, "Face rotation angle")
+	apiKey := fs.String("api-key", "", "API key sent to the workers requiring authentication")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire dispatch -in <dir> -out <dir> -workers <host:port,...> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" || *destination == "" || *workerList == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	src, err := os.Stat(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to open the source directory: %v", err), utils.ErrorMessage))
+	}
+	if !src.IsDir() {
+		log.Fatal(utils.DecorateText("The source should be a directory", utils.ErrorMessage))
+	}
+
+	var workers []string
+	for _, w := range strings.Split(*workerList, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			workers = append(workers, w)
+		}
+	}
+
+	d := &server.Dispatcher{
+		Workers:     workers,
+		Concurrency: *conc,
+		Options: &caire.Processor{
+			BlurRadius:     *blurRadius,
+			SobelThreshold: *sobelThreshold,
+			NewWidth:       *newWidth,
+			NewHeight:      *newHeight,
+			Percentage:     *percentage,
+			Square:         *square,
+			FaceDetect:     *faceDetect,
+			FaceAngle:      *faceAngle,
+		},
+		APIKey: *apiKey,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var failed int
+	for res := range d.Dispatch(ctx, *source, *destination) {
+		if res.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", utils.DecorateText("✘", utils.ErrorMessage), res.Path, res.Err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage), res.Path, res.Worker)
+	}
+
+	if failed > 0 {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("%d image(s) could not be processed", failed), utils.ErrorMessage))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runDoctor checks the runtime environment and prints a pass/fail report.
+// The process exits with a non-zero status code if any of the checks has failed.
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire doctor [-json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	caps := caire.Capabilities()
+	report := caire.Diagnose(context.Background())
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Version string            `json:"version"`
+			OS      string            `json:"os"`
+			Arch    string            `json:"arch"`
+			Checks  caire.Diagnostics `json:"checks"`
+		}{Version, caps.OS, caps.Arch, report})
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+	} else {
+		fmt.Printf("caire %s (%s, %s/%s)\n\n", Version, caps.GoVersion, caps.OS, caps.Arch)
+		for _, c := range report {
+			var status string
+			switch c.Status {
+			case caire.CheckPass:
+				status = utils.DecorateText("✔ pass", utils.SuccessMessage)
+			case caire.CheckWarn:
+				status = utils.DecorateText("! warn", utils.StatusMessage)
+			default:
+				status = utils.DecorateText("✘ fail", utils.ErrorMessage)
+			}
+			fmt.Printf("%-10s %s  %s\n", c.Name, status, c.Detail)
+		}
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+	"golang.org/x/term"
+)
+
+// execute resizes the images with the runner, reporting the progress in the terminal.
+// The `-` source and destination are mapped to the stdin and stdout pipes.
+func execute(proc *caire.Processor, r *caire.Runner) {
+	defaultMsg := fmt.Sprintf("%s %s",
+		utils.DecorateText("⚡ CAIRE", utils.StatusMessage),
+		utils.DecorateText("⇢ resizing image (be patient, it may take a while)...", utils.DefaultMessage),
+	)
+	proc.Spinner = utils.NewSpinner(defaultMsg, time.Millisecond*80)
+
+	if r.Src == pipeName {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			log.Fatal(utils.DecorateText("`-` should be used with a pipe for stdin", utils.ErrorMessage))
+		}
+		r.Src, r.Input = "", os.Stdin
+	}
+	if r.Dst == pipeName {
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			log.Fatal(utils.DecorateText("`-` should be used with a pipe for stdout", utils.ErrorMessage))
+		}
+		r.Dst, r.Output = "", os.Stdout
+	}
+	var isDir bool
+	if r.Src != "" && !utils.IsValidUrl(r.Src) {
+		if fi, err := os.Stat(r.Src); err == nil {
+			isDir = fi.IsDir()
+		}
+	}
+
+	// Capture the CTRL-C signal, so the partially written image is removed and the cursor
+	// visibility is restored. A second signal terminates the process right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	r.OnResult = func(res caire.RunResult) {
+		if res.Err != nil {
+			proc.Spinner.StopMsg = fmt.Sprintf("%s %s %s",
+				utils.DecorateText("⚡ CAIRE", utils.StatusMessage),
+				utils.DecorateText("resizing image failed...", utils.DefaultMessage),
+				utils.DecorateText("✘", utils.ErrorMessage),
+			)
+			if errors.Is(res.Err, context.Canceled) {
+				proc.Spinner.StopMsg = fmt.Sprintf("%s %s %s",
+					utils.DecorateText("⚡ CAIRE", utils.StatusMessage),
+					utils.DecorateText("⇢ process aborted by the user...", utils.DefaultMessage),
+					utils.DecorateText("✘\n", utils.ErrorMessage),
+				)
+			}
+			proc.Spinner.Stop()
+			log.Fatalf(
+				utils.DecorateText("\nError resizing the image: %s", utils.ErrorMessage),
+				utils.DecorateText(fmt.Sprintf("\n\tReason: %v\n", res.Err.Error()), utils.DefaultMessage),
+			)
+		}
+		if isDir {
+			// The spinner keeps running on the next line.
+			fmt.Fprintf(os.Stderr, "\r%s %s\n", savedMsg(res.Dst), resultNotes(res.Processor))
+			return
+		}
+		proc.Spinner.StopMsg = successMsg() + resultNotes(res.Processor)
+		proc.Spinner.Stop()
+		if res.Dst != "" {
+			fmt.Fprintf(os.Stderr, "\n%s\n\n", savedMsg(res.Dst))
+		}
+	}
+
+	now := time.Now()
+	proc.Spinner.Start()
+	// The spinner is still running if the source couldn't be accessed, or in case of a directory.
+	if _, err := r.Run(ctx); err != nil {
+		proc.Spinner.Stop()
+		log.Fatal(utils.DecorateText(fmt.Sprintf("\n%v", err), utils.ErrorMessage))
+	}
+	if isDir {
+		proc.Spinner.StopMsg = successMsg()
+		proc.Spinner.Stop()
+	}
+	fmt.Fprintf(os.Stderr, "\nExecution time: %s\n", utils.DecorateText(utils.FormatTime(time.Since(now)), utils.SuccessMessage))
+}
+
+// successMsg returns the message shown once the resizing has been completed.
+func successMsg() string {
+	return fmt.Sprintf("%s %s %s",
+		utils.DecorateText("⚡ CAIRE", utils.StatusMessage),
+		utils.DecorateText("⇢", utils.DefaultMessage),
+		utils.DecorateText("the image has been resized successfully ✔", utils.SuccessMessage),
+	)
+}
+
+// savedMsg returns the message reporting the path of the resized image.
+func savedMsg(dst string) string {
+	return fmt.Sprintf("The image has been saved as: %s %s",
+		utils.DecorateText(filepath.Base(dst), utils.SuccessMessage),
+		utils.DefaultColor,
+	)
+}
+
+// resultNotes describes the decisions of the automatic options and the warnings of the processor.
+func resultNotes(p *caire.Processor) string {
+	var notes string
+	if d := p.AxisDecision(); p.AutoAxis && d != nil {
+		notes += utils.DecorateText(fmt.Sprintf(
+			"\n\tAuto axis: the image %s has been reduced to %dx%d (estimated distortion: width %.3f, height %.3f)",
+			d.Axis, d.Width, d.Height, d.WidthDistortion, d.HeightDistortion,
+		), utils.DefaultMessage)
+	}
+	if d := p.MethodDecision(); p.AutoMethod && d != nil {
+		notes += utils.DecorateText(fmt.Sprintf(
+			"\n\tAuto: the image has been resized to %dx%d with the %s method (scores: carve %.3f, scale %.3f, crop %.3f)",
+			d.Width, d.Height, d.Method, d.CarveScore, d.ScaleScore, d.CropScore,
+		), utils.DefaultMessage)
+	}
+	if d := p.TuneDecision(); p.AutoTune && d != nil {
+		notes += utils.DecorateText(fmt.Sprintf(
+			"\n\tAuto tune: %d attempt(s), forward energy: %t, face margin: %.1f (distortion %.3f)",
+			d.Attempts, d.ForwardEnergy, d.FaceMargin, d.Distortion,
+		), utils.DefaultMessage)
+	}
+	if p.Noop() {
+		notes += utils.DecorateText("\n\tThe image already has the requested size, it has been copied unchanged", utils.DefaultMessage)
+	}
+	for _, w := range p.Warnings() {
+		notes += utils.DecorateText(fmt.Sprintf("\n\tWarning [%s]: %s", w.Code, w.Message), utils.ErrorMessage)
+	}
+	return notes
+}
@@ -0,0 +1,15 @@
+//go:build !headless
+
+package main
+
+import "gioui.org/app"
+
+// previewDefault is the default value of the preview flag.
+const previewDefault = true
+
+// runGUI executes the resizing process in a separate goroutine in order to not block the Gio thread,
+// which have to run on the main OS thread of the operating systems like MacOS.
+func runGUI(fn func()) {
+	go fn()
+	app.Main()
+}
@@ -0,0 +1,11 @@
+//go:build headless
+
+package main
+
+// previewDefault is the default value of the preview flag. The headless builds can't show the preview.
+const previewDefault = false
+
+// runGUI executes the resizing process directly, since there is no GUI event loop to run.
+func runGUI(fn func()) {
+	fn()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// wizard prompts the user for the resizing options, validating the answers.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints the prompt and reads the answer until it passes the validation.
+// An empty answer selects the default value.
+func (w *wizard) ask(prompt, def string, validate func(string) error) string {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", prompt, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", prompt)
+		}
+		line, err := w.in.ReadString('\n')
+		if err != nil && line == "" {
+			// The input is closed, there is no way to continue.
+			fmt.Fprintln(w.out)
+			os.Exit(1)
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if validate == nil {
+			return answer
+		}
+		if err := validate(answer); err != nil {
+			fmt.Fprintln(w.out, utils.DecorateText(err.Error(), utils.ErrorMessage))
+			continue
+		}
+		return answer
+	}
+}
+
+// confirm asks a yes or no question.
+func (w *wizard) confirm(prompt string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer := w.ask(fmt.Sprintf("%s (%s)", prompt, hint), "", func(s string) error {
+		switch strings.ToLower(s) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return errors.New("please answer with yes or no")
+	})
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// runInteractive prompts for the main options, then prints the equivalent command.
+func runInteractive(args []string) {
+	fs := flag.NewFlagSet("interactive", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire interactive")
+		fmt.Fprintln(os.Stderr, "Prompts for the main options, then prints the equivalent command.")
+	}
+	fs.Parse(args)
+
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+
+	fmt.Fprintf(os.Stdout, HelpBanner, Version)
+	fmt.Fprintln(os.Stdout, "Answer the questions below, press enter to accept the default values.")
+	fmt.Fprintln(os.Stdout)
+
+	var size image.Point
+	source := w.ask("Input image file", "", func(s string) error {
+		if s == "" {
+			return errors.New("the input file is required")
+		}
+		img, err := decodeImage(s)
+		if err != nil {
+			return err
+		}
+		size = img.Bounds().Size()
+		return nil
+	})
+	fmt.Fprintf(os.Stdout, "The image size is %dx%d.\n", size.X, size.Y)
+
+	ext := filepath.Ext(source)
+	destination := w.ask("Output image file", strings.TrimSuffix(source, ext)+"_resized"+ext, func(s string) error {
+		if _, err := caire.ParseFormat(filepath.Ext(s)); err != nil || filepath.Ext(s) == "" {
+			return fmt.Errorf("unsupported output file type %q, use jpg, png, bmp or gif", filepath.Ext(s))
+		}
+		return nil
+	})
+
+	dimension := func(s string) error {
+		if v, err := strconv.Atoi(s); err != nil || v < 0 {
+			return errors.New("please provide a positive number, or 0 to keep the current size")
+		}
+		return nil
+	}
+	var width, height int
+	for {
+		width, _ = strconv.Atoi(w.ask("New width (0 keeps the current width)", "0", dimension))
+		height, _ = strconv.Atoi(w.ask("New height (0 keeps the current height)", "0", dimension))
+		if (width != 0 && width != size.X) || (height != 0 && height != size.Y) {
+			break
+		}
+		fmt.Fprintln(os.Stdout, utils.DecorateText("At least one of the dimensions should be changed.", utils.ErrorMessage))
+	}
+
+	face := w.confirm("Protect the human faces", false)
+
+	maskFile := func(s string) error {
+		if s == "" {
+			return nil
+		}
+		mask, err := decodeImage(s)
+		if err != nil {
+			return err
+		}
+		if mask.Bounds().Size() != size {
+			return fmt.Errorf("the mask size %v should match the image size %v", mask.Bounds().Size(), size)
+		}
+		return nil
+	}
+	var mask, rmask string
+	if w.confirm("Use masks for protecting or removing image regions", false) {
+		mask = w.ask("Protective mask file (leave empty for none)", "", maskFile)
+		rmask = w.ask("Removal mask file (leave empty for none)", "", maskFile)
+	}
+
+	preview := previewDefault && w.confirm("Show the preview window", true)
+
+	cmdArgs := []string{"-in", source, "-out", destination}
+	if width != 0 {
+		cmdArgs = append(cmdArgs, "-width", strconv.Itoa(width))
+	}
+	if height != 0 {
+		cmdArgs = append(cmdArgs, "-height", strconv.Itoa(height))
+	}
+	if face {
+		cmdArgs = append(cmdArgs, "-face")
+	}
+	if mask != "" {
+		cmdArgs = append(cmdArgs, "-mask", mask)
+	}
+	if rmask != "" {
+		cmdArgs = append(cmdArgs, "-rmask", rmask)
+	}
+	if preview != previewDefault {
+		cmdArgs = append(cmdArgs, "-preview="+strconv.FormatBool(preview))
+	}
+
+	fmt.Fprintln(os.Stdout)
+	fmt.Fprintln(os.Stdout, "The equivalent command is:")
+	fmt.Fprintln(os.Stdout, utils.DecorateText(shellCommand("caire", cmdArgs), utils.SuccessMessage))
+	fmt.Fprintln(os.Stdout)
+
+	if !w.confirm("Run it now", true) {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	cmd := exec.Command(exe, cmdArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// shellCommand formats the command line, quoting the arguments for the POSIX shells.
+func shellCommand(name string, args []string) string {
+	parts := []string{name}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`&|;<>()*?[]#~!{}") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
@@ -6,9 +6,12 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
-	"gioui.org/app"
 	"github.com/esimov/caire"
+	"github.com/esimov/caire/assets"
 	"github.com/esimov/caire/utils"
 )
 
@@ -39,25 +42,199 @@ var (
 	percentage     = flag.Bool("perc", false, "Reduce image by percentage")
 	square         = flag.Bool("square", false, "Reduce image to square dimensions")
 	debug          = flag.Bool("debug", false, "Show the seams")
-	shapeType      = flag.String("shape", "circle", "Shape type used for debugging: circle|line")
+	shapeType      = flag.String("shape", "circle", "Shape type used for debugging: circle|line|arrow|dotted|gradient")
+	shapeSize      = flag.Float64("shape-size", 2, "Size of the shapes used for debugging (circle radius, line thickness)")
 	seamColor      = flag.String("color", "#ff0000", "Seam color")
-	preview        = flag.Bool("preview", true, "Show GUI window")
+	preview        = flag.Bool("preview", previewDefault, "Show GUI window")
+	recordPath     = flag.String("record", "", "Record the preview window into an animated GIF file")
+	previewBg      = flag.String("preview-bg", "", "Background of the preview window: a hex color or checkerboard")
+	highContrast   = flag.Bool("high-contrast", false, "Use high contrast colors and no animations in the preview window")
 	maskPath       = flag.String("mask", "", "Mask file path for retaining area")
 	rMaskPath      = flag.String("rmask", "", "Mask file path for removing area")
+	protectColor   = flag.String("protect-color", "", "Key color of the protected area painted on the mask file, ex. #00ff00")
+	removeColor    = flag.String("remove-color", "", "Key color of the removed area painted on the removal mask file, ex. #ff0000")
+	tolerance      = flag.Int("tolerance", 20, "Maximum difference of the color channels from the key color (0-255)")
+	weightMask     = flag.String("weight-mask", "", "Grayscale mask file path mapping the pixel values to protection strength (128 is neutral)")
+	maskFeather    = flag.Int("weight-mask-feather", 0, "Radius of the edge aware feathering of the weight mask (0 disables it)")
+	maskFit        = flag.String("mask-fit", "", "Handling of the masks having a different size than the image: scale or strict")
+	reference      = flag.String("reference", "", "Second frame (burst photo or stereo pair) whose difference from the image protects the moving subjects")
+	exifSubject    = flag.Bool("exif-subject", false, "Protect the subject area (autofocus point) recorded by the camera in the EXIF metadata")
+	screenshot     = flag.Bool("screenshot", false, "Detect and protect the UI elements (buttons, panels, text blocks) of screenshots")
+	watchMasks     = flag.Bool("watch-mask", false, "Reload the mask files when they are modified during the preview")
 	faceDetect     = flag.Bool("face", false, "Use face detection")
 	faceAngle      = flag.Float64("angle", 0.0, "Face rotation angle")
-	workers        = flag.Int("conc", runtime.NumCPU(), "Number of files to process concurrently")
+	workers        = flag.Int("conc-files", runtime.NumCPU(), "Number of files to process concurrently")
+	seamWorkers    = flag.Int("conc-seams", 0, "Number of threads used inside an image (0 uses all the CPUs)")
+	assetList      = flag.String("assets", "", "Comma separated list of model assets as name=path or name=url#sha256=checksum")
+	assetsDir      = flag.String("assets-dir", assets.DefaultCacheDir(), "Directory for caching the downloaded model assets")
+	cpuLimit       = flag.String("cpu-limit", "", "Share of the CPU the processing is allowed to use (ex. 50%)")
+	niceness       = flag.Int("nice", 0, "Scheduling priority of the process, from -20 (highest) to 19 (lowest)")
+	keepPalette    = flag.Bool("palette", false, "Preserve the original palette of paletted images (GIF, PNG8)")
+	paletteDither  = flag.Bool("dither", false, "Use dithering when mapping the colors to the palette")
+	png8           = flag.Bool("png8", false, "Quantize the PNG output to an 8-bit palette")
+	backend        = flag.String("backend", "cpu", "Computation backend used for the energy map: cpu|opencl")
+	inFormat       = flag.String("informat", "", "Force the input image format: jpeg|png|bmp|gif (detected from content by default)")
+	outFormat      = flag.String("outformat", "", "Force the output image format: jpeg|png|bmp|gif (derived from the file extension by default)")
+	progressive    = flag.Bool("progressive", false, "Encode the JPEG output progressively, with optimized Huffman tables")
+	cmyk           = flag.Bool("cmyk", false, "Encode the JPEG output in the CMYK color space")
+	background     = flag.String("bg", "#ffffff", "Background color used for flattening the transparent images on JPEG output")
+	channelWeights = flag.String("weights", "", "Comma separated R,G,B weights of the gradients used in the energy computation (ex. 1,2,2)")
+	seamsSVG       = flag.String("seams-svg", "", "Export the removed seams as an SVG overlay to the provided file")
+	removedPath    = flag.String("removed", "", "Save the content of the removed seams stitched together into the provided image file")
+	energyCSV      = flag.String("energy-csv", "", "Save the energy statistics and the runtime of each seam into the provided CSV file")
+	ghostPath      = flag.String("ghost", "", "Save an overlay of the original image over the result, highlighting the displaced content")
+	heatmapPath    = flag.String("heatmap", "", "Save a heatmap of the density of the seams crossing the source image")
+	displaceMap    = flag.String("displacement", "", "Save the source coordinates of the resized image pixels into a PNG or EXR file")
+	grid           = flag.String("grid", "", "Carve each cell of a COLSxROWS grid independently (ex. 4x4)")
+	tileable       = flag.Bool("tileable", false, "Keep the carved textures seamlessly tileable")
+	autoAxis       = flag.Bool("auto-axis", false, "Detect the carving axis causing less distortion for the pixel budget or ratio")
+	targetPixels   = flag.Int("pixels", 0, "Total pixel budget of the resized image (used with -auto-axis)")
+	targetRatio    = flag.Float64("ratio", 0, "Ratio of the pixels to keep, between 0 and 1 (used with -auto-axis)")
+	blurFaces      = flag.Bool("blur-faces", false, "Blur the detected faces in the resized image")
+	pixelateFaces  = flag.Bool("pixelate", false, "Pixelate the faces instead of blurring them (used with -blur-faces)")
+	watermarkPath  = flag.String("watermark", "", "Watermark image composited over the resized image")
+	wmAnchor       = flag.String("wm-anchor", caire.AnchorBottomRight, "Watermark position: top-left|top-right|bottom-left|bottom-right|center")
+	wmMargin       = flag.Int("wm-margin", 10, "Watermark distance from the image edges in pixels")
+	wmOpacity      = flag.Float64("wm-opacity", 1, "Watermark opacity between 0 and 1")
+	brightness     = flag.Float64("brightness", 0, "Brightness adjustment of the resized image, between -1 and 1")
+	contrast       = flag.Float64("contrast", 0, "Contrast adjustment of the resized image, between -1 and 1")
+	saturation     = flag.Float64("saturation", 0, "Saturation adjustment of the resized image, between -1 and 1")
+	rotate         = flag.Int("rotate", 0, "Rotate the image clockwise before carving: 90|180|270")
+	flip           = flag.String("flip", "", "Flip the image before carving: h|v")
+	energyRules    = flag.String("energy-rule", "", "Semicolon separated energy rules, ex. \"rect(0,0,200,100):+0.8; circle(512,512,100):-0.5\"")
+	faceCache      = flag.String("face-cache", "", "Directory for caching the face detection results of the processed images")
+	fastMode       = flag.Bool("fast", false, "Search the seams on a downscaled image first, then refine them at full resolution")
+	maxSeams       = flag.Int("max-seams-per-region", 0, "Maximum number of seams crossing any band of 64 columns or rows (0 means no limit)")
+	colorBlocks    = flag.Float64("color-blocks", 0, "Minimum share of their area kept by the large homogeneous color regions, between 0 and 1")
+	bandwidth      = flag.Int("bandwidth", 0, "Half width of the band in which the seams are refined in fast mode (0 uses the quality preset)")
+	forwardEnergy  = flag.Bool("forward-energy", false, "Use the forward energy, which better preserves the straight edges")
+	fixedPoint     = flag.Bool("fixed-point", false, "Compute the energy with integer arithmetic, producing identical results on every platform")
+	autoTune       = flag.Bool("auto-tune", false, "Retry with adjusted parameters when the result is too distorted")
+	autoMethod     = flag.Bool("auto", false, "Compare the carving with the scaling and the cropping, and use the best method")
+	minReduction   = flag.Float64("min-reduction", 0, "Scale instead of carving if the size changes by less than the percentage in both axes")
+	qualityPreset  = flag.String("quality-preset", caire.QualityBalanced, "Seam carving quality preset (fast, balanced, best)")
+	timeout        = flag.Duration("timeout", 0, "Abort the processing of an image exceeding the timeout (0 disables it)")
+	partialOutput  = flag.Bool("partial", false, "Save the partially carved image scaled to the requested size on timeout")
+	maxPixels      = flag.Int64("max-pixels", caire.DefaultMaxPixels, "Reject the images having more pixels (-1 disables the limit)")
+	maxDecodeMem   = flag.Int64("max-decode-mem", 0, "Memory budget of the decoded image in MB: the larger JPEGs are downscaled while decoding, the other images are rejected (0 disables the limit)")
+	preflight      = flag.Bool("preflight", false, "Warn about the images on which the seam carving performs poorly")
+	strict         = flag.Bool("strict", false, "Abort the processing of the images raising a pre-flight warning")
+	similarity     = flag.Int("similarity", 0, "Warn if the perceptual hash distance between the source and the resized image exceeds the threshold (0 disables the check)")
+	strictSimilar  = flag.Bool("strict-similarity", false, "Abort the processing of the images failing the similarity check")
+	fetchTimeout   = flag.Duration("fetch-timeout", time.Minute, "Timeout of a remote image download attempt")
+	fetchRetries   = flag.Int("fetch-retries", 3, "Number of retries of a failed remote image download")
+	fetchMaxSize   = flag.Int64("fetch-max-size", 100, "Maximum size of a remote image in MB")
+	fetchCache     = flag.String("fetch-cache", "", "Directory for caching the remote images using their ETag")
 )
 
 func main() {
 	log.SetFlags(0)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "worker":
+			runWorker(os.Args[2:])
+			return
+		case "dispatch":
+			runDispatch(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		case "run":
+			runPipeline(os.Args[2:])
+			return
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "mask":
+			runMask(os.Args[2:])
+			return
+		case "pdf":
+			runPdf(os.Args[2:])
+			return
+		case "suggest-crop":
+			runSuggestCrop(os.Args[2:])
+			return
+		case "srcset":
+			runSrcSet(os.Args[2:])
+			return
+		case "paginate":
+			runPaginate(os.Args[2:])
+			return
+		case "interactive":
+			runInteractive(os.Args[2:])
+			return
+		case "version":
+			runVersion(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "demo":
+			runDemo(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, fmt.Sprintf(HelpBanner, Version))
 		flag.PrintDefaults()
 	}
+	// The -conc flag is kept for backward compatibility.
+	flag.IntVar(workers, "conc", *workers, "Alias of -conc-files")
 	flag.Parse()
 
+	be, err := caire.ParseBackend(*backend)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	for _, format := range []string{*inFormat, *outFormat} {
+		if _, err := caire.ParseFormat(format); err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+	}
+
+	weights, err := parseWeights(*channelWeights)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	gr, err := parseGrid(*grid)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	rules, err := caire.ParseEnergyRules(*energyRules)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	caire.DefaultAssets.CacheDir = *assetsDir
+	for _, def := range strings.Split(*assetList, ",") {
+		if def = strings.TrimSpace(def); def == "" {
+			continue
+		}
+		asset, err := assets.ParseAsset(def)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		caire.DefaultAssets.Register(asset)
+	}
+
+	limit, err := caire.ParseCPULimit(*cpuLimit)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	caire.ApplyCPULimit(limit)
+
+	if *niceness != 0 {
+		if err := setNice(*niceness); err != nil {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("cannot change the process priority: %v", err), utils.ErrorMessage))
+		}
+	}
+
 	proc := &caire.Processor{
 		BlurRadius:     *blurRadius,
 		SobelThreshold: *sobelThreshold,
@@ -71,32 +248,148 @@ func main() {
 		FaceAngle:      *faceAngle,
 		MaskPath:       *maskPath,
 		RMaskPath:      *rMaskPath,
+		WeightMaskPath: *weightMask,
+		MaskFit:        *maskFit,
+		ReferencePath:  *reference,
+		Screenshot:     *screenshot,
+		UseExifSubject: *exifSubject,
+		WatchMasks:     *watchMasks,
 		ShapeType:      *shapeType,
+		ShapeSize:      *shapeSize,
 		SeamColor:      *seamColor,
+		KeepPalette:    *keepPalette,
+		PaletteDither:  *paletteDither,
+		PNG8:           *png8,
+		Backend:        be,
+		InputFormat:    *inFormat,
+		OutputFormat:   *outFormat,
+		Background:     utils.HexToRGBA(*background),
+		ChannelWeights: weights,
+		SeamsSVGPath:   *seamsSVG,
+		RemovedPath:    *removedPath,
+		EnergyCSVPath:  *energyCSV,
+		GhostPath:      *ghostPath,
+		HeatmapPath:    *heatmapPath,
+		DisplaceMap:    *displaceMap,
+		Grid:           gr,
+		Tileable:       *tileable,
+		AutoAxis:       *autoAxis,
+		TargetPixels:   *targetPixels,
+		TargetRatio:    *targetRatio,
+		BlurFaces:      *blurFaces,
+		PixelateFaces:  *pixelateFaces,
+		Rotate:         *rotate,
+		Flip:           *flip,
+		EnergyRules:    rules,
+		FaceCacheDir:   *faceCache,
+		FastMode:       *fastMode,
+		Bandwidth:      *bandwidth,
+		ForwardEnergy:  *forwardEnergy,
+		Quality:        *qualityPreset,
+		AutoTune:       *autoTune,
+		CPULimit:       limit,
+		Timeout:        *timeout,
+		PartialOutput:  *partialOutput,
+		MaxPixels:      *maxPixels,
+		MaxDecodeMem:   *maxDecodeMem << 20,
+		Preflight:      *preflight,
+		Strict:         *strict,
+		Adjustments: caire.Adjustments{
+			Brightness: *brightness,
+			Contrast:   *contrast,
+			Saturation: *saturation,
+		},
+		MaxSeamsPerRegion:   *maxSeams,
+		JPEGProgressive:     *progressive,
+		JPEGCMYK:            *cmyk,
+		ColorBlockKeep:      *colorBlocks,
+		WeightMaskFeather:   *maskFeather,
+		AutoMethod:          *autoMethod,
+		MinReductionPercent: *minReduction,
+		RecordPath:          *recordPath,
+		HighContrast:        *highContrast,
+		PreviewBackground:   *previewBg,
+		SimilarityThreshold: *similarity,
+		StrictSimilarity:    *strictSimilar,
+		ProtectColor:        *protectColor,
+		RemoveColor:         *removeColor,
+		ColorTolerance:      *tolerance,
+		FixedPoint:          *fixedPoint,
+	}
+
+	fetcher := utils.NewFetcher()
+	fetcher.Timeout = *fetchTimeout
+	fetcher.Retries = *fetchRetries
+	fetcher.MaxSize = *fetchMaxSize << 20
+	fetcher.CacheDir = *fetchCache
+
+	if len(*watermarkPath) > 0 {
+		proc.Watermark = &caire.Watermark{
+			Path:    *watermarkPath,
+			Anchor:  *wmAnchor,
+			Margin:  *wmMargin,
+			Opacity: *wmOpacity,
+		}
 	}
 
-	if !(*newWidth > 0 || *newHeight > 0 || *percentage || *square) {
+	if *autoAxis && !(*targetPixels > 0 || (*targetRatio > 0 && *targetRatio < 1)) {
+		flag.Usage()
+		log.Fatal(utils.DecorateText("\nPlease provide a pixel budget or a ratio between 0 and 1 for the auto axis option!", utils.ErrorMessage))
+	}
+
+	if !(*newWidth > 0 || *newHeight > 0 || *percentage || *square || *autoAxis) {
 		flag.Usage()
 		log.Fatal(fmt.Sprintf("%s%s",
 			utils.DecorateText("\nPlease provide a width, height or percentage for image rescaling!", utils.ErrorMessage),
 			utils.DefaultColor,
 		))
 	} else {
-		op := &caire.Ops{
-			Src:      *source,
-			Dst:      *destination,
-			Workers:  *workers,
-			PipeName: pipeName,
+		r := &caire.Runner{
+			Processor:   proc,
+			Src:         *source,
+			Dst:         *destination,
+			Workers:     *workers,
+			SeamWorkers: *seamWorkers,
+			Fetcher:     fetcher,
 		}
 
 		if *preview {
-			// When the preview mode is activated we have to execute the resizing process
-			// in a separate goroutine in order to not block the Gio thread,
-			// which have to run on the main OS thread of the operating systems like MacOS.
-			go proc.Execute(op)
-			app.Main()
+			runGUI(func() { execute(proc, r) })
 		} else {
-			proc.Execute(op)
+			execute(proc, r)
+		}
+	}
+}
+
+// parseWeights parses the comma separated R,G,B channel weights.
+func parseWeights(s string) ([3]float64, error) {
+	var weights [3]float64
+	if s == "" {
+		return weights, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != len(weights) {
+		return weights, fmt.Errorf("invalid channel weights %q: three comma separated values are expected", s)
+	}
+	for i, part := range parts {
+		w, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || w < 0 {
+			return weights, fmt.Errorf("invalid channel weight: %q", part)
 		}
+		weights[i] = w
+	}
+	return weights, nil
+}
+
+// parseGrid parses the grid dimension provided in COLSxROWS format.
+func parseGrid(s string) (*caire.Grid, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var cols, rows int
+	if _, err := fmt.Sscanf(s, "%dx%d", &cols, &rows); err != nil || cols < 1 || rows < 1 {
+		return nil, fmt.Errorf("invalid grid %q: the COLSxROWS format is expected", s)
 	}
+	return &caire.Grid{Cols: cols, Rows: rows}, nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runMask generates a protection mask from the detected faces.
+func runMask(args []string) {
+	fs := flag.NewFlagSet("mask", flag.ExitOnError)
+	source := fs.String("in", "", "Source image")
+	destination := fs.String("out", "", "Destination mask (PNG)")
+	faceDetect := fs.Bool("face", false, "Protect the detected faces")
+	faceAngle := fs.Float64("angle", 0.0, "Face rotation angle")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire mask -face -in <image> -out <mask.png> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" || *destination == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if !*faceDetect {
+		log.Fatal(utils.DecorateText("At least one detector should be enabled, use the -face flag", utils.ErrorMessage))
+	}
+
+	img, err := decodeImage(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	proc := &caire.Processor{FaceAngle: *faceAngle}
+	mask, err := proc.FaceMask(img)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	f, err := os.Create(*destination)
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to create the mask file: %v", err), utils.ErrorMessage))
+	}
+	if err := png.Encode(f, mask); err != nil {
+		f.Close()
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to encode the mask: %v", err), utils.ErrorMessage))
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage), *source, *destination)
+}
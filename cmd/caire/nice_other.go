@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// setNice changes the scheduling priority of the process.
+func setNice(n int) error {
+	return errors.New("the process priority cannot be changed on " + runtime.GOOS)
+}
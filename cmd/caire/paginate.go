@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runPaginate splits a wide image into pages, cutting along the lowest energy seams.
+func runPaginate(args []string) {
+	fs := flag.NewFlagSet("paginate", flag.ExitOnError)
+	source := fs.String("in", "", "Source image")
+	destination := fs.String("out", ".", "Destination directory of the pages")
+	width := fs.Int("width", 1080, "Page width")
+	pageCount := fs.String("pages", "auto", "Number of pages, or auto for the fewest pages leaving room for the cuts")
+	fill := fs.String("fill", "", "Color of the page areas beyond the cuts as #rgb or #rrggbb (transparent by default, white for JPEG)")
+	ext := fs.String("ext", "", "Extension of the pages, defining their format (defaults to the source extension)")
+	blurRadius := fs.Int("blur", 4, "Blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Sobel filter threshold")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire paginate -in <image> -width <px> [-pages <n|auto>] [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var pages int
+	if *pageCount != "auto" {
+		n, err := strconv.Atoi(*pageCount)
+		if err != nil || n <= 0 {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("Invalid number of pages %q: a positive number or auto is expected", *pageCount), utils.ErrorMessage))
+		}
+		pages = n
+	}
+	if *ext == "" {
+		*ext = filepath.Ext(*source)
+	}
+	if !strings.HasPrefix(*ext, ".") {
+		*ext = "." + *ext
+	}
+
+	var bg color.Color
+	switch {
+	case *fill != "":
+		c, err := caire.ParseColorKey(*fill)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		bg = c
+	case strings.EqualFold(*ext, ".jpg") || strings.EqualFold(*ext, ".jpeg"):
+		// The JPEG format has no transparency.
+		bg = color.White
+	}
+
+	img, err := decodeImage(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	proc := &caire.Processor{
+		BlurRadius:     *blurRadius,
+		SobelThreshold: *sobelThreshold,
+	}
+	result, err := proc.Paginate(img, *width, pages)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	if err := os.MkdirAll(*destination, 0755); err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to create the destination directory: %v", err), utils.ErrorMessage))
+	}
+	name := strings.TrimSuffix(filepath.Base(*source), filepath.Ext(*source))
+	for i, page := range result {
+		out := image.Image(page.Image)
+		if bg != nil {
+			dst := image.NewNRGBA(page.Image.Bounds())
+			draw.Draw(dst, dst.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+			draw.Draw(dst, dst.Bounds(), page.Image, image.Point{}, draw.Over)
+			out = dst
+		}
+		file := filepath.Join(*destination, fmt.Sprintf("%s-%d%s", name, i+1, *ext))
+		if err := imaging.Save(out, file); err != nil {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to save the page: %v", err), utils.ErrorMessage))
+		}
+		fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage), *source, file)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"os"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/pdf"
+	"github.com/esimov/caire/utils"
+)
+
+// runPdf resizes the raster images embedded in a PDF document.
+func runPdf(args []string) {
+	fs := flag.NewFlagSet("pdf", flag.ExitOnError)
+	source := fs.String("in", "", "Source PDF document")
+	destination := fs.String("out", "", "Destination PDF document")
+	newWidth := fs.Int("width", 0, "New width of the images (the narrower images are left unchanged)")
+	blurRadius := fs.Int("blur", 4, "Blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Sobel filter threshold")
+	faceDetect := fs.Bool("face", false, "Use face detection")
+	quality := fs.Int("quality", pdf.DefaultQuality, "JPEG quality of the resized images")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire pdf -in <input.pdf> -out <output.pdf> -width <width> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" || *destination == "" || *newWidth <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to read the document: %v", err), utils.ErrorMessage))
+	}
+
+	f, err := os.Create(*destination)
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to create the document: %v", err), utils.ErrorMessage))
+	}
+
+	res, err := pdf.Resize(data, f, *quality, func(img image.Image) (image.Image, error) {
+		bounds := img.Bounds()
+		if bounds.Dx() <= *newWidth {
+			return nil, nil
+		}
+		src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+		// The processor keeps state between the resize operations, so a new one is used for each image.
+		proc := &caire.Processor{
+			BlurRadius:     *blurRadius,
+			SobelThreshold: *sobelThreshold,
+			NewWidth:       *newWidth,
+			FaceDetect:     *faceDetect,
+		}
+		dst, err := proc.Resize(src)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := img.(*image.Gray); ok {
+			gray := image.NewGray(dst.Bounds())
+			draw.Draw(gray, gray.Bounds(), dst, dst.Bounds().Min, draw.Src)
+			return gray, nil
+		}
+		return dst, nil
+	})
+	if err != nil {
+		f.Close()
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ⇢ %s (%d of %d images resized, %d unsupported)\n",
+		utils.DecorateText("✔", utils.SuccessMessage), *source, *destination, res.Resized, res.Images, res.Skipped,
+	)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runPipeline executes the operations described in the pipeline file on each input image.
+func runPipeline(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	destination := fs.String("out", ".", "Destination directory")
+	ext := fs.String("ext", "", "Output image format (defaults to the input format)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire run [options] <pipeline.yaml> <inputs...>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	spec, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to read the pipeline file: %v", err), utils.ErrorMessage))
+	}
+	pl, err := caire.ParsePipeline(spec)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	format, err := caire.ParseFormat(*ext)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	if err := os.MkdirAll(*destination, 0755); err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to create the destination directory: %v", err), utils.ErrorMessage))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var failed int
+	for _, in := range fs.Args()[1:] {
+		out := outputPath(in, *destination, *ext)
+		if err := processPipeline(ctx, pl, in, out, format); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", utils.DecorateText("✘", utils.ErrorMessage), in, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage), in, out)
+	}
+
+	if failed > 0 {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("%d image(s) could not be processed", failed), utils.ErrorMessage))
+	}
+}
+
+// outputPath returns the destination file of the input image, replacing its extension if needed.
+func outputPath(in, dir, ext string) string {
+	name := filepath.Base(in)
+	if ext != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + strings.TrimPrefix(ext, ".")
+	}
+	return filepath.Join(dir, name)
+}
+
+// processPipeline runs the pipeline on a single image file.
+func processPipeline(ctx context.Context, pl *caire.Pipeline, in, out, format string) error {
+	if abs, err := filepath.Abs(in); err == nil {
+		if absOut, err := filepath.Abs(out); err == nil && abs == absOut {
+			return fmt.Errorf("the output file would overwrite the input file")
+		}
+	}
+	if format == "" {
+		var err error
+		if format, err = caire.ParseFormat(filepath.Ext(in)); err != nil {
+			return err
+		}
+	}
+
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := pl.Process(ctx, src, dst, format); err != nil {
+		dst.Close()
+		os.Remove(out)
+		return err
+	}
+	return dst.Close()
+}
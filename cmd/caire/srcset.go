@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runSrcSet generates the variants of a responsive image and describes them in a sidecar file.
+func runSrcSet(args []string) {
+	fs := flag.NewFlagSet("srcset", flag.ExitOnError)
+	source := fs.String("in", "", "Source image")
+	destination := fs.String("out", ".", "Destination directory of the variants")
+	widthList := fs.String("widths", "", "Comma separated list of the variant widths (ex. 320,640,1024)")
+	ratio := fs.String("ratio", "", "Aspect ratio of the variants as W:H, reached by seam carving (defaults to the source ratio)")
+	sidecar := fs.String("sidecar", "", "Sidecar file describing the variants: .json or .html (with the Open Graph tags)")
+	baseURL := fs.String("url", "", "Base URL of the variants used in the sidecar (defaults to the file names)")
+	sizes := fs.String("sizes", "", "Value of the sizes attribute (ex. \"(max-width: 600px) 100vw, 50vw\")")
+	alt := fs.String("alt", "", "Alternative text of the image")
+	blurRadius := fs.Int("blur", 4, "Blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Sobel filter threshold")
+	faceDetect := fs.Bool("face", false, "Use face detection")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire srcset -in <image> -widths <w1,w2,...> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *source == "" || *widthList == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	widths, err := caire.ParseWidths(*widthList)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+
+	img, err := decodeImage(*source)
+	if err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// The srcset variants should have the same aspect ratio, so the image is carved only once
+	// to the requested ratio, then the result is scaled down to each width.
+	base := image.Image(src)
+	if *ratio != "" {
+		r, err := caire.ParseRatio(*ratio)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		proc := &caire.Processor{
+			BlurRadius:     *blurRadius,
+			SobelThreshold: *sobelThreshold,
+			FaceDetect:     *faceDetect,
+		}
+		if float64(src.Bounds().Dx())/float64(src.Bounds().Dy()) > r {
+			proc.NewWidth = int(math.Round(float64(src.Bounds().Dy()) * r))
+		} else {
+			proc.NewHeight = int(math.Round(float64(src.Bounds().Dx()) / r))
+		}
+		if base, err = proc.Resize(src); err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+	}
+
+	if err := os.MkdirAll(*destination, 0755); err != nil {
+		log.Fatal(utils.DecorateText(fmt.Sprintf("Failed to create the destination directory: %v", err), utils.ErrorMessage))
+	}
+
+	ext := filepath.Ext(*source)
+	name := strings.TrimSuffix(filepath.Base(*source), ext)
+
+	var variants []caire.ImageVariant
+	for _, w := range widths {
+		if w > base.Bounds().Dx() {
+			fmt.Fprintf(os.Stderr, "%s the %dpx variant is skipped, since it's wider than the image\n",
+				utils.DecorateText("!", utils.StatusMessage), w)
+			continue
+		}
+		file := fmt.Sprintf("%s-%d%s", name, w, ext)
+		variant := imaging.Resize(base, w, 0, imaging.Lanczos)
+		if err := imaging.Save(variant, filepath.Join(*destination, file)); err != nil {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to save the variant: %v", err), utils.ErrorMessage))
+		}
+
+		url := file
+		if *baseURL != "" {
+			url = strings.TrimSuffix(*baseURL, "/") + "/" + path.Base(file)
+		}
+		variants = append(variants, caire.ImageVariant{
+			URL:    url,
+			Width:  variant.Bounds().Dx(),
+			Height: variant.Bounds().Dy(),
+		})
+		fmt.Fprintf(os.Stderr, "%s %s ⇢ %s\n", utils.DecorateText("✔", utils.SuccessMessage),
+			*source, filepath.Join(*destination, file))
+	}
+	if len(variants) == 0 {
+		log.Fatal(utils.DecorateText("No variants have been generated", utils.ErrorMessage))
+	}
+
+	if *sidecar != "" {
+		f, err := os.Create(*sidecar)
+		if err != nil {
+			log.Fatal(utils.DecorateText(fmt.Sprintf("Unable to create the sidecar file: %v", err), utils.ErrorMessage))
+		}
+		if err := caire.NewResponsiveImage(variants, *sizes, *alt).WriteSidecar(f, *sidecar); err != nil {
+			f.Close()
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// runVersion prints the version and the capabilities of the build.
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the version and the capabilities as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire version [-json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	caps := caire.Capabilities()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Version string `json:"version"`
+			caire.BuildCapabilities
+		}{Version, caps})
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		return
+	}
+
+	fmt.Printf("caire %s (%s, %s/%s)\n", Version, caps.GoVersion, caps.OS, caps.Arch)
+	fmt.Printf("backends:       %s\n", strings.Join(caps.Backends, ", "))
+	fmt.Printf("formats:        %s\n", strings.Join(caps.Formats, ", "))
+	fmt.Printf("gui:            %v\n", caps.GUI)
+	fmt.Printf("face detection: %v\n", caps.FaceDetection)
+	fmt.Printf("webp:           %v\n", caps.WebP)
+	fmt.Printf("heic:           %v\n", caps.HEIC)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/server"
+	"github.com/esimov/caire/utils"
+)
+
+// runWorker starts an HTTP server which resizes the images received from a dispatcher.
+func runWorker(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	blurRadius := fs.Int("blur", 4, "Default blur radius")
+	sobelThreshold := fs.Int("sobel", 2, "Default sobel filter threshold")
+	faceDetect := fs.Bool("face", false, "Use face detection by default")
+	faceAngle := fs.Float64("angle", 0.0, "Default face rotation angle")
+	maxPixels := fs.Int64("max-pixels", caire.DefaultMaxPixels, "Reject the images having more pixels (-1 disables the limit)")
+	maxDecodeMem := fs.Int64("max-decode-mem", 0, "Memory budget of the decoded image in MB: the larger JPEGs are downscaled while decoding, the other images are rejected (0 disables the limit)")
+	maxUpload := fs.Int64("max-upload", server.DefaultMaxUploadSize>>20, "Maximum size of the uploaded images in MB")
+	resultTTL := fs.Duration("result-ttl", server.DefaultResultTTL, "Duration the images resized by the streamed requests are kept for download")
+	jobsPath := fs.String("jobs", "", "Path of the job database enabling the persistent job queue")
+	configPath := fs.String("config", "", "Access configuration file (YAML or JSON): locked options, API keys and rate limits")
+	jobAttempts := fs.Int("job-attempts", server.DefaultMaxAttempts, "Number of times a failed job is tried")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, HelpBanner, Version)
+		fmt.Fprintln(os.Stderr, "Usage: caire worker [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	wk := server.NewWorker(caire.Processor{
+		BlurRadius:     *blurRadius,
+		SobelThreshold: *sobelThreshold,
+		FaceDetect:     *faceDetect,
+		FaceAngle:      *faceAngle,
+		MaxPixels:      *maxPixels,
+		MaxDecodeMem:   *maxDecodeMem << 20,
+	})
+	wk.ResultTTL = *resultTTL
+	wk.MaxUploadSize = *maxUpload << 20
+	if *configPath != "" {
+		cfg, err := server.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		if err := cfg.Apply(wk); err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The queued jobs are processed in the background, while the server accepts new jobs.
+	queueDone := make(chan error, 1)
+	if *jobsPath != "" {
+		jq, err := server.OpenJobQueue(*jobsPath, wk)
+		if err != nil {
+			log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+		}
+		defer jq.Close()
+		jq.MaxAttempts = *jobAttempts
+
+		go func() { queueDone <- jq.Run(ctx) }()
+	} else {
+		close(queueDone)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: wk}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "⚡ CAIRE worker listening on %s\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+	if err := <-queueDone; err != nil {
+		log.Fatal(utils.DecorateText(err.Error(), utils.ErrorMessage))
+	}
+}
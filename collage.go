@@ -0,0 +1,185 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// maxCollageImages is the maximum number of images of a collage, since all the splits of
+// the images into rows are enumerated.
+const maxCollageImages = 16
+
+// collageCandidates is the number of the best fitting layouts whose distortion is estimated.
+const collageCandidates = 3
+
+// Placement is the position of an image in a collage.
+type Placement struct {
+	// Index is the index of the image in the list provided to Collage.
+	Index int
+	// Rect is the area of the canvas covered by the image.
+	Rect image.Rectangle
+	// Image is the image carved to the aspect ratio of the area and scaled to its size.
+	Image *image.NRGBA
+	// Distortion is the estimated distortion of the carving (the mean energy of the removed pixels).
+	Distortion float64
+}
+
+// collageLayout splits the images into consecutive rows. Filling the canvas width with
+// each row, the rows are scaled by the same factor to fill the canvas height, which
+// requires changing the aspect ratio of all the images by the same factor.
+type collageLayout struct {
+	// rows holds the index of the first image of each row.
+	rows []int
+	// factor is the change of the aspect ratio of the images: below 1 the images are
+	// narrowed, above 1 they are flattened.
+	factor float64
+}
+
+// Collage packs the images into a canvas of the provided size without overlaps, keeping their order.
+// The images are laid out in rows, and carved just enough to fill the canvas: among the splits
+// of the images into rows, the ones requiring the smallest changes of the aspect ratios are
+// retained, then the one with the least estimated distortion is selected. The images are carved to
+// their new aspect ratio, then scaled to the size of their area, so the resizing options of the
+// processor (like the face detection) are used for the carving.
+func (p *Processor) Collage(images []image.Image, width, height int) ([]Placement, error) {
+	if len(images) == 0 || len(images) > maxCollageImages {
+		return nil, fmt.Errorf("a collage of 1 to %d images is expected, got %d", maxCollageImages, len(images))
+	}
+	if width < len(images) || height < len(images) {
+		return nil, fmt.Errorf("the %dx%d canvas is too small for %d images", width, height, len(images))
+	}
+	src := make([]*image.NRGBA, len(images))
+	ratios := make([]float64, len(images))
+	for i, img := range images {
+		src[i] = p.imgToNRGBA(img)
+		w, h := src[i].Bounds().Dx(), src[i].Bounds().Dy()
+		if w < minImageSize || h < minImageSize {
+			return nil, fmt.Errorf("the image %d is too small: %dx%d", i+1, w, h)
+		}
+		ratios[i] = float64(w) / float64(h)
+	}
+
+	// Each of the consecutive images can start a new row.
+	var layouts []collageLayout
+	for mask := 0; mask < 1<<(len(images)-1); mask++ {
+		l := collageLayout{rows: []int{0}}
+		for i := 1; i < len(images); i++ {
+			if mask&(1<<(i-1)) != 0 {
+				l.rows = append(l.rows, i)
+			}
+		}
+		// The height of the rows filling the canvas width, keeping the aspect ratios.
+		var natural float64
+		for _, row := range l.rowRatios(ratios) {
+			natural += float64(width) / row
+		}
+		l.factor = natural / float64(height)
+		layouts = append(layouts, l)
+	}
+	sort.SliceStable(layouts, func(i, j int) bool {
+		return math.Abs(math.Log(layouts[i].factor)) < math.Abs(math.Log(layouts[j].factor))
+	})
+
+	var (
+		best     []Placement
+		bestCost = math.Inf(1)
+	)
+	for _, l := range layouts[:min(collageCandidates, len(layouts))] {
+		placements := l.place(ratios, width, height)
+		var cost float64
+		for i, pl := range placements {
+			w, h := src[i].Bounds().Dx(), src[i].Bounds().Dy()
+			cw, ch := collageCarveSize(w, h, pl.Rect)
+			pl.Distortion = p.estimateDistortion(src[i], cw, ch)
+			placements[i] = pl
+			// The distortion of the larger areas is more visible.
+			cost += pl.Distortion * float64(pl.Rect.Dx()*pl.Rect.Dy())
+		}
+		if cost < bestCost {
+			best, bestCost = placements, cost
+		}
+	}
+
+	for i, pl := range best {
+		w, h := src[i].Bounds().Dx(), src[i].Bounds().Dy()
+		img := src[i]
+		if cw, ch := collageCarveSize(w, h, pl.Rect); cw != w || ch != h {
+			proc := p.Clone()
+			proc.NewWidth, proc.NewHeight = 0, 0
+			if cw != w {
+				proc.NewWidth = cw
+			} else {
+				proc.NewHeight = ch
+			}
+			res, err := proc.Resize(img)
+			if err != nil {
+				return nil, fmt.Errorf("unable to carve the image %d: %w", i+1, err)
+			}
+			img = p.imgToNRGBA(res)
+		}
+		best[i].Image = imaging.Resize(img, pl.Rect.Dx(), pl.Rect.Dy(), imaging.Lanczos)
+	}
+	return best, nil
+}
+
+// rowRatios returns the aspect ratio of each row, the sum of the aspect ratios of its images.
+func (l collageLayout) rowRatios(ratios []float64) []float64 {
+	rows := make([]float64, len(l.rows))
+	for r, first := range l.rows {
+		last := len(ratios)
+		if r+1 < len(l.rows) {
+			last = l.rows[r+1]
+		}
+		for _, ratio := range ratios[first:last] {
+			rows[r] += ratio
+		}
+	}
+	return rows
+}
+
+// place returns the area of each image on the canvas. The row heights and the image widths
+// are proportional to their natural size, and rounded cumulatively, so the canvas is covered.
+func (l collageLayout) place(ratios []float64, width, height int) []Placement {
+	rows := l.rowRatios(ratios)
+	var natural float64
+	for _, row := range rows {
+		natural += 1 / row
+	}
+
+	placements := make([]Placement, 0, len(ratios))
+	var y float64
+	for r, first := range l.rows {
+		last := len(ratios)
+		if r+1 < len(l.rows) {
+			last = l.rows[r+1]
+		}
+		y0 := int(math.Round(y))
+		y += float64(height) / rows[r] / natural
+		y1 := int(math.Round(y))
+
+		var x float64
+		for i := first; i < last; i++ {
+			x0 := int(math.Round(x))
+			x += float64(width) * ratios[i] / rows[r]
+			placements = append(placements, Placement{
+				Index: i,
+				Rect:  image.Rect(x0, y0, int(math.Round(x)), y1),
+			})
+		}
+	}
+	return placements
+}
+
+// collageCarveSize returns the size the image is carved to, reaching the aspect ratio of the
+// area by reducing either its width or its height.
+func collageCarveSize(w, h int, area image.Rectangle) (int, int) {
+	ratio := float64(area.Dx()) / float64(area.Dy())
+	if float64(w)/float64(h) > ratio {
+		return max(minImageSize, int(math.Round(float64(h)*ratio))), h
+	}
+	return w, max(minImageSize, int(math.Round(float64(w)/ratio)))
+}
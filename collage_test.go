@@ -0,0 +1,90 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collageImage returns a noisy image of the provided size.
+func collageImage(rnd *rand.Rand, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := uint8(rnd.Intn(256))
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 0xff
+	}
+	return img
+}
+
+func TestCollage_ShouldPackTheImages(t *testing.T) {
+	assert := assert.New(t)
+
+	rnd := rand.New(rand.NewSource(1))
+	images := []image.Image{
+		collageImage(rnd, 80, 60),
+		collageImage(rnd, 60, 60),
+		collageImage(rnd, 120, 60),
+		collageImage(rnd, 60, 80),
+	}
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1}
+
+	placements, err := proc.Collage(images, 200, 150)
+	assert.NoError(err)
+	assert.Len(placements, len(images))
+
+	// The images cover the whole canvas, without overlaps.
+	canvas := image.Rect(0, 0, 200, 150)
+	var area int
+	for i, pl := range placements {
+		assert.Equal(i, pl.Index)
+		assert.True(pl.Rect.In(canvas), "the area %v exceeds the canvas", pl.Rect)
+		assert.Equal(pl.Rect.Size(), pl.Image.Bounds().Size())
+		assert.GreaterOrEqual(pl.Distortion, 0.0)
+		area += pl.Rect.Dx() * pl.Rect.Dy()
+		for _, other := range placements[i+1:] {
+			assert.True(pl.Rect.Intersect(other.Rect).Empty(), "the areas %v and %v overlap", pl.Rect, other.Rect)
+		}
+	}
+	assert.Equal(200*150, area)
+}
+
+func TestCollage_ShouldKeepTheFittingImages(t *testing.T) {
+	assert := assert.New(t)
+
+	// Two images side by side fill the canvas exactly, so they are only scaled.
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.SetNRGBA(10, 10, color.NRGBA{A: 0xff})
+	proc := &Processor{SobelThreshold: 2}
+
+	placements, err := proc.Collage([]image.Image{img, img}, 160, 80)
+	assert.NoError(err)
+	assert.Len(placements, 2)
+	assert.Equal(image.Rect(0, 0, 80, 80), placements[0].Rect)
+	assert.Equal(image.Rect(80, 0, 160, 80), placements[1].Rect)
+	assert.Zero(placements[0].Distortion)
+	assert.Less(placements[0].Image.NRGBAAt(20, 20).R, uint8(0x80))
+}
+
+func TestCollage_ShouldRejectTheInvalidInput(t *testing.T) {
+	assert := assert.New(t)
+
+	proc := &Processor{SobelThreshold: 2}
+	_, err := proc.Collage(nil, 100, 100)
+	assert.Error(err)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	images := make([]image.Image, maxCollageImages+1)
+	for i := range images {
+		images[i] = img
+	}
+	_, err = proc.Collage(images, 1000, 1000)
+	assert.Error(err)
+	_, err = proc.Collage(images[:3], 2, 100)
+	assert.Error(err)
+}
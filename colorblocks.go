@@ -0,0 +1,181 @@
+package caire
+
+import (
+	"encoding/binary"
+	"image"
+
+	"github.com/esimov/caire/utils"
+)
+
+const (
+	// colorBlockTolerance is the maximum difference of the color channels from the color
+	// of the first pixel of a block, for which a pixel is considered part of the block.
+	colorBlockTolerance = 12
+	// minColorBlockShare is the minimum area of the color blocks, relative to the image area.
+	minColorBlockShare = 0.01
+	// minColorBlockArea is the minimum area of the color blocks in pixels.
+	minColorBlockArea = 64
+)
+
+// colorBlocks tracks the area of the large homogeneous color regions (like the brand color blocks
+// of the marketing images) while carving, for the ColorBlockKeep option. The flat regions have no
+// energy, so without protection the seams would remove them entirely.
+type colorBlocks struct {
+	// labels holds the 1 based index of the block of each pixel (0 outside of the blocks), encoded
+	// in the four bytes of the pixels, so the labels are rotated together with the image.
+	labels *image.NRGBA
+	// area is the original area of the blocks, while left is their remaining area.
+	area, left []int
+	// span is the longer side of the bounding box of the blocks, the maximum
+	// number of pixels removed from a block by a single seam.
+	span []int
+}
+
+// findColorBlocks segments the image into homogeneous color regions by flood filling,
+// and returns the regions larger than the minimum color block area.
+func findColorBlocks(img *image.NRGBA) *colorBlocks {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	minArea := max(int(float64(width*height)*minColorBlockShare), minColorBlockArea)
+
+	blocks := &colorBlocks{labels: image.NewNRGBA(image.Rect(0, 0, width, height))}
+	visited := make([]bool, width*height)
+	var stack, pixels []int
+
+	for start := range visited {
+		if visited[start] {
+			continue
+		}
+		seed := img.Pix[img.PixOffset(b.Min.X+start%width, b.Min.Y+start/width):]
+		stack, pixels = append(stack[:0], start), pixels[:0]
+		visited[start] = true
+		minX, minY, maxX, maxY := width, height, 0, 0
+
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			pixels = append(pixels, i)
+			x, y := i%width, i/width
+			minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[0] >= width || n[1] < 0 || n[1] >= height || visited[n[1]*width+n[0]] {
+					continue
+				}
+				px := img.Pix[img.PixOffset(b.Min.X+n[0], b.Min.Y+n[1]):]
+				if colorDist(seed, px) <= colorBlockTolerance {
+					visited[n[1]*width+n[0]] = true
+					stack = append(stack, n[1]*width+n[0])
+				}
+			}
+		}
+		if len(pixels) < minArea {
+			continue
+		}
+
+		blocks.area = append(blocks.area, len(pixels))
+		blocks.span = append(blocks.span, max(maxX-minX, maxY-minY)+1)
+		label := uint32(len(blocks.area))
+		for _, i := range pixels {
+			binary.LittleEndian.PutUint32(blocks.labels.Pix[i*4:], label)
+		}
+	}
+	blocks.left = append([]int(nil), blocks.area...)
+	return blocks
+}
+
+// trackColorBlocks segments the image at the first seam, since the image might be
+// rescaled before carving, while the labels are carried along with the image afterwards.
+func (p *Processor) trackColorBlocks(img *image.NRGBA) {
+	if p.ColorBlockKeep > 0 && p.blocks == nil {
+		p.blocks = findColorBlocks(img)
+	}
+}
+
+// colorDist returns the maximum difference of the RGB channels of the two pixels.
+func colorDist(a, b []uint8) int {
+	d := 0
+	for k := 0; k < 3; k++ {
+		d = max(d, utils.Abs(int(a[k])-int(b[k])))
+	}
+	return d
+}
+
+// label returns the block index of the pixel, or -1 outside of the blocks.
+func (b *colorBlocks) label(x, y int) int {
+	return int(binary.LittleEndian.Uint32(b.labels.Pix[b.labels.PixOffset(x, y):])) - 1
+}
+
+// remove decrements the remaining area of the blocks crossed by the seam and removes its pixels.
+func (b *colorBlocks) remove(seams []Seam) {
+	if b == nil {
+		return
+	}
+	b.update(seams, -1)
+	b.labels = (&Carver{}).RemoveSeam(b.labels, seams, false)
+}
+
+// insert increments the remaining area of the blocks crossed by the seam and duplicates its pixels.
+// The pixels are duplicated instead of averaged with their neighbours, since they are holding labels.
+func (b *colorBlocks) insert(seams []Seam) {
+	if b == nil {
+		return
+	}
+	b.update(seams, 1)
+
+	width, height := b.labels.Bounds().Dx(), b.labels.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width+1, height))
+	for _, s := range seams {
+		src := b.labels.Pix[s.Y*b.labels.Stride : s.Y*b.labels.Stride+width*4]
+		row := dst.Pix[s.Y*dst.Stride : s.Y*dst.Stride+(width+1)*4]
+		copy(row, src[:(s.X+1)*4])
+		copy(row[(s.X+1)*4:], src[s.X*4:])
+	}
+	b.labels = dst
+}
+
+// update adds the delta to the remaining area of the blocks for each seam pixel inside them.
+func (b *colorBlocks) update(seams []Seam, delta int) {
+	for _, s := range seams {
+		if l := b.label(s.X, s.Y); l >= 0 {
+			b.left[l] += delta
+		}
+	}
+}
+
+// rotate rotates the labels together with the image.
+func (b *colorBlocks) rotate(fn func(*image.NRGBA) *image.NRGBA) {
+	if b != nil {
+		b.labels = fn(b.labels)
+	}
+}
+
+// applyColorBlocks raises the energy of the color blocks to the maximum once the next seam
+// could reduce their area below the ColorBlockKeep share of their original area, so
+// each color block keeps at least this share, instead of being carved away entirely.
+func (p *Processor) applyColorBlocks(sobel *image.NRGBA) {
+	b := p.blocks
+	bounds := sobel.Bounds()
+	if b == nil || b.labels.Bounds().Size() != bounds.Size() {
+		return
+	}
+	protected := make([]bool, len(b.area))
+	var any bool
+	for l := range b.area {
+		protected[l] = float64(b.left[l]-b.span[l]) < p.ColorBlockKeep*float64(b.area[l])
+		any = any || protected[l]
+	}
+	if !any {
+		return
+	}
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if l := b.label(x, y); l < 0 || !protected[l] {
+				continue
+			}
+			i := sobel.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			sobel.Pix[i], sobel.Pix[i+1], sobel.Pix[i+2] = 0xff, 0xff, 0xff
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// brandImage returns a noisy image with a flat red block of 40 columns on the left side.
+func brandImage() *image.NRGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 120, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 120; x++ {
+			v := uint8(90 + 20*rnd.Intn(3))
+			c := color.NRGBA{v, v, v, 255}
+			if x < 40 {
+				c = color.NRGBA{R: 220, G: 20, B: 40, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// countRed returns the number of pixels having the color of the red block in the image.
+func countRed(img *image.NRGBA) int {
+	n := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if colorDist(img.Pix[i:], []uint8{220, 20, 40}) <= colorBlockTolerance {
+			n++
+		}
+	}
+	return n
+}
+
+func TestColorBlocks_ShouldFindTheBlocks(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := findColorBlocks(brandImage())
+	assert.Equal([]int{40 * 60}, blocks.area)
+	assert.Equal([]int{60}, blocks.span)
+	assert.Equal(0, blocks.label(10, 10))
+	assert.Equal(-1, blocks.label(80, 10))
+}
+
+func TestColorBlocks_ShouldKeepTheBlockArea(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 70}
+	res, err := p.Resize(brandImage())
+	assert.NoError(err)
+	// Without protection the flat block is removed first.
+	assert.Less(countRed(res.(*image.NRGBA)), 40*60/2)
+
+	p = &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 70, ColorBlockKeep: 0.5}
+	res, err = p.Resize(brandImage())
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 70, 60), res.Bounds())
+	assert.GreaterOrEqual(countRed(res.(*image.NRGBA)), 40*60/2)
+
+	// The image is rescaled before carving when both sides are resized.
+	p = &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 70, NewHeight: 50, ColorBlockKeep: 0.5}
+	res, err = p.Resize(brandImage())
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 70, 50), res.Bounds())
+	assert.GreaterOrEqual(countRed(res.(*image.NRGBA)), p.blocks.area[0]/2)
+}
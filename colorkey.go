@@ -0,0 +1,75 @@
+package caire
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/esimov/caire/utils"
+)
+
+// ParseColorKey parses the key color of a mask, expressed as a hexadecimal color (#rgb or #rrggbb).
+func ParseColorKey(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if n := len(hex); n != 3 && n != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid key color: %q, expected a hex color (#rgb or #rrggbb)", s)
+	}
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid key color: %q, expected a hex color (#rgb or #rrggbb)", s)
+	}
+	return utils.HexToRGBA(hex), nil
+}
+
+// ColorKeyMask returns a binary mask of the image, which is white where the pixels match the key
+// color and black elsewhere. A pixel matches if none of its color channels differs from the key
+// color by more than the tolerance, this way the anti-aliased brush strokes and the compression
+// artifacts of the painted images are covered too. The transparent pixels never match.
+func ColorKeyMask(img *image.NRGBA, key color.NRGBA, tolerance int) *image.NRGBA {
+	b := img.Bounds()
+	mask := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			i, j := img.PixOffset(x+b.Min.X, y+b.Min.Y), mask.PixOffset(x, y)
+			px := img.Pix[i : i+4 : i+4]
+
+			v := uint8(0)
+			if px[3] > 0 &&
+				utils.Abs(int(px[0])-int(key.R)) <= tolerance &&
+				utils.Abs(int(px[1])-int(key.G)) <= tolerance &&
+				utils.Abs(int(px[2])-int(key.B)) <= tolerance {
+				v = 0xff
+			}
+			mask.Pix[j], mask.Pix[j+1], mask.Pix[j+2], mask.Pix[j+3] = v, v, v, 0xff
+		}
+	}
+	return mask
+}
+
+// validateColorKeys checks the key colors of the masks and the tolerance. The key colors are
+// applied to the mask files, which are expected to be copies of the image painted with them.
+func (p *Processor) validateColorKeys() error {
+	if p.ProtectColor != "" {
+		if p.MaskPath == "" {
+			return errors.New("the protect color requires a mask file, painted with the key color")
+		}
+		if _, err := ParseColorKey(p.ProtectColor); err != nil {
+			return err
+		}
+	}
+	if p.RemoveColor != "" {
+		if p.RMaskPath == "" {
+			return errors.New("the remove color requires a removal mask file, painted with the key color")
+		}
+		if _, err := ParseColorKey(p.RemoveColor); err != nil {
+			return err
+		}
+	}
+	if p.ColorTolerance < 0 || p.ColorTolerance > 255 {
+		return fmt.Errorf("invalid color tolerance: %d, it should be between 0 and 255", p.ColorTolerance)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorKey_ShouldSelectTheMatchingPixels(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := ParseColorKey("#00ff00")
+	assert.NoError(err)
+	assert.Equal(color.NRGBA{G: 0xff, A: 0xff}, key)
+	key, err = ParseColorKey("0f0")
+	assert.NoError(err)
+	assert.Equal(color.NRGBA{G: 0xff, A: 0xff}, key)
+	for _, s := range []string{"", "#00ff0", "#00ff00ff", "#ggffgg", "green"} {
+		_, err := ParseColorKey(s)
+		assert.Error(err, s)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{G: 0xff, A: 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 15, G: 240, B: 10, A: 0xff})
+	img.SetNRGBA(2, 0, color.NRGBA{R: 40, G: 0xff, A: 0xff})
+	img.SetNRGBA(3, 0, color.NRGBA{G: 0xff})
+
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black := color.NRGBA{A: 0xff}
+	mask := ColorKeyMask(img, key, 0)
+	assert.Equal([]color.NRGBA{white, black, black, black}, rowColors(mask))
+	mask = ColorKeyMask(img, key, 20)
+	assert.Equal([]color.NRGBA{white, white, black, black}, rowColors(mask))
+}
+
+func rowColors(img *image.NRGBA) []color.NRGBA {
+	var row []color.NRGBA
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		row = append(row, img.NRGBAAt(x, 0))
+	}
+	return row
+}
+
+func TestColorKey_ShouldRemoveThePaintedArea(t *testing.T) {
+	assert := assert.New(t)
+
+	bg := color.NRGBA{R: 120, G: 160, B: 200, A: 255}
+	img := subjectsImage(100, 40, 10)
+
+	// The left part of the subject is painted red on a copy of the image.
+	painted := image.NewNRGBA(img.Bounds())
+	draw.Draw(painted, img.Bounds(), img, image.Point{}, draw.Src)
+	draw.Draw(painted, image.Rect(10, 0, 22, 40), &image.Uniform{color.NRGBA{R: 250, G: 4, B: 6, A: 255}}, image.Point{}, draw.Src)
+	path := filepath.Join(t.TempDir(), "painted.png")
+	f, err := os.Create(path)
+	assert.NoError(err)
+	assert.NoError(png.Encode(f, painted))
+	assert.NoError(f.Close())
+
+	// The removal masks are weakened by the faces detected by the previous tests.
+	detAttempts, isFaceDetected = 0, false
+
+	var in bytes.Buffer
+	assert.NoError(png.Encode(&in, img))
+	p := &Processor{
+		NewWidth:       90,
+		SobelThreshold: 2,
+		OutputFormat:   FormatPNG,
+		RMaskPath:      path,
+		RemoveColor:    "#ff0000",
+		ColorTolerance: 10,
+	}
+	var out bytes.Buffer
+	assert.NoError(p.Process(&in, &out))
+	res, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(100-10, res.Bounds().Dx())
+
+	// The seams are crossing the painted area of the subject, instead of the flat background.
+	for y := 0; y < 40; y += 10 {
+		var subject int
+		for x := 0; x < res.Bounds().Dx(); x++ {
+			if color.NRGBAModel.Convert(res.At(x, y)) != bg {
+				subject++
+			}
+		}
+		assert.Equal(30, subject, "row %d", y)
+	}
+
+	p = &Processor{NewWidth: 90, RemoveColor: "#ff0000"}
+	assert.Error(p.Process(bytes.NewReader(in.Bytes()), &out))
+	p = &Processor{NewWidth: 90, RMaskPath: path, RemoveColor: "#ff0000", ColorTolerance: 300}
+	assert.Error(p.Process(bytes.NewReader(in.Bytes()), &out))
+}
@@ -0,0 +1,137 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/esimov/caire/filters"
+	"github.com/esimov/caire/utils"
+)
+
+// Comparison holds the metrics obtained by comparing two images.
+type Comparison struct {
+	// ChangedPixels is the fraction of the pixels which differ.
+	ChangedPixels float64
+	// MeanDiff is the mean absolute difference of the color channels in the [0, 255] range.
+	MeanDiff float64
+	// MaxDiff is the maximum absolute difference of the color channels.
+	MaxDiff uint8
+	// SSIM is the structural similarity index of the luminance in the [-1, 1] range.
+	SSIM float64
+}
+
+// Compare computes the pixel difference and the structural similarity of two images of identical size.
+func Compare(a, b image.Image) (*Comparison, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return nil, errors.New("the images have different sizes")
+	}
+	p := &Processor{}
+	na, nb := p.imgToNRGBA(a), p.imgToNRGBA(b)
+
+	var (
+		cmp     Comparison
+		changed int
+		sum     int
+	)
+	for i := 0; i+3 < len(na.Pix); i += 4 {
+		diff := false
+		for ch := 0; ch < 4; ch++ {
+			d := na.Pix[i+ch] - nb.Pix[i+ch]
+			if na.Pix[i+ch] < nb.Pix[i+ch] {
+				d = nb.Pix[i+ch] - na.Pix[i+ch]
+			}
+			if d > 0 {
+				diff = true
+			}
+			if d > cmp.MaxDiff {
+				cmp.MaxDiff = d
+			}
+			sum += int(d)
+		}
+		if diff {
+			changed++
+		}
+	}
+	pixels := len(na.Pix) / 4
+	if pixels > 0 {
+		cmp.ChangedPixels = float64(changed) / float64(pixels)
+		cmp.MeanDiff = float64(sum) / float64(pixels*4)
+	}
+	cmp.SSIM = SSIM(na, nb)
+
+	return &cmp, nil
+}
+
+// SSIM returns the mean structural similarity index of the luminance of two images of identical size,
+// computed over 8x8 windows sliding with a step of 4 pixels. Identical images have an index of 1.
+// See https://en.wikipedia.org/wiki/Structural_similarity
+func SSIM(a, b *image.NRGBA) float64 {
+	const (
+		win  = 8
+		step = 4
+		c1   = (0.01 * 255) * (0.01 * 255)
+		c2   = (0.03 * 255) * (0.03 * 255)
+	)
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+	la, lb := filters.Luminance(nil, a), filters.Luminance(nil, b)
+
+	ws, hs := utils.Min(win, width), utils.Min(win, height)
+	if ws == 0 || hs == 0 {
+		return 1
+	}
+
+	var total float64
+	var count int
+	for y := 0; y+hs <= height; y += step {
+		for x := 0; x+ws <= width; x += step {
+			var sa, sb, saa, sbb, sab float64
+			for j := y; j < y+hs; j++ {
+				for i := x; i < x+ws; i++ {
+					va, vb := float64(la[j*width+i]), float64(lb[j*width+i])
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			n := float64(ws * hs)
+			ma, mb := sa/n, sb/n
+			va := saa/n - ma*ma
+			vb := sbb/n - mb*mb
+			cov := sab/n - ma*mb
+
+			total += ((2*ma*mb + c1) * (2*cov + c2)) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			count++
+		}
+	}
+	return math.Max(-1, math.Min(1, total/float64(count)))
+}
+
+// SeamOverlap returns the intersection over union of the pixels covered by two sets of seams,
+// like the ones exported as SVG overlays. It's 1 if the seams are identical and 0 if they are disjoint.
+func SeamOverlap(a, b [][]image.Point) float64 {
+	set := make(map[image.Point]uint8)
+	for _, path := range a {
+		for _, pt := range path {
+			set[pt] |= 1
+		}
+	}
+	for _, path := range b {
+		for _, pt := range path {
+			set[pt] |= 2
+		}
+	}
+	if len(set) == 0 {
+		return 1
+	}
+
+	var inter int
+	for _, v := range set {
+		if v == 3 {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(set))
+}
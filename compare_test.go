@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare_ShouldComputeTheMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	a := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			a.Set(x, y, color.NRGBA{R: uint8(x * 12), G: uint8(y * 12), B: 100, A: 255})
+		}
+	}
+	cmp, err := Compare(a, a)
+	assert.NoError(err)
+	assert.Equal(0.0, cmp.ChangedPixels)
+	assert.InDelta(1.0, cmp.SSIM, 1e-9)
+
+	b := image.NewNRGBA(a.Bounds())
+	copy(b.Pix, a.Pix)
+	b.Set(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	cmp, err = Compare(a, b)
+	assert.NoError(err)
+	assert.Equal(1.0/400, cmp.ChangedPixels)
+	assert.Equal(uint8(255), cmp.MaxDiff)
+	assert.Less(cmp.SSIM, 1.0)
+
+	_, err = Compare(a, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	assert.Error(err)
+}
+
+func TestCompare_ShouldComputeSeamOverlap(t *testing.T) {
+	assert := assert.New(t)
+
+	a := [][]image.Point{{{0, 0}, {0, 1}}}
+	b := [][]image.Point{{{0, 0}, {1, 1}}}
+	assert.Equal(1.0, SeamOverlap(a, a))
+	assert.InDelta(1.0/3, SeamOverlap(a, b), 1e-9)
+
+	proc := &Processor{tracker: &coordTracker{origW: 2, origH: 2, removed: a}}
+	var buf bytes.Buffer
+	assert.NoError(proc.WriteSeamsSVG(&buf))
+
+	seams, err := ParseSeamsSVG(&buf)
+	assert.NoError(err)
+	assert.Equal(a, seams)
+}
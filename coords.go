@@ -0,0 +1,163 @@
+package caire
+
+import (
+	"image"
+	"math"
+)
+
+// coordTracker keeps track of the original coordinates of the pixels of the image being carved.
+// The coordinates are stored in the orientation of the source image, this is why the seams
+// obtained from the rotated image (used for the vertical resizing) have to be transposed.
+type coordTracker struct {
+	width, height int
+	// xs and ys holds the original coordinates of each pixel in row-major order.
+	xs, ys []int32
+	// scaleX and scaleY are used to map the coordinates back to the source image,
+	// in case the image has been rescaled prior to carving.
+	scaleX, scaleY float64
+	origW, origH   int
+
+	removed  [][]image.Point
+	inserted [][]image.Point
+	// removedH and insertedH report whether the seams are horizontal (changing the image height).
+	removedH  []bool
+	insertedH []bool
+}
+
+// newCoordTracker returns a tracker for an image of the provided size, which has
+// been obtained by rescaling an image of origW x origH size.
+func newCoordTracker(width, height, origW, origH int) *coordTracker {
+	t := &coordTracker{
+		width:  width,
+		height: height,
+		xs:     make([]int32, width*height),
+		ys:     make([]int32, width*height),
+		scaleX: float64(origW) / float64(width),
+		scaleY: float64(origH) / float64(height),
+		origW:  origW,
+		origH:  origH,
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			t.xs[y*width+x] = int32(x)
+			t.ys[y*width+x] = int32(y)
+		}
+	}
+	return t
+}
+
+// at returns the coordinate of the pixel in the source image.
+func (t *coordTracker) at(x, y int) image.Point {
+	i := y*t.width + x
+	return image.Point{
+		X: int(math.Floor(float64(t.xs[i]) * t.scaleX)),
+		Y: int(math.Floor(float64(t.ys[i]) * t.scaleY)),
+	}
+}
+
+// seamToPoints converts the seam obtained from the current image to tracker coordinates.
+// In case the image is rotated, the seam is transposed to a horizontal seam.
+func (t *coordTracker) seamToPoints(seams []Seam, rotated bool) []image.Point {
+	pts := make([]image.Point, len(seams))
+	for i, s := range seams {
+		if rotated {
+			pts[i] = image.Point{X: t.width - s.Y - 1, Y: s.X}
+		} else {
+			pts[i] = image.Point{X: s.X, Y: s.Y}
+		}
+	}
+	return pts
+}
+
+// remove removes the seam from the tracked coordinates and records its original position.
+func (t *coordTracker) remove(seams []Seam, rotated bool) {
+	pts := t.seamToPoints(seams, rotated)
+	path := make([]image.Point, len(pts))
+	for i, pt := range pts {
+		path[i] = t.at(pt.X, pt.Y)
+	}
+	t.removed = append(t.removed, path)
+	t.removedH = append(t.removedH, rotated)
+
+	if rotated {
+		t.update(pts, t.width, t.height-1, func(x, y, sx, sy int) (int, int) {
+			if y >= sy {
+				return x, y + 1
+			}
+			return x, y
+		})
+	} else {
+		t.update(pts, t.width-1, t.height, func(x, y, sx, sy int) (int, int) {
+			if x >= sx {
+				return x + 1, y
+			}
+			return x, y
+		})
+	}
+}
+
+// insert adds the seam to the tracked coordinates. The inserted pixels
+// inherit the original coordinates of the pixels they have been cloned from.
+func (t *coordTracker) insert(seams []Seam, rotated bool) {
+	pts := t.seamToPoints(seams, rotated)
+	path := make([]image.Point, len(pts))
+	for i, pt := range pts {
+		path[i] = t.at(pt.X, pt.Y)
+	}
+	t.inserted = append(t.inserted, path)
+	t.insertedH = append(t.insertedH, rotated)
+
+	if rotated {
+		t.update(pts, t.width, t.height+1, func(x, y, sx, sy int) (int, int) {
+			if y > sy {
+				return x, y - 1
+			}
+			return x, y
+		})
+	} else {
+		t.update(pts, t.width+1, t.height, func(x, y, sx, sy int) (int, int) {
+			if x > sx {
+				return x - 1, y
+			}
+			return x, y
+		})
+	}
+}
+
+// update rebuilds the coordinate planes for the new image size. The src function maps the new
+// pixel position to the position in the previous planes, given the seam position on the same
+// row (for vertical seams) or column (for horizontal seams).
+func (t *coordTracker) update(pts []image.Point, width, height int, src func(x, y, sx, sy int) (int, int)) {
+	// Vertical seams change only the width, horizontal seams only the height.
+	vertical := height == t.height
+
+	// Index the seam position by row or column.
+	n := t.height
+	if !vertical {
+		n = t.width
+	}
+	pos := make([]image.Point, n)
+	for _, pt := range pts {
+		if vertical {
+			pos[pt.Y] = pt
+		} else {
+			pos[pt.X] = pt
+		}
+	}
+
+	xs := make([]int32, width*height)
+	ys := make([]int32, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			s := pos[y]
+			if !vertical {
+				s = pos[x]
+			}
+			ox, oy := src(x, y, s.X, s.Y)
+			xs[y*width+x] = t.xs[oy*t.width+ox]
+			ys[y*width+x] = t.ys[oy*t.width+ox]
+		}
+	}
+	t.xs, t.ys = xs, ys
+	t.width, t.height = width, height
+}
@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoords_ShouldTrackOriginalCoordinates(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		width, height int
+	}{
+		{"horizontal", 30, 0},
+		{"vertical", 0, 30},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Encode the pixel coordinates into the pixel colors.
+			img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+			for x := 0; x < 40; x++ {
+				for y := 0; y < 40; y++ {
+					img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8((x * y) % 256), A: 255})
+				}
+			}
+			proc := &Processor{
+				NewWidth:       tc.width,
+				NewHeight:      tc.height,
+				BlurRadius:     1,
+				SobelThreshold: 4,
+				SeamsSVGPath:   "seams.svg",
+			}
+			res, err := proc.Resize(img)
+			assert.NoError(err)
+
+			dst := res.(*image.NRGBA)
+			for x := 0; x < dst.Bounds().Dx(); x++ {
+				for y := 0; y < dst.Bounds().Dy(); y++ {
+					c := dst.NRGBAAt(x, y)
+					assert.Equal(image.Pt(int(c.R), int(c.G)), proc.tracker.at(x, y))
+				}
+			}
+			assert.Len(proc.tracker.removed, 10)
+
+			var buf bytes.Buffer
+			assert.NoError(proc.WriteSeamsSVG(&buf))
+			assert.Equal(10, strings.Count(buf.String(), "<polyline"))
+		})
+	}
+}
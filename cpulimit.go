@@ -0,0 +1,47 @@
+package caire
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ParseCPULimit parses the share of the CPU the processing is allowed to use, provided
+// either as a percentage (ex. "50%") or as a fraction (ex. "0.5"). Zero means no limit.
+func ParseCPULimit(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s, scale = strings.TrimSuffix(s, "%"), 100
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v/scale > 1 {
+		return 0, fmt.Errorf("invalid CPU limit: %q", s)
+	}
+	return v / scale, nil
+}
+
+// ApplyCPULimit adjusts the number of the operating system threads executing Go code
+// simultaneously to the share of the available CPUs and returns the new value.
+// A zero limit leaves the current setting unchanged.
+func ApplyCPULimit(limit float64) int {
+	if limit <= 0 || limit >= 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	procs := cpuShare(limit)
+	runtime.GOMAXPROCS(procs)
+
+	return procs
+}
+
+// cpuShare returns the number of CPUs corresponding to the limit, at least one.
+// The limit is applied only by reducing the number of the threads, so the effective
+// share cannot be lower than a single CPU.
+func cpuShare(limit float64) int {
+	return max(1, int(math.Round(float64(runtime.NumCPU())*limit)))
+}
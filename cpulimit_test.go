@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPULimit_ShouldParseTheLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	for s, expected := range map[string]float64{"": 0, "50%": 0.5, "0.25": 0.25, "100%": 1} {
+		limit, err := ParseCPULimit(s)
+		assert.NoError(err)
+		assert.Equal(expected, limit)
+	}
+	for _, s := range []string{"abc", "150%", "-0.5", "2"} {
+		_, err := ParseCPULimit(s)
+		assert.Error(err)
+	}
+
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+	assert.Equal(cpuShare(0.5), ApplyCPULimit(0.5))
+	assert.Equal(cpuShare(0.5), runtime.GOMAXPROCS(0))
+	assert.Equal(1, cpuShare(0.0001))
+}
+
+func TestCPULimit_ShouldUseTheShareOfTheCPUs(t *testing.T) {
+	assert := assert.New(t)
+
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+
+	cpus := float64(runtime.NumCPU())
+	for _, limit := range []float64{0.1, 0.25, 0.5, 0.75} {
+		runtime.GOMAXPROCS(runtime.NumCPU())
+		p := &Processor{SeamWorkers: -1, CPULimit: limit}
+		carving := float64(p.seamWorkers()) / cpus
+
+		ApplyCPULimit(limit)
+		process := float64(runtime.GOMAXPROCS(0)) / cpus
+
+		// The share is rounded to whole CPUs, but it is never below a single CPU.
+		expected := max(limit, 1/cpus)
+		assert.InDelta(expected, carving, 0.5/cpus, "limit %v", limit)
+		assert.InDelta(expected, process, 0.5/cpus, "limit %v", limit)
+		// The limit is applied only once, by the number of the threads.
+		assert.Equal(runtime.GOMAXPROCS(0), p.seamWorkers())
+	}
+
+	p := &Processor{SeamWorkers: 2}
+	assert.Equal(2, p.seamWorkers())
+}
@@ -0,0 +1,190 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/utils"
+)
+
+// maxCropAnalysisSize is the maximum size of the image used for computing the energy map of the crop suggestion.
+const maxCropAnalysisSize = 512
+
+// faceCropWeight is the energy of the face pixels relative to the most salient image pixels.
+const faceCropWeight = 10
+
+// CropSuggestion is the crop rectangle retaining the most salient content for a given aspect ratio.
+type CropSuggestion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Score is the share of the image energy retained by the crop, between 0 and 1.
+	Score float64 `json:"score"`
+	// Faces is the number of the detected faces fully contained in the crop.
+	Faces int `json:"faces"`
+}
+
+// Rect returns the crop rectangle.
+func (c *CropSuggestion) Rect() image.Rectangle {
+	return image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+}
+
+// ParseRatio parses an aspect ratio provided as W:H (ex. 16:9) or as a decimal number (ex. 1.5).
+func ParseRatio(s string) (float64, error) {
+	var (
+		ratio float64
+		err   error
+	)
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		var fw, fh float64
+		if fw, err = strconv.ParseFloat(strings.TrimSpace(w), 64); err == nil {
+			fh, err = strconv.ParseFloat(strings.TrimSpace(h), 64)
+		}
+		if fh != 0 {
+			ratio = fw / fh
+		}
+	} else {
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	if err != nil || ratio <= 0 || math.IsInf(ratio, 0) || math.IsNaN(ratio) {
+		return 0, fmt.Errorf("invalid aspect ratio %q: W:H or a positive number is expected", s)
+	}
+	return ratio, nil
+}
+
+// SuggestCrop returns the largest crop of the provided aspect ratio (width/height) retaining the
+// most salient content. It's using the same energy map as the seam carver, computed over a
+// downscaled copy of the image, and keeps the detected faces if the face detection is enabled.
+// Since no seams are removed, this is much faster than resizing the image.
+func (p *Processor) SuggestCrop(src image.Image, ratio float64) (*CropSuggestion, error) {
+	if ratio <= 0 {
+		return nil, fmt.Errorf("invalid aspect ratio: %v", ratio)
+	}
+	img := p.imgToNRGBA(src)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < minImageSize || height < minImageSize {
+		return nil, fmt.Errorf("the image is too small: %dx%d", width, height)
+	}
+
+	crop := &CropSuggestion{Width: width, Height: height}
+	if float64(width)/float64(height) > ratio {
+		crop.Width = utils.Max(1, int(math.Round(float64(height)*ratio)))
+	} else {
+		crop.Height = utils.Max(1, int(math.Round(float64(width)/ratio)))
+	}
+
+	// The energy map is computed over a downscaled image, then mapped back to the original size.
+	scale := math.Min(1, float64(maxCropAnalysisSize)/float64(utils.Max(width, height)))
+	small := img
+	if scale < 1 {
+		small = imaging.Resize(img, utils.Max(minImageSize, int(math.Round(float64(width)*scale))), 0, imaging.Box)
+	}
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+	sx, sy := float64(sw)/float64(width), float64(sh)/float64(height)
+
+	backend := p.getBackend()
+	c := NewCarver(sw, sh)
+	energy := backend.sobel(c, small, float64(p.SobelThreshold))
+	if p.BlurRadius > 0 {
+		energy = backend.blur(c, energy, uint32(p.BlurRadius))
+	}
+
+	// The edge detector produces spurious energy along the image borders, which is ignored.
+	border := 3 + p.BlurRadius
+	weights := make([]float64, sw*sh)
+	for y := border; y < sh-border; y++ {
+		for x := border; x < sw-border; x++ {
+			weights[y*sw+x] = float64(energy.Pix[energy.PixOffset(x, y)]) / 0xff
+		}
+	}
+
+	var faces []image.Rectangle
+	if p.FaceDetect {
+		if p.FaceDetector == nil {
+			det, err := p.unpackCascade()
+			if err != nil {
+				return nil, err
+			}
+			p.FaceDetector = det
+		}
+		for _, face := range p.detectFaces(NewCarver(width, height), img) {
+			if face.Q <= minFaceQuality {
+				continue
+			}
+			rect := faceRect(face).Intersect(img.Bounds())
+			faces = append(faces, rect)
+
+			// The face pixels outweigh any other content.
+			scaled := image.Rect(
+				int(float64(rect.Min.X)*sx), int(float64(rect.Min.Y)*sy),
+				int(math.Ceil(float64(rect.Max.X)*sx)), int(math.Ceil(float64(rect.Max.Y)*sy)),
+			).Intersect(small.Bounds())
+			for y := scaled.Min.Y; y < scaled.Max.Y; y++ {
+				for x := scaled.Min.X; x < scaled.Max.X; x++ {
+					weights[y*sw+x] = faceCropWeight
+				}
+			}
+		}
+	}
+
+	// The crop spans the whole image along one of the axes, so it's enough
+	// to slide it along the other one, using the prefix sums of the energy.
+	horizontal := crop.Width < width
+	n, size, limit, s := sh, crop.Height, height, sy
+	if horizontal {
+		n, size, limit, s = sw, crop.Width, width, sx
+	}
+	prefix := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		var sum float64
+		if horizontal {
+			for y := 0; y < sh; y++ {
+				sum += weights[y*sw+i]
+			}
+		} else {
+			for x := 0; x < sw; x++ {
+				sum += weights[i*sw+x]
+			}
+		}
+		prefix[i+1] = prefix[i] + sum
+	}
+
+	// Sum of the energy between the original pixel positions, interpolating the partially covered cells.
+	at := func(pos int) float64 {
+		f := math.Min(float64(n), float64(pos)*s)
+		i := int(f)
+		if i >= n {
+			return prefix[n]
+		}
+		return prefix[i] + (prefix[i+1]-prefix[i])*(f-float64(i))
+	}
+
+	best, bestScore, center := 0, -1.0, float64(limit-size)/2
+	for pos := 0; pos <= limit-size; pos++ {
+		score := at(pos+size) - at(pos)
+		// On equal scores the crop closer to the image center is preferred.
+		if score > bestScore+1e-9 || (math.Abs(score-bestScore) <= 1e-9 && math.Abs(float64(pos)-center) < math.Abs(float64(best)-center)) {
+			best, bestScore = pos, score
+		}
+	}
+	if horizontal {
+		crop.X = best
+	} else {
+		crop.Y = best
+	}
+
+	if prefix[n] > 0 {
+		crop.Score = math.Min(1, bestScore/prefix[n])
+	}
+	for _, face := range faces {
+		if face.In(crop.Rect()) {
+			crop.Faces++
+		}
+	}
+	return crop, nil
+}
@@ -0,0 +1,56 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrop_ShouldParseTheRatio(t *testing.T) {
+	assert := assert.New(t)
+
+	for s, expected := range map[string]float64{"1:1": 1, "16:9": 16.0 / 9, "1.5": 1.5, " 4 : 3 ": 4.0 / 3} {
+		ratio, err := ParseRatio(s)
+		assert.NoError(err)
+		assert.InDelta(expected, ratio, 1e-9)
+	}
+	for _, s := range []string{"", "1:0", "0", "-1", "a:b", "16:"} {
+		_, err := ParseRatio(s)
+		assert.Error(err, s)
+	}
+}
+
+func TestCrop_ShouldKeepTheSalientContent(t *testing.T) {
+	assert := assert.New(t)
+
+	// A textured patch on the right side of a uniform image.
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+	fillGray(img, img.Bounds(), 128)
+	patch := image.Rect(200, 10, 280, 90)
+	draw.Draw(img, patch, texturedImage(300, 100), patch.Min, draw.Src)
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1}
+
+	crop, err := proc.SuggestCrop(img, 1)
+	assert.NoError(err)
+	assert.Equal(100, crop.Width)
+	assert.Equal(100, crop.Height)
+	assert.Equal(0, crop.Y)
+	assert.True(patch.In(crop.Rect()), "the crop %v should contain the patch", crop.Rect())
+	assert.Greater(crop.Score, 0.9)
+
+	// The crop is vertical for a tall ratio, centered on the uniform image.
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 100; y++ {
+			img.Set(x, y, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+		}
+	}
+	crop, err = proc.SuggestCrop(img, 6)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 25, 300, 75), crop.Rect())
+
+	_, err = proc.SuggestCrop(img, 0)
+	assert.Error(err)
+}
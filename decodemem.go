@@ -0,0 +1,69 @@
+package caire
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/esimov/caire/jpegdec"
+)
+
+// bytesPerPixel is the memory used by a decoded pixel, the images being processed as NRGBA.
+const bytesPerPixel = 4
+
+// DecodeMemoryError is returned when the memory required to decode the image exceeds the
+// MaxDecodeMem budget and the image cannot be downscaled while decoding it.
+type DecodeMemoryError struct {
+	Width, Height int
+	Required      int64
+	Budget        int64
+}
+
+func (e *DecodeMemoryError) Error() string {
+	return fmt.Sprintf("the image is too large: decoding %dx%d requires %d bytes, exceeding the budget of %d bytes",
+		e.Width, e.Height, e.Required, e.Budget)
+}
+
+// decodeMemory estimates the memory required to decode an image of the provided size.
+func decodeMemory(width, height int) int64 {
+	return int64(width) * int64(height) * bytesPerPixel
+}
+
+// jpegScale returns the smallest DCT scaling denominator, which brings the memory required
+// to decode the JPEG image within the budget, or zero if the image doesn't fit at any scale.
+// The coefficients of the progressive images are kept in memory at full resolution.
+func jpegScale(h jpegdec.Header, budget int64) int {
+	for _, s := range jpegdec.Scales {
+		w, ht := (h.Width+s-1)/s, (h.Height+s-1)/s
+		if decodeMemory(w, ht)+h.CoefficientBytes <= budget {
+			return s
+		}
+	}
+	return 0
+}
+
+// decodeJPEG decodes the JPEG image downscaled by the DCT scaling: at the scale required by the
+// pre-scaling, or at a smaller one if needed to fit in the memory budget. The image header is
+// read twice, the consumed bytes are replayed for the decoder.
+func decodeJPEG(r io.Reader, opts decodeOptions) (image.Image, error) {
+	var header bytes.Buffer
+	h, err := jpegdec.DecodeHeader(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	scale := prescale(h.Width, h.Height, opts.size)
+	if opts.maxMem > 0 {
+		s := jpegScale(h, opts.maxMem)
+		if s == 0 {
+			return nil, &DecodeMemoryError{
+				Width:    h.Width,
+				Height:   h.Height,
+				Required: decodeMemory(h.Width, h.Height) + h.CoefficientBytes,
+				Budget:   opts.maxMem,
+			}
+		}
+		scale = max(scale, s)
+	}
+	return jpegdec.DecodeScaled(io.MultiReader(&header, r), scale)
+}
@@ -0,0 +1,72 @@
+package caire
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/esimov/caire/jpegdec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMem_ShouldRejectNonJPEGImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 100, 80))))
+
+	_, _, err := decodeWith(bytes.NewReader(buf.Bytes()), "", decodeOptions{maxPixels: DefaultMaxPixels, maxMem: 100*80*4 - 1})
+	var memErr *DecodeMemoryError
+	assert.True(errors.As(err, &memErr))
+	assert.Equal(int64(100*80*4), memErr.Required)
+	assert.Equal(int64(100*80*4-1), memErr.Budget)
+
+	img, _, err := decodeWith(bytes.NewReader(buf.Bytes()), "", decodeOptions{maxPixels: DefaultMaxPixels, maxMem: 100 * 80 * 4})
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 100, 80), img.Bounds())
+}
+
+func TestDecodeMem_ShouldDownscaleJPEGImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 100, 80)), nil))
+
+	// The smallest scale fitting in the budget is used.
+	img, format, err := decodeWith(bytes.NewReader(buf.Bytes()), "", decodeOptions{maxPixels: DefaultMaxPixels, maxMem: 50 * 40 * 4})
+	assert.NoError(err)
+	assert.Equal(FormatJPEG, format)
+	assert.Equal(image.Rect(0, 0, 50, 40), img.Bounds())
+
+	img, _, err = decodeWith(bytes.NewReader(buf.Bytes()), "", decodeOptions{maxPixels: DefaultMaxPixels, maxMem: 50*40*4 - 1})
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 25, 20), img.Bounds())
+
+	_, _, err = decodeWith(bytes.NewReader(buf.Bytes()), "", decodeOptions{maxPixels: DefaultMaxPixels, maxMem: 100})
+	var memErr *DecodeMemoryError
+	assert.True(errors.As(err, &memErr))
+
+	proc := &Processor{NewWidth: 40, MaxDecodeMem: 50 * 40 * 4, OutputFormat: FormatPNG}
+	var out bytes.Buffer
+	assert.NoError(proc.Process(bytes.NewReader(buf.Bytes()), &out))
+	res, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 40, 40), res.Bounds())
+}
+
+func TestDecodeMem_ShouldAccountForProgressiveCoefficients(t *testing.T) {
+	assert := assert.New(t)
+
+	h := jpegdec.Header{Width: 100, Height: 80}
+	assert.Equal(1, jpegScale(h, 100*80*4))
+	assert.Equal(2, jpegScale(h, 100*80*4-1))
+	assert.Equal(8, jpegScale(h, 13*10*4))
+	assert.Equal(0, jpegScale(h, 13*10*4-1))
+
+	h.Progressive, h.CoefficientBytes = true, 100*80*4
+	assert.Equal(2, jpegScale(h, 100*80*4+50*40*4))
+	assert.Equal(0, jpegScale(h, 100*80*4))
+}
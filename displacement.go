@@ -0,0 +1,145 @@
+package caire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeDisplacementFile saves the displacement map, which holds for each pixel of the carved image
+// its coordinates in the source image. The format is defined by the file extension: the PNG files
+// store the X and Y coordinates as 16 bit values in the red and green channels, while the OpenEXR
+// files have two 32 bit float channels named X and Y.
+func (p *Processor) writeDisplacementFile(path string) error {
+	if p.tracker == nil {
+		return errors.New("the displacement map is not available")
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".png" && ext != ".exr" {
+		return fmt.Errorf("unsupported displacement map format: %q", ext)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the displacement map file: %v", err)
+	}
+	defer f.Close()
+
+	if ext == ".exr" {
+		err = p.encodeDisplacementEXR(f)
+	} else {
+		err = png.Encode(f, p.displacementImage())
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// displacementImage returns the displacement map as a 16 bit image, having the source
+// X and Y coordinates of each carved pixel in the red and green channels.
+func (p *Processor) displacementImage() *image.RGBA64 {
+	t := p.tracker
+	img := image.NewRGBA64(image.Rect(0, 0, t.width, t.height))
+	for y := 0; y < t.height; y++ {
+		for x := 0; x < t.width; x++ {
+			pt := t.at(x, y)
+			img.SetRGBA64(x, y, color.RGBA64{R: uint16(pt.X), G: uint16(pt.Y), A: 0xffff})
+		}
+	}
+	return img
+}
+
+// encodeDisplacementEXR writes the displacement map as an uncompressed scanline OpenEXR image.
+func (p *Processor) encodeDisplacementEXR(w io.Writer) error {
+	t := p.tracker
+	width, height := t.width, t.height
+
+	var hdr exrHeader
+	hdr.bytes(0x76, 0x2f, 0x31, 0x01) // magic number
+	hdr.int32(2)                      // version 2, single part scanline image
+
+	// The channels are sorted alphabetically: name, pixel type (2 = float), linear flag,
+	// 3 reserved bytes and the x, y sampling.
+	var chlist exrHeader
+	for _, name := range []string{"X", "Y"} {
+		chlist.string(name)
+		chlist.int32(2)
+		chlist.bytes(0, 0, 0, 0)
+		chlist.int32(1)
+		chlist.int32(1)
+	}
+	chlist.bytes(0)
+	hdr.attr("channels", "chlist", chlist.buf)
+
+	var box exrHeader
+	box.int32(0)
+	box.int32(0)
+	box.int32(int32(width - 1))
+	box.int32(int32(height - 1))
+	hdr.attr("compression", "compression", []byte{0})
+	hdr.attr("dataWindow", "box2i", box.buf)
+	hdr.attr("displayWindow", "box2i", box.buf)
+	hdr.attr("lineOrder", "lineOrder", []byte{0})
+	hdr.attr("pixelAspectRatio", "float", float32Bytes(1))
+	hdr.attr("screenWindowCenter", "v2f", append(float32Bytes(0), float32Bytes(0)...))
+	hdr.attr("screenWindowWidth", "float", float32Bytes(1))
+	hdr.bytes(0)
+
+	// The offset table holds the position of each scanline block in the file.
+	lineSize := 2 * 4 * width
+	blockSize := 8 + lineSize
+	offset := uint64(len(hdr.buf) + 8*height)
+	for y := 0; y < height; y++ {
+		hdr.buf = binary.LittleEndian.AppendUint64(hdr.buf, offset+uint64(y*blockSize))
+	}
+	if _, err := w.Write(hdr.buf); err != nil {
+		return err
+	}
+
+	block := make([]byte, blockSize)
+	for y := 0; y < height; y++ {
+		binary.LittleEndian.PutUint32(block[0:], uint32(y))
+		binary.LittleEndian.PutUint32(block[4:], uint32(lineSize))
+		for x := 0; x < width; x++ {
+			pt := t.at(x, y)
+			binary.LittleEndian.PutUint32(block[8+4*x:], math.Float32bits(float32(pt.X)))
+			binary.LittleEndian.PutUint32(block[8+4*(width+x):], math.Float32bits(float32(pt.Y)))
+		}
+		if _, err := w.Write(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exrHeader is a helper for building the little endian OpenEXR header.
+type exrHeader struct {
+	buf []byte
+}
+
+func (h *exrHeader) bytes(b ...byte) { h.buf = append(h.buf, b...) }
+
+func (h *exrHeader) int32(v int32) { h.buf = binary.LittleEndian.AppendUint32(h.buf, uint32(v)) }
+
+func (h *exrHeader) string(s string) { h.buf = append(append(h.buf, s...), 0) }
+
+// attr appends an attribute defined by its name, type and value.
+func (h *exrHeader) attr(name, typ string, value []byte) {
+	h.string(name)
+	h.string(typ)
+	h.int32(int32(len(value)))
+	h.bytes(value...)
+}
+
+func float32Bytes(v float32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+}
@@ -0,0 +1,68 @@
+package caire
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplacement_ShouldExportTheSourceCoordinates(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, DisplaceMap: "map.png"}
+	_, err := p.Resize(img)
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	assert.NoError(p.writeDisplacementFile(filepath.Join(dir, "map.png")))
+	f, err := os.Open(filepath.Join(dir, "map.png"))
+	assert.NoError(err)
+	defer f.Close()
+	m, err := png.Decode(f)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), m.Bounds())
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 32; x++ {
+			r, g, _, _ := m.At(x, y).RGBA()
+			assert.Equal(p.tracker.at(x, y), image.Pt(int(r), int(g)))
+		}
+	}
+
+	assert.NoError(p.writeDisplacementFile(filepath.Join(dir, "map.exr")))
+	data, err := os.ReadFile(filepath.Join(dir, "map.exr"))
+	assert.NoError(err)
+	assert.Equal([]byte{0x76, 0x2f, 0x31, 0x01}, data[:4])
+
+	// The offset table follows the header and the first scanline follows the offset table.
+	var first int
+	for i := 8; i+8 < len(data); i++ {
+		if int(binary.LittleEndian.Uint64(data[i:])) == i+8*30 {
+			first = i + 8*30
+			break
+		}
+	}
+	assert.NotZero(first)
+	assert.Equal(first+30*(8+2*4*32), len(data))
+	assert.Equal(uint32(0), binary.LittleEndian.Uint32(data[first:]))
+	assert.Equal(uint32(2*4*32), binary.LittleEndian.Uint32(data[first+4:]))
+	for x := 0; x < 32; x++ {
+		pt := p.tracker.at(x, 0)
+		assert.Equal(float32(pt.X), math.Float32frombits(binary.LittleEndian.Uint32(data[first+8+4*x:])))
+		assert.Equal(float32(pt.Y), math.Float32frombits(binary.LittleEndian.Uint32(data[first+8+4*(32+x):])))
+	}
+
+	assert.Error(p.writeDisplacementFile(filepath.Join(dir, "map.jpg")))
+}
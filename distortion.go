@@ -0,0 +1,72 @@
+package caire
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/utils"
+)
+
+// maxEstimatorSize is the maximum size of the image used by the distortion estimator.
+const maxEstimatorSize = 160
+
+// estimateDistortion returns a cheap estimation of the distortion produced by carving
+// the image to the provided size, expressed as the mean energy of the removed pixels
+// in the [0, 1] range. The carving is simulated on a downscaled copy of the image,
+// without considering the masks and the detected faces. Only the shrinking is estimated,
+// a zero value for the width or height means that the dimension is preserved.
+func (p *Processor) estimateDistortion(img *image.NRGBA, width, height int) float64 {
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	if width == 0 {
+		width = dx
+	}
+	if height == 0 {
+		height = dy
+	}
+
+	scale := math.Min(1, float64(maxEstimatorSize)/float64(utils.Max(dx, dy)))
+	small := img
+	if scale < 1 {
+		small = imaging.Resize(img, int(math.Round(float64(dx)*scale)), int(math.Round(float64(dy)*scale)), imaging.Box)
+	}
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+
+	est := &Processor{
+		SobelThreshold: p.SobelThreshold,
+		BlurRadius:     p.BlurRadius,
+		ChannelWeights: p.ChannelWeights,
+		Tileable:       p.Tileable,
+	}
+
+	var energy float64
+	var pixels int
+
+	carve := func(img *image.NRGBA, seams int) *image.NRGBA {
+		for i := 0; i < seams && img.Bounds().Dx() > 1; i++ {
+			c := NewCarver(img.Bounds().Dx(), img.Bounds().Dy())
+			if _, err := c.ComputeSeams(est, img); err != nil {
+				break
+			}
+			path := c.FindLowestEnergySeams(est)
+			// The cumulative energy of the last row holds the energy of the whole seam.
+			energy += c.get(path[0].X, path[0].Y)
+			pixels += len(path)
+			img = c.RemoveSeam(img, path, false)
+		}
+		return img
+	}
+
+	if n := int(math.Round(float64(dx-width) * scale)); n > 0 && n < sw {
+		small = carve(small, n)
+	}
+	if n := int(math.Round(float64(dy-height) * scale)); n > 0 && n < sh {
+		c := NewCarver(small.Bounds().Dx(), small.Bounds().Dy())
+		carve(c.RotateImage90(small), n)
+	}
+
+	if pixels == 0 {
+		return 0
+	}
+	return energy / float64(pixels)
+}
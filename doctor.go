@@ -0,0 +1,162 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"runtime"
+	"time"
+)
+
+//go:embed data/selftest.png
+var selfTestImage []byte
+
+// The statuses of the diagnostic checks.
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Check is the outcome of a single diagnostic check of the runtime environment.
+type Check struct {
+	// Name identifies the check: "gpu", "display", "temp-dir", "cascade" or "self-test".
+	Name string `json:"name"`
+	// Status is "pass", "warn" or "fail". The warnings are reported for the optional features,
+	// which are not available, while caire still works without them.
+	Status string `json:"status"`
+	// Detail is the human readable outcome of the check.
+	Detail string `json:"detail"`
+}
+
+// Diagnostics holds the outcome of the diagnostic checks.
+type Diagnostics []Check
+
+// Failed reports whether any of the checks has failed.
+func (d Diagnostics) Failed() bool {
+	for _, c := range d {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnose checks the runtime environment: the availability of the GPU backend, of the display
+// server used by the preview window, the writable temporary directory and the face detection
+// cascade, then carves an embedded 64x64 image. It's meant for triaging the installations, where
+// caire doesn't work as expected.
+func Diagnose(ctx context.Context) Diagnostics {
+	return Diagnostics{
+		checkGPU(),
+		checkDisplay(runtime.GOOS, os.Getenv),
+		checkTempDir(),
+		checkCascade(),
+		checkSelfTest(ctx),
+	}
+}
+
+// checkGPU checks whether the OpenCL backend is compiled in and an OpenCL device is available.
+func checkGPU() Check {
+	c := Check{Name: "gpu"}
+	if !openCLAvailable {
+		c.Status, c.Detail = CheckWarn, "the OpenCL backend is not compiled in (build tag opencl), the CPU is used"
+		return c
+	}
+	if _, err := newBackend(BackendOpenCL); err != nil {
+		c.Status, c.Detail = CheckWarn, fmt.Sprintf("no OpenCL device is available, the CPU is used: %v", err)
+		return c
+	}
+	c.Status, c.Detail = CheckPass, "the OpenCL device is available"
+	return c
+}
+
+// checkDisplay checks whether the preview window can be shown. On the Unix systems, other
+// than macOS, a running X11 or Wayland display server is required.
+func checkDisplay(goos string, getenv func(string) string) Check {
+	c := Check{Name: "display"}
+	if !previewAvailable {
+		c.Status, c.Detail = CheckWarn, "the preview is not available in the headless builds"
+		return c
+	}
+	switch goos {
+	case "windows", "darwin", "ios", "android":
+		c.Status, c.Detail = CheckPass, "the native window system is used"
+		return c
+	}
+	if d := getenv("WAYLAND_DISPLAY"); d != "" {
+		c.Status, c.Detail = CheckPass, fmt.Sprintf("Wayland display %q", d)
+		return c
+	}
+	if d := getenv("DISPLAY"); d != "" {
+		c.Status, c.Detail = CheckPass, fmt.Sprintf("X11 display %q", d)
+		return c
+	}
+	c.Status, c.Detail = CheckWarn, "no display server found (DISPLAY and WAYLAND_DISPLAY are not set), use -preview=false"
+	return c
+}
+
+// checkTempDir checks whether the temporary directory, used for the downloaded images, is writable.
+func checkTempDir() Check {
+	c := Check{Name: "temp-dir"}
+	f, err := os.CreateTemp("", "caire-doctor-*")
+	if err != nil {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("the temporary directory %s is not writable: %v", os.TempDir(), err)
+		return c
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(selfTestImage)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("unable to write into the temporary directory %s: %v", os.TempDir(), err)
+		return c
+	}
+	c.Status, c.Detail = CheckPass, os.TempDir()
+	return c
+}
+
+// checkCascade checks whether the face detection cascade can be loaded and unpacked.
+func checkCascade() Check {
+	c := Check{Name: "cascade"}
+	if _, err := new(Processor).unpackCascade(); err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		return c
+	}
+	c.Status, c.Detail = CheckPass, "the face detection cascade has been unpacked"
+	return c
+}
+
+// checkSelfTest carves the embedded 64x64 image to 48x48 through the whole decoding,
+// carving and encoding process, and checks the size of the result.
+func checkSelfTest(ctx context.Context) Check {
+	c := Check{Name: "self-test"}
+	const size = 48
+
+	p := &Processor{NewWidth: size, NewHeight: size, BlurRadius: 1, SobelThreshold: 2, OutputFormat: FormatPNG}
+	var out bytes.Buffer
+	start := time.Now()
+	if err := p.ProcessContext(ctx, bytes.NewReader(selfTestImage), &out); err != nil {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("carving the test image failed: %v", err)
+		return c
+	}
+	elapsed := time.Since(start)
+
+	res, err := png.Decode(&out)
+	if err != nil {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("the carved test image is invalid: %v", err)
+		return c
+	}
+	if res.Bounds() != image.Rect(0, 0, size, size) {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("the carved test image has the wrong size: %v", res.Bounds().Size())
+		return c
+	}
+	c.Status, c.Detail = CheckPass, fmt.Sprintf("the 64x64 test image has been carved to %dx%d in %v", size, size, elapsed.Round(time.Millisecond))
+	return c
+}
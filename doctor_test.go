@@ -0,0 +1,45 @@
+package caire
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctor_ShouldRunTheChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	d := Diagnose(context.Background())
+	assert.Len(d, 5)
+	names := make(map[string]Check)
+	for _, c := range d {
+		names[c.Name] = c
+		assert.Contains([]string{CheckPass, CheckWarn, CheckFail}, c.Status)
+		assert.NotEmpty(c.Detail)
+	}
+	assert.Equal(CheckPass, names["temp-dir"].Status)
+	assert.Equal(CheckPass, names["cascade"].Status)
+	assert.Equal(CheckPass, names["self-test"].Status, names["self-test"].Detail)
+	assert.False(d.Failed())
+
+	d = append(d, Check{Name: "other", Status: CheckFail})
+	assert.True(d.Failed())
+}
+
+func TestDoctor_ShouldDetectTheDisplayServer(t *testing.T) {
+	assert := assert.New(t)
+
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	c := checkDisplay("linux", env(nil))
+	if !previewAvailable {
+		assert.Equal(CheckWarn, c.Status)
+		return
+	}
+	assert.Equal(CheckWarn, c.Status)
+	assert.Equal(CheckPass, checkDisplay("linux", env(map[string]string{"DISPLAY": ":0"})).Status)
+	assert.Contains(checkDisplay("linux", env(map[string]string{"WAYLAND_DISPLAY": "wayland-0"})).Detail, "Wayland")
+	assert.Equal(CheckPass, checkDisplay("windows", env(nil)).Status)
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package caire
+
+// setDPIAwareness is a no-op, the scale factor of the display is reported by Gio.
+func setDPIAwareness() {}
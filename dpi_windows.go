@@ -0,0 +1,20 @@
+package caire
+
+import "syscall"
+
+// dpiAwarenessPerMonitorV2 is the DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 handle.
+const dpiAwarenessPerMonitorV2 = ^uintptr(3) // -4
+
+var procSetProcessDpiAwarenessContext = syscall.NewLazyDLL("user32.dll").NewProc("SetProcessDpiAwarenessContext")
+
+// setDPIAwareness makes the preview window aware of the scale factor of each monitor on
+// Windows 10 (version 1703) and later, so the window is rendered at the native resolution
+// when it's moved between monitors with different scaling, instead of being stretched by
+// the system. It must be called before creating the window. On the older versions the
+// system-wide DPI awareness set by Gio is used.
+func setDPIAwareness() {
+	if procSetProcessDpiAwarenessContext.Find() != nil {
+		return
+	}
+	procSetProcessDpiAwarenessContext.Call(dpiAwarenessPerMonitorV2)
+}
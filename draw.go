@@ -1,3 +1,5 @@
+//go:build !headless
+
 package caire
 
 import (
@@ -14,20 +16,64 @@ import (
 )
 
 const (
-	circle = "circle"
-	line   = "line"
+	circle   = "circle"
+	line     = "line"
+	arrow    = "arrow"
+	dotted   = "dotted"
+	gradient = "gradient"
+)
+
+const (
+	// defaultShapeSize is the size of the seam shapes, expressed in Dp units.
+	defaultShapeSize = 2
+	// dotSpacing is the distance between the dots of the dotted seams, expressed in seam points.
+	dotSpacing = 4
+	// arrowSpacing is the distance between the arrow heads, expressed in seam points.
+	arrowSpacing = 12
 )
 
 // DrawSeam visualizes the seam carver in action when the preview mode is activated.
-// It receives as parameters the shape type, the seam (x,y) coordinates and a dimension.
+// It receives as parameters the shape type, the seam (x,y) coordinates and a dimension,
+// all of them expressed in screen pixels.
 func (g *Gui) DrawSeam(shape string, x, y, dim float32) {
-	r := getRatio(g.cfg.window.w, g.cfg.window.h)
+	g.setFillColor(utils.HexToRGBA(g.cp.SeamColor))
 
 	switch shape {
 	case circle:
-		g.drawCircle(x*r, y*r, dim)
+		g.drawCircle(x, y, dim)
 	case line:
-		g.drawLine(x*r, y*r, dim)
+		g.drawLine(x, y, dim)
+	}
+}
+
+// DrawSeamPath visualizes a whole seam. Contrary to DrawSeam, it supports the shapes
+// which depend on the neighboring seam points: the dotted line, the arrows showing
+// the seam direction and the line fading out towards the start of the seam.
+func (g *Gui) DrawSeamPath(shape string, pts []f32.Point, dim float32) {
+	col := utils.HexToRGBA(g.cp.SeamColor)
+
+	switch shape {
+	case dotted:
+		g.setFillColor(col)
+		for i := 0; i < len(pts); i += dotSpacing {
+			g.drawCircle(pts[i].X, pts[i].Y, dim)
+		}
+	case arrow:
+		g.setFillColor(col)
+		g.drawPolyline(pts, dim/2)
+		for i := arrowSpacing; i < len(pts); i += arrowSpacing {
+			g.drawArrowHead(pts[i-1], pts[i], 3*dim)
+		}
+	case gradient:
+		for i := 1; i < len(pts); i++ {
+			col.A = gradientAlpha(i, len(pts))
+			g.setFillColor(col)
+			g.drawPolyline(pts[i-1:i+1], dim)
+		}
+	default:
+		for _, pt := range pts {
+			g.DrawSeam(shape, pt.X, pt.Y, dim)
+		}
 	}
 }
 
@@ -68,9 +114,6 @@ func (g *Gui) drawCircle(x, y, s float32) {
 	p1 = g.point(x+float32(sq), y).Sub(orig)
 	p2 = g.point(x-float32(sq), y).Sub(orig)
 
-	col := utils.HexToRGBA(g.cp.SeamColor)
-	g.setFillColor(col)
-
 	var path clip.Path
 	path.Begin(g.ctx.Ops)
 	path.Move(orig)
@@ -95,14 +138,71 @@ func (g *Gui) drawLine(x, y, thickness float32) {
 	path.Line(p2.Sub(path.Pos()))
 	path.Close()
 
-	col := utils.HexToRGBA(g.cp.SeamColor)
-	g.setFillColor(col)
-
 	defer clip.Stroke{Path: path.End(), Width: float32(thickness)}.Op().Push(g.ctx.Ops).Pop()
 	paint.ColorOp{Color: g.setColor(g.getFillColor())}.Add(g.ctx.Ops)
 	paint.PaintOp{}.Add(g.ctx.Ops)
 }
 
+// drawPolyline connects the seam points with a line of the provided thickness.
+func (g *Gui) drawPolyline(pts []f32.Point, thickness float32) {
+	if len(pts) < 2 {
+		return
+	}
+	var path clip.Path
+	path.Begin(g.ctx.Ops)
+	path.MoveTo(pts[0])
+	for _, pt := range pts[1:] {
+		path.LineTo(pt)
+	}
+
+	defer clip.Stroke{Path: path.End(), Width: thickness}.Op().Push(g.ctx.Ops).Pop()
+	paint.ColorOp{Color: g.setColor(g.getFillColor())}.Add(g.ctx.Ops)
+	paint.PaintOp{}.Add(g.ctx.Ops)
+}
+
+// drawArrowHead draws a filled arrow head at the to point, pointing in the from -> to direction.
+func (g *Gui) drawArrowHead(from, to f32.Point, size float32) {
+	head := arrowHead(from, to, size)
+
+	var path clip.Path
+	path.Begin(g.ctx.Ops)
+	path.MoveTo(head[0])
+	path.LineTo(head[1])
+	path.LineTo(head[2])
+	path.Close()
+
+	defer clip.Outline{Path: path.End()}.Op().Push(g.ctx.Ops).Pop()
+	paint.ColorOp{Color: g.setColor(g.getFillColor())}.Add(g.ctx.Ops)
+	paint.PaintOp{}.Add(g.ctx.Ops)
+}
+
+// arrowHead returns the vertices of the arrow head: the tip and the two back corners.
+func arrowHead(from, to f32.Point, size float32) [3]f32.Point {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		dx, dy, length = 0, 1, 1
+	}
+	// Unit vector of the direction and its normal.
+	ux, uy := dx/length, dy/length
+	nx, ny := -uy, ux
+
+	back := f32.Point{X: to.X - ux*size, Y: to.Y - uy*size}
+	return [3]f32.Point{
+		to,
+		{X: back.X + nx*size/2, Y: back.Y + ny*size/2},
+		{X: back.X - nx*size/2, Y: back.Y - ny*size/2},
+	}
+}
+
+// gradientAlpha returns the opacity of the i-th seam segment, increasing linearly along the seam.
+func gradientAlpha(i, n int) uint8 {
+	if n <= 1 {
+		return 0xff
+	}
+	return uint8(0xff * i / (n - 1))
+}
+
 // point converts the seam (x,y) coordinate to Gio f32.Point.
 func (g *Gui) point(x, y float32) f32.Point {
 	return f32.Point{
@@ -132,6 +232,18 @@ func (g *Gui) getFillColor() color.Color {
 	return g.cfg.color.fill
 }
 
+// fitScale returns the factor used by the widget.Contain fit option
+// for scaling an image of the provided size to the screen constraints.
+func fitScale(screen, size image.Point) float32 {
+	if size.X == 0 || size.Y == 0 {
+		return 1
+	}
+	sx := float32(screen.X) / float32(size.X)
+	sy := float32(screen.Y) / float32(size.Y)
+
+	return utils.Min(sx, sy)
+}
+
 // getRatio returns the image aspect ratio.
 func getRatio(w, h float32) float32 {
 	var r float32 = 1
@@ -0,0 +1,24 @@
+//go:build !headless
+
+package caire
+
+import (
+	"testing"
+
+	"gioui.org/f32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraw_ShouldComputeTheSeamShapes(t *testing.T) {
+	assert := assert.New(t)
+
+	head := arrowHead(f32.Point{X: 10, Y: 0}, f32.Point{X: 10, Y: 10}, 4)
+	assert.Equal(f32.Point{X: 10, Y: 10}, head[0])
+	assert.Equal(f32.Point{X: 8, Y: 6}, head[1])
+	assert.Equal(f32.Point{X: 12, Y: 6}, head[2])
+
+	assert.Equal(uint8(0), gradientAlpha(0, 5))
+	assert.Equal(uint8(0x7f), gradientAlpha(2, 5))
+	assert.Equal(uint8(0xff), gradientAlpha(4, 5))
+	assert.Equal(uint8(0xff), gradientAlpha(0, 1))
+}
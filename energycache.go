@@ -0,0 +1,135 @@
+package caire
+
+import (
+	"container/list"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// DefaultCacheSize is the capacity in bytes of the in-memory cache used when no cache is provided.
+const DefaultCacheSize = 64 << 20
+
+// Cache stores the energy maps computed in the first pass of the seam carving. Since multiple
+// target sizes of the same source share the first pass, the services producing several variants
+// of an image can skip its computation. The implementations should be safe for concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Add(key string, value []byte)
+}
+
+// LRUCache is an in-memory Cache evicting the least recently used entries
+// once the total size of the stored values exceeds its capacity.
+type LRUCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// defaultCache is shared by the processors having no cache configured.
+var defaultCache = NewLRUCache(DefaultCacheSize)
+
+// NewLRUCache returns a new LRU cache holding at most maxBytes of values.
+func NewLRUCache(maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored under the key.
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*lruEntry).value, true
+	}
+	return nil, false
+}
+
+// Add stores the value under the key. The values larger than the cache capacity are ignored.
+func (c *LRUCache) Add(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if int64(len(value)) > c.maxBytes {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.size -= int64(len(el.Value.(*lruEntry).value))
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	c.size += int64(len(value))
+
+	for c.size > c.maxBytes {
+		el := c.ll.Back()
+		entry := el.Value.(*lruEntry)
+		c.ll.Remove(el)
+		delete(c.items, entry.key)
+		c.size -= int64(len(entry.value))
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// cache returns the energy map cache of the processor.
+func (p *Processor) cache() Cache {
+	if p.Cache != nil {
+		return p.Cache
+	}
+	return defaultCache
+}
+
+// energyCacheKeys returns the cache keys of the sobel image and of the blurred energy map
+// of the current step. Only the first step of the resizing operation, performed on the
+// source image, is cached, this is why the keys are consumed by the first call.
+func (p *Processor) energyCacheKeys() (sobelKey, energyKey string) {
+	if p.energyHash == "" {
+		return "", ""
+	}
+	sobelKey = fmt.Sprintf("%s/%t/%s/%d/%v/%t/%t", p.energyHash, p.vRes,
+		p.Backend, p.SobelThreshold, p.ChannelWeights, p.Tileable, p.FixedPoint)
+	energyKey = fmt.Sprintf("%s/%d", sobelKey, p.BlurRadius)
+	p.energyHash = ""
+
+	return sobelKey, energyKey
+}
+
+// loadEnergy returns a copy of the cached image, if it matches the provided size.
+func (p *Processor) loadEnergy(key string, width, height int) (*image.NRGBA, bool) {
+	if key == "" {
+		return nil, false
+	}
+	data, ok := p.cache().Get(key)
+	if !ok || len(data) != width*height*4 {
+		return nil, false
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	copy(img.Pix, data)
+	return img, true
+}
+
+// storeEnergy stores a copy of the image in the cache.
+func (p *Processor) storeEnergy(key string, img *image.NRGBA) {
+	if key == "" {
+		return
+	}
+	p.cache().Add(key, append([]byte(nil), img.Pix...))
+}
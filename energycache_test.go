@@ -0,0 +1,71 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCache counts the cache hits of the wrapped cache.
+type countingCache struct {
+	*LRUCache
+	hits int
+}
+
+func (c *countingCache) Get(key string) ([]byte, bool) {
+	v, ok := c.LRUCache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return v, ok
+}
+
+func TestEnergyCache_ShouldEvictTheLeastRecentlyUsed(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewLRUCache(10)
+	c.Add("a", make([]byte, 4))
+	c.Add("b", make([]byte, 4))
+	_, ok := c.Get("a")
+	assert.True(ok)
+
+	c.Add("c", make([]byte, 4))
+	_, ok = c.Get("b")
+	assert.False(ok)
+	_, ok = c.Get("a")
+	assert.True(ok)
+	assert.Equal(2, c.Len())
+
+	c.Add("d", make([]byte, 11))
+	_, ok = c.Get("d")
+	assert.False(ok)
+}
+
+func TestEnergyCache_ShouldReuseTheFirstPass(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	resize := func(cache Cache, width int) image.Image {
+		proc := &Processor{BlurRadius: 2, SobelThreshold: 4, NewWidth: width, Cache: cache}
+		res, err := proc.Resize(img)
+		assert.NoError(err)
+		return res
+	}
+
+	cache := &countingCache{LRUCache: NewLRUCache(DefaultCacheSize)}
+	resize(cache, 36)
+	assert.Equal(0, cache.hits)
+	assert.Equal(2, cache.Len())
+
+	// The sobel image and the blurred energy map are reused for the second size.
+	res := resize(cache, 32)
+	assert.Equal(2, cache.hits)
+	assert.Equal(resize(NewLRUCache(DefaultCacheSize), 32), res)
+
+	// The energy options are part of the key.
+	proc := &Processor{BlurRadius: 3, SobelThreshold: 4, NewWidth: 32, Cache: cache}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(3, cache.hits)
+}
@@ -0,0 +1,88 @@
+package caire
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"time"
+)
+
+// energyLogger collects the energy statistics of the seams, which are saved into a CSV file
+// for comparing the energy functions without instrumenting the code.
+type energyLogger struct {
+	rows  []energyRow
+	start time.Time
+}
+
+// energyRow holds the statistics of a single seam removal or insertion.
+type energyRow struct {
+	direction string
+	inserted  bool
+	total     int
+	min, max  uint8
+	runtime   time.Duration
+}
+
+// begin marks the start of the resizing step.
+func (l *energyLogger) begin() {
+	if l == nil {
+		return
+	}
+	l.start = time.Now()
+}
+
+// record stores the energy of the seam cells, looked up in the energy map the seam has been
+// found in. The seams of the rotated image (vertical resizing) are horizontal ones.
+func (l *energyLogger) record(energy *image.NRGBA, seams []Seam, rotated, inserted bool) {
+	if l == nil || energy == nil || len(seams) == 0 {
+		return
+	}
+	row := energyRow{direction: "vertical", inserted: inserted, min: 0xff}
+	if rotated {
+		row.direction = "horizontal"
+	}
+	for _, s := range seams {
+		v := energy.Pix[energy.PixOffset(s.X, s.Y)]
+		row.total += int(v)
+		row.min, row.max = min(row.min, v), max(row.max, v)
+	}
+	row.runtime = time.Since(l.start)
+	l.rows = append(l.rows, row)
+}
+
+// writeEnergyCSV saves the statistics of the seams into the provided CSV file, one row per seam.
+func (p *Processor) writeEnergyCSV(path string) error {
+	if p.energyLog == nil {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the energy log file: %v", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"seam", "direction", "operation", "total_energy", "min_energy", "max_energy", "runtime_ms"})
+	for i, row := range p.energyLog.rows {
+		op := "remove"
+		if row.inserted {
+			op = "insert"
+		}
+		w.Write([]string{
+			strconv.Itoa(i),
+			row.direction,
+			op,
+			strconv.Itoa(row.total),
+			strconv.Itoa(int(row.min)),
+			strconv.Itoa(int(row.max)),
+			strconv.FormatFloat(float64(row.runtime.Microseconds())/1000, 'f', 3, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
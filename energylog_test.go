@@ -0,0 +1,76 @@
+package caire
+
+import (
+	"encoding/csv"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyLog_ShouldRecordTheSeamEnergy(t *testing.T) {
+	assert := assert.New(t)
+
+	energy := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for i := range energy.Pix {
+		energy.Pix[i] = uint8(i / 4 * 10)
+	}
+	seam := []Seam{{X: 1, Y: 2}, {X: 0, Y: 1}, {X: 1, Y: 0}}
+
+	l := &energyLogger{}
+	l.begin()
+	l.record(energy, seam, false, false)
+	l.record(energy, seam, true, true)
+	// The seams found without the energy map (fast mode) are not logged.
+	l.record(nil, seam, false, false)
+
+	assert.Len(l.rows, 2)
+	assert.Equal("vertical", l.rows[0].direction)
+	assert.Equal("horizontal", l.rows[1].direction)
+	assert.True(l.rows[1].inserted)
+	assert.Equal(70+30+10, l.rows[0].total)
+	assert.Equal(uint8(10), l.rows[0].min)
+	assert.Equal(uint8(70), l.rows[0].max)
+
+	var nilLog *energyLogger
+	nilLog.begin()
+	nilLog.record(energy, seam, false, false)
+}
+
+func TestEnergyLog_ShouldWriteTheCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "energy.csv")
+	proc := &Processor{
+		SobelThreshold: 4,
+		NewWidth:       25,
+		FastMode:       true,
+		EnergyCSVPath:  path,
+		energyLog:      &energyLogger{},
+	}
+	_, err := proc.Resize(texturedImage(30, 20))
+	assert.NoError(err)
+	assert.NoError(proc.writeEnergyCSV(path))
+
+	f, err := os.Open(path)
+	assert.NoError(err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(err)
+	assert.Len(rows, 6)
+	assert.Equal([]string{"seam", "direction", "operation", "total_energy", "min_energy", "max_energy", "runtime_ms"}, rows[0])
+	for i, row := range rows[1:] {
+		assert.Equal(strconv.Itoa(i), row[0])
+		assert.Equal("vertical", row[1])
+		assert.Equal("remove", row[2])
+		total, _ := strconv.Atoi(row[3])
+		lo, _ := strconv.Atoi(row[4])
+		hi, _ := strconv.Atoi(row[5])
+		assert.LessOrEqual(lo, hi)
+		assert.GreaterOrEqual(total, 20*lo)
+		assert.LessOrEqual(total, 20*hi)
+	}
+}
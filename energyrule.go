@@ -0,0 +1,173 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/esimov/caire/utils"
+)
+
+// biasNeutral is the gray level of the energy bias map which leaves the energy unchanged.
+// The lighter pixels increase, while the darker pixels decrease the pixel energy.
+const biasNeutral = 128
+
+// EnergyRule describes an image region whose energy is boosted (protected from carving)
+// or reduced (removed first), without the need of providing a mask file.
+type EnergyRule struct {
+	// Shape is the region type: "rect", "circle" or "ellipse".
+	Shape string
+	// Args are the region parameters:
+	//	rect: x, y, width, height
+	//	circle: center x, center y, radius
+	//	ellipse: center x, center y, radius x, radius y
+	Args []float64
+	// Weight is the energy added to the region, between -1 and 1.
+	Weight float64
+}
+
+// shapeArgs holds the number of arguments required by each rule shape.
+var shapeArgs = map[string]int{
+	"rect":    4,
+	"circle":  3,
+	"ellipse": 4,
+}
+
+// ParseEnergyRules parses the semicolon separated energy rules expressed as shape(args):weight,
+// for example: "rect(0,0,200,100):+0.8; circle(512,512,100):-0.5".
+func ParseEnergyRules(s string) ([]EnergyRule, error) {
+	var rules []EnergyRule
+
+	for _, expr := range strings.Split(s, ";") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		rule, err := parseEnergyRule(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid energy rule %q: %v", expr, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseEnergyRule parses a single shape(args):weight expression.
+func parseEnergyRule(expr string) (EnergyRule, error) {
+	var rule EnergyRule
+
+	open := strings.IndexByte(expr, '(')
+	end := strings.LastIndexByte(expr, ')')
+	if open < 0 || end < open {
+		return rule, fmt.Errorf("the shape(args):weight format is expected")
+	}
+
+	rule.Shape = strings.ToLower(strings.TrimSpace(expr[:open]))
+	n, ok := shapeArgs[rule.Shape]
+	if !ok {
+		return rule, fmt.Errorf("unknown shape %q", rule.Shape)
+	}
+
+	args := strings.Split(expr[open+1:end], ",")
+	if len(args) != n {
+		return rule, fmt.Errorf("the %s shape requires %d arguments", rule.Shape, n)
+	}
+	for _, arg := range args {
+		v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return rule, fmt.Errorf("invalid argument %q", arg)
+		}
+		rule.Args = append(rule.Args, v)
+	}
+
+	weight, found := strings.CutPrefix(strings.TrimSpace(expr[end+1:]), ":")
+	if !found {
+		return rule, fmt.Errorf("missing weight")
+	}
+	w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+	if err != nil || w < -1 || w > 1 {
+		return rule, fmt.Errorf("the weight should be a number between -1 and 1")
+	}
+	rule.Weight = w
+
+	return rule, nil
+}
+
+// contains reports whether the pixel center of (x, y) is inside the rule region.
+func (r EnergyRule) contains(x, y int) bool {
+	px, py := float64(x)+0.5, float64(y)+0.5
+	a := r.Args
+
+	switch r.Shape {
+	case "rect":
+		return px >= a[0] && px < a[0]+a[2] && py >= a[1] && py < a[1]+a[3]
+	case "circle":
+		return math.Hypot(px-a[0], py-a[1]) <= a[2]
+	case "ellipse":
+		if a[2] <= 0 || a[3] <= 0 {
+			return false
+		}
+		dx, dy := (px-a[0])/a[2], (py-a[1])/a[3]
+		return dx*dx+dy*dy <= 1
+	}
+	return false
+}
+
+// renderBiasMap rasterizes the energy rules and the prioritized regions into a bias map
+// of the provided size. The weights of the overlapping rules are summed up, then clamped
+// to the [-1, 1] range, while the pixels covered by a region take the region priority.
+func renderBiasMap(rules []EnergyRule, regions []Region, bounds image.Rectangle) *image.NRGBA {
+	dst := image.NewNRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var w float64
+			for _, r := range rules {
+				if r.contains(x, y) {
+					w += r.Weight
+				}
+			}
+			if pr, ok := regionPriority(regions, x, y); ok {
+				w = float64(pr)
+			}
+			w = math.Max(-1, math.Min(1, w))
+			v := uint8(biasNeutral + math.Round(w*(biasNeutral-1)))
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = v, v, v, 0xff
+		}
+	}
+	return dst
+}
+
+// rotateBiasMap rotates the bias map (and the color block labels) together with the image
+// in case of the vertical resizing.
+func (p *Processor) rotateBiasMap(rotate func(*image.NRGBA) *image.NRGBA) {
+	if p.biasMap != nil {
+		p.biasMap = rotate(p.biasMap)
+	}
+	p.blocks.rotate(rotate)
+}
+
+// applyEnergyBias adjusts the sobel image with the energy bias map obtained from the energy rules.
+func (p *Processor) applyEnergyBias(sobel *image.NRGBA) {
+	if p.biasMap == nil {
+		return
+	}
+	bounds := sobel.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			bias := int(p.biasMap.Pix[p.biasMap.PixOffset(x, y)]) - biasNeutral
+			if bias == 0 {
+				continue
+			}
+			i := sobel.PixOffset(x, y)
+			for ch := 0; ch < 3; ch++ {
+				v := int(sobel.Pix[i+ch]) + bias*0xff/(biasNeutral-1)
+				sobel.Pix[i+ch] = uint8(utils.Max(0, utils.Min(v, 0xff)))
+			}
+		}
+	}
+}
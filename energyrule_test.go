@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyRule_ShouldParseTheRules(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := ParseEnergyRules("rect(0,0,200,100):+0.8; circle(512, 512, 100):-0.5;")
+	assert.NoError(err)
+	assert.Equal([]EnergyRule{
+		{Shape: "rect", Args: []float64{0, 0, 200, 100}, Weight: 0.8},
+		{Shape: "circle", Args: []float64{512, 512, 100}, Weight: -0.5},
+	}, rules)
+
+	for _, expr := range []string{
+		"rect(0,0,200):0.5",
+		"square(0,0,10):0.5",
+		"circle(0,0,10)",
+		"circle(0,0,10):2",
+		"circle(0,a,10):1",
+	} {
+		_, err := ParseEnergyRules(expr)
+		assert.Error(err, expr)
+	}
+
+	bias := renderBiasMap([]EnergyRule{
+		{Shape: "rect", Args: []float64{0, 0, 4, 4}, Weight: 0.8},
+		{Shape: "rect", Args: []float64{2, 2, 4, 4}, Weight: 0.8},
+	}, nil, image.Rect(0, 0, 8, 8))
+	assert.Equal(uint8(128), bias.NRGBAAt(7, 7).R)
+	assert.Equal(uint8(230), bias.NRGBAAt(0, 0).R)
+	assert.Equal(uint8(255), bias.NRGBAAt(3, 3).R)
+}
+
+func TestEnergyRule_ShouldRemoveTheRegion(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+
+	proc := &Processor{
+		SobelThreshold: 4,
+		NewWidth:       35,
+		SeamsSVGPath:   "seams.svg",
+		EnergyRules:    []EnergyRule{{Shape: "rect", Args: []float64{20, 0, 10, 30}, Weight: -1}},
+	}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+
+	assert.Len(proc.tracker.removed, 5)
+	for _, seam := range proc.tracker.removed {
+		for _, pt := range seam {
+			assert.True(pt.X >= 20 && pt.X < 30)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package caire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// exifMaxSize is the maximum number of bytes read from the beginning of the JPEG files when
+// searching for the EXIF metadata, which is stored in an APP1 segment of at most 64KB.
+const exifMaxSize = 1 << 17
+
+// EXIF tags and value types used for locating the subject.
+const (
+	exifIFDPointer  = 0x8769
+	exifSubjectArea = 0x9214
+	exifSubjectLoc  = 0xa214
+	exifPixelXDim   = 0xa002
+	exifPixelYDim   = 0xa003
+	exifTypeShort   = 3
+	exifTypeLong    = 4
+)
+
+// exifPointDivisor defines the size of the area protected around the subject recorded as a
+// point, as a fraction of the shorter image side.
+const exifPointDivisor = 5
+
+// exifSubject returns the subject area recorded by the camera in the EXIF metadata of the JPEG
+// data, in the coordinates of an image of the provided size. The SubjectArea tag can hold the
+// subject position as a point, a circle or a rectangle, while the SubjectLocation tag only as a
+// point. Since a point doesn't tell the subject size, it's surrounded by a square having the
+// fifth of the shorter image side.
+func exifSubject(data []byte, size image.Point) (image.Rectangle, bool) {
+	tiff := exifSegment(data)
+	if len(tiff) < 8 {
+		return image.Rectangle{}, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return image.Rectangle{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	ptr, ok := ifd0[exifIFDPointer]
+	if !ok || len(ptr) != 1 {
+		return image.Rectangle{}, false
+	}
+	tags := readIFD(tiff, order, uint32(ptr[0]))
+
+	area, ok := tags[exifSubjectArea]
+	if !ok {
+		area, ok = tags[exifSubjectLoc]
+	}
+	if !ok || len(area) < 2 {
+		return image.Rectangle{}, false
+	}
+
+	// The coordinates are relative to the image dimensions recorded by the camera.
+	scaleX, scaleY := 1.0, 1.0
+	if w, h := tags[exifPixelXDim], tags[exifPixelYDim]; len(w) == 1 && len(h) == 1 && w[0] > 0 && h[0] > 0 {
+		scaleX, scaleY = float64(size.X)/float64(w[0]), float64(size.Y)/float64(h[0])
+	}
+
+	cx, cy := float64(area[0]), float64(area[1])
+	var rw, rh float64
+	switch len(area) {
+	case 3:
+		rw, rh = float64(area[2]), float64(area[2])
+	case 4:
+		rw, rh = float64(area[2]), float64(area[3])
+	default:
+		side := float64(min(size.X, size.Y)) / exifPointDivisor
+		rw, rh = side/scaleX, side/scaleY
+	}
+	rect := image.Rect(
+		int((cx-rw/2)*scaleX), int((cy-rh/2)*scaleY),
+		int((cx+rw/2)*scaleX+0.5), int((cy+rh/2)*scaleY+0.5),
+	).Intersect(image.Rect(0, 0, size.X, size.Y))
+
+	return rect, !rect.Empty()
+}
+
+// exifSegment returns the TIFF structure stored in the EXIF segment of the JPEG data.
+func exifSegment(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		// The EXIF segment precedes the image data.
+		if marker == 0xda || marker == 0xd9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := min(i+2+length, len(data))
+		if payload := data[i+4 : end]; marker == 0xe1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return payload[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// readIFD reads the short and long integer values of the entries of the image file directory
+// found at the provided offset of the TIFF structure. The other value types are skipped.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]uint32 {
+	tags := make(map[uint16][]uint32)
+	if int64(offset)+2 > int64(len(tiff)) {
+		return tags
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < n; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag, typ := order.Uint16(tiff[entry:]), order.Uint16(tiff[entry+2:])
+		count := order.Uint32(tiff[entry+4:])
+
+		size := 2
+		if typ == exifTypeLong {
+			size = 4
+		} else if typ != exifTypeShort {
+			continue
+		}
+		// The values fitting in 4 bytes are stored in place of the offset.
+		start := int64(entry + 8)
+		if int64(count)*int64(size) > 4 {
+			start = int64(order.Uint32(tiff[entry+8:]))
+		}
+		if count > 16 || start+int64(count)*int64(size) > int64(len(tiff)) {
+			continue
+		}
+		values := make([]uint32, count)
+		for j := range values {
+			pos := start + int64(j*size)
+			if size == 2 {
+				values[j] = uint32(order.Uint16(tiff[pos:]))
+			} else {
+				values[j] = order.Uint32(tiff[pos:])
+			}
+		}
+		tags[tag] = values
+	}
+	return tags
+}
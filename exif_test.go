@@ -0,0 +1,132 @@
+package caire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifJPEG encodes the image as JPEG, having an EXIF segment with the provided subject area
+// and image dimensions, using the byte order of the order argument.
+func exifJPEG(t *testing.T, img image.Image, order binary.ByteOrder, area []uint16, dims [2]uint16) []byte {
+	var tiff bytes.Buffer
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	write := func(v any) { binary.Write(&tiff, order, v) }
+	write(uint16(42))
+	write(uint32(8))
+
+	// IFD0 with the pointer to the EXIF IFD, placed right after it.
+	write(uint16(1))
+	write([]uint16{exifIFDPointer, exifTypeLong})
+	write(uint32(1))
+	write(uint32(8 + 2 + 12 + 4))
+	write(uint32(0))
+
+	// The EXIF IFD, followed by the subject area values which don't fit in the entry.
+	exifIFD := 8 + 2 + 12 + 4
+	write(uint16(3))
+	write([]uint16{exifSubjectArea, exifTypeShort})
+	write(uint32(len(area)))
+	if len(area) <= 2 {
+		write(append(area, make([]uint16, 2-len(area))...))
+	} else {
+		write(uint32(exifIFD + 2 + 3*12 + 4))
+	}
+	write([]uint16{exifPixelXDim, exifTypeShort})
+	write(uint32(1))
+	write([]uint16{dims[0], 0})
+	write([]uint16{exifPixelYDim, exifTypeShort})
+	write(uint32(1))
+	write([]uint16{dims[1], 0})
+	write(uint32(0))
+	if len(area) > 2 {
+		write(area)
+	}
+
+	var enc bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&enc, img, nil))
+	data := enc.Bytes()
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(2+6+tiff.Len()))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff.Bytes())
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestExif_ShouldParseTheSubjectArea(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	size := img.Bounds().Size()
+
+	// The rectangle is defined by its center and size.
+	data := exifJPEG(t, img, binary.LittleEndian, []uint16{30, 20, 20, 10}, [2]uint16{100, 50})
+	rect, ok := exifSubject(data, size)
+	assert.True(ok)
+	assert.Equal(image.Rect(20, 15, 40, 25), rect)
+
+	// The coordinates are scaled to the decoded image size.
+	data = exifJPEG(t, img, binary.BigEndian, []uint16{60, 40, 40, 20}, [2]uint16{200, 100})
+	rect, ok = exifSubject(data, size)
+	assert.True(ok)
+	assert.Equal(image.Rect(20, 15, 40, 25), rect)
+
+	// A circle is protected by its bounding square.
+	data = exifJPEG(t, img, binary.LittleEndian, []uint16{50, 25, 10}, [2]uint16{100, 50})
+	rect, ok = exifSubject(data, size)
+	assert.True(ok)
+	assert.Equal(image.Rect(45, 20, 55, 30), rect)
+
+	// A point is surrounded by a square of the fifth of the shorter side.
+	data = exifJPEG(t, img, binary.LittleEndian, []uint16{50, 25}, [2]uint16{100, 50})
+	rect, ok = exifSubject(data, size)
+	assert.True(ok)
+	assert.Equal(image.Rect(45, 20, 55, 30), rect)
+
+	var plain bytes.Buffer
+	assert.NoError(jpeg.Encode(&plain, img, nil))
+	_, ok = exifSubject(plain.Bytes(), size)
+	assert.False(ok)
+	_, ok = exifSubject(data[:30], size)
+	assert.False(ok)
+}
+
+func TestExif_ShouldProtectTheSubject(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for x := 0; x < 60; x++ {
+		for y := 0; y < 40; y++ {
+			img.Set(x, y, color.NRGBA{R: 120, G: 120, B: 120, A: 255})
+		}
+	}
+	data := exifJPEG(t, img, binary.LittleEndian, []uint16{15, 20, 10, 10}, [2]uint16{60, 40})
+
+	p := &Processor{SobelThreshold: 2, NewWidth: 50, UseExifSubject: true, Rotate: 180}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(data), &out))
+	// The subject is rotated together with the image.
+	assert.Equal(image.Rect(40, 15, 50, 25), p.subject.Rect)
+	assert.Equal(PriorityMustKeep, p.subject.Priority)
+
+	res, err := jpeg.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 40), res.Bounds())
+
+	p = &Processor{SobelThreshold: 2, NewWidth: 50}
+	assert.NoError(p.Process(bytes.NewReader(data), &out))
+	assert.Nil(p.subject)
+}
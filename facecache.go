@@ -0,0 +1,129 @@
+package caire
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+// faceCache persists the face detection results of an image on disk. The faces are detected
+// on each intermediate image of the carving process, so the results are stored per step,
+// keyed by the content hash of the intermediate image. This way the repeated runs with the
+// same options are skipping the detection entirely, while the changed options are only
+// causing cache misses for the diverging steps.
+type faceCache struct {
+	path  string
+	steps map[string][]pigo.Detection
+	dirty bool
+}
+
+// loadFaceCache loads the face detection cache of the source image from the cache directory.
+// The cache file is named after the SHA-256 hash of the image content.
+func loadFaceCache(dir string, img *image.NRGBA) (*faceCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the face cache directory: %v", err)
+	}
+	fc := &faceCache{
+		path:  filepath.Join(dir, imageHash(img, 0)+".json"),
+		steps: make(map[string][]pigo.Detection),
+	}
+
+	data, err := os.ReadFile(fc.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the face cache: %v", err)
+	}
+	// A corrupted cache file is discarded and rebuilt.
+	if err := json.Unmarshal(data, &fc.steps); err != nil {
+		fc.steps = make(map[string][]pigo.Detection)
+	}
+	return fc, nil
+}
+
+// imageHash returns the hex encoded SHA-256 hash of the image content and the face rotation angle.
+func imageHash(img *image.NRGBA, angle float64) string {
+	h := sha256.New()
+
+	var buf [24]byte
+	binary.LittleEndian.PutUint64(buf[0:], uint64(img.Bounds().Dx()))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(img.Bounds().Dy()))
+	binary.LittleEndian.PutUint64(buf[16:], math.Float64bits(angle))
+	h.Write(buf[:])
+
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		i := img.PixOffset(img.Rect.Min.X, y)
+		h.Write(img.Pix[i : i+img.Rect.Dx()*4])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// key returns the cache key of the intermediate image. It returns an empty key if the cache is not used.
+func (fc *faceCache) key(img *image.NRGBA, angle float64) string {
+	if fc == nil {
+		return ""
+	}
+	return imageHash(img, angle)
+}
+
+// lookup returns the cached detection results.
+func (fc *faceCache) lookup(key string) ([]pigo.Detection, bool) {
+	if fc == nil {
+		return nil, false
+	}
+	dets, ok := fc.steps[key]
+	return dets, ok
+}
+
+// store records the detection results of the intermediate image.
+func (fc *faceCache) store(key string, dets []pigo.Detection) {
+	if fc == nil {
+		return
+	}
+	if dets == nil {
+		dets = []pigo.Detection{}
+	}
+	fc.steps[key] = dets
+	fc.dirty = true
+}
+
+// save writes the cache file in case new detection results have been recorded.
+// The file is replaced atomically, so the concurrent runs never read a partially written cache.
+func (fc *faceCache) save() error {
+	if fc == nil || !fc.dirty {
+		return nil
+	}
+	data, err := json.Marshal(fc.steps)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fc.path), ".facecache-*")
+	if err != nil {
+		return fmt.Errorf("unable to save the face cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save the face cache: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save the face cache: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), fc.path); err != nil {
+		return fmt.Errorf("unable to save the face cache: %v", err)
+	}
+	fc.dirty = false
+	return nil
+}
@@ -0,0 +1,74 @@
+package caire
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	pigo "github.com/esimov/pigo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaceCache_ShouldReuseTheDetections(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.Open(filepath.Join("./testdata", "sample.jpg"))
+	if err != nil {
+		t.Fatalf("could not load sample image: %v", err)
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("error decoding image: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imaging.Resize(src, 200, 0, imaging.Box)); err != nil {
+		t.Fatalf("error encoding image: %v", err)
+	}
+
+	dir := t.TempDir()
+	process := func() ([]byte, error) {
+		detAttempts, isFaceDetected = 0, false
+		proc := &Processor{
+			NewWidth:     195,
+			FaceDetect:   true,
+			FaceCacheDir: dir,
+			OutputFormat: FormatPNG,
+		}
+		var out bytes.Buffer
+		err := proc.Process(bytes.NewReader(buf.Bytes()), &out)
+		return out.Bytes(), err
+	}
+
+	first, err := process()
+	assert.NoError(err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Len(files, 1)
+
+	second, err := process()
+	assert.NoError(err)
+	assert.Equal(first, second)
+
+	// Replace the cached detections with a face which is larger than the requested size.
+	// The resizing fails only if the cached results are used instead of running the detector.
+	data, err := os.ReadFile(files[0])
+	assert.NoError(err)
+	steps := make(map[string][]pigo.Detection)
+	assert.NoError(json.Unmarshal(data, &steps))
+	assert.NotEmpty(steps)
+	for key := range steps {
+		steps[key] = []pigo.Detection{{Row: 50, Col: 50, Scale: 500, Q: 10}}
+	}
+	data, _ = json.Marshal(steps)
+	assert.NoError(os.WriteFile(files[0], data, 0644))
+
+	_, err = process()
+	assert.Error(err)
+}
@@ -0,0 +1,91 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/esimov/caire/utils"
+	pigo "github.com/esimov/pigo/core"
+)
+
+// minFaceQuality is the minimum detection score of the faces protected from carving.
+const minFaceQuality = 5.0
+
+// detectFaces runs the face classifier over the image, using the same parameters as the seam carver.
+func (p *Processor) detectFaces(c *Carver, img *image.NRGBA) []pigo.Detection {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	var ratio float64
+	if width < height {
+		ratio = float64(width) / float64(height)
+	} else {
+		ratio = float64(height) / float64(width)
+	}
+	minSize := float64(utils.Min(width, height)) * ratio / 3
+
+	// Transform the image to pixel array.
+	pixels := p.getBackend().grayscale(c, img)
+
+	cParams := pigo.CascadeParams{
+		MinSize:     int(minSize),
+		MaxSize:     utils.Min(width, height),
+		ShiftFactor: 0.1,
+		ScaleFactor: 1.1,
+
+		ImageParams: pigo.ImageParams{
+			Pixels: pixels,
+			Rows:   height,
+			Cols:   width,
+			Dim:    width,
+		},
+	}
+	defer p.startSpan(SpanFaceDetect)()
+
+	// Run the classifier over the obtained leaf nodes and return the detection results.
+	// The result contains quadruplets representing the row, column, scale and detection score.
+	dets := p.FaceDetector.RunCascade(cParams, p.FaceAngle)
+
+	// Calculate the intersection over union (IoU) of two clusters.
+	return p.FaceDetector.ClusterDetections(dets, 0.1)
+}
+
+// faceRect returns the area protected around the detected face.
+func faceRect(face pigo.Detection) image.Rectangle {
+	scale := int(float64(face.Scale) / 1.7)
+	return image.Rect(
+		face.Col-scale,
+		face.Row-scale,
+		face.Col+scale,
+		face.Row+scale,
+	)
+}
+
+// FaceMask detects the human faces and returns a protection mask of the same size as the image,
+// having the face areas filled with white over black background. The mask can be inspected and
+// edited by hand, then provided as protective mask to the seam carver.
+func (p *Processor) FaceMask(src image.Image) (*image.NRGBA, error) {
+	if p.FaceDetector == nil {
+		det, err := p.unpackCascade()
+		if err != nil {
+			return nil, err
+		}
+		p.FaceDetector = det
+	}
+	img := p.imgToNRGBA(src)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < minImageSize || height < minImageSize {
+		return nil, fmt.Errorf("the image is too small: %dx%d", width, height)
+	}
+
+	mask := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(mask, mask.Bounds(), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+
+	for _, face := range p.detectFaces(NewCarver(width, height), img) {
+		if face.Q > minFaceQuality {
+			draw.Draw(mask, faceRect(face), &image.Uniform{color.White}, image.Point{}, draw.Src)
+		}
+	}
+	return mask, nil
+}
@@ -0,0 +1,47 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaceMask_ShouldProtectTheDetectedFaces(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.Open(filepath.Join("./testdata", "sample.jpg"))
+	assert.NoError(err)
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	assert.NoError(err)
+
+	proc := &Processor{}
+	mask, err := proc.FaceMask(src)
+	assert.NoError(err)
+	assert.Equal(src.Bounds().Size(), mask.Bounds().Size())
+
+	var white int
+	for i := 0; i < len(mask.Pix); i += 4 {
+		switch mask.Pix[i] {
+		case 0xff:
+			white++
+		case 0:
+		default:
+			t.Fatalf("the mask should be binary, got %d", mask.Pix[i])
+		}
+	}
+	assert.Greater(white, 0)
+	assert.Less(white, len(mask.Pix)/4/2)
+
+	empty := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	mask, err = proc.FaceMask(empty)
+	assert.NoError(err)
+	assert.Equal(color.NRGBA{A: 255}, mask.NRGBAAt(20, 15))
+
+	_, err = proc.FaceMask(image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	assert.Error(err)
+}
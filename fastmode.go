@@ -0,0 +1,196 @@
+package caire
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/filters"
+	"github.com/esimov/caire/utils"
+)
+
+const (
+	// minPyramidSize is the minimum image size for which the coarse-to-fine seam search is used.
+	minPyramidSize = 64
+	// defaultBandwidth is the half width of the band around the upscaled coarse seam,
+	// in which the seam is refined at full resolution.
+	defaultBandwidth = 3
+)
+
+// useFastMode reports whether the seam can be searched with the coarse-to-fine method.
+// The face detection, the tileable mode, the jitter, the seam density limit, the color blocks, the
+// debug snapshots, the energy log and the enlargement are relying on the full resolution energy map,
+// so in these cases the regular seam search is used.
+func (p *Processor) useFastMode(img *image.NRGBA) bool {
+	return (p.FastMode || p.preset().fastMode) && !p.FaceDetect && !p.Tileable && p.jitterRand == nil &&
+		p.MaxSeamsPerRegion == 0 && p.ColorBlockKeep == 0 && p.DebugSnapshot == nil &&
+		p.energyLog == nil && len(energySeams) == 0 &&
+		img.Bounds().Dx() >= minPyramidSize && img.Bounds().Dy() >= minPyramidSize
+}
+
+// findSeamCoarseToFine finds an approximate seam on the half sized copy of the image,
+// then refines it at full resolution inside a narrow band around the upscaled coarse seam.
+// This way the full resolution energy is computed only for a fraction of the pixels.
+func (c *Carver) findSeamCoarseToFine(p *Processor, img *image.NRGBA) ([]Seam, error) {
+	defer p.startEnergy()()
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	sw, sh := width/2, height/2
+
+	coarse := &Processor{
+		SobelThreshold: p.SobelThreshold,
+		BlurRadius:     p.BlurRadius / 2,
+		ChannelWeights: p.ChannelWeights,
+		MaskPath:       p.MaskPath,
+		RMaskPath:      p.RMaskPath,
+		FixedPoint:     p.FixedPoint,
+		backend:        p.backend,
+		vRes:           p.vRes,
+	}
+	if p.Mask != nil {
+		coarse.Mask = imaging.Resize(p.Mask, sw, sh, imaging.NearestNeighbor)
+	}
+	if p.RMask != nil {
+		coarse.RMask = imaging.Resize(p.RMask, sw, sh, imaging.NearestNeighbor)
+	}
+	if p.biasMap != nil {
+		coarse.biasMap = imaging.Resize(p.biasMap, sw, sh, imaging.Box)
+	}
+
+	cc := NewCarver(sw, sh)
+	cc.workers = c.workers
+	if _, err := cc.ComputeSeams(coarse, imaging.Resize(img, sw, sh, imaging.Box)); err != nil {
+		return nil, err
+	}
+	// The coarse seam is ordered from the bottom row to the top row.
+	path := cc.FindLowestEnergySeams(coarse)
+	centers := make([]int, height)
+	for _, s := range path {
+		for y := s.Y * 2; y < utils.Min(s.Y*2+2, height); y++ {
+			centers[y] = s.X*2 + 1
+		}
+	}
+	// The last row is left out in case of odd image heights.
+	if height%2 != 0 {
+		centers[height-1] = centers[height-2]
+	}
+
+	p.GuiDebug = image.NewNRGBA(img.Bounds())
+	return c.refineSeam(p, img, centers, p.bandwidth()), nil
+}
+
+// bandwidth returns the half width of the band used for refining the seams.
+func (p *Processor) bandwidth() int {
+	if p.Bandwidth > 0 {
+		return p.Bandwidth
+	}
+	return p.preset().bandwidth
+}
+
+// refineSeam computes the minimum energy seam at full resolution restricted to the band
+// of the provided radius around the seam centers. The wider the band, the closer the seam
+// is to the optimal one, but the more pixels have to be processed.
+func (c *Carver) refineSeam(p *Processor, img *image.NRGBA, centers []int, radius int) []Seam {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	// There is no reason to process a band wider than the image.
+	radius = utils.Min(radius, width)
+	band := 2*radius + 1
+
+	energy := p.pixelEnergies(img)
+	lo := make([]int, height)
+	cost := make([]float64, height*band)
+	for y := 0; y < height; y++ {
+		lo[y] = centers[y] - radius
+		for j := 0; j < band; j++ {
+			x := lo[y] + j
+			e := math.Inf(1)
+			if x >= 0 && x < width {
+				e = energy(x, y)
+			}
+			if y > 0 && !math.IsInf(e, 1) {
+				min := math.Inf(1)
+				for dx := -1; dx <= 1; dx++ {
+					if k := x + dx - lo[y-1]; k >= 0 && k < band {
+						min = math.Min(min, cost[(y-1)*band+k])
+					}
+				}
+				e += min
+			}
+			cost[y*band+j] = e
+		}
+	}
+
+	// Walk up from the lowest cumulative energy of the last row.
+	best := 0
+	for j := 1; j < band; j++ {
+		if cost[(height-1)*band+j] < cost[(height-1)*band+best] {
+			best = j
+		}
+	}
+	px := lo[height-1] + best
+	seams := []Seam{{X: px, Y: height - 1}}
+
+	for y := height - 2; y >= 0; y-- {
+		next, min := px, math.Inf(1)
+		for dx := -1; dx <= 1; dx++ {
+			if k := px + dx - lo[y]; k >= 0 && k < band && cost[y*band+k] < min {
+				next, min = px+dx, cost[y*band+k]
+			}
+		}
+		px = next
+		seams = append(seams, Seam{X: px, Y: y})
+	}
+	return seams
+}
+
+// pixelEnergies returns the function computing the energy of the single pixels of the image,
+// equal to the one of the full energy map. The energy is in the [0, 1] range, or an integer in
+// the [0, 255] range with the FixedPoint option. The edges are detected with the same sobel
+// variant and channel weights, the masks and the energy bias map are applied, then the energy
+// is blurred by the blur radius. The unblurred energies of the pixels are memoized, since the
+// blur of the neighboring pixels is reusing them.
+func (p *Processor) pixelEnergies(img *image.NRGBA) func(x, y int) float64 {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	isWhite := func(m *image.NRGBA, x, y int) bool {
+		i := m.PixOffset(x, y)
+		return m.Pix[i] == 0xff && m.Pix[i+1] == 0xff && m.Pix[i+2] == 0xff
+	}
+	edge := func(x, y int) uint8 {
+		e := filters.SobelAt(img, y*width+x, float64(p.SobelThreshold), p.ChannelWeights, p.FixedPoint)
+		if len(p.MaskPath) > 0 && p.Mask != nil && isWhite(p.Mask, x, y) {
+			e = 0xff
+		}
+		if len(p.RMaskPath) > 0 && p.RMask != nil && isWhite(p.RMask, x, y) {
+			e = 0
+		}
+		if p.biasMap != nil {
+			bias := int(p.biasMap.Pix[p.biasMap.PixOffset(x, y)]) - biasNeutral
+			e = uint8(utils.Max(0, utils.Min(int(e)+bias*0xff/(biasNeutral-1), 0xff)))
+		}
+		return e
+	}
+
+	energy := edge
+	if p.BlurRadius > 0 {
+		// The memoized energies are offset by one, so the zero value marks the missing ones.
+		edges := make([]uint16, width*height)
+		memo := func(x, y int) uint8 {
+			i := y*width + x
+			if edges[i] == 0 {
+				edges[i] = uint16(edge(x, y)) + 1
+			}
+			return uint8(edges[i] - 1)
+		}
+		energy = func(x, y int) uint8 {
+			return filters.StackBlurAt(memo, x, y, width, height, uint32(p.BlurRadius))
+		}
+	}
+
+	return func(x, y int) float64 {
+		if p.FixedPoint {
+			return float64(energy(x, y))
+		}
+		return float64(energy(x, y)) / 255
+	}
+}
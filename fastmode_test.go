@@ -0,0 +1,125 @@
+package caire
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/esimov/caire/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastMode_ShouldApproximateTheRegularCarving(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.Open(filepath.Join("./testdata", "sample.jpg"))
+	if err != nil {
+		t.Fatalf("could not load sample image: %v", err)
+	}
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("error decoding image: %v", err)
+	}
+
+	resize := func(fast bool) *image.NRGBA {
+		proc := &Processor{
+			SobelThreshold: 4,
+			BlurRadius:     2,
+			NewWidth:       src.Bounds().Dx() - 20,
+			FastMode:       fast,
+			SeamsSVGPath:   "seams.svg",
+			EnergyRules:    []EnergyRule{{Shape: "rect", Args: []float64{0, 0, 100, 1000}, Weight: 1}},
+		}
+		res, err := proc.Resize(proc.imgToNRGBA(src))
+		assert.NoError(err)
+
+		// The protected region is preserved in both modes.
+		for _, seam := range proc.tracker.removed {
+			for _, pt := range seam {
+				assert.GreaterOrEqual(pt.X, 100)
+			}
+		}
+		return res.(*image.NRGBA)
+	}
+
+	regular, fast := resize(false), resize(true)
+	assert.Equal(regular.Bounds(), fast.Bounds())
+
+	cmp, err := Compare(regular, fast)
+	assert.NoError(err)
+	assert.Greater(cmp.SSIM, 0.8)
+}
+
+func TestFastMode_ShouldRefineTheSeamsInsideTheBand(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	centers := make([]int, 30)
+	for y := range centers {
+		centers[y] = 20
+	}
+
+	proc := &Processor{SobelThreshold: 4}
+	energy := func(seams []Seam) float64 {
+		var sum float64
+		pixelEnergy := proc.pixelEnergies(img)
+		for _, s := range seams {
+			sum += pixelEnergy(s.X, s.Y)
+		}
+		return sum
+	}
+
+	c := NewCarver(40, 30)
+	var prev float64
+	for i, radius := range []int{1, 3, 40} {
+		seams := c.refineSeam(proc, img, centers, radius)
+		assert.Len(seams, 30)
+		for j, s := range seams {
+			assert.Equal(29-j, s.Y)
+			assert.LessOrEqual(utils.Abs(s.X-20), radius)
+			if j > 0 {
+				assert.LessOrEqual(utils.Abs(s.X-seams[j-1].X), 1)
+			}
+		}
+		// The wider bands are finding seams with lower or equal energy.
+		e := energy(seams)
+		if i > 0 {
+			assert.LessOrEqual(e, prev)
+		}
+		prev = e
+	}
+}
+
+func TestFastMode_ShouldComputeThePixelEnergiesLikeTheEnergyMap(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(i*7), uint8(i*i%251), uint8(i*13%97), 0xff
+	}
+	bias := renderBiasMap([]EnergyRule{{Shape: "rect", Args: []float64{5, 5, 10, 10}, Weight: 0.5}}, nil, img.Bounds())
+
+	for _, proc := range []*Processor{
+		{SobelThreshold: 4, BlurRadius: 3},
+		{SobelThreshold: 4, BlurRadius: 2, ChannelWeights: [3]float64{0.3, 0.6, 0.1}},
+		{SobelThreshold: 4, BlurRadius: 2, ChannelWeights: [3]float64{0.3, 0.6, 0.1}, FixedPoint: true},
+		{SobelThreshold: 4, FixedPoint: true, biasMap: bias},
+	} {
+		energy, err := NewCarver(40, 30).ComputeSeams(proc, img)
+		assert.NoError(err)
+
+		pixelEnergy := proc.pixelEnergies(img)
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 40; x++ {
+				expected := float64(energy.Pix[energy.PixOffset(x, y)])
+				if !proc.FixedPoint {
+					expected /= 255
+				}
+				assert.InDelta(expected, pixelEnergy(x, y), 1e-9, "(%d, %d)", x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package filters
+
+import (
+	"image"
+	"testing"
+)
+
+// benchImage returns a noisy image used by the benchmarks.
+func benchImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*7919 + i/2048*31)
+	}
+	return img
+}
+
+func BenchmarkGrayscale(b *testing.B) {
+	img := benchImage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Grayscale(img)
+	}
+}
+
+func BenchmarkLuminance(b *testing.B) {
+	img := benchImage()
+	var buf []uint8
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = Luminance(buf, img)
+	}
+}
+
+func BenchmarkStackBlur(b *testing.B) {
+	img := benchImage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		StackBlur(img, 4)
+	}
+}
+
+func BenchmarkSobel(b *testing.B) {
+	img := Grayscale(benchImage())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Sobel(img, 2, nil)
+	}
+}
+
+func BenchmarkWeightedSobel(b *testing.B) {
+	img := benchImage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		WeightedSobel(img, 2, [3]float64{0.5, 1, 0.25}, nil)
+	}
+}
+
+func BenchmarkThreshold(b *testing.B) {
+	img := benchImage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Threshold(img, 0x80)
+	}
+}
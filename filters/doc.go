@@ -0,0 +1,14 @@
+/*
+Package filters provides the image filters used by caire for generating the energy map of the
+images: the grayscale conversion, the stack blur, the sobel edge detection and the thresholding.
+
+The filters operate on in-memory NRGBA images, without any input or output, so they can be used
+for building custom energy functions, which are consistent with the energy computed by caire:
+
+	gray := filters.Grayscale(img)
+	blurred := filters.StackBlur(gray, 4)
+	energy := filters.Sobel(blurred, 2, nil)
+
+The images are expected to have their bounds starting at the origin.
+*/
+package filters
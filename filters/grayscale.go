@@ -0,0 +1,89 @@
+package filters
+
+import "image"
+
+// Luma returns the luminance of the opaque color using the ITU-R BT.601 coefficients,
+// computed on the 16 bit color values like the luminance of the image pixels.
+func Luma(r, g, b uint8) uint8 {
+	return luma(uint32(r)*0x101, uint32(g)*0x101, uint32(b)*0x101)
+}
+
+// luma returns the luminance of the 16 bit color values.
+func luma(r, g, b uint32) uint8 {
+	return uint8((0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 256)
+}
+
+// rgb16 returns the 16 bit color values of the NRGBA pixel premultiplied by its alpha,
+// like the RGBA method of the color.NRGBA type.
+func rgb16(pix []uint8) (r, g, b uint32) {
+	a := uint32(pix[3]) * 0x101
+	r = uint32(pix[0]) * 0x101 * a / 0xffff
+	g = uint32(pix[1]) * 0x101 * a / 0xffff
+	b = uint32(pix[2]) * 0x101 * a / 0xffff
+	return
+}
+
+// Grayscale converts the image to an opaque grayscale image.
+func Grayscale(src *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(src.Bounds())
+	grayscaleLoop(dst.Pix, src, 4)
+
+	return dst
+}
+
+// Luminance returns the luminance of the image pixels as a one dimensional array, row by row.
+// The dst slice is reused if it has enough capacity, otherwise a new slice is allocated.
+func Luminance(dst []uint8, src *image.NRGBA) []uint8 {
+	n := src.Bounds().Dx() * src.Bounds().Dy()
+	if cap(dst) < n {
+		dst = make([]uint8, n)
+	}
+	dst = dst[:n]
+	grayscaleLoop(dst, src, 1)
+
+	return dst
+}
+
+// LuminanceFixed returns the luminance of the image pixels like Luminance, computed in 16.16 fixed
+// point. Unlike the floating point computations, which can be fused differently by the compiler
+// on each architecture, the result is identical on every platform.
+func LuminanceFixed(dst []uint8, src *image.NRGBA) []uint8 {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if cap(dst) < width*height {
+		dst = make([]uint8, width*height)
+	}
+	dst = dst[:width*height]
+
+	for y := 0; y < height; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+width*4]
+		for i, j := 0, y*width; i+3 < len(row); i, j = i+4, j+1 {
+			r, g, b := rgb16(row[i : i+4])
+			dst[j] = uint8((19595*r + 38470*g + 7471*b) >> 24)
+		}
+	}
+	return dst
+}
+
+// grayscaleLoop writes the luminance of the source pixels into dst. With a step of 1 the result
+// is a plain luminance array, while with a step of 4 it's an opaque grayscale NRGBA pixel buffer.
+// The pixels are processed row by row in a flat loop over the pixel buffer, without the
+// overhead of the color interface conversions, which is friendly to the compiler optimizations.
+func grayscaleLoop(dst []uint8, src *image.NRGBA, step int) {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+width*4]
+		out := dst[y*width*step : (y+1)*width*step]
+
+		for i, j := 0, 0; i+3 < len(row); i, j = i+4, j+step {
+			r, g, b := rgb16(row[i : i+4])
+			if step == 4 {
+				// The grayscale image is computed in single precision.
+				l := uint8((float32(r)*0.299 + float32(g)*0.587 + float32(b)*0.114) / 256)
+				out[j], out[j+1], out[j+2], out[j+3] = l, l, l, 0xff
+			} else {
+				out[j] = luma(r, g, b)
+			}
+		}
+	}
+}
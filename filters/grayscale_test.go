@@ -0,0 +1,66 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrayscale_ShouldComputeTheLuma(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint8(0), Luma(0, 0, 0))
+	assert.Equal(uint8(255), Luma(255, 255, 255))
+	assert.Equal(uint8(177), Luma(177, 177, 177))
+	assert.Equal(uint8(150), Luma(0, 255, 0))
+	assert.Equal(uint8(76), Luma(255, 0, 0))
+}
+
+func TestGrayscale_ShouldConvertTheImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.SetNRGBA(1, 1, color.NRGBA{R: 0xff, A: 0x80})
+
+	// The translucent pixels are premultiplied by their alpha.
+	gray := Grayscale(img)
+	assert.Equal(img.Bounds(), gray.Bounds())
+	assert.Equal(color.NRGBA{R: 38, G: 38, B: 38, A: 0xff}, gray.NRGBAAt(1, 1))
+	assert.Equal(color.NRGBA{A: 0xff}, gray.NRGBAAt(0, 0))
+
+	lum := Luminance(nil, img)
+	assert.Equal([]uint8{0, 0, 0, 0, 38, 0}, lum)
+
+	// The buffer having enough capacity is reused.
+	buf := make([]uint8, 0, 10)
+	lum = Luminance(buf, img)
+	assert.Len(lum, 6)
+	assert.Equal(&buf[:1][0], &lum[0])
+}
+
+func TestGrayscale_ShouldMatchTheColorConversions(t *testing.T) {
+	assert := assert.New(t)
+
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	rnd.Read(img.Pix)
+
+	gray, lum := Grayscale(img), Luminance(nil, img)
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			l32 := uint8((float32(r)*0.299 + float32(g)*0.587 + float32(b)*0.114) / 256)
+			l64 := uint8((0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 256)
+			assert.Equal(l32, gray.NRGBAAt(x, y).R)
+			assert.Equal(l64, lum[y*64+x])
+		}
+	}
+
+	// The fixed point luminance differs by the rounding errors only.
+	for i, l := range LuminanceFixed(nil, img) {
+		assert.InDelta(lum[i], l, 1)
+	}
+}
@@ -0,0 +1,270 @@
+package filters
+
+import (
+	"image"
+	"math"
+)
+
+// The sobel operator kernels, computing the horizontal and the vertical gradients.
+// See https://en.wikipedia.org/wiki/Sobel_operator
+var (
+	SobelX = [3][3]int32{
+		{-1, 0, 1},
+		{-2, 0, 2},
+		{-1, 0, 1},
+	}
+
+	SobelY = [3][3]int32{
+		{-1, -2, -1},
+		{0, 0, 0},
+		{1, 2, 1},
+	}
+)
+
+// Runner processes the [0, n) range by calling fn over consecutive chunks of the range,
+// possibly concurrently. The nil Runner processes the whole range at once.
+type Runner func(n int, fn func(start, end int))
+
+// run calls fn over the [0, n) range using the runner.
+func (r Runner) run(n int, fn func(start, end int)) {
+	if r == nil {
+		fn(0, n)
+		return
+	}
+	r(n, fn)
+}
+
+// Sobel uses the sobel filter operator for detecting the edges of the image, computed over its red
+// channel, which is expected to be a grayscale image. The magnitudes not exceeding the threshold
+// are set to zero. The pixels are processed using the run Runner, which can be nil.
+func Sobel(img *image.NRGBA, threshold float64, run Runner) *image.NRGBA {
+	magnitudes := SobelMagnitudes(nil, Channel(img, 0), img.Bounds().Max.X, threshold, run)
+	return EdgeImage(img.Bounds(), magnitudes)
+}
+
+// SobelMagnitudes writes the sobel magnitudes of the data, having rows of dx pixels, into dst,
+// like Sobel does. The dst slice is reused if it has enough capacity, otherwise a new slice is
+// allocated. Without a Runner the magnitudes are computed without any allocation.
+func SobelMagnitudes(dst, data []uint8, dx int, threshold float64, run Runner) []uint8 {
+	if cap(dst) < len(data) {
+		dst = make([]uint8, len(data))
+	}
+	dst = dst[:len(data)]
+
+	if run == nil {
+		sobelMagnitudes(dst, data, dx, threshold, 0, len(dst))
+	} else {
+		run(len(dst), func(start, end int) {
+			sobelMagnitudes(dst, data, dx, threshold, start, end)
+		})
+	}
+	return dst
+}
+
+// sobelMagnitudes computes the magnitudes of the [start, end) range of the data. The magnitudes
+// not exceeding the threshold are set to zero.
+func sobelMagnitudes(dst, data []uint8, dx int, threshold float64, start, end int) {
+	for i := start; i < end; i++ {
+		dst[i] = 0
+		if magnitude := SobelMagnitude(data, i, dx); magnitude > threshold {
+			dst[i] = uint8(magnitude)
+		}
+	}
+}
+
+// WeightedSobel is a variant of the sobel filter operator, which computes the gradient magnitude
+// of each color channel separately and combines them using the provided R, G, B weights.
+// The combined magnitude is clamped to the [0, 255] range.
+func WeightedSobel(img *image.NRGBA, threshold float64, weights [3]float64, run Runner) *image.NRGBA {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	magnitudes := make([]uint8, dx*dy)
+	sums := make([]float64, dx*dy)
+
+	for ch, w := range weights {
+		if w == 0 {
+			continue
+		}
+		data := Channel(img, ch)
+		run.run(len(sums), func(start, end int) {
+			for i := start; i < end; i++ {
+				sums[i] += w * SobelMagnitude(data, i, dx)
+			}
+		})
+	}
+
+	for i, magnitude := range sums {
+		if magnitude > 255 {
+			magnitude = 255
+		}
+		if magnitude > threshold {
+			magnitudes[i] = uint8(magnitude)
+		}
+	}
+	return EdgeImage(img.Bounds(), magnitudes)
+}
+
+// SobelMagnitude applies the sobel kernels over the 3x3 window of pixels starting at index i
+// of the data, having rows of dx pixels, and returns the gradient magnitude clamped to the
+// [0, 255] range. The pixels outside of the data are ignored.
+func SobelMagnitude(data []uint8, i, dx int) float64 {
+	sumX, sumY := sobelGradient(data, i, dx)
+	return math.Min(math.Sqrt(float64(sumX*sumX)+float64(sumY*sumY)), 255)
+}
+
+// sobelGradient returns the horizontal and the vertical gradients of the 3x3 window of pixels
+// starting at index i of the data.
+func sobelGradient(data []uint8, i, dx int) (sumX, sumY int32) {
+	// Sum each pixel with the kernel value
+	for x := 0; x < len(SobelX); x++ {
+		for y := 0; y < len(SobelY); y++ {
+			if idx := i + (dx * y) + x; idx < len(data) {
+				r := data[idx]
+				sumX += int32(r) * SobelX[y][x]
+				sumY += int32(r) * SobelY[y][x]
+			}
+		}
+	}
+	return sumX, sumY
+}
+
+// SobelFixed is the integer variant of Sobel, whose result doesn't depend on the floating point
+// arithmetic of the platform. The magnitudes are the integer square roots of the squared
+// gradients, which is equal to the truncated magnitudes of Sobel.
+func SobelFixed(img *image.NRGBA, threshold int, run Runner) *image.NRGBA {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	data := Channel(img, 0)
+	magnitudes := make([]uint8, dx*dy)
+
+	run.run(len(magnitudes), func(start, end int) {
+		for i := start; i < end; i++ {
+			sumX, sumY := sobelGradient(data, i, dx)
+			// Comparing the squares keeps the fractional magnitudes exceeding the threshold.
+			if sq := uint32(sumX*sumX + sumY*sumY); sq > uint32(threshold*threshold) {
+				magnitudes[i] = uint8(min(ISqrt(sq), 255))
+			}
+		}
+	})
+	return EdgeImage(img.Bounds(), magnitudes)
+}
+
+// WeightedSobelFixed is the integer variant of WeightedSobel. The channel weights are
+// rounded to the 8.8 fixed point representation.
+func WeightedSobelFixed(img *image.NRGBA, threshold int, weights [3]float64, run Runner) *image.NRGBA {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	magnitudes := make([]uint8, dx*dy)
+	sums := make([]int32, dx*dy)
+
+	for ch, w := range weights {
+		fw := int32(math.Round(w * 256))
+		if fw == 0 {
+			continue
+		}
+		data := Channel(img, ch)
+		run.run(len(sums), func(start, end int) {
+			for i := start; i < end; i++ {
+				sumX, sumY := sobelGradient(data, i, dx)
+				sums[i] += fw * int32(min(ISqrt(uint32(sumX*sumX+sumY*sumY)), 255))
+			}
+		})
+	}
+
+	for i, sum := range sums {
+		if magnitude := min(sum>>8, 255); magnitude > int32(threshold) {
+			magnitudes[i] = uint8(magnitude)
+		}
+	}
+	return EdgeImage(img.Bounds(), magnitudes)
+}
+
+// SobelAt returns the edge magnitude of the pixel at index i of the image, equal to the one
+// computed by Sobel, or by WeightedSobel if any of the channel weights is set. With the fixed
+// option the magnitude is computed like by SobelFixed and WeightedSobelFixed.
+func SobelAt(img *image.NRGBA, i int, threshold float64, weights [3]float64, fixed bool) uint8 {
+	if weights == [3]float64{} {
+		sumX, sumY := channelGradient(img, 0, i)
+		if fixed {
+			if sq := uint32(sumX*sumX + sumY*sumY); sq > uint32(int(threshold)*int(threshold)) {
+				return uint8(min(ISqrt(sq), 255))
+			}
+			return 0
+		}
+		if magnitude := math.Min(math.Sqrt(float64(sumX*sumX)+float64(sumY*sumY)), 255); magnitude > threshold {
+			return uint8(magnitude)
+		}
+		return 0
+	}
+
+	if fixed {
+		var sum int32
+		for ch, w := range weights {
+			if fw := int32(math.Round(w * 256)); fw != 0 {
+				sumX, sumY := channelGradient(img, ch, i)
+				sum += fw * int32(min(ISqrt(uint32(sumX*sumX+sumY*sumY)), 255))
+			}
+		}
+		if magnitude := min(sum>>8, 255); magnitude > int32(threshold) {
+			return uint8(magnitude)
+		}
+		return 0
+	}
+
+	var sum float64
+	for ch, w := range weights {
+		if w != 0 {
+			sumX, sumY := channelGradient(img, ch, i)
+			sum += w * math.Min(math.Sqrt(float64(sumX*sumX)+float64(sumY*sumY)), 255)
+		}
+	}
+	if sum = math.Min(sum, 255); sum > threshold {
+		return uint8(sum)
+	}
+	return 0
+}
+
+// channelGradient returns the gradients of the color channel like sobelGradient does,
+// reading the channel values directly from the pixels of the image.
+func channelGradient(img *image.NRGBA, ch, i int) (sumX, sumY int32) {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	for x := 0; x < len(SobelX); x++ {
+		for y := 0; y < len(SobelY); y++ {
+			if idx := i + (dx * y) + x; idx < dx*dy {
+				r := img.Pix[idx*4+ch]
+				sumX += int32(r) * SobelX[y][x]
+				sumY += int32(r) * SobelY[y][x]
+			}
+		}
+	}
+	return sumX, sumY
+}
+
+// ISqrt returns the integer square root of n, the largest integer whose square doesn't exceed n.
+func ISqrt(n uint32) uint32 {
+	var root uint32
+	// The bits of the root are found from the highest one, like in the long division.
+	for bit := uint32(1) << 15; bit > 0; bit >>= 1 {
+		if r := root | bit; r*r <= n {
+			root = r
+		}
+	}
+	return root
+}
+
+// EdgeImage returns the opaque grayscale image of the edge magnitudes, one for each pixel.
+func EdgeImage(bounds image.Rectangle, magnitudes []uint8) *image.NRGBA {
+	dst := image.NewNRGBA(bounds)
+	for i, m := range magnitudes[:bounds.Dx()*bounds.Dy()] {
+		dst.Pix[i*4], dst.Pix[i*4+1], dst.Pix[i*4+2], dst.Pix[i*4+3] = m, m, m, 0xff
+	}
+	return dst
+}
+
+// Channel returns the values of the provided color channel (0: red, 1: green, 2: blue, 3: alpha).
+func Channel(img *image.NRGBA, ch int) []uint8 {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	pixels := make([]uint8, dx*dy)
+
+	for i := range pixels {
+		pixels[i] = img.Pix[i*4+ch]
+	}
+	return pixels
+}
@@ -0,0 +1,132 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// edgeImage returns an image, whose left half is black and right half is white.
+func edgeImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(0)
+			if x >= width/2 {
+				v = 0xff
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: 0, A: 0xff})
+		}
+	}
+	return img
+}
+
+func TestSobel_ShouldDetectTheEdges(t *testing.T) {
+	assert := assert.New(t)
+
+	img := edgeImage(10, 6)
+	res := Sobel(img, 2, nil)
+	assert.Equal(img.Bounds(), res.Bounds())
+
+	// The 3x3 windows are starting at the pixels, the edge is detected left of the boundary.
+	assert.Equal(uint8(0xff), res.NRGBAAt(3, 2).R)
+	assert.Equal(uint8(0xff), res.NRGBAAt(4, 2).R)
+	assert.Equal(uint8(0), res.NRGBAAt(0, 2).R)
+	assert.Equal(uint8(0), res.NRGBAAt(6, 2).R)
+	assert.Equal(uint8(0xff), res.NRGBAAt(0, 2).A)
+
+	// The uniform images have no edges.
+	res = Sobel(image.NewNRGBA(image.Rect(0, 0, 8, 8)), 0, nil)
+	for i := 0; i < len(res.Pix); i += 4 {
+		assert.Equal(uint8(0), res.Pix[i])
+	}
+}
+
+func TestSobel_ShouldProduceTheSameResultWithRunners(t *testing.T) {
+	assert := assert.New(t)
+
+	img := edgeImage(64, 48)
+	var mu sync.Mutex
+	var chunks int
+	run := func(n int, fn func(start, end int)) {
+		var wg sync.WaitGroup
+		for start := 0; start < n; start += 100 {
+			wg.Add(1)
+			go func(start, end int) {
+				defer wg.Done()
+				mu.Lock()
+				chunks++
+				mu.Unlock()
+				fn(start, end)
+			}(start, min(start+100, n))
+		}
+		wg.Wait()
+	}
+	assert.Equal(Sobel(img, 2, nil).Pix, Sobel(img, 2, run).Pix)
+	assert.Greater(chunks, 1)
+
+	weights := [3]float64{0.5, 0.5, 1}
+	assert.Equal(WeightedSobel(img, 2, weights, nil).Pix, WeightedSobel(img, 2, weights, run).Pix)
+}
+
+func TestSobel_ShouldWeightTheChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	img := edgeImage(10, 6)
+	assert.Equal(Sobel(img, 2, nil).Pix, WeightedSobel(img, 2, [3]float64{1, 0, 0}, nil).Pix)
+
+	// The blue channel is uniform.
+	blue := WeightedSobel(img, 2, [3]float64{0, 0, 1}, nil)
+	assert.Equal(uint8(0), blue.NRGBAAt(4, 2).R)
+}
+
+func TestSobel_ShouldComputeTheFixedPointMagnitudes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, n := range []uint32{0, 1, 3, 4, 15, 16, 17, 65024, 2080800} {
+		root := ISqrt(n)
+		assert.True(root*root <= n && (root+1)*(root+1) > n, "invalid integer square root of %d: %d", n, root)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*73 + i*i*31)
+	}
+	// The truncated magnitudes are equal, the threshold being compared to the exact magnitudes.
+	for _, threshold := range []int{0, 2, 40} {
+		assert.Equal(Sobel(img, float64(threshold), nil).Pix, SobelFixed(img, threshold, nil).Pix)
+	}
+	assert.Equal(SobelFixed(img, 2, nil).Pix, WeightedSobelFixed(img, 2, [3]float64{1, 0, 0}, nil).Pix)
+
+	// The weighted magnitudes are combined exactly in 8.8 fixed point.
+	gray := Grayscale(img)
+	assert.Equal(SobelFixed(gray, 2, nil).Pix, WeightedSobelFixed(gray, 2, [3]float64{0.5, 0.25, 0.25}, nil).Pix)
+}
+
+func TestSobel_ShouldComputeTheSinglePixelsLikeTheImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 17, 13))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(i*7), uint8(i*i%251), uint8(i*13%97), 0xff
+	}
+	weights := [3]float64{0.3, 0.6, 0.1}
+
+	for _, tc := range []struct {
+		res     *image.NRGBA
+		weights [3]float64
+		fixed   bool
+	}{
+		{Sobel(img, 20, nil), [3]float64{}, false},
+		{WeightedSobel(img, 20, weights, nil), weights, false},
+		{SobelFixed(img, 20, nil), [3]float64{}, true},
+		{WeightedSobelFixed(img, 20, weights, nil), weights, true},
+	} {
+		for i := 0; i < 17*13; i++ {
+			assert.Equal(tc.res.Pix[i*4], SobelAt(img, i, 20, tc.weights, tc.fixed), "pixel %d", i)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Go implementation of StackBlur algorithm described here:
+// http://incubator.quasimondo.com/processing/fast_blur_deluxe.php
+
+package filters
+
+import (
+	"image"
+)
+
+// blurstack is a linked list containing the color value and a pointer to the next struct.
+type blurstack struct {
+	r, g, b, a uint32
+	next       *blurstack
+}
+
+var mulTable = []uint32{
+	512, 512, 456, 512, 328, 456, 335, 512, 405, 328, 271, 456, 388, 335, 292, 512,
+	454, 405, 364, 328, 298, 271, 496, 456, 420, 388, 360, 335, 312, 292, 273, 512,
+	482, 454, 428, 405, 383, 364, 345, 328, 312, 298, 284, 271, 259, 496, 475, 456,
+	437, 420, 404, 388, 374, 360, 347, 335, 323, 312, 302, 292, 282, 273, 265, 512,
+	497, 482, 468, 454, 441, 428, 417, 405, 394, 383, 373, 364, 354, 345, 337, 328,
+	320, 312, 305, 298, 291, 284, 278, 271, 265, 259, 507, 496, 485, 475, 465, 456,
+	446, 437, 428, 420, 412, 404, 396, 388, 381, 374, 367, 360, 354, 347, 341, 335,
+	329, 323, 318, 312, 307, 302, 297, 292, 287, 282, 278, 273, 269, 265, 261, 512,
+	505, 497, 489, 482, 475, 468, 461, 454, 447, 441, 435, 428, 422, 417, 411, 405,
+	399, 394, 389, 383, 378, 373, 368, 364, 359, 354, 350, 345, 341, 337, 332, 328,
+	324, 320, 316, 312, 309, 305, 301, 298, 294, 291, 287, 284, 281, 278, 274, 271,
+	268, 265, 262, 259, 257, 507, 501, 496, 491, 485, 480, 475, 470, 465, 460, 456,
+	451, 446, 442, 437, 433, 428, 424, 420, 416, 412, 408, 404, 400, 396, 392, 388,
+	385, 381, 377, 374, 370, 367, 363, 360, 357, 354, 350, 347, 344, 341, 338, 335,
+	332, 329, 326, 323, 320, 318, 315, 312, 310, 307, 304, 302, 299, 297, 294, 292,
+	289, 287, 285, 282, 280, 278, 275, 273, 271, 269, 267, 265, 263, 261, 259,
+}
+
+var shgTable = []uint32{
+	9, 11, 12, 13, 13, 14, 14, 15, 15, 15, 15, 16, 16, 16, 16, 17,
+	17, 17, 17, 17, 17, 17, 18, 18, 18, 18, 18, 18, 18, 18, 18, 19,
+	19, 19, 19, 19, 19, 19, 19, 19, 19, 19, 19, 19, 19, 20, 20, 20,
+	20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 21,
+	21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 21,
+	21, 21, 21, 21, 21, 21, 21, 21, 21, 21, 22, 22, 22, 22, 22, 22,
+	22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22,
+	22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 22, 23,
+	23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23,
+	23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23,
+	23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23, 23,
+	23, 23, 23, 23, 23, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+	24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+	24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+	24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+	24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+}
+
+// Blur is a reusable stack blur filter. It keeps the blur stack between the calls,
+// so blurring the images repeatedly doesn't allocate.
+type Blur struct {
+	stack []blurstack
+}
+
+// StackBlur applies a blur filter to the provided image, which is blurred in place and returned.
+// The radius defines the bluring average, it's limited to the [1, 254] range.
+func StackBlur(img *image.NRGBA, radius uint32) *image.NRGBA {
+	return new(Blur).StackBlur(img, radius)
+}
+
+// StackBlur is like the StackBlur function, but it reuses the blur stack of the previous calls.
+func (bl *Blur) StackBlur(img *image.NRGBA, radius uint32) *image.NRGBA {
+	var stackEnd, stackIn, stackOut *blurstack
+	// The pixel indices are native unsigned integers, since the pixel buffer
+	// of the very large images (ex. the stitched panoramas) exceeds the 32 bit range.
+	var width, height = uint(img.Bounds().Dx()), uint(img.Bounds().Dy())
+	var (
+		widthMinus1, heightMinus1, x, y, p, yp, yi, yw uint
+		div, radiusPlus1, sumFactor, i,
+		rSum, gSum, bSum, aSum,
+		rOutSum, gOutSum, bOutSum, aOutSum,
+		rInSum, gInSum, bInSum, aInSum,
+		pr, pg, pb, pa uint32
+	)
+
+	// Limit the maximum blur radius to 255, otherwise it overflows the multable length
+	// and will panic with and index out of range error.
+	if int(radius) >= len(mulTable) {
+		radius = uint32(len(mulTable) - 1)
+	}
+	if radius < 1 {
+		radius = 1
+	}
+
+	div = radius + radius + 1
+	widthMinus1 = width - 1
+	heightMinus1 = height - 1
+	radiusPlus1 = radius + 1
+	sumFactor = radiusPlus1 * (radiusPlus1 + 1) / 2
+
+	// The stack is a circular linked list, its nodes being stored in a reusable slice.
+	if uint32(cap(bl.stack)) < div {
+		bl.stack = make([]blurstack, div)
+	}
+	nodes := bl.stack[:div]
+	for i = 0; i < div; i++ {
+		nodes[i] = blurstack{next: &nodes[(i+1)%div]}
+	}
+	stackStart := &nodes[0]
+	stackEnd = &nodes[radiusPlus1]
+	stack := stackStart
+
+	mulSum := mulTable[radius]
+	shgSum := shgTable[radius]
+
+	for y = 0; y < height; y++ {
+		rInSum, gInSum, bInSum, aInSum, rSum, gSum, bSum, aSum = 0, 0, 0, 0, 0, 0, 0, 0
+
+		pr = uint32(img.Pix[yi])
+		pg = uint32(img.Pix[yi+1])
+		pb = uint32(img.Pix[yi+2])
+		pa = uint32(img.Pix[yi+3])
+
+		rOutSum = radiusPlus1 * pr
+		gOutSum = radiusPlus1 * pg
+		bOutSum = radiusPlus1 * pb
+		aOutSum = radiusPlus1 * pa
+
+		rSum += sumFactor * pr
+		gSum += sumFactor * pg
+		bSum += sumFactor * pb
+		aSum += sumFactor * pa
+
+		stack = stackStart
+
+		for i = 0; i < radiusPlus1; i++ {
+			stack.r = pr
+			stack.g = pg
+			stack.b = pb
+			stack.a = pa
+			stack = stack.next
+		}
+
+		for i = 1; i < radiusPlus1; i++ {
+			var diff uint
+			if widthMinus1 < uint(i) {
+				diff = widthMinus1
+			} else {
+				diff = uint(i)
+			}
+			p = yi + (diff << 2)
+			pr = uint32(img.Pix[p])
+			pg = uint32(img.Pix[p+1])
+			pb = uint32(img.Pix[p+2])
+			pa = uint32(img.Pix[p+3])
+
+			stack.r = pr
+			stack.g = pg
+			stack.b = pb
+			stack.a = pa
+
+			rSum += stack.r * (radiusPlus1 - i)
+			gSum += stack.g * (radiusPlus1 - i)
+			bSum += stack.b * (radiusPlus1 - i)
+			aSum += stack.a * (radiusPlus1 - i)
+
+			rInSum += pr
+			gInSum += pg
+			bInSum += pb
+			aInSum += pa
+
+			stack = stack.next
+		}
+		stackIn = stackStart
+		stackOut = stackEnd
+
+		for x = 0; x < width; x++ {
+			pa = (aSum * mulSum) >> shgSum
+			img.Pix[yi+3] = uint8(pa)
+
+			if pa != 0 {
+				img.Pix[yi] = uint8((rSum * mulSum) >> shgSum)
+				img.Pix[yi+1] = uint8((gSum * mulSum) >> shgSum)
+				img.Pix[yi+2] = uint8((bSum * mulSum) >> shgSum)
+			} else {
+				img.Pix[yi] = 0
+				img.Pix[yi+1] = 0
+				img.Pix[yi+2] = 0
+			}
+
+			rSum -= rOutSum
+			gSum -= gOutSum
+			bSum -= bOutSum
+			aSum -= aOutSum
+
+			rOutSum -= stackIn.r
+			gOutSum -= stackIn.g
+			bOutSum -= stackIn.b
+			aOutSum -= stackIn.a
+
+			p = x + uint(radius) + 1
+
+			if p > widthMinus1 {
+				p = widthMinus1
+			}
+			p = (yw + p) << 2
+
+			stackIn.r = uint32(img.Pix[p])
+			stackIn.g = uint32(img.Pix[p+1])
+			stackIn.b = uint32(img.Pix[p+2])
+			stackIn.a = uint32(img.Pix[p+3])
+
+			rInSum += stackIn.r
+			gInSum += stackIn.g
+			bInSum += stackIn.b
+			aInSum += stackIn.a
+
+			rSum += rInSum
+			gSum += gInSum
+			bSum += bInSum
+			aSum += aInSum
+
+			stackIn = stackIn.next
+
+			pr = stackOut.r
+			pg = stackOut.g
+			pb = stackOut.b
+			pa = stackOut.a
+
+			rOutSum += pr
+			gOutSum += pg
+			bOutSum += pb
+			aOutSum += pa
+
+			rInSum -= pr
+			gInSum -= pg
+			bInSum -= pb
+			aInSum -= pa
+
+			stackOut = stackOut.next
+
+			yi += 4
+		}
+		yw += width
+	}
+
+	for x = 0; x < width; x++ {
+		rInSum, gInSum, bInSum, aInSum, rSum, gSum, bSum, aSum = 0, 0, 0, 0, 0, 0, 0, 0
+
+		yi = x << 2
+		pr = uint32(img.Pix[yi])
+		pg = uint32(img.Pix[yi+1])
+		pb = uint32(img.Pix[yi+2])
+		pa = uint32(img.Pix[yi+3])
+
+		rOutSum = radiusPlus1 * pr
+		gOutSum = radiusPlus1 * pg
+		bOutSum = radiusPlus1 * pb
+		aOutSum = radiusPlus1 * pa
+
+		rSum += sumFactor * pr
+		gSum += sumFactor * pg
+		bSum += sumFactor * pb
+		aSum += sumFactor * pa
+
+		stack = stackStart
+
+		for i = 0; i < radiusPlus1; i++ {
+			stack.r = pr
+			stack.g = pg
+			stack.b = pb
+			stack.a = pa
+			stack = stack.next
+		}
+
+		yp = width
+
+		for i = 1; i <= radius; i++ {
+			yi = (yp + x) << 2
+			pr = uint32(img.Pix[yi])
+			pg = uint32(img.Pix[yi+1])
+			pb = uint32(img.Pix[yi+2])
+			pa = uint32(img.Pix[yi+3])
+
+			stack.r = pr
+			stack.g = pg
+			stack.b = pb
+			stack.a = pa
+
+			rSum += stack.r * (radiusPlus1 - i)
+			gSum += stack.g * (radiusPlus1 - i)
+			bSum += stack.b * (radiusPlus1 - i)
+			aSum += stack.a * (radiusPlus1 - i)
+
+			rInSum += pr
+			gInSum += pg
+			bInSum += pb
+			aInSum += pa
+
+			stack = stack.next
+
+			if uint(i) < heightMinus1 {
+				yp += width
+			}
+		}
+
+		yi = x
+		stackIn = stackStart
+		stackOut = stackEnd
+
+		for y = 0; y < height; y++ {
+			p = yi << 2
+			pa = (aSum * mulSum) >> shgSum
+			img.Pix[p+3] = uint8(pa)
+
+			if pa > 0 {
+				img.Pix[p] = uint8((rSum * mulSum) >> shgSum)
+				img.Pix[p+1] = uint8((gSum * mulSum) >> shgSum)
+				img.Pix[p+2] = uint8((bSum * mulSum) >> shgSum)
+			} else {
+				img.Pix[p] = 0
+				img.Pix[p+1] = 0
+				img.Pix[p+2] = 0
+			}
+
+			rSum -= rOutSum
+			gSum -= gOutSum
+			bSum -= bOutSum
+			aSum -= aOutSum
+
+			rOutSum -= stackIn.r
+			gOutSum -= stackIn.g
+			bOutSum -= stackIn.b
+			aOutSum -= stackIn.a
+
+			p = y + uint(radiusPlus1)
+
+			if p > heightMinus1 {
+				p = heightMinus1
+			}
+			p = (x + (p * width)) << 2
+
+			stackIn.r = uint32(img.Pix[p])
+			stackIn.g = uint32(img.Pix[p+1])
+			stackIn.b = uint32(img.Pix[p+2])
+			stackIn.a = uint32(img.Pix[p+3])
+
+			rInSum += stackIn.r
+			gInSum += stackIn.g
+			bInSum += stackIn.b
+			aInSum += stackIn.a
+
+			rSum += rInSum
+			gSum += gInSum
+			bSum += bInSum
+			aSum += aInSum
+
+			stackIn = stackIn.next
+
+			pr = stackOut.r
+			pg = stackOut.g
+			pb = stackOut.b
+			pa = stackOut.a
+
+			rOutSum += pr
+			gOutSum += pg
+			bOutSum += pb
+			aOutSum += pa
+
+			rInSum -= pr
+			gInSum -= pg
+			bInSum -= pb
+			aInSum -= pa
+
+			stackOut = stackOut.next
+
+			yi += width
+		}
+	}
+	return img
+}
+
+// StackBlurAt returns the value of a single pixel of the channel blurred by StackBlur, without
+// blurring the whole image. The at function returns the channel value of the opaque image of
+// the provided size, it's called only with coordinates inside the image.
+func StackBlurAt(at func(x, y int) uint8, x, y, width, height int, radius uint32) uint8 {
+	if int(radius) >= len(mulTable) {
+		radius = uint32(len(mulTable) - 1)
+	}
+	if radius < 1 {
+		radius = 1
+	}
+	mulSum, shgSum := mulTable[radius], shgTable[radius]
+	r := int(radius)
+
+	// The stack blur is a tent filter, which is applied horizontally then vertically,
+	// rounding the intermediate results and clamping the coordinates to the image edges.
+	var sum uint32
+	for dy := -r; dy <= r; dy++ {
+		yy := max(0, min(y+dy, height-1))
+		var rowSum uint32
+		for dx := -r; dx <= r; dx++ {
+			xx := max(0, min(x+dx, width-1))
+			rowSum += uint32(r+1-abs(dx)) * uint32(at(xx, yy))
+		}
+		sum += uint32(r+1-abs(dy)) * ((rowSum * mulSum) >> shgSum)
+	}
+	return uint8((sum * mulSum) >> shgSum)
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
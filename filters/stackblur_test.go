@@ -0,0 +1,67 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackBlur_ShouldPreserveUniformImages(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	res := StackBlur(img, 3)
+	assert.Same(img, res)
+	for i := range res.Pix {
+		assert.InDelta(0x80, res.Pix[i], 1)
+	}
+}
+
+func TestStackBlur_ShouldSpreadThePixels(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 21, 21))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	img.SetNRGBA(10, 10, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+
+	res := StackBlur(img, 2)
+	center := res.NRGBAAt(10, 10).R
+	assert.Less(center, uint8(0xff))
+	assert.Greater(res.NRGBAAt(11, 10).R, uint8(0))
+	assert.Greater(center, res.NRGBAAt(11, 10).R)
+	assert.Equal(uint8(0), res.NRGBAAt(0, 0).R)
+
+	// The radius exceeding the lookup tables is clamped.
+	assert.NotPanics(func() { StackBlur(img, 1000) })
+}
+
+func TestStackBlur_ShouldBlurTheSinglePixelsLikeTheImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 23, 17))
+	for x := 0; x < 23; x++ {
+		for y := 0; y < 17; y++ {
+			v := uint8((x*x*7 + y*31 + x*y*13) % 256)
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+	at := func(x, y int) uint8 { return img.Pix[img.PixOffset(x, y)] }
+
+	for _, radius := range []uint32{1, 2, 5, 30} {
+		res := image.NewNRGBA(img.Rect)
+		copy(res.Pix, img.Pix)
+		StackBlur(res, radius)
+		for y := 0; y < 17; y++ {
+			for x := 0; x < 23; x++ {
+				assert.Equal(res.Pix[res.PixOffset(x, y)], StackBlurAt(at, x, y, 23, 17, radius), "radius %d at (%d, %d)", radius, x, y)
+			}
+		}
+	}
+}
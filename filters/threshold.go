@@ -0,0 +1,30 @@
+package filters
+
+import "image"
+
+// Threshold converts the image to a binary image: the pixels, whose alpha premultiplied red,
+// green and blue components are all above the level, become opaque white, while the other
+// pixels become fully transparent. It's used for converting the mask images.
+func Threshold(src *image.NRGBA, level uint8) *image.NRGBA {
+	dst := image.NewNRGBA(src.Bounds())
+	// The components are compared in the 16 bit color space of the color.Color interface.
+	limit := uint32(level)*0x101 + 0x7f
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		row := src.Pix[y*src.Stride : y*src.Stride+width*4]
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+width*4]
+
+		for i := 0; i+3 < len(row); i += 4 {
+			a := uint32(row[i+3])
+			r := uint32(row[i]) * 0x101 * a / 0xff
+			g := uint32(row[i+1]) * 0x101 * a / 0xff
+			b := uint32(row[i+2]) * 0x101 * a / 0xff
+
+			if r > limit && g > limit && b > limit {
+				out[i], out[i+1], out[i+2], out[i+3] = 0xff, 0xff, 0xff, 0xff
+			}
+		}
+	}
+	return dst
+}
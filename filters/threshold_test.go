@@ -0,0 +1,51 @@
+package filters
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreshold_ShouldBinarizeTheImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 0x80, G: 0x81, B: 0x90, A: 0xff})
+	img.SetNRGBA(2, 0, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x40})
+	img.SetNRGBA(3, 0, color.NRGBA{R: 0xff, G: 0, B: 0xff, A: 0xff})
+
+	white, clear := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.NRGBA{}
+
+	res := Threshold(img, 0)
+	assert.Equal(white, res.NRGBAAt(0, 0))
+	assert.Equal(white, res.NRGBAAt(1, 0))
+	assert.Equal(white, res.NRGBAAt(2, 0))
+	assert.Equal(clear, res.NRGBAAt(3, 0))
+
+	// The components are compared after premultiplying them with the alpha.
+	res = Threshold(img, 0x80)
+	assert.Equal(white, res.NRGBAAt(0, 0))
+	assert.Equal(clear, res.NRGBAAt(1, 0))
+	assert.Equal(clear, res.NRGBAAt(2, 0))
+}
+
+func TestThreshold_ShouldMatchTheColorModel(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(x), B: uint8(x), A: uint8(y)})
+		}
+	}
+	res := Threshold(img, 0)
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			assert.Equal(r > 127, res.NRGBAAt(x, y).A == 0xff)
+		}
+	}
+}
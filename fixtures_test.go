@@ -0,0 +1,25 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// texturedImage returns an opaque grayscale image of the provided size, covered by
+// a deterministic texture, so the energy is spread over the whole image.
+func texturedImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			v := uint8((x*y*37 + x*11) % 256)
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+// fillGray fills the area of the image with an opaque gray level, having no energy.
+func fillGray(img *image.NRGBA, r image.Rectangle, v uint8) {
+	draw.Draw(img, r, &image.Uniform{color.NRGBA{R: v, G: v, B: v, A: 255}}, image.Point{}, draw.Src)
+}
@@ -0,0 +1,368 @@
+package caire
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/esimov/caire/jpegenc"
+	"golang.org/x/image/bmp"
+)
+
+// Supported image formats.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatBMP  = "bmp"
+	FormatGIF  = "gif"
+)
+
+// magicBytes maps the image formats to the signature found at the beginning of the files.
+var magicBytes = []struct {
+	format string
+	magic  []byte
+}{
+	{FormatJPEG, []byte("\xff\xd8\xff")},
+	{FormatPNG, []byte("\x89PNG\r\n\x1a\n")},
+	{FormatGIF, []byte("GIF87a")},
+	{FormatGIF, []byte("GIF89a")},
+	{FormatBMP, []byte("BM")},
+}
+
+// ParseFormat returns the normalized image format name.
+// An empty name means the format should be detected automatically.
+func ParseFormat(name string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "":
+		return "", nil
+	case "jpg", "jpeg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "bmp":
+		return FormatBMP, nil
+	case "gif":
+		return FormatGIF, nil
+	}
+	return "", fmt.Errorf("unsupported image format: %q", name)
+}
+
+// formatExt returns the file extension of the image format.
+func formatExt(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
+
+// DetectFormat sniffs the magic bytes of the image and returns its format,
+// together with a reader which should be used in place of r, since the
+// sniffed bytes are consumed from the original reader.
+// An empty format is returned if the content is not recognized.
+func DetectFormat(r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(8)
+	if err != nil && err != io.EOF {
+		return "", br, err
+	}
+	for _, m := range magicBytes {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.format, br, nil
+		}
+	}
+	return "", br, nil
+}
+
+// DetectFileFormat returns the format of the image file by sniffing its content.
+func DetectFileFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	format, _, err := DetectFormat(f)
+	return format, err
+}
+
+// DefaultMaxPixels is the maximum number of pixels of the decoded images, used when
+// the MaxPixels option is not set. It protects against the decompression bombs.
+const DefaultMaxPixels = 100_000_000
+
+// ImageTooLargeError is returned when the image size exceeds the maximum number of pixels.
+type ImageTooLargeError struct {
+	Width, Height int
+	MaxPixels     int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("the image is too large: %dx%d exceeds the limit of %d pixels", e.Width, e.Height, e.MaxPixels)
+}
+
+// maxPixels returns the maximum number of pixels of the decoded images. A negative MaxPixels disables the limit.
+func (p *Processor) maxPixels() int64 {
+	if p.MaxPixels == 0 {
+		return DefaultMaxPixels
+	}
+	return p.MaxPixels
+}
+
+// decoders returns the config and the image decoder functions of the image format.
+func decoders(format string) (func(io.Reader) (image.Config, error), func(io.Reader) (image.Image, error), error) {
+	switch format {
+	case FormatJPEG:
+		return jpeg.DecodeConfig, jpeg.Decode, nil
+	case FormatPNG:
+		return png.DecodeConfig, png.Decode, nil
+	case FormatBMP:
+		return bmp.DecodeConfig, bmp.Decode, nil
+	case FormatGIF:
+		return gif.DecodeConfig, gif.Decode, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported image format: %q", format)
+}
+
+// decode decodes the image using the decoder of the provided format.
+// In case the format is empty it's detected from the image content.
+// The image dimensions are checked against the maxPixels limit prior to
+// decoding the image data, so the oversized images are never allocated.
+func decode(r io.Reader, format string, maxPixels int64) (image.Image, string, error) {
+	return decodeWith(r, format, decodeOptions{maxPixels: maxPixels})
+}
+
+// decodeOptions are the limits and hints of the image decoding.
+type decodeOptions struct {
+	// maxPixels is the maximum number of pixels of the image, if positive.
+	maxPixels int64
+	// maxMem is the memory budget of the decoded image, if positive. The JPEG images exceeding
+	// the budget are downscaled by the DCT scaling while decoding them, the other formats are rejected.
+	maxMem int64
+	// size is the size the image is resized to right after decoding, if not zero. The JPEG images
+	// are decoded at the smallest DCT scale which is still larger than the size, see prescale.
+	size image.Point
+}
+
+// decodeWith is like decode, but it applies all the decoding options.
+func decodeWith(r io.Reader, format string, opts decodeOptions) (image.Image, string, error) {
+	var err error
+	if format == "" {
+		if format, r, err = DetectFormat(r); err != nil {
+			return nil, "", err
+		}
+		if format == "" {
+			return nil, "", image.ErrFormat
+		}
+	}
+
+	decodeConfig, decodeImage, err := decoders(format)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if opts.maxPixels > 0 || opts.maxMem > 0 || opts.size != (image.Point{}) {
+		// The header consumed by the config decoder is replayed for the image decoder.
+		var header bytes.Buffer
+		cfg, err := decodeConfig(io.TeeReader(r, &header))
+		if err != nil {
+			return nil, "", err
+		}
+		if opts.maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > opts.maxPixels {
+			return nil, "", &ImageTooLargeError{Width: cfg.Width, Height: cfg.Height, MaxPixels: opts.maxPixels}
+		}
+		r = io.MultiReader(&header, r)
+
+		required := decodeMemory(cfg.Width, cfg.Height)
+		exceeded := opts.maxMem > 0 && required > opts.maxMem
+		if exceeded && format != FormatJPEG {
+			return nil, "", &DecodeMemoryError{Width: cfg.Width, Height: cfg.Height, Required: required, Budget: opts.maxMem}
+		}
+		if format == FormatJPEG && (exceeded || prescale(cfg.Width, cfg.Height, opts.size) > 1) {
+			img, err := decodeJPEG(r, opts)
+			return img, format, err
+		}
+	}
+
+	img, err := decodeImage(r)
+	return img, format, err
+}
+
+// outputFormat returns the format of the encoded image. The format defined by the
+// OutputFormat option has priority, then the destination file extension is used.
+// It falls back to JPEG for pipes and files without extension.
+func (p *Processor) outputFormat(w io.Writer) (string, error) {
+	if p.OutputFormat != "" {
+		return ParseFormat(p.OutputFormat)
+	}
+	if f, ok := w.(*os.File); ok {
+		if ext := filepath.Ext(f.Name()); ext != "" {
+			return ParseFormat(ext)
+		}
+	}
+	return FormatJPEG, nil
+}
+
+// encode resizes the image and encodes the result in the provided format.
+func (p *Processor) encode(w io.Writer, img *image.NRGBA, format string) (err error) {
+	// JPEG and GIF (using the default palette) don't support transparency.
+	if format == FormatJPEG || (format == FormatGIF && p.palette == nil) {
+		img = p.flatten(img)
+	}
+
+	if format == FormatGIF {
+		// The intermediary frames would reveal the faces.
+		if p.BlurFaces {
+			return errors.New("the face anonymization cannot be used with Gif animations")
+		}
+		g = new(gif.GIF)
+		isGif = true
+		endCarve := p.startSpan(SpanCarve)
+		_, err := p.resizeLocked(img)
+		endCarve()
+		if err != nil {
+			return err
+		}
+		if !p.Adjustments.IsZero() && len(g.Image) > 0 {
+			// The frames are sharing the same palette.
+			pal := p.Adjustments.applyPalette(g.Image[0].Palette)
+			for _, frame := range g.Image {
+				frame.Palette = pal
+			}
+		}
+		if p.Watermark != nil {
+			logo, err := p.Watermark.load()
+			if err != nil {
+				return err
+			}
+			for _, frame := range g.Image {
+				if err := p.Watermark.apply(frame, logo); err != nil {
+					return err
+				}
+			}
+		}
+		defer p.startSpan(SpanEncode)()
+		return gif.EncodeAll(w, g)
+	}
+
+	endCarve := p.startSpan(SpanCarve)
+	res, err := p.resizeLocked(img)
+	endCarve()
+
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) && timeout.Partial != nil {
+		// The partial result is encoded, while the timeout is still reported to the caller.
+		res = timeout.Partial
+		defer func() {
+			if err == nil {
+				err = timeout
+			}
+		}()
+	} else if err != nil {
+		return err
+	}
+	if timeout == nil {
+		if err := p.checkSimilarity(img, res); err != nil {
+			return err
+		}
+	}
+	if p.GhostPath != "" && timeout == nil {
+		if err := p.writeGhostFile(p.GhostPath, img, res); err != nil {
+			return err
+		}
+	}
+	if p.BlurFaces {
+		res = p.anonymizeFaces(p.imgToNRGBA(res))
+	}
+	if !p.Adjustments.IsZero() {
+		res = p.Adjustments.Apply(p.imgToNRGBA(res))
+	}
+	if p.Watermark != nil {
+		logo, err := p.Watermark.load()
+		if err != nil {
+			return err
+		}
+		dst := p.imgToNRGBA(res)
+		if err := p.Watermark.apply(dst, logo); err != nil {
+			return err
+		}
+		res = dst
+	}
+
+	defer p.startSpan(SpanEncode)()
+	switch format {
+	case FormatPNG:
+		if p.palette != nil {
+			res = p.toPaletted(res, p.palette)
+		} else if p.PNG8 {
+			res = p.toPaletted(res, quantize(res, maxQuantizeColors))
+		}
+		return png.Encode(w, res)
+	case FormatBMP:
+		if p.palette != nil {
+			res = p.toPaletted(res, p.palette)
+		}
+		return bmp.Encode(w, res)
+	}
+	return p.encodeJPEG(w, res)
+}
+
+// encodeJPEG encodes the output image as JPEG, progressively or in the CMYK color space if requested.
+func (p *Processor) encodeJPEG(w io.Writer, img image.Image) error {
+	if p.JPEGCMYK {
+		return jpegenc.Encode(w, toCMYK(p.imgToNRGBA(img)), &jpegenc.Options{Quality: 100})
+	}
+	if p.JPEGProgressive {
+		return jpegenc.Encode(w, img, &jpegenc.Options{Quality: 100})
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 100})
+}
+
+// toCMYK converts the opaque image to the CMYK color space.
+func toCMYK(img *image.NRGBA) *image.CMYK {
+	b := img.Bounds()
+	dst := image.NewCMYK(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		si, di := img.PixOffset(b.Min.X, y), dst.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, si, di = x+1, si+4, di+4 {
+			dst.Pix[di], dst.Pix[di+1], dst.Pix[di+2], dst.Pix[di+3] = color.RGBToCMYK(img.Pix[si], img.Pix[si+1], img.Pix[si+2])
+		}
+	}
+	return dst
+}
+
+// encodeImage encodes a still image in the provided format, using the same
+// settings as the processor output. It defaults to PNG for an empty format.
+func encodeImage(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 100})
+	case FormatBMP:
+		return bmp.Encode(w, img)
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	}
+	return png.Encode(w, img)
+}
+
+// flatten composites the image over the background color, removing the transparency.
+// The image is returned unaltered if the background is fully transparent.
+func (p *Processor) flatten(img *image.NRGBA) *image.NRGBA {
+	if p.Background.A == 0 {
+		return img
+	}
+	dst := image.NewNRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{p.Background}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	return dst
+}
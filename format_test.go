@@ -0,0 +1,127 @@
+package caire
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_ShouldDetectFormatByContent(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	assert.NoError(png.Encode(&buf, img))
+
+	format, r, err := DetectFormat(&buf)
+	assert.NoError(err)
+	assert.Equal(FormatPNG, format)
+
+	// The returned reader should still contain the sniffed bytes.
+	_, err = png.Decode(r)
+	assert.NoError(err)
+
+	format, _, err = DetectFormat(bytes.NewReader([]byte("not an image")))
+	assert.NoError(err)
+	assert.Empty(format)
+}
+
+func TestFormat_ShouldForceOutputFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	var src, dst bytes.Buffer
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	assert.NoError(png.Encode(&src, img))
+
+	proc := &Processor{
+		NewWidth:       imgWidth / 2,
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		OutputFormat:   "png",
+	}
+	assert.NoError(proc.Process(&src, &dst))
+
+	_, format, err := image.DecodeConfig(&dst)
+	assert.NoError(err)
+	assert.Equal("png", format)
+
+	_, err = ParseFormat("tiff")
+	assert.Error(err)
+}
+
+func TestFormat_ShouldFlattenTransparentImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	proc := &Processor{}
+	assert.Equal(img, proc.flatten(img))
+
+	proc.Background = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	res := proc.flatten(img)
+	assert.Equal(color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, res.NRGBAAt(0, 0))
+}
+
+func TestFormat_ShouldRejectOversizedImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 100, 80))))
+
+	_, _, err := decode(bytes.NewReader(buf.Bytes()), "", 5000)
+	var tooLarge *ImageTooLargeError
+	assert.True(errors.As(err, &tooLarge))
+	assert.Equal(100, tooLarge.Width)
+	assert.Equal(80, tooLarge.Height)
+
+	// The header consumed by the size check is replayed for the decoder.
+	img, format, err := decode(bytes.NewReader(buf.Bytes()), "", 8000)
+	assert.NoError(err)
+	assert.Equal(FormatPNG, format)
+	assert.Equal(image.Rect(0, 0, 100, 80), img.Bounds())
+
+	proc := &Processor{NewWidth: 90, MaxPixels: 1000, OutputFormat: FormatPNG}
+	assert.Error(proc.Process(bytes.NewReader(buf.Bytes()), &bytes.Buffer{}))
+	proc.MaxPixels = -1
+	assert.NoError(proc.Process(bytes.NewReader(buf.Bytes()), &bytes.Buffer{}))
+}
+
+func TestFormat_ShouldResetTheGifStateBetweenTheImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, texturedImage(40, 30)))
+
+	var out bytes.Buffer
+	p := &Processor{SobelThreshold: 2, NewWidth: 36, OutputFormat: FormatGIF}
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	assert.True(isGif)
+
+	// The following image is not carved as a Gif frame, and the auto tuning is not skipped.
+	out.Reset()
+	p = &Processor{SobelThreshold: 2, NewWidth: 36, OutputFormat: FormatPNG, AutoTune: true}
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	assert.False(isGif)
+	assert.Nil(g)
+	assert.Positive(p.TuneDecision().Attempts)
+}
+
+func TestFormat_ShouldEncodeTheCMYKOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	var in, out bytes.Buffer
+	assert.NoError(jpeg.Encode(&in, texturedImage(40, 30), nil))
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, OutputFormat: FormatJPEG, JPEGCMYK: true}
+	assert.NoError(p.Process(&in, &out))
+
+	res, err := jpeg.Decode(&out)
+	assert.NoError(err)
+	assert.IsType(&image.CMYK{}, res)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+}
@@ -0,0 +1,63 @@
+package caire
+
+import "math"
+
+// useForwardEnergy reports whether the seams are searched using the forward energy.
+// The tileable mode relies on its own toroidal energy accumulation.
+func (p *Processor) useForwardEnergy() bool {
+	return (p.ForwardEnergy || p.preset().forwardEnergy) && !p.Tileable
+}
+
+// accumulateForwardEnergy computes the cumulative energy map using the forward energy criterion
+// (Rubinstein, Shamir, Avidan: Improved Seam Carving for Video Retargeting). Besides the energy
+// of the removed pixel, it takes into account the energy introduced by the pixels which are
+// becoming neighbors after the seam removal, which reduces the artifacts on the straight edges.
+// The direction of the chosen parent pixel is stored, so the seam can be traced back exactly.
+func (c *Carver) accumulateForwardEnergy(gray []uint8) {
+	// The intensities are on the same scale as the energy.
+	scale := float64(0xff)
+	if c.fixed {
+		scale = 1
+	}
+	intensity := func(x, y int) float64 {
+		x = min(max(x, 0), c.Width-1)
+		return float64(gray[x+y*c.Width]) / scale
+	}
+	c.parents = make([]int8, c.Width*c.Height)
+
+	for y := 1; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			// The cost of the new edge between the left and right neighbors.
+			cu := math.Abs(intensity(x+1, y) - intensity(x-1, y))
+
+			best, dir := c.get(x, y-1)+cu, int8(0)
+			if x > 0 {
+				cl := cu + math.Abs(intensity(x, y-1)-intensity(x-1, y))
+				if v := c.get(x-1, y-1) + cl; v < best {
+					best, dir = v, -1
+				}
+			}
+			if x < c.Width-1 {
+				cr := cu + math.Abs(intensity(x, y-1)-intensity(x+1, y))
+				if v := c.get(x+1, y-1) + cr; v < best {
+					best, dir = v, 1
+				}
+			}
+			c.set(x, y, c.get(x, y)+best)
+			c.parents[x+y*c.Width] = dir
+		}
+	}
+}
+
+// traceForwardSeam traces back the seam ending in the px column of the last row,
+// following the parent directions recorded by the forward energy accumulation.
+func (c *Carver) traceForwardSeam(px int) []Seam {
+	seams := make([]Seam, 0, c.Height)
+	seams = append(seams, Seam{X: px, Y: c.Height - 1})
+
+	for y := c.Height - 1; y > 0; y-- {
+		px += int(c.parents[px+y*c.Width])
+		seams = append(seams, Seam{X: px, Y: y - 1})
+	}
+	return seams
+}
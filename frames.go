@@ -0,0 +1,119 @@
+package caire
+
+import (
+	"context"
+	"errors"
+	"image"
+)
+
+// errStopFrames is used internally for stopping the resizing process
+// when the consumer of the intermediate frames is not interested in more frames.
+var errStopFrames = errors.New("frames iteration stopped")
+
+// SeamInfo describes the seam removed or inserted at a resizing step.
+type SeamInfo struct {
+	// Step is the index of the resizing step, starting from 0.
+	Step int
+	// Axis is the dimension which is changed by the seam: "width" or "height".
+	Axis string
+	// Inserted reports whether the seam has been inserted (enlargement) or removed.
+	Inserted bool
+	// Seam holds the seam pixels in the coordinates of the image prior to the step.
+	Seam []image.Point
+}
+
+// Frame is an intermediate state of the resizing process sent by FramesChan.
+type Frame struct {
+	Image *image.NRGBA
+	Info  SeamInfo
+	// Err is set on the last frame, in case the resizing process has failed.
+	Err error
+}
+
+// FramesChan resizes the image and sends each intermediate state through the returned channel.
+// The channel is closed once the resizing is completed or the context is canceled.
+// It's an alternative of the Frames iterator for the Go versions lacking the iter package.
+func (p *Processor) FramesChan(ctx context.Context, img image.Image) <-chan Frame {
+	frames := make(chan Frame)
+
+	go func() {
+		defer close(frames)
+
+		err := p.runFrames(ctx, img, func(frame *image.NRGBA, info SeamInfo) bool {
+			select {
+			case frames <- Frame{Image: frame, Info: info}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			frames <- Frame{Err: err}
+		}
+	}()
+	return frames
+}
+
+// runFrames resizes the image and invokes the yield function after each resizing step.
+// The resizing process is stopped when the yield function returns false.
+func (p *Processor) runFrames(ctx context.Context, img image.Image, yield func(*image.NRGBA, SeamInfo) bool) error {
+	if p.Grid != nil {
+		return errors.New("the intermediate frames are not available in grid mode")
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	defer p.cancel()
+
+	step := 0
+	p.onStep = func(frame *image.NRGBA, info SeamInfo) error {
+		info.Step = step
+		step++
+		if !yield(frame, info) {
+			return errStopFrames
+		}
+		return nil
+	}
+	defer func() { p.onStep = nil }()
+
+	defer lockCarving()()
+	resizeXY = p.NewWidth != 0 && p.NewHeight != 0
+
+	src := p.imgToNRGBA(img)
+	if p.GuiDebug == nil {
+		p.GuiDebug = image.NewNRGBA(src.Bounds())
+	}
+	if _, err := p.resizeLocked(src); err != nil && !errors.Is(err, errStopFrames) {
+		return err
+	}
+	return nil
+}
+
+// notifyStep passes the image obtained after a seam removal or insertion to the step hook.
+// The frames of the vertical resizing are rotated back to the original orientation.
+func (p *Processor) notifyStep(c *Carver, img *image.NRGBA, seams []Seam, inserted bool) error {
+	info := p.seamInfo(c, seams, inserted)
+	if p.vRes {
+		img = c.RotateImage270(img)
+	}
+	return p.onStep(img, info)
+}
+
+// seamInfo describes the seam in the orientation of the source image.
+func (p *Processor) seamInfo(c *Carver, seams []Seam, inserted bool) SeamInfo {
+	info := SeamInfo{
+		Axis:     "width",
+		Inserted: inserted,
+		Seam:     make([]image.Point, len(seams)),
+	}
+	height := c.Height
+	for i, s := range seams {
+		info.Seam[i] = image.Point{X: s.X, Y: s.Y}
+		if p.vRes {
+			// The canonical image width equals with the height of the rotated image.
+			info.Seam[i] = image.Point{X: height - s.Y - 1, Y: s.X}
+		}
+	}
+	if p.vRes {
+		info.Axis = "height"
+	}
+	return info
+}
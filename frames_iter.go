@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package caire
+
+import (
+	"context"
+	"image"
+	"iter"
+)
+
+// Frames returns an iterator over the intermediate states of the resizing process.
+// Each iteration yields the image obtained after a seam removal or insertion together with
+// the seam details. Breaking the loop stops the resizing process. The error which might
+// have interrupted the iteration is reported by FramesErr.
+func (p *Processor) Frames(img image.Image) iter.Seq2[*image.NRGBA, SeamInfo] {
+	return func(yield func(*image.NRGBA, SeamInfo) bool) {
+		p.framesErr = p.runFrames(context.Background(), img, yield)
+	}
+}
+
+// FramesErr returns the error which has interrupted the last Frames iteration.
+func (p *Processor) FramesErr() error {
+	return p.framesErr
+}
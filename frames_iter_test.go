@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrames_ShouldStopTheIteration(t *testing.T) {
+	assert := assert.New(t)
+
+	proc := &Processor{
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		NewWidth:       35,
+	}
+
+	var last *image.NRGBA
+	for frame, info := range proc.Frames(texturedImage(30, 20)) {
+		assert.True(info.Inserted)
+		last = frame
+		if info.Step == 1 {
+			break
+		}
+	}
+	assert.NoError(proc.FramesErr())
+	assert.Equal(image.Rect(0, 0, 32, 20), last.Bounds())
+}
@@ -0,0 +1,44 @@
+package caire
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrames_ShouldSendTheIntermediateStates(t *testing.T) {
+	assert := assert.New(t)
+
+	proc := &Processor{
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		NewWidth:       25,
+	}
+
+	var steps int
+	for frame := range proc.FramesChan(context.Background(), texturedImage(30, 20)) {
+		assert.NoError(frame.Err)
+		assert.Equal(steps, frame.Info.Step)
+		assert.Equal("width", frame.Info.Axis)
+		assert.False(frame.Info.Inserted)
+		assert.Len(frame.Info.Seam, 20)
+		assert.Equal(image.Rect(0, 0, 30-steps-1, 20), frame.Image.Bounds())
+		steps++
+	}
+	assert.Equal(5, steps)
+
+	// The frames of the vertical resizing are reported in the original orientation.
+	proc.NewWidth, proc.NewHeight = 0, 18
+	ctx, cancel := context.WithCancel(context.Background())
+	frame := <-proc.FramesChan(ctx, texturedImage(30, 20))
+	cancel()
+
+	assert.Equal("height", frame.Info.Axis)
+	assert.Len(frame.Info.Seam, 30)
+	assert.Equal(image.Rect(0, 0, 30, 19), frame.Image.Bounds())
+	for _, pt := range frame.Info.Seam {
+		assert.True(pt.In(image.Rect(0, 0, 30, 20)))
+	}
+}
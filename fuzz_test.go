@@ -0,0 +1,79 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzProcess(f *testing.F) {
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 10))
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 20), G: uint8(y * 25), B: 100, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	f.Add(buf.Bytes(), uint8(10), uint8(0))
+	f.Add(buf.Bytes()[:buf.Len()/2], uint8(10), uint8(8))
+
+	buf.Reset()
+	jpeg.Encode(&buf, img, nil)
+	f.Add(buf.Bytes(), uint8(0), uint8(8))
+	f.Add(buf.Bytes()[:buf.Len()/2], uint8(14), uint8(0))
+
+	buf.Reset()
+	gif.Encode(&buf, img, nil)
+	f.Add(buf.Bytes(), uint8(1), uint8(1))
+
+	f.Fuzz(func(t *testing.T, data []byte, width, height uint8) {
+		if width == 0 && height == 0 {
+			return
+		}
+		// Keep the fuzzing fast by skipping the inputs declaring huge images.
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && cfg.Width*cfg.Height > 64*64 {
+			return
+		}
+		proc := &Processor{
+			NewWidth:     int(width % 32),
+			NewHeight:    int(height % 32),
+			OutputFormat: FormatPNG,
+		}
+		// The malformed inputs have to be reported as errors, without panicking.
+		proc.Process(bytes.NewReader(data), &bytes.Buffer{})
+	})
+}
+
+func TestProcess_ShouldRejectTheMalformedInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h)))
+		return buf.Bytes()
+	}
+	valid := encode(12, 10)
+
+	for name, tc := range map[string]struct {
+		data          []byte
+		width, height int
+	}{
+		"truncated":   {valid[:len(valid)/2], 8, 0},
+		"garbage":     {[]byte("not an image"), 8, 0},
+		"tiny source": {encode(1, 5), 0, 3},
+		"tiny target": {valid, 1, 0},
+		// The image rescaled prior to carving would be a single pixel wide.
+		"tiny rescale": {encode(3, 5), 2, 2},
+	} {
+		proc := &Processor{NewWidth: tc.width, NewHeight: tc.height, OutputFormat: FormatPNG}
+		assert.Error(proc.Process(bytes.NewReader(tc.data), &bytes.Buffer{}), name)
+	}
+}
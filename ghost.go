@@ -0,0 +1,101 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// ghostOpacity is the opacity of the original image drawn over the carved image.
+	ghostOpacity = 0.35
+	// ghostHighlight is the maximum opacity of the highlight marking the displaced pixels.
+	ghostHighlight = 0.6
+)
+
+// ghostColor is the color of the highlight marking the displaced pixels.
+var ghostColor = color.NRGBA{R: 0xff, G: 0x1e, B: 0x78, A: 0xff}
+
+// displacement returns the distance of each pixel of the carved image from the position it
+// would have in the uniformly scaled source image, measured in pixels of the carved image.
+// It returns nil if the coordinates of the carved pixels are not tracked.
+func (p *Processor) displacement(srcW, srcH, width, height int) []float64 {
+	t := p.tracker
+	if t == nil || t.width != width || t.height != height {
+		return nil
+	}
+	sx, sy := float64(width)/float64(srcW), float64(height)/float64(srcH)
+
+	dist := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pt := t.at(x, y)
+			dx := float64(x) - ((float64(pt.X)+0.5)*sx - 0.5)
+			dy := float64(y) - ((float64(pt.Y)+0.5)*sy - 0.5)
+			dist[y*width+x] = math.Hypot(dx, dy)
+		}
+	}
+	return dist
+}
+
+// ghostImage overlays a semi-transparent ghost of the source image, scaled to the size of
+// the carved image, over the carved image, then highlights the pixels proportionally with
+// their displacement, so the objects shifted by the seam carving are easy to spot.
+func (p *Processor) ghostImage(src *image.NRGBA, res image.Image) *image.NRGBA {
+	bounds := res.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), res, bounds.Min, draw.Src)
+
+	ghost := imaging.Resize(src, width, height, imaging.Linear)
+	mask := &image.Uniform{color.Alpha{A: uint8(math.Round(ghostOpacity * 0xff))}}
+	draw.DrawMask(dst, dst.Bounds(), ghost, image.Point{}, mask, image.Point{}, draw.Over)
+
+	dist := p.displacement(src.Bounds().Dx(), src.Bounds().Dy(), width, height)
+	var maxDist float64
+	for _, d := range dist {
+		maxDist = math.Max(maxDist, d)
+	}
+	if maxDist < 1 {
+		return dst
+	}
+	for i, d := range dist {
+		alpha := ghostHighlight * d / maxDist
+		o := 4 * i
+		dst.Pix[o+0] = uint8(float64(dst.Pix[o+0])*(1-alpha) + float64(ghostColor.R)*alpha)
+		dst.Pix[o+1] = uint8(float64(dst.Pix[o+1])*(1-alpha) + float64(ghostColor.G)*alpha)
+		dst.Pix[o+2] = uint8(float64(dst.Pix[o+2])*(1-alpha) + float64(ghostColor.B)*alpha)
+	}
+	return dst
+}
+
+// writeGhostFile saves the ghost overlay of the source and the carved image.
+// The image format is defined by the file extension.
+func (p *Processor) writeGhostFile(path string, src *image.NRGBA, res image.Image) error {
+	format, err := ParseFormat(filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	img := p.ghostImage(src, res)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the ghost overlay file: %v", err)
+	}
+	defer f.Close()
+
+	if format == FormatJPEG {
+		img = p.flatten(img)
+	}
+	if err := encodeImage(f, img, format); err != nil {
+		return err
+	}
+	return f.Close()
+}
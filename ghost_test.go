@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGhost_ShouldHighlightTheDisplacedPixels(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 30, GhostPath: "ghost.png"}
+	res, err := p.Resize(img)
+	assert.NoError(err)
+
+	dist := p.displacement(40, 30, 30, 30)
+	assert.Len(dist, 30*30)
+	var moved int
+	for _, d := range dist {
+		if d >= 1 {
+			moved++
+		}
+	}
+	assert.Greater(moved, 0)
+	assert.Nil(p.displacement(40, 30, 20, 30))
+
+	path := filepath.Join(t.TempDir(), "ghost.png")
+	assert.NoError(p.writeGhostFile(path, img, res))
+	f, err := os.Open(path)
+	assert.NoError(err)
+	defer f.Close()
+	ghost, err := png.Decode(f)
+	assert.NoError(err)
+	assert.Equal(res.Bounds(), ghost.Bounds())
+
+	// The most displaced pixels get the strongest highlight.
+	var most int
+	for i, d := range dist {
+		if d > dist[most] {
+			most = i
+		}
+	}
+	r, g, b, _ := ghost.At(most%30, most/30).RGBA()
+	assert.Greater(r, g)
+	assert.Greater(r, b)
+
+	assert.Error(p.writeGhostFile(filepath.Join(t.TempDir(), "ghost.txt"), img, res))
+}
@@ -0,0 +1,173 @@
+// Package giowidget provides the live preview of the seam carving process as a Gio widget,
+// which can be embedded into the windows of other Gio applications.
+//
+// The widget is decoupled from the window and from the lifecycle of the caire command:
+// the carving runs in a goroutine started by the application, which feeds the widget
+// with the intermediate frames, while the window's event loop calls Layout.
+//
+//	var preview giowidget.Preview
+//	go preview.Run(ctx, proc, img, w.Invalidate)
+//	...
+//	case system.FrameEvent:
+//		gtx := layout.NewContext(&ops, e)
+//		preview.Layout(gtx)
+//		e.Frame(gtx.Ops)
+package giowidget
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+
+	"gioui.org/io/semantic"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+	"github.com/esimov/caire"
+)
+
+// defaultSeamColor is the color of the seams if SeamColor is not set.
+var defaultSeamColor = color.NRGBA{R: 0xff, A: 0xff}
+
+// defaultLabel is the accessible name of the widget if Label is not set.
+const defaultLabel = "Seam carving preview"
+
+// Preview is a widget showing the image being carved. It's safe to update the widget
+// from the carving goroutine while the window's goroutine is drawing it.
+type Preview struct {
+	// ShowSeams draws the seam changed by the last resizing step over the image.
+	ShowSeams bool
+	// SeamColor is the color of the seams. It defaults to red.
+	SeamColor color.NRGBA
+	// Fit specifies how to scale the image to the constraints.
+	// The zero value (widget.Unscaled) is replaced by widget.Contain.
+	Fit widget.Fit
+	// Label is the name of the widget announced by the screen readers, followed by the
+	// size of the displayed image and the state of the resizing.
+	Label string
+
+	mu    sync.Mutex
+	img   *image.NRGBA
+	seam  []image.Point
+	done  bool
+	err   error
+	last  *image.NRGBA
+	imgOp paint.ImageOp
+}
+
+// Run resizes the image using the processor and shows the intermediate frames in the widget.
+// The invalidate function (usually the Invalidate method of the window) is called after each
+// frame for requesting a redraw. Run blocks until the resizing is completed or the context
+// is canceled, so it should be called from a separate goroutine.
+func (p *Preview) Run(ctx context.Context, proc *caire.Processor, img image.Image, invalidate func()) error {
+	var err error
+	for frame := range proc.FramesChan(ctx, img) {
+		if frame.Err != nil {
+			err = frame.Err
+			break
+		}
+		p.Update(frame.Image, frame.Info)
+		if invalidate != nil {
+			invalidate()
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	p.done, p.err = true, err
+	p.mu.Unlock()
+	if invalidate != nil {
+		invalidate()
+	}
+	return err
+}
+
+// Update sets the displayed frame together with the seam changed by the resizing step.
+func (p *Preview) Update(img *image.NRGBA, info caire.SeamInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.img, p.seam = img, info.Seam
+}
+
+// Done reports whether the resizing has been completed, together with its error, if any.
+func (p *Preview) Done() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.done, p.err
+}
+
+// Image returns the currently displayed frame.
+func (p *Preview) Image() *image.NRGBA {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.img
+}
+
+// Layout draws the current frame, scaled according to the Fit option,
+// and the last seam in case ShowSeams is enabled.
+func (p *Preview) Layout(gtx layout.Context) layout.Dimensions {
+	p.mu.Lock()
+	img, seam, done := p.img, p.seam, p.done
+	p.mu.Unlock()
+
+	if img == nil {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+	// The image operation is created only for new frames, so the texture is uploaded once.
+	if img != p.last {
+		p.imgOp, p.last = paint.NewImageOp(img), img
+	}
+
+	fit := p.Fit
+	if fit == widget.Unscaled {
+		fit = widget.Contain
+	}
+	dims := widget.Image{
+		Src:   p.imgOp,
+		Fit:   fit,
+		Scale: 1 / gtx.Metric.PxPerDp,
+	}.Layout(gtx)
+	p.layoutSemantics(gtx, dims, img.Bounds().Size(), done)
+
+	if p.ShowSeams && len(seam) > 0 {
+		col := p.SeamColor
+		if col == (color.NRGBA{}) {
+			col = defaultSeamColor
+		}
+		size := img.Bounds().Size()
+		sx := float32(dims.Size.X) / float32(size.X)
+		sy := float32(dims.Size.Y) / float32(size.Y)
+
+		for _, pt := range seam {
+			rect := image.Rect(
+				int(float32(pt.X)*sx), int(float32(pt.Y)*sy),
+				int(float32(pt.X+1)*sx+0.5), int(float32(pt.Y+1)*sy+0.5),
+			)
+			paint.FillShape(gtx.Ops, col, clip.Rect(rect).Op())
+		}
+	}
+	return dims
+}
+
+// layoutSemantics describes the widget to the assistive technologies.
+func (p *Preview) layoutSemantics(gtx layout.Context, dims layout.Dimensions, size image.Point, done bool) {
+	label := p.Label
+	if label == "" {
+		label = defaultLabel
+	}
+	state := "resizing"
+	if done {
+		state = "done"
+	}
+	defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+	semantic.LabelOp(label).Add(gtx.Ops)
+	semantic.DescriptionOp(fmt.Sprintf("%d by %d pixels, %s", size.X, size.Y, state)).Add(gtx.Ops)
+}
@@ -0,0 +1,77 @@
+package giowidget
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"gioui.org/io/router"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"github.com/esimov/caire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreview_ShouldShowTheCarvedFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Constraints: layout.Exact(image.Pt(80, 80)),
+	}
+
+	preview := &Preview{ShowSeams: true}
+	// Nothing is drawn before the first frame.
+	assert.Equal(image.Pt(80, 80), preview.Layout(gtx).Size)
+
+	var redraws int
+	proc := &caire.Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 36}
+	assert.NoError(preview.Run(context.Background(), proc, img, func() { redraws++ }))
+
+	done, err := preview.Done()
+	assert.True(done)
+	assert.NoError(err)
+	// Each of the 4 steps and the completion are requesting a redraw.
+	assert.Equal(5, redraws)
+	assert.Equal(image.Rect(0, 0, 36, 30), preview.Image().Bounds())
+
+	// The frame is scaled up to the constraints, keeping its aspect ratio.
+	gtx.Constraints = layout.Exact(image.Pt(72, 60))
+	gtx.Constraints.Min = image.Point{}
+	assert.Equal(image.Pt(72, 60), preview.Layout(gtx).Size)
+}
+
+func TestPreview_ShouldDescribeTheImage(t *testing.T) {
+	assert := assert.New(t)
+
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Constraints: layout.Exact(image.Pt(40, 30)),
+	}
+	preview := &Preview{Label: "Carved photo"}
+	preview.Update(image.NewNRGBA(image.Rect(0, 0, 40, 30)), caire.SeamInfo{})
+	preview.Layout(gtx)
+
+	var r router.Router
+	r.Frame(gtx.Ops)
+	var labels, descs []string
+	for _, n := range r.AppendSemantics(nil) {
+		if n.Desc.Label != "" {
+			labels = append(labels, n.Desc.Label)
+			descs = append(descs, n.Desc.Description)
+		}
+	}
+	assert.Equal([]string{"Carved photo"}, labels)
+	assert.Equal([]string{"40 by 30 pixels, resizing"}, descs)
+}
@@ -0,0 +1,165 @@
+package caire
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// Grid defines the guide lines (like the tile boundaries of a texture atlas) dividing the image
+// into cells, which are carved independently. The guide lines are never crossed by the seams,
+// this way the layout of the image survives the resize.
+type Grid struct {
+	// Cols and Rows divides the image into equally sized cells.
+	Cols, Rows int
+	// X and Y holds the position of the vertical and horizontal guide lines in pixels.
+	// They have priority over Cols and Rows.
+	X, Y []int
+}
+
+// guides returns the cell boundaries along one axis, including the image edges.
+func (gr *Grid) guides(size, cells int, lines []int) ([]int, error) {
+	bounds := []int{0}
+	if len(lines) > 0 {
+		lines = append([]int(nil), lines...)
+		sort.Ints(lines)
+		for _, l := range lines {
+			if l <= bounds[len(bounds)-1] || l >= size {
+				return nil, fmt.Errorf("invalid guide line position: %d", l)
+			}
+			bounds = append(bounds, l)
+		}
+	} else if cells > 1 {
+		for i := 1; i < cells; i++ {
+			bounds = append(bounds, i*size/cells)
+		}
+	}
+	return append(bounds, size), nil
+}
+
+// scaleGuides returns the position of the cell boundaries in the resized image.
+func scaleGuides(bounds []int, size, newSize int) ([]int, error) {
+	scaled := make([]int, len(bounds))
+	for i, b := range bounds {
+		scaled[i] = int(math.Round(float64(b) * float64(newSize) / float64(size)))
+		if i > 0 && scaled[i] <= scaled[i-1] {
+			return nil, errors.New("the grid cells are too small for the requested image size")
+		}
+	}
+	return scaled, nil
+}
+
+// resizeGrid carves each cell of the grid independently and assembles the resized cells.
+func (p *Processor) resizeGrid(img *image.NRGBA) (*image.NRGBA, error) {
+	if p.Square {
+		return nil, errors.New("the square option cannot be used with the grid constraint")
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	newWidth, newHeight := p.NewWidth, p.NewHeight
+	if p.Percentage {
+		newWidth = width * newWidth / 100
+		newHeight = height * newHeight / 100
+	}
+	if newWidth == 0 {
+		newWidth = width
+	}
+	if newHeight == 0 {
+		newHeight = height
+	}
+
+	xs, err := p.Grid.guides(width, p.Grid.Cols, p.Grid.X)
+	if err != nil {
+		return nil, err
+	}
+	ys, err := p.Grid.guides(height, p.Grid.Rows, p.Grid.Y)
+	if err != nil {
+		return nil, err
+	}
+	nxs, err := scaleGuides(xs, width, newWidth)
+	if err != nil {
+		return nil, err
+	}
+	nys, err := scaleGuides(ys, height, newHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// The intermediary frames of the cells cannot be used for the Gif animation.
+	gifMode, xyMode := isGif, resizeXY
+	isGif = false
+	defer func() { isGif, resizeXY = gifMode, xyMode }()
+
+	dst := image.NewNRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for j := 0; j < len(ys)-1; j++ {
+		for i := 0; i < len(xs)-1; i++ {
+			rect := image.Rect(xs[i], ys[j], xs[i+1], ys[j+1])
+
+			cell := *p
+			cell.Grid = nil
+			cell.Preview = false
+			cell.Percentage = false
+			cell.SeamsSVGPath = ""
+			cell.maskWatch, cell.rmaskWatch = nil, nil
+			cell.NewWidth = nxs[i+1] - nxs[i]
+			cell.NewHeight = nys[j+1] - nys[j]
+			if cell.NewWidth == rect.Dx() {
+				cell.NewWidth = 0
+			}
+			if cell.NewHeight == rect.Dy() {
+				cell.NewHeight = 0
+			}
+			if p.Mask != nil {
+				cell.Mask = p.imgToNRGBA(p.Mask.SubImage(rect))
+			}
+			if p.RMask != nil {
+				cell.RMask = p.imgToNRGBA(p.RMask.SubImage(rect))
+			}
+			if p.biasMap != nil {
+				cell.EnergyRules = nil
+				cell.Regions = nil
+				cell.WeightMask = nil
+				cell.Reference = nil
+				cell.Screenshot = false
+				cell.subject = nil
+				cell.biasMap = p.imgToNRGBA(p.biasMap.SubImage(rect))
+			}
+			// The seams table used for enlargement is specific to each cell.
+			energySeams = energySeams[:0]
+			resizeXY = cell.NewWidth != 0 && cell.NewHeight != 0
+
+			res, err := cell.resizeLocked(p.imgToNRGBA(img.SubImage(rect)))
+			if err != nil {
+				return nil, err
+			}
+			draw.Draw(dst, image.Rect(nxs[i], nys[j], nxs[i+1], nys[j+1]), res, res.Bounds().Min, draw.Src)
+		}
+	}
+
+	if gifMode {
+		pal := color.Palette(palette.Plan9)
+		if p.palette != nil {
+			pal = p.palette
+		}
+		frame := image.NewPaletted(dst.Bounds(), pal)
+		draw.Draw(frame, dst.Bounds(), dst, image.Point{}, draw.Src)
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 0)
+	}
+
+	if p.Preview {
+		go func() {
+			imgWorker <- worker{
+				carver: nil,
+				img:    nil,
+				done:   true,
+			}
+		}()
+	}
+	return dst, nil
+}
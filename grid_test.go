@@ -0,0 +1,47 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrid_ShouldKeepTheCellBoundaries(t *testing.T) {
+	assert := assert.New(t)
+
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			if x < 20 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	proc := &Processor{
+		NewWidth:       30,
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		Grid:           &Grid{Cols: 2},
+	}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 30, 20), res.Bounds())
+
+	dst := res.(*image.NRGBA)
+	for y := 0; y < 20; y++ {
+		assert.Equal(red, dst.NRGBAAt(14, y))
+		assert.Equal(blue, dst.NRGBAAt(15, y))
+	}
+
+	proc.Grid = &Grid{X: []int{50}}
+	_, err = proc.Resize(img)
+	assert.Error(err)
+}
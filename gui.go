@@ -1,3 +1,5 @@
+//go:build !headless
+
 package caire
 
 import (
@@ -5,14 +7,16 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"math"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"gioui.org/app"
 	"gioui.org/f32"
 	"gioui.org/font/gofont"
+	"gioui.org/gpu/headless"
 	"gioui.org/io/key"
+	"gioui.org/io/semantic"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -23,7 +27,6 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/esimov/caire/imop"
-	"github.com/esimov/caire/utils"
 )
 
 const (
@@ -45,6 +48,11 @@ var (
 
 	defaultBkgColor  = color.Transparent
 	defaultFillColor = color.Black
+
+	// The colors of the high contrast theme.
+	hcBackground = color.NRGBA{A: 0xff}
+	hcForeground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	hcAccent     = color.NRGBA{R: 0xff, G: 0xff, A: 0xff}
 )
 
 type interval struct {
@@ -71,13 +79,16 @@ type Gui struct {
 
 			background color.Color
 			fill       color.Color
+			checker    bool
 		}
 		timeStamp time.Time
+		metric    unit.Metric
 	}
 	proc struct {
-		isDone bool
-		img    image.Image
-		seams  []Seam
+		isDone    bool
+		isAborted bool
+		img       image.Image
+		seams     []Seam
 
 		wrk <-chan worker
 		err chan<- error
@@ -91,6 +102,15 @@ type Gui struct {
 	view struct {
 		huds layout.List
 	}
+	// rec records the frames rendered offscreen in the shot window.
+	rec  *previewRecorder
+	shot *headless.Window
+	// checker holds the checkerboard image covering the window, which is
+	// regenerated only when the window is resized.
+	checker struct {
+		size image.Point
+		src  paint.ImageOp
+	}
 }
 
 type hudCtrl struct {
@@ -136,6 +156,19 @@ func (g *Gui) Add(index int, title string, enabled bool) {
 	g.huds[index] = control
 }
 
+// useHighContrast switches the GUI to the high contrast theme: white text and yellow controls
+// over a black background, using a larger text size.
+func (g *Gui) useHighContrast() {
+	g.th.Palette = material.Palette{
+		Bg:         hcBackground,
+		Fg:         hcForeground,
+		ContrastBg: hcAccent,
+		ContrastFg: hcBackground,
+	}
+	g.th.TextSize = unit.Sp(16)
+	g.cfg.color.background = hcBackground
+}
+
 // initWindow creates and initializes the GUI window.
 func (g *Gui) initWindow(w, h int) {
 	rand.NewSource(time.Now().UnixNano())
@@ -156,6 +189,7 @@ func (g *Gui) initWindow(w, h int) {
 		g.cfg.window.w, g.cfg.window.h = g.getWindowSize()
 	}
 	g.cfg.window.title = "Preview"
+	g.cfg.metric = unit.Metric{PxPerDp: 1, PxPerSp: 1}
 }
 
 // getWindowSize returns the resized image dimension.
@@ -170,6 +204,18 @@ func (g *Gui) getWindowSize() (float32, float32) {
 	return w, h
 }
 
+// windowSize returns the window dimension expressed in device independent units.
+// The preview window is sized in such a way that one image pixel is mapped to
+// one device pixel, otherwise the image is blurred or doubled in size on HiDPI displays.
+func (g *Gui) windowSize() (unit.Dp, unit.Dp) {
+	w, h := g.cfg.window.w, g.cfg.window.h
+	if !resizeXY && g.cfg.metric.PxPerDp > 0 {
+		w /= g.cfg.metric.PxPerDp
+		h /= g.cfg.metric.PxPerDp
+	}
+	return unit.Dp(w), unit.Dp(h)
+}
+
 // Run is the core method of the Gio GUI application.
 // This updates the window with the resized image received from a channel
 // and terminates when the image resizing operation completes.
@@ -181,18 +227,32 @@ func (g *Gui) Run() error {
 
 		descRed, descGreen, descBlue bool
 	)
-	w := app.NewWindow(app.Title(g.cfg.window.title), app.Size(
-		unit.Dp(g.cfg.window.w),
-		unit.Dp(g.cfg.window.h),
-	))
+	setDPIAwareness()
+	w := app.NewWindow(app.Title(g.cfg.window.title), app.Size(g.windowSize()))
 	w.Perform(system.ActionCenter)
 	g.cfg.timeStamp = time.Now()
 
+	if g.cp.HighContrast {
+		g.useHighContrast()
+	}
+	// The option is validated before the window is opened.
+	if bg, err := parsePreviewBackground(g.cp.PreviewBackground); err == nil && len(g.cp.PreviewBackground) > 0 {
+		g.cfg.color.background, g.cfg.color.checker = bg.color, bg.checker
+	}
+
+	if len(g.cp.RecordPath) > 0 {
+		var err error
+		if g.rec, err = newPreviewRecorder(g.cp.RecordPath); err != nil {
+			return err
+		}
+	}
+
 	if g.cp.Debug {
 		g.Add(0, "Show seams", true)
 		if len(g.cp.MaskPath) > 0 || len(g.cp.RMaskPath) > 0 || g.cp.FaceDetect {
 			g.Add(1, "Debug mask", false)
 		}
+		g.Add(2, "Checkerboard", g.cfg.color.checker)
 	}
 
 	abortFn := func() {
@@ -205,14 +265,10 @@ func (g *Gui) Run() error {
 		if !g.proc.isDone {
 			if (g.cp.NewWidth > 0 && g.cp.NewWidth != dx) ||
 				(g.cp.NewHeight > 0 && g.cp.NewHeight != dy) {
-
-				errorMsg := fmt.Sprintf("%s %s %s",
-					utils.DecorateText("⚡ CAIRE", utils.StatusMessage),
-					utils.DecorateText("⇢ process aborted by the user...", utils.DefaultMessage),
-					utils.DecorateText("✘\n", utils.ErrorMessage),
-				)
-				g.cp.Spinner.StopMsg = errorMsg
-				g.cp.Spinner.Stop()
+				// Cancel the carving goroutine. The caller is responsible
+				// to terminate the execution with a non-zero exit status.
+				g.proc.isAborted = true
+				g.cp.Abort()
 			}
 		}
 		g.cp.Spinner.RestoreCursor()
@@ -225,10 +281,29 @@ func (g *Gui) Run() error {
 			case system.FrameEvent:
 				gtx := layout.NewContext(g.ctx.Ops, e)
 
-				key.InputOp{Tag: w, Keys: key.NameEscape}.Add(gtx.Ops)
+				// The pixel density changes when the window is moved between monitors
+				// with different DPI settings. Resize the window to the new metric.
+				if e.Metric != g.cfg.metric {
+					g.cfg.metric = e.Metric
+					if !resizeXY {
+						w.Option(app.Size(g.windowSize()))
+					}
+				}
+
+				// The debug controls are reachable with the Tab key, but they can be toggled
+				// directly with the number keys shown next to their titles as well.
+				key.InputOp{Tag: w, Keys: key.NameEscape + "|Ctrl-C|1|2|3"}.Add(gtx.Ops)
 				for _, ev := range gtx.Queue.Events(w) {
-					if e, ok := ev.(key.Event); ok && e.Name == key.NameEscape {
-						w.Perform(system.ActionClose)
+					if e, ok := ev.(key.Event); ok && e.State == key.Press {
+						if e.Name == key.NameEscape || (e.Name == "C" && e.Modifiers.Contain(key.ModCtrl)) {
+							abortFn()
+							w.Perform(system.ActionClose)
+						}
+						if n, err := strconv.Atoi(string(e.Name)); err == nil {
+							if hud, ok := g.huds[n-1]; ok {
+								hud.visible.Value = !hud.visible.Value
+							}
+						}
 					}
 				}
 
@@ -273,8 +348,12 @@ func (g *Gui) Run() error {
 				}
 				g.draw(gtx, color.NRGBA{R: rc, G: gc, B: bc})
 				e.Frame(gtx.Ops)
+				g.capture(gtx.Ops, e.Size)
 			case system.DestroyEvent:
 				abortFn()
+				if err := g.saveRecording(); err != nil {
+					return err
+				}
 				return e.Err
 			}
 		case res := <-g.proc.wrk:
@@ -316,6 +395,48 @@ func (g *Gui) Run() error {
 	}
 }
 
+// capture renders the operations of the frame once again in an offscreen window, since the
+// content of the preview window can't be read back, and adds the result to the recording.
+func (g *Gui) capture(ops *op.Ops, size image.Point) {
+	now := time.Now()
+	if g.rec == nil || !g.rec.due(now) || size.X == 0 || size.Y == 0 {
+		return
+	}
+	// The offscreen window follows the size of the preview window.
+	if g.shot != nil && g.shot.Size() != size {
+		g.shot.Release()
+		g.shot = nil
+	}
+	var err error
+	if g.shot == nil {
+		if g.shot, err = headless.NewWindow(size.X, size.Y); err != nil {
+			g.rec.fail(err)
+			return
+		}
+	}
+	frame := image.NewRGBA(image.Rectangle{Max: size})
+	if err = g.shot.Frame(ops); err == nil {
+		err = g.shot.Screenshot(frame)
+	}
+	if err != nil {
+		g.rec.fail(err)
+		return
+	}
+	g.rec.add(frame, now)
+}
+
+// saveRecording releases the offscreen window and saves the recorded frames.
+func (g *Gui) saveRecording() error {
+	if g.rec == nil {
+		return nil
+	}
+	if g.shot != nil {
+		g.shot.Release()
+		g.shot = nil
+	}
+	return g.rec.save()
+}
+
 type (
 	C = layout.Context
 	D = layout.Dimensions
@@ -339,54 +460,46 @@ func (g *Gui) draw(gtx layout.Context, bgCol color.NRGBA) {
 				paint.FillShape(gtx.Ops, c,
 					clip.Rect{Max: g.ctx.Constraints.Max}.Op(),
 				)
+				if g.showChecker() {
+					g.drawCheckerboard(gtx)
+				}
 				return layout.UniformInset(unit.Dp(0)).Layout(gtx,
 					func(gtx C) D {
+						// The screen readers are announcing the preview together with the current image size.
+						size := g.proc.img.Bounds().Size()
+						area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+						semantic.LabelOp("Preview of the resized image").Add(gtx.Ops)
+						semantic.DescriptionOp(fmt.Sprintf("%d by %d pixels", size.X, size.Y)).Add(gtx.Ops)
+						area.Pop()
+
 						widget.Image{
 							Src:   src,
-							Scale: 1 / float32(unit.Dp(1)),
+							Scale: 1 / gtx.Metric.PxPerDp,
 							Fit:   widget.Contain,
 						}.Layout(gtx)
 
 						if seam, ok := g.huds[0]; ok {
 							if seam.visible.Value {
-								tr := f32.Affine2D{}
-								screen := layout.FPt(g.ctx.Constraints.Max)
-								width, height := float32(g.proc.img.Bounds().Dx()), float32(g.proc.img.Bounds().Dy())
-								sw, sh := float32(screen.X), float32(screen.Y)
-
-								if sw > width {
-									ratio := sw / width
-									tr = tr.Scale(f32.Pt(sw/2, sh/2), f32.Pt(1, ratio))
-								} else if sh > height {
-									ratio := sh / height
-									tr = tr.Scale(f32.Pt(sw/2, sh/2), f32.Pt(ratio, 1))
+								bounds := g.proc.img.Bounds()
+								scale := fitScale(gtx.Constraints.Max, bounds.Size())
+								size := float32(defaultShapeSize)
+								if g.cp.ShapeSize > 0 {
+									size = float32(g.cp.ShapeSize)
 								}
-
-								if g.cp.vRes {
-									angle := float32(270 * math.Pi / 180)
-									half := float32(math.Round(float64(sh*0.5-height*0.5) * 0.5))
-
-									ox := math.Abs(float64(sw - (sw - (sw/2 - sh/2))))
-									oy := math.Abs(float64(sh - (sh - (sw/2 - height/2 + half))))
-									tr = tr.Rotate(f32.Pt(sw/2, sh/2), -angle)
-
-									if screen.X > screen.Y {
-										tr = tr.Offset(f32.Pt(float32(ox), float32(oy)))
-									} else {
-										tr = tr.Offset(f32.Pt(float32(-ox), float32(-oy)))
+								size *= gtx.Metric.PxPerDp
+
+								pts := make([]f32.Point, len(g.proc.seams))
+								for i, s := range g.proc.seams {
+									x, y := float32(s.X), float32(s.Y)
+									// The seams are computed on the rotated image in case of vertical resizing,
+									// so their coordinates have to be transformed back to the displayed image.
+									if g.cp.vRes {
+										x, y = float32(bounds.Dx()-s.Y-1), float32(s.X)
 									}
+									// Convert the image coordinates from pixel values to screen pixels.
+									pts[i] = f32.Point{X: (x + 0.5) * scale, Y: (y + 0.5) * scale}
 								}
-								op.Affine(tr).Add(gtx.Ops)
-
-								for _, s := range g.proc.seams {
-									dpx := unit.Dp(s.X)
-									dpy := unit.Dp(s.Y)
-
-									// Convert the image coordinates from pixel values to DP units.
-									dpiy := unit.Dp(float32(g.cfg.window.w) / float32(300))
-									dpix := unit.Dp(float32(g.cfg.window.h) / float32(300))
-									g.DrawSeam(g.cp.ShapeType, float32(dpx*dpix), float32(dpy*dpiy), 2.0)
-								}
+								g.DrawSeamPath(g.cp.ShapeType, pts, size)
 							}
 						}
 						return layout.Dimensions{Size: gtx.Constraints.Max}
@@ -395,9 +508,14 @@ func (g *Gui) draw(gtx layout.Context, bgCol color.NRGBA) {
 		)
 	}
 	if g.cp.Debug {
+		hudBg := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xcc}
+		hudBorder, borderSize := color.NRGBA{R: 0x3B, G: 0x41, B: 0x3C, A: 0xaa}, unit.Dp(0.5)
+		if g.cp.HighContrast {
+			hudBg, hudBorder, borderSize = hcBackground, hcAccent, unit.Dp(2)
+		}
 		layout.Stack{}.Layout(g.ctx,
 			layout.Stacked(func(gtx C) D {
-				hudHeight := 40
+				hudHeight := gtx.Dp(unit.Dp(40))
 				r := image.Rectangle{
 					Max: image.Point{
 						X: gtx.Constraints.Max.X,
@@ -407,24 +525,32 @@ func (g *Gui) draw(gtx layout.Context, bgCol color.NRGBA) {
 				defer op.Offset(image.Pt(0, gtx.Constraints.Max.Y-hudHeight)).Push(gtx.Ops).Pop()
 				return layout.Stack{}.Layout(gtx,
 					layout.Expanded(func(gtx C) D {
-						paint.FillShape(gtx.Ops, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xcc}, clip.Rect(r).Op())
+						paint.FillShape(gtx.Ops, hudBg, clip.Rect(r).Op())
+						area := clip.Rect(r).Push(gtx.Ops)
+						semantic.LabelOp("Debug controls").Add(gtx.Ops)
+						area.Pop()
 						return layout.Dimensions{Size: r.Max}
 					}),
 					layout.Stacked(func(gtx C) D {
 						border := image.Rectangle{
 							Max: image.Point{
 								X: gtx.Constraints.Max.X,
-								Y: gtx.Dp(unit.Dp(0.5)),
+								Y: gtx.Dp(borderSize),
 							},
 						}
-						paint.FillShape(gtx.Ops, color.NRGBA{R: 0x3B, G: 0x41, B: 0x3C, A: 0xaa}, clip.Rect(border).Op())
+						paint.FillShape(gtx.Ops, hudBorder, clip.Rect(border).Op())
 						return layout.Dimensions{Size: r.Max}
 					}),
 					layout.Stacked(func(gtx C) D {
-						return g.view.huds.Layout(gtx, len(g.huds),
+						// The controls are indexed by their role, so some of the indices might be missing.
+						var count int
+						for index := range g.huds {
+							count = max(count, index+1)
+						}
+						return g.view.huds.Layout(gtx, count,
 							func(gtx layout.Context, index int) D {
 								if hud, ok := g.huds[index]; ok {
-									checkbox := material.CheckBox(g.th, &hud.visible, fmt.Sprintf("%v", hud.title))
+									checkbox := material.CheckBox(g.th, &hud.visible, fmt.Sprintf("%v [%d]", hud.title, hud.index+1))
 									checkbox.Size = 20
 									return checkbox.Layout(gtx)
 								}
@@ -450,9 +576,33 @@ func (g *Gui) draw(gtx layout.Context, bgCol color.NRGBA) {
 	}
 }
 
+// showChecker reports whether the checkerboard is shown behind the image. In debug mode it's
+// toggled by the checkerboard control, otherwise it's defined by the preview background option.
+func (g *Gui) showChecker() bool {
+	if hud, ok := g.huds[2]; ok {
+		return hud.visible.Value
+	}
+	return g.cfg.color.checker
+}
+
+// drawCheckerboard fills the window with the checkerboard revealing the transparent image areas.
+func (g *Gui) drawCheckerboard(gtx layout.Context) {
+	size := gtx.Constraints.Max
+	if size != g.checker.size {
+		g.checker.size = size
+		g.checker.src = paint.NewImageOp(checkerboard(size, gtx.Dp(unit.Dp(checkerCell))))
+	}
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	g.checker.src.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+}
+
 // displayMessage show a static message when the image is resized both horizontally and vertically.
 func (g *Gui) displayMessage(ctx layout.Context, bgCol color.NRGBA, msg string) {
 	g.th.Palette.Fg = color.NRGBA{R: 251, G: 254, B: 249, A: 0xff}
+	if g.cp.HighContrast {
+		g.th.Palette.Fg, bgCol = hcForeground, hcBackground
+	}
 	paint.ColorOp{Color: bgCol}.Add(ctx.Ops)
 
 	rect := image.Rectangle{
@@ -465,7 +615,8 @@ func (g *Gui) displayMessage(ctx layout.Context, bgCol color.NRGBA, msg string)
 	layout.Stack{}.Layout(ctx,
 		layout.Stacked(func(gtx C) D {
 			return layout.UniformInset(unit.Dp(4)).Layout(ctx, func(gtx C) D {
-				if !g.proc.isDone {
+				// The animated gradient is not shown in the high contrast mode.
+				if !g.proc.isDone && !g.cp.HighContrast {
 					gtx.Constraints.Min.Y = 0
 					tr := f32.Affine2D{}
 					dr := image.Rectangle{Max: gtx.Constraints.Min}
@@ -3,56 +3,23 @@ package caire
 import (
 	"image"
 	"image/color"
+
+	"github.com/esimov/caire/filters"
 )
 
 // Grayscale converts the source image to grayscale mode.
 func (c *Carver) Grayscale(src *image.NRGBA) *image.NRGBA {
-	dx, dy := src.Bounds().Max.X, src.Bounds().Max.Y
-	dst := image.NewNRGBA(src.Bounds())
-
-	for x := 0; x < dx; x++ {
-		for y := 0; y < dy; y++ {
-			r, g, b, _ := src.At(x, y).RGBA()
-			lum := float32(r)*0.299 + float32(g)*0.587 + float32(b)*0.114
-			pixel := color.Gray{Y: uint8(lum / 256)}
-			dst.Set(x, y, pixel)
-		}
-	}
-	return dst
+	return filters.Grayscale(src)
 }
 
 // RotateImage90 rotate the image by 90 degree counter clockwise.
 func (c *Carver) RotateImage90(src *image.NRGBA) *image.NRGBA {
-	b := src.Bounds()
-	dst := image.NewNRGBA(image.Rect(0, 0, b.Max.Y, b.Max.X))
-	for dstY := 0; dstY < b.Max.X; dstY++ {
-		for dstX := 0; dstX < b.Max.Y; dstX++ {
-			srcX := b.Max.X - dstY - 1
-			srcY := dstX
-
-			srcOff := srcY*src.Stride + srcX*4
-			dstOff := dstY*dst.Stride + dstX*4
-			copy(dst.Pix[dstOff:dstOff+4], src.Pix[srcOff:srcOff+4])
-		}
-	}
-	return dst
+	return rotate(src, true)
 }
 
 // RotateImage270 rotate the image by 270 degree counter clockwise.
 func (c *Carver) RotateImage270(src *image.NRGBA) *image.NRGBA {
-	b := src.Bounds()
-	dst := image.NewNRGBA(image.Rect(0, 0, b.Max.Y, b.Max.X))
-	for dstY := 0; dstY < b.Max.X; dstY++ {
-		for dstX := 0; dstX < b.Max.Y; dstX++ {
-			srcX := dstY
-			srcY := b.Max.Y - dstX - 1
-
-			srcOff := srcY*src.Stride + srcX*4
-			dstOff := dstY*dst.Stride + dstX*4
-			copy(dst.Pix[dstOff:dstOff+4], src.Pix[srcOff:srcOff+4])
-		}
-	}
-	return dst
+	return rotate(src, false)
 }
 
 // imgToPix converts an image to a pixel array.
@@ -97,18 +64,5 @@ func (c *Carver) pixToImage(pixels []uint8) image.Image {
 // rgbToGrayscale converts an image to grayscale mode and
 // returns the pixel values as an one dimensional array.
 func (c *Carver) rgbToGrayscale(src *image.NRGBA) []uint8 {
-	width, height := src.Bounds().Dx(), src.Bounds().Dy()
-	gray := make([]uint8, width*height)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			r, g, b, _ := src.At(x, y).RGBA()
-			gray[y*width+x] = uint8(
-				(0.299*float64(r) +
-					0.587*float64(g) +
-					0.114*float64(b)) / 256,
-			)
-		}
-	}
-	return gray
+	return filters.Luminance(nil, src)
 }
@@ -228,3 +228,20 @@ func compareBytes(a, b []uint8, delta int) bool {
 	}
 	return true
 }
+
+func TestImage_CMYKToNRGBA(t *testing.T) {
+	img := image.NewCMYK(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 16)
+	}
+
+	dst := p.imgToNRGBA(img)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if got := dst.NRGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d,%d): got %v want %v", x, y, got, want)
+			}
+		}
+	}
+}
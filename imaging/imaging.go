@@ -0,0 +1,143 @@
+// Package imaging exposes the content aware resizing as a named operation, the way the image
+// proxies (imgproxy, thumbor and the like) register their transformations. The image servers
+// can enable the seam carving by importing this package and dispatching the "smart" operation
+// with the width, height and face parameters taken from the request URL:
+//
+//	op, _ := imaging.Lookup(imaging.Smart)
+//	dst, err := op.Apply(ctx, src, r.URL.Query())
+package imaging
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/esimov/caire"
+)
+
+// Smart is the name of the content aware resizing operation.
+const Smart = "smart"
+
+// Operation is an image transformation registered under a name.
+type Operation struct {
+	Name string
+	// Apply transforms the image using the parameters of the request URL.
+	Apply func(ctx context.Context, img image.Image, params url.Values) (image.Image, error)
+}
+
+var (
+	mu         sync.RWMutex
+	operations = make(map[string]Operation)
+
+	// The seam carver relies on package level state, so the images are resized one at a time.
+	carveMu sync.Mutex
+)
+
+func init() {
+	Register(Operation{Name: Smart, Apply: smartResize})
+}
+
+// Register registers the operation, replacing the existing operation with the same name.
+func Register(op Operation) {
+	if op.Name == "" || op.Apply == nil {
+		panic("imaging: the operation should have a name and an Apply function")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	operations[op.Name] = op
+}
+
+// Lookup returns the operation registered under the name.
+func Lookup(name string) (Operation, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	op, ok := operations[name]
+	return op, ok
+}
+
+// Names returns the sorted names of the registered operations.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Params are the resizing parameters of the smart operation.
+type Params struct {
+	Width          int
+	Height         int
+	Face           bool
+	BlurRadius     int
+	SobelThreshold int
+}
+
+// ParseParams parses the resizing parameters from the URL query. Both the long and the short
+// parameter names used by the image proxies are accepted: width or w, height or h and face or
+// faces. The blur and sobel parameters are optional, the defaults being the CLI defaults.
+func ParseParams(q url.Values) (Params, error) {
+	p := Params{BlurRadius: 4, SobelThreshold: 2}
+
+	var err error
+	parseInt := func(v *int, names ...string) {
+		for _, name := range names {
+			if s := q.Get(name); s != "" && err == nil {
+				n, perr := strconv.Atoi(s)
+				if perr != nil || n < 0 {
+					err = fmt.Errorf("invalid %s parameter: %q", name, s)
+					return
+				}
+				*v = n
+			}
+		}
+	}
+	parseInt(&p.Width, "width", "w")
+	parseInt(&p.Height, "height", "h")
+	parseInt(&p.BlurRadius, "blur")
+	parseInt(&p.SobelThreshold, "sobel")
+
+	for _, name := range []string{"face", "faces"} {
+		if s := q.Get(name); s != "" && err == nil {
+			if p.Face, err = strconv.ParseBool(s); err != nil {
+				err = fmt.Errorf("invalid %s parameter: %q", name, s)
+			}
+		}
+	}
+	if err != nil {
+		return Params{}, err
+	}
+	if p.Width == 0 && p.Height == 0 {
+		return Params{}, fmt.Errorf("the width or the height parameter is required")
+	}
+	return p, nil
+}
+
+// smartResize resizes the image with the seam carving algorithm.
+func smartResize(ctx context.Context, img image.Image, q url.Values) (image.Image, error) {
+	p, err := ParseParams(q)
+	if err != nil {
+		return nil, err
+	}
+	pl := &caire.Pipeline{Steps: []caire.Operation{{
+		Op:             caire.OpCarve,
+		Width:          p.Width,
+		Height:         p.Height,
+		BlurRadius:     p.BlurRadius,
+		SobelThreshold: p.SobelThreshold,
+		FaceDetect:     p.Face,
+	}}}
+
+	carveMu.Lock()
+	defer carveMu.Unlock()
+
+	return pl.Run(ctx, img)
+}
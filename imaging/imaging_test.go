@@ -0,0 +1,58 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImaging_ShouldParseTheParams(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := ParseParams(url.Values{"w": {"300"}, "h": {"200"}, "faces": {"1"}})
+	assert.NoError(err)
+	assert.Equal(Params{Width: 300, Height: 200, Face: true, BlurRadius: 4, SobelThreshold: 2}, p)
+
+	p, err = ParseParams(url.Values{"width": {"300"}, "sobel": {"8"}})
+	assert.NoError(err)
+	assert.Equal(Params{Width: 300, BlurRadius: 4, SobelThreshold: 8}, p)
+
+	for _, q := range []url.Values{
+		{},
+		{"width": {"abc"}},
+		{"h": {"-1"}},
+		{"w": {"10"}, "face": {"maybe"}},
+	} {
+		_, err := ParseParams(q)
+		assert.Error(err, q.Encode())
+	}
+}
+
+func TestImaging_ShouldApplyTheSmartOperation(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Contains(Names(), Smart)
+	op, ok := Lookup(Smart)
+	assert.True(ok)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	res, err := op.Apply(context.Background(), img, url.Values{"w": {"32"}})
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+
+	Register(Operation{Name: "noop", Apply: func(_ context.Context, img image.Image, _ url.Values) (image.Image, error) {
+		return img, nil
+	}})
+	_, ok = Lookup("noop")
+	assert.True(ok)
+	assert.Panics(func() { Register(Operation{Name: "invalid"}) })
+}
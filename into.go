@@ -0,0 +1,206 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/esimov/caire/filters"
+)
+
+// inPlaceCarver holds the working buffers of the in place carving, which are reused
+// by the successive ResizeInto calls of the processor.
+type inPlaceCarver struct {
+	carver Carver
+	red    []uint8
+	mags   []uint8
+	edges  image.NRGBA
+	blur   filters.Blur
+	seam   []Seam
+}
+
+// ResizeInto resizes the source image and writes the result into the caller owned dst buffer.
+// The pixel buffer of dst is reused if its capacity is large enough for the source image,
+// otherwise it's reallocated. The bounds of dst are updated to the size of the resized image.
+//
+// If the image is reduced along a single axis and the energy map is computed only by the sobel
+// and the blur filters (no masks, face detection, quality presets or debugging outputs), the seams
+// are removed directly from the pixel buffer of dst, while the energy map and the filter buffers
+// are kept by the processor. This way the steady state of the callers carving the same sized
+// *image.NRGBA frames (like the video pipelines) doesn't allocate, unless multiple seam workers
+// are used. The other resizing operations are carried out by Resize, the result being copied into
+// dst. In case of an error the content of dst is undefined.
+func (p *Processor) ResizeInto(dst *image.NRGBA, src image.Image) error {
+	if dst == nil {
+		return errors.New("the destination image cannot be nil")
+	}
+	img := p.imgToNRGBA(src)
+	if p.canCarveInPlace(img) {
+		return p.carveInPlace(dst, img)
+	}
+	res, err := p.Resize(img)
+	if err != nil {
+		return err
+	}
+
+	bounds := res.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	fitPix(dst, width, height)
+
+	if nrgba, ok := res.(*image.NRGBA); ok {
+		for y := 0; y < height; y++ {
+			i := nrgba.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+			copy(dst.Pix[y*dst.Stride:(y+1)*dst.Stride], nrgba.Pix[i:i+dst.Stride])
+		}
+		return nil
+	}
+	draw.Draw(dst, dst.Rect, res, bounds.Min, draw.Src)
+
+	return nil
+}
+
+// fitPix resizes the pixel buffer of the image to the provided size, reusing it if it's large enough.
+func fitPix(img *image.NRGBA, width, height int) {
+	if size := 4 * width * height; cap(img.Pix) < size {
+		img.Pix = make([]uint8, size)
+	} else {
+		img.Pix = img.Pix[:size]
+	}
+	img.Stride = 4 * width
+	img.Rect = image.Rect(0, 0, width, height)
+}
+
+// canCarveInPlace reports whether the image is reduced along a single axis, using only the options
+// of the plain energy map, in which case ResizeInto carves it in place.
+func (p *Processor) canCarveInPlace(img *image.NRGBA) bool {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < minImageSize || height < minImageSize {
+		return false
+	}
+	switch {
+	case (p.NewWidth == 0) == (p.NewHeight == 0):
+		return false
+	case p.NewWidth != 0 && (p.NewWidth >= width || p.NewWidth < minImageSize):
+		return false
+	case p.NewHeight != 0 && (p.NewHeight >= height || p.NewHeight < minImageSize):
+		return false
+	}
+	return !p.Percentage && !p.Square && !p.Debug && !p.Preview && !p.FaceDetect && p.MaskPath == "" &&
+		p.RMaskPath == "" && p.WeightMask == nil && p.Reference == nil && !p.Screenshot && p.Backend == BackendCPU &&
+		p.ChannelWeights == [3]float64{} && p.SeamsSVGPath == "" && p.RemovedPath == "" && p.EnergyCSVPath == "" &&
+		p.GhostPath == "" && p.HeatmapPath == "" && p.DisplaceMap == "" && !p.TrackCoords && p.Grid == nil &&
+		!p.Tileable && !p.AutoAxis && len(p.EnergyRules) == 0 && len(p.Regions) == 0 && !p.FastMode &&
+		!p.ForwardEnergy && (p.Quality == "" || p.Quality == QualityBalanced) && !p.AutoTune && p.Timeout == 0 &&
+		!p.PartialOutput && p.Jitter == nil && p.Tracer == nil && !p.WatchMasks && p.MaxSeamsPerRegion == 0 &&
+		p.ColorBlockKeep == 0 && !p.AutoMethod && p.MinReductionPercent == 0 && p.DebugSnapshot == nil &&
+		p.OnProgress == nil && !p.FixedPoint && p.subject == nil && !isGif
+}
+
+// carveInPlace copies the image into the pixel buffer of dst and removes the seams from it in place.
+// The seams are found exactly like by Resize, so the result is the same.
+func (p *Processor) carveInPlace(dst, img *image.NRGBA) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	stride := 4 * width
+	fitPix(dst, width, height)
+	for y := 0; y < height; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		copy(dst.Pix[y*stride:(y+1)*stride], img.Pix[i:i+stride])
+	}
+
+	// The pixel at the (x, y) position of the carved image is at origin + x*xs + y*ys in the buffer.
+	// Reducing the height, the image is carved like the image rotated counter clockwise by Resize.
+	w, h, target := width, height, p.NewWidth
+	origin, xs, ys := 0, 4, stride
+	if p.vRes = p.NewHeight != 0; p.vRes {
+		w, h, target = height, width, p.NewHeight
+		origin, xs, ys = 4*(width-1), stride, -4
+	}
+	p.tracker, p.jitterRand = nil, nil
+
+	if p.inPlace == nil {
+		p.inPlace = &inPlaceCarver{}
+	}
+	s := p.inPlace
+	c := &s.carver
+	c.workers = p.seamWorkers()
+	var run filters.Runner
+	if c.workers > 1 {
+		run = c.runner
+	}
+
+	for ; w > target; w-- {
+		if err := p.getContext().Err(); err != nil {
+			return err
+		}
+		n := w * h
+		s.red = fitSlice(s.red, n)
+		for y := 0; y < h; y++ {
+			for x, i := 0, origin+y*ys; x < w; x, i = x+1, i+xs {
+				s.red[y*w+x] = dst.Pix[i]
+			}
+		}
+		s.mags = filters.SobelMagnitudes(s.mags, s.red, w, float64(p.SobelThreshold), run)
+
+		s.edges.Pix = fitSlice(s.edges.Pix, 4*n)
+		s.edges.Stride, s.edges.Rect = 4*w, image.Rect(0, 0, w, h)
+		for i, m := range s.mags {
+			s.edges.Pix[i*4], s.edges.Pix[i*4+1], s.edges.Pix[i*4+2], s.edges.Pix[i*4+3] = m, m, m, 0xff
+		}
+		if p.BlurRadius > 0 {
+			s.blur.StackBlur(&s.edges, uint32(p.BlurRadius))
+		}
+
+		c.Width, c.Height = w, h
+		c.Points = fitSlice(c.Points, n)
+		for i := range c.Points {
+			pix := s.edges.Pix[i*4 : i*4+4]
+			r, _, _, a := color.NRGBA{R: pix[0], G: pix[1], B: pix[2], A: pix[3]}.RGBA()
+			c.Points[i] = float64(r) / float64(a)
+		}
+		c.accumulateEnergy()
+		if err := c.checkEnergy(); err != nil {
+			return err
+		}
+		s.seam = c.appendLowestEnergySeam(p, s.seam[:0])
+		if err := c.checkSeam(s.seam); err != nil {
+			return err
+		}
+
+		for _, seam := range s.seam {
+			row := origin + seam.Y*ys
+			if xs == 4 {
+				copy(dst.Pix[row+seam.X*4:row+(w-1)*4], dst.Pix[row+(seam.X+1)*4:row+w*4])
+				continue
+			}
+			for x, i := seam.X, row+seam.X*xs; x < w-1; x, i = x+1, i+xs {
+				copy(dst.Pix[i:i+4], dst.Pix[i+xs:i+xs+4])
+			}
+		}
+	}
+
+	// The rows are packed to the stride of the carved image. Reducing the height,
+	// the remaining rows are already at the beginning of the buffer.
+	if p.vRes {
+		dst.Pix, dst.Rect = dst.Pix[:stride*target], image.Rect(0, 0, width, target)
+		return nil
+	}
+	for y := 1; y < height; y++ {
+		copy(dst.Pix[y*target*4:(y+1)*target*4], dst.Pix[y*stride:y*stride+target*4])
+	}
+	dst.Pix, dst.Stride, dst.Rect = dst.Pix[:target*4*height], target*4, image.Rect(0, 0, target, height)
+
+	return nil
+}
+
+// fitSlice returns the slice resized to n elements, reusing its backing array if it's large enough.
+func fitSlice[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,84 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResizeInto_ShouldReuseTheBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32}
+	expected, err := p.Resize(img)
+	assert.NoError(err)
+
+	// The buffer is too small, so it's reallocated.
+	dst := &image.NRGBA{}
+	assert.NoError(p.ResizeInto(dst, img))
+	assert.Equal(image.Rect(0, 0, 32, 30), dst.Bounds())
+	assert.Equal(expected.(*image.NRGBA).Pix, dst.Pix)
+
+	// A larger buffer is reused.
+	buf := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	pix := &buf.Pix[0]
+	assert.NoError(p.ResizeInto(buf, img))
+	assert.Same(pix, &buf.Pix[0])
+	assert.Equal(image.Rect(0, 0, 32, 30), buf.Bounds())
+	assert.Equal(expected.(*image.NRGBA).Pix, buf.Pix)
+
+	assert.Error(p.ResizeInto(nil, img))
+}
+
+func TestResizeInto_ShouldCarveInPlaceLikeResize(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(60, 50)
+	for _, p := range []*Processor{
+		{SobelThreshold: 2, BlurRadius: 1, NewWidth: 45},
+		{SobelThreshold: 4, BlurRadius: 3, NewHeight: 38},
+		{SobelThreshold: 2, NewWidth: 52, SeamWorkers: -1},
+		// The options altering the energy map are carved by Resize.
+		{SobelThreshold: 2, BlurRadius: 1, NewWidth: 45, Quality: QualityBest},
+		{SobelThreshold: 2, BlurRadius: 1, NewWidth: 45, NewHeight: 40},
+	} {
+		expected, err := p.Resize(img)
+		assert.NoError(err)
+
+		dst := &image.NRGBA{}
+		assert.NoError(p.ResizeInto(dst, img))
+		assert.Equal(expected.Bounds(), dst.Bounds())
+		assert.Equal(expected.(*image.NRGBA).Pix, dst.Pix)
+	}
+}
+
+func TestResizeInto_ShouldNotAllocateInTheSteadyState(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(60, 50)
+	for _, p := range []*Processor{
+		{SobelThreshold: 2, BlurRadius: 1, NewWidth: 45},
+		{SobelThreshold: 2, BlurRadius: 1, NewHeight: 40},
+	} {
+		dst := &image.NRGBA{}
+		assert.NoError(p.ResizeInto(dst, img))
+		pix := &dst.Pix[0]
+
+		allocs := testing.AllocsPerRun(10, func() {
+			if err := p.ResizeInto(dst, img); err != nil {
+				t.Fatal(err)
+			}
+		})
+		assert.Zero(allocs)
+		assert.Same(pix, &dst.Pix[0])
+	}
+}
@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Jitter enables the augmentation mode, in which the carver removes a random subset
+// of the low-energy seams instead of the strictly optimal ones. This produces varied
+// but plausible distortions of the same image, useful for dataset augmentation.
+type Jitter struct {
+	// Seed initializes the random generator, the same seed reproduces the same result.
+	Seed int64
+	// Intensity is the fraction of the lowest energy seams, between 0 and 1,
+	// from which the seam to be removed is randomly picked. 0 disables the jitter.
+	Intensity float64
+}
+
+// validate checks the jitter parameters.
+func (j *Jitter) validate() error {
+	if j.Intensity < 0 || j.Intensity > 1 {
+		return fmt.Errorf("the jitter intensity should be between 0 and 1, got %v", j.Intensity)
+	}
+	return nil
+}
+
+// jitterStart picks a random starting pixel on the last row of the energy map among the
+// columns having the lowest cumulative energy. The px value is the optimal starting pixel,
+// which is returned unchanged when the jitter is not enabled.
+func (p *Processor) jitterStart(c *Carver, px int) int {
+	if p.jitterRand == nil {
+		return px
+	}
+	n := int(p.Jitter.Intensity * float64(c.Width))
+	if n <= 1 {
+		return px
+	}
+
+	cols := make([]int, c.Width)
+	for x := range cols {
+		cols[x] = x
+	}
+	y := c.Height - 1
+	sort.SliceStable(cols, func(i, j int) bool {
+		return c.get(cols[i], y) < c.get(cols[j], y)
+	})
+	return cols[p.jitterRand.Intn(n)]
+}
+
+// newJitterRand returns the random generator used for picking the seams, or nil if the jitter is not enabled.
+func newJitterRand(j *Jitter) (*rand.Rand, error) {
+	if j == nil || j.Intensity == 0 {
+		return nil, nil
+	}
+	if err := j.validate(); err != nil {
+		return nil, err
+	}
+	return rand.New(rand.NewSource(j.Seed)), nil
+}
@@ -0,0 +1,33 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter_ShouldProduceReproducibleVariations(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	resize := func(j *Jitter) *image.NRGBA {
+		proc := &Processor{SobelThreshold: 4, NewWidth: 32, Jitter: j}
+		res, err := proc.Resize(img)
+		assert.NoError(err)
+		assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+		return res.(*image.NRGBA)
+	}
+
+	optimal := resize(nil)
+	assert.Equal(optimal.Pix, resize(&Jitter{Seed: 1}).Pix)
+
+	a := resize(&Jitter{Seed: 1, Intensity: 0.5})
+	assert.Equal(a.Pix, resize(&Jitter{Seed: 1, Intensity: 0.5}).Pix)
+	assert.NotEqual(a.Pix, resize(&Jitter{Seed: 2, Intensity: 0.5}).Pix)
+	assert.NotEqual(optimal.Pix, a.Pix)
+
+	proc := &Processor{SobelThreshold: 4, NewWidth: 32, Jitter: &Jitter{Intensity: 1.5}}
+	_, err := proc.Resize(img)
+	assert.Error(err)
+}
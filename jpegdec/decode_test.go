@@ -0,0 +1,162 @@
+package jpegdec
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/esimov/caire/jpegenc"
+)
+
+// testImage returns a smooth gradient with a few sharp edges, having an odd size
+// so the partial blocks and the chroma subsampling are exercised.
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8((x + y) % 256), A: 0xff}
+			if (x/16+y/16)%5 == 0 {
+				c = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// encodings returns the baseline, the progressive and the grayscale encodings of the test image.
+func encodings(t *testing.T) map[string][]byte {
+	img := testImage(101, 67)
+	res := make(map[string][]byte)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	res["baseline"] = bytes.Clone(buf.Bytes())
+
+	buf.Reset()
+	if err := jpegenc.Encode(&buf, img, &jpegenc.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	res["progressive"] = bytes.Clone(buf.Bytes())
+
+	gray := image.NewGray(img.Bounds())
+	for i := range gray.Pix {
+		gray.Pix[i] = img.Pix[i*4+1]
+	}
+	buf.Reset()
+	if err := jpeg.Encode(&buf, gray, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	res["gray"] = bytes.Clone(buf.Bytes())
+	return res
+}
+
+func TestDecode_ShouldMatchTheStandardDecoder(t *testing.T) {
+	for name, data := range encodings(t) {
+		want, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeScaled(bytes.NewReader(data), 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("%s: got bounds %v, want %v", name, got.Bounds(), want.Bounds())
+		}
+		b := want.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if got.At(x, y) != want.At(x, y) {
+					t.Fatalf("%s: pixel (%d, %d) differs: got %v, want %v", name, x, y, got.At(x, y), want.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestDecode_ShouldScaleTheImage(t *testing.T) {
+	for name, data := range encodings(t) {
+		full, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, scale := range []int{2, 4, 8} {
+			img, err := DecodeScaled(bytes.NewReader(data), scale)
+			if err != nil {
+				t.Fatalf("%s 1/%d: %v", name, scale, err)
+			}
+			want := image.Rect(0, 0, (101+scale-1)/scale, (67+scale-1)/scale)
+			if img.Bounds() != want {
+				t.Fatalf("%s 1/%d: got bounds %v, want %v", name, scale, img.Bounds(), want)
+			}
+
+			// The luma of the scaled pixels is compared with the mean of the full resolution pixels
+			// they cover. The chroma is left out, since its resolution is halved by the subsampling.
+			var diff, n float64
+			for y := 0; y < 67/scale; y++ {
+				for x := 0; x < 101/scale; x++ {
+					var sum float64
+					for yy := 0; yy < scale; yy++ {
+						for xx := 0; xx < scale; xx++ {
+							sum += float64(luma(full.At(x*scale+xx, y*scale+yy)))
+						}
+					}
+					d := float64(luma(img.At(x, y))) - sum/float64(scale*scale)
+					diff += d * d
+					n++
+				}
+			}
+			if mse := diff / n; mse > 16 {
+				t.Errorf("%s 1/%d: the mean squared error is too large: %.2f", name, scale, mse)
+			}
+		}
+	}
+}
+
+// luma returns the Y component of the color.
+func luma(c color.Color) uint8 {
+	if yc, ok := c.(color.YCbCr); ok {
+		return yc.Y
+	}
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+func TestDecode_ShouldUseTheDCCoefficientForTheMean(t *testing.T) {
+	for _, dc := range []int32{-1024, -803, -8, -3, 0, 5, 100, 1016} {
+		var b block
+		b[0] = dc
+		idct(&b)
+		if got := dcMean(dc); got != b[0] {
+			t.Errorf("dc %d: got mean %d, the inverse DCT gives %d", dc, got, b[0])
+		}
+	}
+}
+
+func TestDecode_ShouldReadTheHeader(t *testing.T) {
+	data := encodings(t)
+
+	h, err := DecodeHeader(bytes.NewReader(data["baseline"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 101 || h.Height != 67 || h.Components != 3 || h.Progressive || h.CoefficientBytes != 0 {
+		t.Errorf("unexpected baseline header: %+v", h)
+	}
+
+	h, err = DecodeHeader(bytes.NewReader(data["progressive"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Progressive || h.CoefficientBytes == 0 {
+		t.Errorf("unexpected progressive header: %+v", h)
+	}
+
+	if _, err := DecodeScaled(bytes.NewReader(data["baseline"]), 3); err == nil {
+		t.Error("expected an error for the unsupported scale")
+	}
+}
@@ -0,0 +1,253 @@
+// Package jpegenc implements a progressive JPEG encoder with optimized Huffman tables.
+// The progressive files are rendered gradually by the browsers while downloading, and
+// together with the Huffman tables built from the actual symbol frequencies (instead of
+// the generic tables of the standard) they are usually smaller than the baseline files.
+package jpegenc
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// DefaultQuality is the quality used when the options are not provided.
+const DefaultQuality = 75
+
+// Options are the encoding parameters. Quality ranges from 1 to 100 inclusive, higher is better.
+type Options struct {
+	Quality int
+}
+
+// unzig maps the zig-zag order of the coefficients to their natural order in the block.
+var unzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10, 17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34, 27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36, 29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46, 53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// unscaledQuant are the luminance and chrominance quantization tables of the standard,
+// in zig-zag order, which are scaled according to the quality.
+var unscaledQuant = [2][64]int{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14, 13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37, 29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68, 87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113, 121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26, 26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// dctCos holds the cosine terms of the 8 point DCT, scaled by the normalization factors.
+var dctCos = func() (c [8][8]float64) {
+	for u := 0; u < 8; u++ {
+		s := 0.5
+		if u == 0 {
+			s = 1 / (2 * math.Sqrt2)
+		}
+		for x := 0; x < 8; x++ {
+			c[u][x] = s * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return
+}()
+
+// component is a color component of the image, holding the quantized coefficients of its blocks.
+type component struct {
+	id   byte
+	h, v int
+	// table selects the quantization and the Huffman tables: 0 for luminance, 1 for chrominance.
+	table int
+	// bw and bh are the number of blocks covering the MCUs, used by the interleaved scans, while
+	// cw and ch are the number of blocks covering the component, used by the single component scans.
+	bw, bh, cw, ch int
+	// coefs holds the coefficients of the blocks in zig-zag order.
+	coefs []int32
+}
+
+// scan is a progressive scan, encoding the Ss to Se spectral band of the components.
+type scan struct {
+	comps  []int
+	ss, se int
+}
+
+// Encode writes the image to w in progressive JPEG format. The gray images are
+// encoded with a single component, the CMYK images with four full resolution components
+// and the other images with 4:2:0 chroma subsampling.
+func Encode(w io.Writer, img image.Image, o *Options) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || width >= 1<<16 || height >= 1<<16 {
+		return errors.New("jpegenc: invalid image size")
+	}
+	quality := DefaultQuality
+	if o != nil {
+		quality = min(max(o.Quality, 1), 100)
+	}
+	var quant [2][64]int
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	for t := range quant {
+		for i, q := range unscaledQuant[t] {
+			quant[t][i] = min(max((q*scale+50)/100, 1), 255)
+		}
+	}
+
+	planes := toPlanes(img)
+	var comps []*component
+	switch len(planes) {
+	case 1:
+		comps = []*component{{id: 1, h: 1, v: 1}}
+	case 4:
+		comps = []*component{{id: 1, h: 1, v: 1}, {id: 2, h: 1, v: 1}, {id: 3, h: 1, v: 1}, {id: 4, h: 1, v: 1}}
+	default:
+		comps = []*component{{id: 1, h: 2, v: 2}, {id: 2, h: 1, v: 1, table: 1}, {id: 3, h: 1, v: 1, table: 1}}
+	}
+	hmax, vmax := comps[0].h, comps[0].v
+	mcusX, mcusY := (width+8*hmax-1)/(8*hmax), (height+8*vmax-1)/(8*vmax)
+	for i, c := range comps {
+		c.bw, c.bh = mcusX*c.h, mcusY*c.v
+		cw, ch := (width*c.h+hmax-1)/hmax, (height*c.v+vmax-1)/vmax
+		c.cw, c.ch = (cw+7)/8, (ch+7)/8
+		c.coefs = make([]int32, c.bw*c.bh*64)
+		c.transform(planes[i], width, height, hmax/c.h, vmax/c.v, &quant[c.table])
+	}
+
+	var scans []scan
+	switch len(comps) {
+	case 1:
+		scans = []scan{{[]int{0}, 0, 0}, {[]int{0}, 1, 5}, {[]int{0}, 6, 63}}
+	case 4:
+		scans = []scan{{[]int{0, 1, 2, 3}, 0, 0}, {[]int{0}, 1, 63}, {[]int{1}, 1, 63}, {[]int{2}, 1, 63}, {[]int{3}, 1, 63}}
+	default:
+		scans = []scan{{[]int{0, 1, 2}, 0, 0}, {[]int{0}, 1, 5}, {[]int{1}, 1, 63}, {[]int{2}, 1, 63}, {[]int{0}, 6, 63}}
+	}
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.write([]byte{0xff, 0xd8})
+	if len(comps) == 4 {
+		e.writeAdobe()
+	}
+	e.writeDQT(quant, len(comps))
+	e.writeSOF2(comps, width, height)
+	for _, s := range scans {
+		e.writeScan(comps, s, mcusX, mcusY)
+	}
+	e.write([]byte{0xff, 0xd9})
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// toPlanes returns the luma plane of the gray images, the inverted C, M, Y and K planes
+// of the CMYK images, or the Y, Cb and Cr planes of the other images.
+func toPlanes(img image.Image) [][]float64 {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	switch m := img.(type) {
+	case *image.Gray:
+		y := make([]float64, width*height)
+		for j := 0; j < height; j++ {
+			for i := 0; i < width; i++ {
+				y[j*width+i] = float64(m.GrayAt(b.Min.X+i, b.Min.Y+j).Y)
+			}
+		}
+		return [][]float64{y}
+	case *image.CMYK:
+		// The CMYK values are stored inverted, following the convention of the Adobe files.
+		planes := [][]float64{make([]float64, width*height), make([]float64, width*height), make([]float64, width*height), make([]float64, width*height)}
+		for j := 0; j < height; j++ {
+			k := m.PixOffset(b.Min.X, b.Min.Y+j)
+			for i := 0; i < width; i++ {
+				for c := range planes {
+					planes[c][j*width+i] = float64(0xff - m.Pix[k+4*i+c])
+				}
+			}
+		}
+		return planes
+	}
+
+	planes := [][]float64{make([]float64, width*height), make([]float64, width*height), make([]float64, width*height)}
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			var yy, cb, cr float64
+			switch m := img.(type) {
+			case *image.YCbCr:
+				yi, ci := m.YOffset(b.Min.X+i, b.Min.Y+j), m.COffset(b.Min.X+i, b.Min.Y+j)
+				yy, cb, cr = float64(m.Y[yi]), float64(m.Cb[ci]), float64(m.Cr[ci])
+			default:
+				c := color.RGBAModel.Convert(img.At(b.Min.X+i, b.Min.Y+j)).(color.RGBA)
+				if n, ok := img.(*image.NRGBA); ok {
+					// The fast path of the NRGBA images, premultiplied like the color conversion.
+					k := n.PixOffset(b.Min.X+i, b.Min.Y+j)
+					a := uint32(n.Pix[k+3])
+					c = color.RGBA{
+						R: uint8(uint32(n.Pix[k]) * a / 0xff),
+						G: uint8(uint32(n.Pix[k+1]) * a / 0xff),
+						B: uint8(uint32(n.Pix[k+2]) * a / 0xff),
+					}
+				}
+				r, g, bb := float64(c.R), float64(c.G), float64(c.B)
+				yy = 0.299*r + 0.587*g + 0.114*bb
+				cb = -0.168736*r - 0.331264*g + 0.5*bb + 128
+				cr = 0.5*r - 0.418688*g - 0.081312*bb + 128
+			}
+			planes[0][j*width+i], planes[1][j*width+i], planes[2][j*width+i] = yy, cb, cr
+		}
+	}
+	return planes
+}
+
+// transform computes the quantized DCT coefficients of the component blocks. The component is
+// subsampled by sx and sy, averaging the covered pixels, and the image edges are replicated
+// over the blocks exceeding the image.
+func (c *component) transform(plane []float64, width, height, sx, sy int, quant *[64]int) {
+	var block, tmp [64]float64
+	for by := 0; by < c.bh; by++ {
+		for bx := 0; bx < c.bw; bx++ {
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					var sum float64
+					for dy := 0; dy < sy; dy++ {
+						for dx := 0; dx < sx; dx++ {
+							px := min((bx*8+x)*sx+dx, width-1)
+							py := min((by*8+y)*sy+dy, height-1)
+							sum += plane[py*width+px]
+						}
+					}
+					block[y*8+x] = sum/float64(sx*sy) - 128
+				}
+			}
+			// The separable DCT: the rows, then the columns.
+			for y := 0; y < 8; y++ {
+				for u := 0; u < 8; u++ {
+					var s float64
+					for x := 0; x < 8; x++ {
+						s += dctCos[u][x] * block[y*8+x]
+					}
+					tmp[y*8+u] = s
+				}
+			}
+			coefs := c.coefs[(by*c.bw+bx)*64:]
+			for k := 0; k < 64; k++ {
+				u, v := unzig[k]%8, unzig[k]/8
+				var s float64
+				for y := 0; y < 8; y++ {
+					s += dctCos[v][y] * tmp[y*8+u]
+				}
+				coefs[k] = int32(math.Round(s / float64(quant[k])))
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package jpegenc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// testImage returns a smooth gradient with a few sharp edges, having an odd size
+// so the blocks exceeding the image and the chroma subsampling are exercised.
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8((x + y) % 256), A: 0xff}
+			if (x/16+y/16)%5 == 0 {
+				c = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// psnr returns the peak signal to noise ratio of the decoded image compared to the source.
+func psnr(src, dst image.Image) float64 {
+	var se float64
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, _ := src.At(x, y).RGBA()
+			r2, g2, b2, _ := dst.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				se += d * d
+			}
+		}
+	}
+	mse := se / float64(3*b.Dx()*b.Dy())
+	return 10 * math.Log10(255*255/mse)
+}
+
+func TestEncode_Progressive(t *testing.T) {
+	img := testImage(203, 117)
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, &Options{Quality: 90}); err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{0xff, 0xc2}) {
+		t.Error("the progressive start of frame marker is missing")
+	}
+
+	res, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if res.Bounds() != img.Bounds() {
+		t.Fatalf("expected %v bounds, got %v", img.Bounds(), res.Bounds())
+	}
+	if v := psnr(img, res); v < 30 {
+		t.Errorf("expected the PSNR to exceed 30dB, got %.2f", v)
+	}
+}
+
+func TestEncode_SmallerThanBaseline(t *testing.T) {
+	img := testImage(320, 240)
+	for _, q := range []int{50, 90, 100} {
+		var prog, base bytes.Buffer
+		if err := Encode(&prog, img, &Options{Quality: q}); err != nil {
+			t.Fatalf("encoding failed: %v", err)
+		}
+		if err := jpeg.Encode(&base, img, &jpeg.Options{Quality: q}); err != nil {
+			t.Fatalf("encoding failed: %v", err)
+		}
+		if prog.Len() >= base.Len() {
+			t.Errorf("quality %d: expected the progressive output (%d bytes) to be smaller than the baseline (%d bytes)",
+				q, prog.Len(), base.Len())
+		}
+	}
+}
+
+func TestEncode_Gray(t *testing.T) {
+	src := testImage(64, 50)
+	img := image.NewGray(src.Bounds())
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, src.At(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	res, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if _, ok := res.(*image.Gray); !ok {
+		t.Errorf("expected a gray image, got %T", res)
+	}
+	if v := psnr(img, res); v < 30 {
+		t.Errorf("expected the PSNR to exceed 30dB, got %.2f", v)
+	}
+}
+
+func TestEncode_CMYK(t *testing.T) {
+	src := testImage(64, 50)
+	img := image.NewCMYK(src.Bounds())
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, src.At(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, &Options{Quality: 90}); err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	res, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if _, ok := res.(*image.CMYK); !ok {
+		t.Errorf("expected a CMYK image, got %T", res)
+	}
+	if v := psnr(img, res); v < 30 {
+		t.Errorf("expected the PSNR to exceed 30dB, got %.2f", v)
+	}
+}
+
+func TestEncode_InvalidSize(t *testing.T) {
+	if err := Encode(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, 0, 10)), nil); err == nil {
+		t.Error("expected an error for the empty image")
+	}
+}
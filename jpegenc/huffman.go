@@ -0,0 +1,119 @@
+package jpegenc
+
+import "sort"
+
+// huffTable is a Huffman table built from the symbol frequencies of a scan.
+type huffTable struct {
+	// bits holds the number of codes of each length, from 1 to 16 bits.
+	bits [16]byte
+	// values holds the symbols ordered by code length.
+	values []byte
+	// codes and sizes holds the code and its length in bits of each symbol.
+	codes [256]uint16
+	sizes [256]byte
+}
+
+// newHuffTable builds the optimal Huffman table limited to 16 bit codes for the symbol
+// frequencies, following the procedure described in the Annex K.2 of the JPEG standard.
+func newHuffTable(freq [256]int) *huffTable {
+	var f [257]int
+	copy(f[:], freq[:])
+	// The decoders are rejecting the empty tables.
+	empty := true
+	for _, n := range freq {
+		empty = empty && n == 0
+	}
+	if empty {
+		f[0] = 1
+	}
+	// The reserved symbol guarantees that no code consists only of 1 bits.
+	f[256] = 1
+
+	var size [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+	for {
+		// Find the two least frequent symbols, the higher symbols winning the ties.
+		v1, v2 := -1, -1
+		for i := 0; i < len(f); i++ {
+			if f[i] == 0 {
+				continue
+			}
+			if v1 < 0 || f[i] <= f[v1] {
+				v2, v1 = v1, i
+			} else if v2 < 0 || f[i] <= f[v2] {
+				v2 = i
+			}
+		}
+		if v2 < 0 {
+			break
+		}
+		f[v1] += f[v2]
+		f[v2] = 0
+		for size[v1]++; others[v1] >= 0; size[v1]++ {
+			v1 = others[v1]
+		}
+		others[v1] = v2
+		for size[v2]++; others[v2] >= 0; size[v2]++ {
+			v2 = others[v2]
+		}
+	}
+
+	var bits [33]int
+	for _, s := range size {
+		if s > 0 {
+			bits[s]++
+		}
+	}
+	// Limit the code lengths to 16 bits (Annex K.3).
+	for i := 32; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	// Remove the reserved symbol, which has the longest code.
+	for i := 16; i > 0; i-- {
+		if bits[i] > 0 {
+			bits[i]--
+			break
+		}
+	}
+
+	t := &huffTable{}
+	for i := range t.bits {
+		t.bits[i] = byte(bits[i+1])
+	}
+	symbols := make([]int, 0, 256)
+	for v := 0; v < 256; v++ {
+		if size[v] > 0 {
+			symbols = append(symbols, v)
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return size[symbols[i]] < size[symbols[j]] })
+	for _, v := range symbols {
+		t.values = append(t.values, byte(v))
+	}
+
+	// Assign the canonical codes in the order of the code lengths.
+	var code uint16
+	k := 0
+	for length := 1; length <= 16; length++ {
+		for n := 0; n < int(t.bits[length-1]); n++ {
+			v := t.values[k]
+			t.codes[v], t.sizes[v] = code, byte(length)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return t
+}
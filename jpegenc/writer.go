@@ -0,0 +1,215 @@
+package jpegenc
+
+import (
+	"bufio"
+	"math/bits"
+)
+
+// encoder writes the JPEG markers and the entropy coded segments, keeping the first error.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+	// acc and n hold the pending bits of the entropy coded segment.
+	acc uint64
+	n   uint
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *encoder) writeByte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+// writeMarker writes the marker followed by the segment length, which includes the length bytes.
+func (e *encoder) writeMarker(marker byte, length int) {
+	e.write([]byte{0xff, marker, byte(length >> 8), byte(length)})
+}
+
+// writeAdobe writes the APP14 Adobe marker, which tells the decoders that the four components
+// are the inverted CMYK values, without any color transform.
+func (e *encoder) writeAdobe() {
+	e.writeMarker(0xee, 14)
+	e.write([]byte{'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 0})
+}
+
+// writeDQT writes the quantization tables used by the components.
+func (e *encoder) writeDQT(quant [2][64]int, ncomps int) {
+	tables := min(ncomps, 2)
+	e.writeMarker(0xdb, 2+65*tables)
+	for t := 0; t < tables; t++ {
+		e.writeByte(byte(t))
+		for _, q := range quant[t] {
+			e.writeByte(byte(q))
+		}
+	}
+}
+
+// writeSOF2 writes the start of frame marker of the progressive DCT encoding.
+func (e *encoder) writeSOF2(comps []*component, width, height int) {
+	e.writeMarker(0xc2, 8+3*len(comps))
+	e.write([]byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), byte(len(comps))})
+	for _, c := range comps {
+		e.write([]byte{c.id, byte(c.h<<4 | c.v), byte(c.table)})
+	}
+}
+
+// writeDHT writes the Huffman tables of the class (0 for DC, 1 for AC) selected by the table ids.
+func (e *encoder) writeDHT(class int, tables map[int]*huffTable) {
+	length := 2
+	for _, t := range tables {
+		length += 17 + len(t.values)
+	}
+	e.writeMarker(0xc4, length)
+	for id := 0; id < 2; id++ {
+		t, ok := tables[id]
+		if !ok {
+			continue
+		}
+		e.writeByte(byte(class<<4 | id))
+		e.write(t.bits[:])
+		e.write(t.values)
+	}
+}
+
+// writeScan writes the scan with the Huffman tables optimized for it. The scan is traversed
+// twice: the first time collecting the symbol frequencies, the second time encoding them.
+func (e *encoder) writeScan(comps []*component, s scan, mcusX, mcusY int) {
+	class := 0
+	if s.ss > 0 {
+		class = 1
+	}
+	var freq [2][256]int
+	traverseScan(comps, s, mcusX, mcusY,
+		func(table int, sym byte) { freq[table][sym]++ },
+		func(uint32, uint) {},
+	)
+
+	tables := make(map[int]*huffTable)
+	for _, i := range s.comps {
+		if _, ok := tables[comps[i].table]; !ok {
+			tables[comps[i].table] = newHuffTable(freq[comps[i].table])
+		}
+	}
+	e.writeDHT(class, tables)
+
+	e.writeMarker(0xda, 6+2*len(s.comps))
+	e.writeByte(byte(len(s.comps)))
+	for _, i := range s.comps {
+		// The same table id is used for the DC and the AC tables of the component.
+		e.write([]byte{comps[i].id, byte(comps[i].table<<4 | comps[i].table)})
+	}
+	e.write([]byte{byte(s.ss), byte(s.se), 0})
+
+	traverseScan(comps, s, mcusX, mcusY,
+		func(table int, sym byte) {
+			t := tables[table]
+			e.emit(uint32(t.codes[sym]), uint(t.sizes[sym]))
+		},
+		e.emit,
+	)
+	// Pad the last byte with 1 bits.
+	if e.n > 0 {
+		e.emit(1<<(8-e.n)-1, 8-e.n)
+	}
+}
+
+// emit writes the lowest n bits of the value, stuffing a zero byte after each 0xff byte.
+func (e *encoder) emit(v uint32, n uint) {
+	e.acc = e.acc<<n | uint64(v)&(1<<n-1)
+	e.n += n
+	for e.n >= 8 {
+		b := byte(e.acc >> (e.n - 8))
+		e.writeByte(b)
+		if b == 0xff {
+			e.writeByte(0)
+		}
+		e.n -= 8
+	}
+	e.acc &= 1<<e.n - 1
+}
+
+// traverseScan walks the coefficients of the scan, reporting the Huffman symbols of the
+// component tables and the additional bits following them.
+func traverseScan(comps []*component, s scan, mcusX, mcusY int, symbol func(table int, sym byte), raw func(v uint32, n uint)) {
+	if s.ss == 0 {
+		// The DC scan, interleaving the components by MCU.
+		pred := make([]int32, len(s.comps))
+		for my := 0; my < mcusY; my++ {
+			for mx := 0; mx < mcusX; mx++ {
+				for k, i := range s.comps {
+					c := comps[i]
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							dc := c.coefs[((my*c.v+v)*c.bw+mx*c.h+h)*64]
+							size, bits := magnitude(dc - pred[k])
+							pred[k] = dc
+							symbol(c.table, byte(size))
+							if size > 0 {
+								raw(bits, size)
+							}
+						}
+					}
+				}
+			}
+		}
+		return
+	}
+
+	// The AC scans are not interleaved, covering only the blocks of the component,
+	// and the runs of blocks ending with zero coefficients are coded with EOBRUN.
+	c := comps[s.comps[0]]
+	eobrun := 0
+	flush := func() {
+		if eobrun == 0 {
+			return
+		}
+		n := uint(bits.Len(uint(eobrun))) - 1
+		symbol(c.table, byte(n<<4))
+		if n > 0 {
+			raw(uint32(eobrun), n)
+		}
+		eobrun = 0
+	}
+	for by := 0; by < c.ch; by++ {
+		for bx := 0; bx < c.cw; bx++ {
+			coefs := c.coefs[(by*c.bw+bx)*64:]
+			run := 0
+			for k := s.ss; k <= s.se; k++ {
+				if coefs[k] == 0 {
+					run++
+					continue
+				}
+				flush()
+				for ; run > 15; run -= 16 {
+					symbol(c.table, 0xf0)
+				}
+				size, bits := magnitude(coefs[k])
+				symbol(c.table, byte(run<<4)|byte(size))
+				raw(bits, size)
+				run = 0
+			}
+			if run > 0 {
+				if eobrun++; eobrun == 0x7fff {
+					flush()
+				}
+			}
+		}
+	}
+	flush()
+}
+
+// magnitude returns the size category of the value and its additional bits,
+// the negative values being coded as the ones' complement of their magnitude.
+func magnitude(v int32) (uint, uint32) {
+	if v < 0 {
+		size := uint(bits.Len32(uint32(-v)))
+		return size, uint32(v - 1)
+	}
+	return uint(bits.Len32(uint32(v))), uint32(v)
+}
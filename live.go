@@ -0,0 +1,161 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// defaultSeamBudget is the maximum number of seams removed from a live frame by default.
+	defaultSeamBudget = 32
+	// defaultLiveBandwidth is the default half width of the band around the seam of the
+	// previous frame, in which the seam of the current frame is searched.
+	defaultLiveBandwidth = 8
+)
+
+// LiveCarver carves a stream of frames (like the frames captured from a webcam or decoded from
+// a video) to a fixed size in soft real time. The number of seams removed from a frame is limited
+// by the seam budget: the part of the size reduction exceeding the budget is done by scaling.
+// The seams of the previous frame are reused as the centers of the narrow band in which the seams
+// of the current frame are searched, so the seams are following the slowly changing content
+// instead of jumping around (which would make the frames flicker), and only the energy of the
+// band pixels has to be computed. With BackgroundFrames, the static background is detected from
+// the statistics of the recent frames and the seams are biased toward it, which keeps the moving
+// subjects intact across the frames. The carver doesn't rely on the package level state, so the
+// carvers of different streams can be used concurrently, but a carver is not safe for concurrent use.
+type LiveCarver struct {
+	// SeamBudget is the maximum number of seams removed from a frame. It defaults to 32.
+	SeamBudget int
+	// Bandwidth is the half width of the band around the seam of the previous frame,
+	// in which the seam of the current frame is searched. It defaults to 8 pixels.
+	Bandwidth int
+	// BackgroundFrames is the number of the recent frames buffered for detecting the static
+	// background, from the temporal median and the variance of the pixels. The subjects covering
+	// a pixel in more than half of the buffered frames are merged into the background, so the more
+	// frames, the slower moving subjects are detected. If zero, the background is not detected.
+	BackgroundFrames int
+
+	width, height int
+	opts          Processor
+	// seams holds the columns of the seams of the previous frame, indexed by the row.
+	seams [][]int
+	// size is the size of the scaled frame the seams were found on.
+	size image.Point
+	// background holds the statistics of the recent frames.
+	background *backgroundModel
+}
+
+// NewLiveCarver returns a carver resizing the frames to the provided size. The energy
+// of the pixels is computed using the SobelThreshold of the processor options.
+func NewLiveCarver(width, height int, opts Processor) *LiveCarver {
+	return &LiveCarver{width: width, height: height, opts: opts}
+}
+
+// Reset drops the seams of the previous frame, so the seams of the next frame are searched
+// over the whole frame, and the buffered frames used for detecting the background. It should be called on scene changes, like the cuts of a video.
+func (lc *LiveCarver) Reset() {
+	lc.seams = nil
+	if lc.background != nil {
+		lc.background.reset()
+	}
+}
+
+// Carve resizes the frame to the size of the carver.
+func (lc *LiveCarver) Carve(frame image.Image) (*image.NRGBA, error) {
+	if lc.width < minImageSize || lc.height < minImageSize {
+		return nil, errors.New("the size of the carved frames is too small")
+	}
+	budget := lc.SeamBudget
+	if budget <= 0 {
+		budget = defaultSeamBudget
+	}
+
+	// Scale the frame to cover the requested size, then squash the excess exceeding the seam budget.
+	b := frame.Bounds()
+	scale := math.Max(float64(lc.width)/float64(b.Dx()), float64(lc.height)/float64(b.Dy()))
+	sw := min(max(int(math.Round(float64(b.Dx())*scale)), lc.width), lc.width+budget)
+	sh := min(max(int(math.Round(float64(b.Dy())*scale)), lc.height), lc.height+budget)
+
+	img := imaging.Resize(frame, sw, sh, imaging.Linear)
+	// The horizontal seams are removed from the rotated frame.
+	rotated := sh > lc.height
+	if rotated {
+		img = rotate(img, false)
+	}
+	if size := img.Bounds().Size(); size != lc.size {
+		lc.seams, lc.size = nil, size
+	}
+	// The background statistics are collected on the scaled frames, since the seams are searched on them.
+	lc.opts.biasMap = nil
+	if lc.BackgroundFrames > 0 {
+		if lc.background == nil {
+			lc.background = newBackgroundModel(lc.BackgroundFrames)
+		}
+		lc.background.add(img)
+		lc.opts.biasMap = lc.background.biasMap()
+	}
+
+	seams := max(sw-lc.width, sh-lc.height)
+	for i := 0; i < seams; i++ {
+		img = lc.removeSeam(img, i)
+	}
+	if rotated {
+		img = rotate(img, true)
+	}
+	return img, nil
+}
+
+// removeSeam removes the i-th seam of the frame, searching it around the same seam of the previous frame.
+func (lc *LiveCarver) removeSeam(img *image.NRGBA, i int) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	radius := lc.Bandwidth
+	if radius <= 0 {
+		radius = defaultLiveBandwidth
+	}
+
+	centers := make([]int, height)
+	if i < len(lc.seams) {
+		for y, x := range lc.seams[i] {
+			centers[y] = min(x, width-1)
+		}
+	} else {
+		// The band is covering the whole frame for the first frame.
+		for y := range centers {
+			centers[y] = width / 2
+		}
+		radius = width
+	}
+
+	seam := (&Carver{}).refineSeam(&lc.opts, img, centers, radius)
+	for _, s := range seam {
+		centers[s.Y] = s.X
+	}
+	if i < len(lc.seams) {
+		lc.seams[i] = centers
+	} else {
+		lc.seams = append(lc.seams, centers)
+	}
+
+	// The bias map is carried along with the frame.
+	if lc.opts.biasMap != nil {
+		lc.opts.biasMap = removeColumns(lc.opts.biasMap, centers)
+	}
+	return removeColumns(img, centers)
+}
+
+// removeColumns removes the pixels of the seam, provided as the columns indexed by the row,
+// by shifting the rest of the rows to the left.
+func removeColumns(img *image.NRGBA, cols []int) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width-1, height))
+	for y, x := range cols {
+		src := img.Pix[y*img.Stride : y*img.Stride+width*4]
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+(width-1)*4]
+		copy(row, src[:x*4])
+		copy(row[x*4:], src[(x+1)*4:])
+	}
+	return dst
+}
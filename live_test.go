@@ -0,0 +1,69 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/esimov/caire/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// liveFrame returns a frame with a bright square at the provided position on a dark background.
+func liveFrame(width, height, pos int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 20, G: 20, B: 20, A: 255}
+			if x >= pos && x < pos+20 && y >= 30 && y < 60 {
+				c = color.NRGBA{R: 250, G: 200, B: 40, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestLiveCarver_ShouldCarveTheFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	lc := NewLiveCarver(100, 90, Processor{SobelThreshold: 2})
+	lc.SeamBudget = 16
+	for pos := 40; pos < 60; pos += 2 {
+		res, err := lc.Carve(liveFrame(160, 90, pos))
+		assert.NoError(err)
+		assert.Equal(image.Rect(0, 0, 100, 90), res.Bounds())
+	}
+	// The excess exceeding the budget is scaled, so only the budget is carved.
+	assert.Len(lc.seams, 16)
+
+	// The horizontal seams are removed from the frames being too tall.
+	res, err := lc.Carve(liveFrame(90, 160, 30))
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 100, 90), res.Bounds())
+}
+
+func TestLiveCarver_ShouldReuseTheSeams(t *testing.T) {
+	assert := assert.New(t)
+
+	lc := NewLiveCarver(100, 90, Processor{SobelThreshold: 2})
+	lc.Bandwidth = 3
+	_, err := lc.Carve(liveFrame(120, 90, 50))
+	assert.NoError(err)
+	prev := make([][]int, len(lc.seams))
+	for i, s := range lc.seams {
+		prev[i] = append([]int(nil), s...)
+	}
+
+	// The square is moving slowly, so the seams are staying inside the band of the previous ones.
+	_, err = lc.Carve(liveFrame(120, 90, 52))
+	assert.NoError(err)
+	for i, s := range lc.seams {
+		for y, x := range s {
+			assert.LessOrEqual(utils.Abs(x-prev[i][y]), lc.Bandwidth)
+		}
+	}
+
+	lc.Reset()
+	assert.Empty(lc.seams)
+}
@@ -0,0 +1,58 @@
+package caire
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The prefixes of the Windows extended-length paths, which are not limited to MAX_PATH (260)
+// characters. See https://learn.microsoft.com/en-us/windows/win32/fileio/naming-a-file
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+	devicePrefix      = `\\.\`
+)
+
+// longPath returns the path to be used with the file system calls. On Windows the path is made
+// absolute and converted to the extended-length form, so the deeply nested files (ex. the synced
+// cloud storage folders) can be accessed and the directory walks are returning the extended-length
+// paths too. On the other systems the path is returned unaltered.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" || path == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedPath(abs)
+}
+
+// extendedPath converts an absolute Windows path to the extended-length form: the drive paths
+// are prefixed with \\?\, while the UNC paths (\\server\share) become \\?\UNC\server\share.
+// The paths already in the extended-length form, the device paths and the relative paths are
+// returned unaltered. The path should be cleaned, since the prefix disables the normalization.
+func extendedPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, extendedPrefix), strings.HasPrefix(path, devicePrefix):
+		return path
+	case strings.HasPrefix(path, `\\`):
+		return extendedUNCPrefix + path[2:]
+	case len(path) >= 3 && path[1] == ':' && path[2] == '\\':
+		return extendedPrefix + path
+	}
+	return path
+}
+
+// trimExtendedPath converts back an extended-length path to the regular form, which is shown to
+// the users and passed to the callbacks. It's the inverse of extendedPath.
+func trimExtendedPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, extendedUNCPrefix):
+		return `\\` + path[len(extendedUNCPrefix):]
+	case strings.HasPrefix(path, extendedPrefix):
+		return path[len(extendedPrefix):]
+	}
+	return path
+}
@@ -0,0 +1,65 @@
+package caire
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongPath_ShouldUseTheExtendedLengthForm(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct{ path, extended string }{
+		{`C:\Users\me\OneDrive\photo.jpg`, `\\?\C:\Users\me\OneDrive\photo.jpg`},
+		{`\\server\share\photos\photo.jpg`, `\\?\UNC\server\share\photos\photo.jpg`},
+		{`\\?\C:\photo.jpg`, `\\?\C:\photo.jpg`},
+		{`\\?\UNC\server\share\photo.jpg`, `\\?\UNC\server\share\photo.jpg`},
+		{`\\.\pipe\caire`, `\\.\pipe\caire`},
+		{`photos\photo.jpg`, `photos\photo.jpg`},
+		{`C:photo.jpg`, `C:photo.jpg`},
+	} {
+		assert.Equal(tc.extended, extendedPath(tc.path))
+	}
+	assert.Equal(`C:\Users\fotó.jpg`, trimExtendedPath(extendedPath(`C:\Users\fotó.jpg`)))
+	assert.Equal(`\\server\share\photo.jpg`, trimExtendedPath(extendedPath(`\\server\share\photo.jpg`)))
+	assert.Equal("photos/photo.jpg", trimExtendedPath("photos/photo.jpg"))
+
+	if runtime.GOOS != "windows" {
+		assert.Equal("photos/photo.jpg", longPath("photos/photo.jpg"))
+	}
+}
+
+func TestLongPath_ShouldWalkTheNestedFolders(t *testing.T) {
+	assert := assert.New(t)
+
+	// The nested folders and the file names are exceeding 260 characters in total.
+	dir := t.TempDir()
+	nested := dir
+	for i := 0; i < 6; i++ {
+		nested = filepath.Join(nested, "folder-with-a-rather-long-name-ünïcödé-"+string(rune('a'+i)))
+	}
+	assert.NoError(os.MkdirAll(longPath(nested), 0755))
+	files := []string{
+		filepath.Join(nested, "kép-日本.JPG"),
+		filepath.Join(nested, "image.png"),
+		filepath.Join(nested, "notes.txt"),
+	}
+	for _, f := range files {
+		assert.NoError(os.WriteFile(longPath(f), []byte("data"), 0644))
+	}
+	assert.Greater(len(files[0]), 260)
+
+	done := make(chan interface{})
+	defer close(done)
+	paths, errc := walkDir(done, longPath(dir), []string{".jpg", ".png"})
+
+	var found []string
+	for path := range paths {
+		found = append(found, path)
+	}
+	assert.NoError(<-errc)
+	assert.ElementsMatch(files[:2], found)
+}
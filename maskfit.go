@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// The supported values of the MaskFit option, defining how the masks having
+// a different size than the image are handled.
+const (
+	// MaskFitScale resizes the masks to the image size with nearest-neighbor interpolation,
+	// which keeps the binary masks binary.
+	MaskFitScale = "scale"
+	// MaskFitStrict rejects the masks having a different size with a *MaskSizeError.
+	MaskFitStrict = "strict"
+)
+
+// MaskSizeError is returned when the size of a mask doesn't match the image size.
+type MaskSizeError struct {
+	// Mask is the name of the mask option: "mask", "rmask" or "weight mask".
+	Mask      string
+	MaskSize  image.Point
+	ImageSize image.Point
+}
+
+func (e *MaskSizeError) Error() string {
+	return fmt.Sprintf("the %s size %v should match the image size %v", e.Mask, e.MaskSize, e.ImageSize)
+}
+
+// validateMaskFit checks the value of the MaskFit option.
+func (p *Processor) validateMaskFit() error {
+	switch p.MaskFit {
+	case "", MaskFitScale, MaskFitStrict:
+		return nil
+	}
+	return fmt.Errorf("unsupported mask fit: %q, it should be %q or %q", p.MaskFit, MaskFitScale, MaskFitStrict)
+}
+
+// fitMask matches the size of the mask with the image size according to the MaskFit option.
+// By default the binary masks are applied as they are, anchored to the top-left corner of the
+// image, for backward compatibility.
+func (p *Processor) fitMask(name string, mask *image.NRGBA, size image.Point) (*image.NRGBA, error) {
+	if mask == nil || mask.Bounds().Size() == size {
+		return mask, nil
+	}
+	switch p.MaskFit {
+	case MaskFitScale:
+		return imaging.Resize(mask, size.X, size.Y, imaging.NearestNeighbor), nil
+	case MaskFitStrict:
+		return nil, &MaskSizeError{Mask: name, MaskSize: mask.Bounds().Size(), ImageSize: size}
+	}
+	return mask, nil
+}
@@ -0,0 +1,94 @@
+package caire
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskFit_ShouldMatchTheImageSize(t *testing.T) {
+	assert := assert.New(t)
+
+	mask := image.NewNRGBA(image.Rect(0, 0, 10, 5))
+	draw.Draw(mask, image.Rect(0, 0, 5, 5), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	size := image.Pt(40, 20)
+
+	p := &Processor{MaskFit: MaskFitScale}
+	res, err := p.fitMask("mask", mask, size)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 40, 20), res.Bounds())
+	// The nearest-neighbor interpolation keeps the mask binary.
+	assert.Equal(uint8(0xff), res.NRGBAAt(19, 10).R)
+	assert.Equal(uint8(0), res.NRGBAAt(20, 10).R)
+
+	p.MaskFit = MaskFitStrict
+	_, err = p.fitMask("mask", mask, size)
+	var sizeErr *MaskSizeError
+	assert.True(errors.As(err, &sizeErr))
+	assert.Equal(image.Pt(10, 5), sizeErr.MaskSize)
+	assert.Equal(size, sizeErr.ImageSize)
+
+	// The masks of the right size are left untouched in every mode.
+	res, err = p.fitMask("mask", mask, image.Pt(10, 5))
+	assert.NoError(err)
+	assert.Same(mask, res)
+
+	p.MaskFit = ""
+	res, err = p.fitMask("mask", mask, size)
+	assert.NoError(err)
+	assert.Same(mask, res)
+
+	p.MaskFit = "stretch"
+	assert.Error(p.validateMaskFit())
+}
+
+func TestMaskFit_ShouldRescaleTheMaskFile(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 80, G: 120, B: 160, A: 255}}, image.Point{}, draw.Src)
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	// The mask has half of the image resolution.
+	mask := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(mask, mask.Bounds(), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	draw.Draw(mask, image.Rect(0, 0, 10, 10), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	path := filepath.Join(t.TempDir(), "mask.png")
+	f, err := os.Create(path)
+	assert.NoError(err)
+	assert.NoError(png.Encode(f, mask))
+	assert.NoError(f.Close())
+
+	p := &Processor{SobelThreshold: 2, NewWidth: 30, MaskPath: path, MaskFit: MaskFitScale, OutputFormat: FormatPNG}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	assert.Equal(image.Rect(0, 0, 30, 20), p.Mask.Bounds())
+	// The protected left half of the image is kept entirely.
+	for x := 0; x < 20; x++ {
+		assert.Equal(uint8(0xff), p.Mask.NRGBAAt(x, 10).R)
+	}
+
+	p = &Processor{SobelThreshold: 2, NewWidth: 30, MaskPath: path, MaskFit: MaskFitStrict}
+	err = p.Process(bytes.NewReader(src.Bytes()), &out)
+	var sizeErr *MaskSizeError
+	assert.True(errors.As(err, &sizeErr))
+	assert.Equal("mask", sizeErr.Mask)
+
+	// The weight mask provided by the library users is rescaled too.
+	weight := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	p = &Processor{SobelThreshold: 2, NewWidth: 30, WeightMask: weight}
+	_, err = p.Resize(img)
+	assert.True(errors.As(err, &sizeErr))
+	p.MaskFit = MaskFitScale
+	_, err = p.Resize(img)
+	assert.NoError(err)
+}
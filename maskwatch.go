@@ -0,0 +1,104 @@
+package caire
+
+import (
+	"image"
+	"os"
+	"time"
+)
+
+// maskPollInterval is the minimum time elapsed between two checks of the mask files.
+const maskPollInterval = 250 * time.Millisecond
+
+// maskWatcher detects the changes of a mask file by polling its modification time and size.
+type maskWatcher struct {
+	path    string
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// newMaskWatcher returns a watcher for the mask file. It returns nil for an empty path.
+func newMaskWatcher(path string) *maskWatcher {
+	if path == "" {
+		return nil
+	}
+	w := &maskWatcher{path: path}
+	if fi, err := os.Stat(path); err == nil {
+		w.modTime, w.size = fi.ModTime(), fi.Size()
+	}
+	return w
+}
+
+// changed reports whether the mask file has been modified since the last check.
+// The missing files are ignored, since the editors are often replacing the file on save.
+func (w *maskWatcher) changed() bool {
+	if w == nil || time.Since(w.checked) < maskPollInterval {
+		return false
+	}
+	w.checked = time.Now()
+
+	fi, err := os.Stat(w.path)
+	if err != nil || (fi.ModTime().Equal(w.modTime) && fi.Size() == w.size) {
+		return false
+	}
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	return true
+}
+
+// reloadMasks replaces the masks with their updated version, in case the mask files have
+// been modified while the image is being carved. The new masks are defined in the coordinates
+// of the source image, so they are remapped to the current image using the coordinate tracker.
+func (p *Processor) reloadMasks(c *Carver) {
+	if p.tracker == nil {
+		return
+	}
+	if p.maskWatch.changed() {
+		if mask, err := p.loadWatchedMask("mask", p.MaskPath, p.ProtectColor); err == nil {
+			p.Mask = p.remapMask(c, mask)
+			p.GuiDebug = p.Mask
+		} else {
+			// The file might be partially written, so try again on the next check.
+			p.maskWatch.modTime = time.Time{}
+		}
+	}
+	if p.rmaskWatch.changed() {
+		if rmask, err := p.loadWatchedMask("rmask", p.RMaskPath, p.RemoveColor); err == nil {
+			p.RMask = p.remapMask(c, rmask)
+			p.GuiDebug = p.RMask
+		} else {
+			p.rmaskWatch.modTime = time.Time{}
+		}
+	}
+}
+
+// loadWatchedMask reloads the mask file, fitting it to the size of the source image.
+func (p *Processor) loadWatchedMask(name, path, key string) (*image.NRGBA, error) {
+	mask, err := p.loadMask(path, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.fitMask(name, mask, image.Pt(p.tracker.origW, p.tracker.origH))
+}
+
+// remapMask transfers the mask from the source image coordinates to the image being carved.
+func (p *Processor) remapMask(c *Carver, mask *image.NRGBA) *image.NRGBA {
+	t := p.tracker
+	b := mask.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, t.width, t.height))
+
+	for y := 0; y < t.height; y++ {
+		for x := 0; x < t.width; x++ {
+			pt := t.at(x, y)
+			pt.X = min(max(pt.X, b.Min.X), b.Max.X-1)
+			pt.Y = min(max(pt.Y, b.Min.Y), b.Max.Y-1)
+
+			i, j := dst.PixOffset(x, y), mask.PixOffset(pt.X, pt.Y)
+			copy(dst.Pix[i:i+4], mask.Pix[j:j+4])
+		}
+	}
+	// The tracker coordinates are in the orientation of the source image.
+	if p.vRes {
+		dst = c.RotateImage90(dst)
+	}
+	return dst
+}
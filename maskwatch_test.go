@@ -0,0 +1,76 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskWatch_ShouldReloadTheModifiedMask(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 20))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 80, G: 120, B: 160, A: 255}}, image.Point{}, draw.Src)
+	writeMask := func(path string, protected image.Rectangle) {
+		mask := image.NewNRGBA(img.Bounds())
+		for x := 0; x < 30; x++ {
+			for y := 0; y < 20; y++ {
+				c := color.NRGBA{A: 255}
+				if (image.Point{X: x, Y: y}).In(protected) {
+					c = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+				mask.Set(x, y, c)
+			}
+		}
+		f, err := os.Create(path)
+		assert.NoError(err)
+		assert.NoError(png.Encode(f, mask))
+		assert.NoError(f.Close())
+	}
+
+	path := filepath.Join(t.TempDir(), "mask.png")
+	writeMask(path, image.Rectangle{})
+
+	proc := &Processor{SobelThreshold: 4, NewWidth: 24, MaskPath: path}
+	var err error
+	proc.Mask, err = proc.loadMask(path, "")
+	assert.NoError(err)
+	proc.maskWatch = newMaskWatcher(path)
+
+	// Protect the left half of the image once the first seam has been removed.
+	var steps int
+	proc.onStep = func(*image.NRGBA, SeamInfo) error {
+		if steps++; steps == 1 {
+			writeMask(path, image.Rect(0, 0, 15, 20))
+			future := time.Now().Add(time.Hour)
+			assert.NoError(os.Chtimes(path, future, future))
+			proc.maskWatch.checked = time.Time{}
+		}
+		return nil
+	}
+	_, err = proc.Resize(img)
+	assert.NoError(err)
+
+	assert.Len(proc.tracker.removed, 6)
+
+	// The carved mask should correspond to the updated mask file.
+	b := proc.Mask.Bounds()
+	assert.Equal(image.Rect(0, 0, 24, 20), b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			protected := proc.tracker.at(x, y).X < 15
+			assert.Equal(protected, proc.Mask.NRGBAAt(x, y).R == 0xff, "%d,%d", x, y)
+		}
+	}
+
+	var w *maskWatcher
+	assert.False(w.changed())
+	assert.Nil(newMaskWatcher(""))
+}
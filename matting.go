@@ -0,0 +1,100 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/esimov/caire/filters"
+)
+
+// mattingEpsilon is the regularization of the guided filter, relative to the squared range of
+// the guide intensities. The smaller values are following the image edges more closely.
+const mattingEpsilon = 0.01
+
+// featherWeightMask refines the edges of the weight mask using the image as a guide, similarly to
+// the alpha matting: inside a window the filtered mask is a linear function of the image intensity,
+// so the protection falls off smoothly over the flat areas, while it follows the edges of the image.
+// Without feathering, the sharp boundary of a hand painted mask attracts the seams right at the mask
+// edge, where the protection drops, which leaves a visible discontinuity in the carved image.
+func featherWeightMask(img, mask *image.NRGBA, radius int) *image.NRGBA {
+	if mask == nil || radius <= 0 || mask.Bounds().Size() != img.Bounds().Size() {
+		return mask
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	guide := make([]float64, width*height)
+	weight := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y)
+			guide[y*width+x] = float64(filters.Luma(c.R, c.G, c.B)) / 0xff
+			m := mask.NRGBAAt(mask.Bounds().Min.X+x, mask.Bounds().Min.Y+y)
+			weight[y*width+x] = float64(color.GrayModel.Convert(m).(color.Gray).Y) / 0xff
+		}
+	}
+	res := guidedFilter(guide, weight, width, height, radius, mattingEpsilon)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, v := range res {
+		g := uint8(min(max(v*0xff+0.5, 0), 0xff))
+		dst.Pix[i*4], dst.Pix[i*4+1], dst.Pix[i*4+2], dst.Pix[i*4+3] = g, g, g, 0xff
+	}
+	return dst
+}
+
+// guidedFilter filters the input with the guided filter of He et al. using the provided guide,
+// both of them being stored row by row. The filter is computed with box filters, so its cost
+// doesn't depend on the radius of the window.
+func guidedFilter(guide, input []float64, width, height, radius int, eps float64) []float64 {
+	n := len(guide)
+	gg, gi := make([]float64, n), make([]float64, n)
+	for k := range guide {
+		gg[k] = guide[k] * guide[k]
+		gi[k] = guide[k] * input[k]
+	}
+	meanG := boxFilter(guide, width, height, radius)
+	meanI := boxFilter(input, width, height, radius)
+	corrG := boxFilter(gg, width, height, radius)
+	corrGI := boxFilter(gi, width, height, radius)
+
+	// The coefficients of the linear model a*guide+b in each window.
+	a, b := make([]float64, n), make([]float64, n)
+	for k := range a {
+		variance := corrG[k] - meanG[k]*meanG[k]
+		covariance := corrGI[k] - meanG[k]*meanI[k]
+		a[k] = covariance / (variance + eps)
+		b[k] = meanI[k] - a[k]*meanG[k]
+	}
+	meanA := boxFilter(a, width, height, radius)
+	meanB := boxFilter(b, width, height, radius)
+
+	res := make([]float64, n)
+	for k := range res {
+		res[k] = meanA[k]*guide[k] + meanB[k]
+	}
+	return res
+}
+
+// boxFilter returns the mean of the values inside the square window of the provided radius
+// around each element, computed with a summed area table. The window is clipped at the borders.
+func boxFilter(src []float64, width, height, radius int) []float64 {
+	stride := width + 1
+	sat := make([]float64, stride*(height+1))
+	for y := 0; y < height; y++ {
+		var row float64
+		for x := 0; x < width; x++ {
+			row += src[y*width+x]
+			sat[(y+1)*stride+x+1] = sat[y*stride+x+1] + row
+		}
+	}
+
+	dst := make([]float64, len(src))
+	for y := 0; y < height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, width)
+			sum := sat[y1*stride+x1] - sat[y0*stride+x1] - sat[y1*stride+x0] + sat[y0*stride+x0]
+			dst[y*width+x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,65 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stepMask returns a weight mask protecting the columns left to the edge.
+func stepMask(rect image.Rectangle, edge int) *image.NRGBA {
+	mask := image.NewNRGBA(rect)
+	draw.Draw(mask, rect, &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	draw.Draw(mask, image.Rect(0, 0, edge, rect.Dy()), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	return mask
+}
+
+func TestMatting_ShouldComputeTheBoxFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	src := []float64{1, 2, 3, 4, 5, 6}
+	assert.Equal([]float64{3, 3.5, 4, 3, 3.5, 4}, boxFilter(src, 3, 2, 1))
+	assert.Equal(src, boxFilter(src, 3, 2, 0))
+}
+
+func TestMatting_ShouldFeatherTheFlatAreas(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 10))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 90, G: 140, B: 60, A: 255}}, image.Point{}, draw.Src)
+
+	res := featherWeightMask(img, stepMask(img.Bounds(), 20), 4)
+	assert.Equal(uint8(255), res.NRGBAAt(5, 5).R)
+	assert.Equal(uint8(0), res.NRGBAAt(35, 5).R)
+
+	// The protection is decreasing gradually around the edge of the mask.
+	for x := 16; x < 24; x++ {
+		assert.Greater(res.NRGBAAt(x, 5).R, res.NRGBAAt(x+1, 5).R)
+	}
+	assert.Equal(stepMask(img.Bounds(), 20), featherWeightMask(img, stepMask(img.Bounds(), 20), 0))
+}
+
+func TestMatting_ShouldFollowTheImageEdges(t *testing.T) {
+	assert := assert.New(t)
+
+	// The subject extends two pixels beyond the edge of the painted mask.
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 10))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 230, G: 230, B: 230, A: 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 22, 10), &image.Uniform{color.NRGBA{R: 30, G: 30, B: 30, A: 255}}, image.Point{}, draw.Src)
+
+	res := featherWeightMask(img, stepMask(img.Bounds(), 20), 4)
+	assert.Greater(res.NRGBAAt(21, 5).R, uint8(96))
+	assert.Less(res.NRGBAAt(22, 5).R, uint8(16))
+
+	// The protection drops at the edge of the subject, instead of the edge of the mask.
+	drop, at := 0, 0
+	for x := 10; x < 30; x++ {
+		if d := int(res.NRGBAAt(x, 5).R) - int(res.NRGBAAt(x+1, 5).R); d > drop {
+			drop, at = d, x+1
+		}
+	}
+	assert.Equal(22, at)
+}
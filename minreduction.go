@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// belowMinReduction reports whether the requested size differs from the image size by less than
+// the MinReductionPercent in both axes, and returns the requested size. The carving is skipped in
+// this case, since the seams would hardly be distinguishable from the plain scaling anyway.
+// The options whose outcome depends on the carving itself (the object removal, the grid and the
+// relative sizes) are always carved, as well as the Gif animations and the preview. The outputs
+// describing the removed seams (like the seams SVG or the displacement map) require the carving too.
+func (p *Processor) belowMinReduction(img *image.NRGBA) (int, int, bool) {
+	if p.MinReductionPercent <= 0 || p.Percentage || p.Square || p.AutoAxis || p.Grid != nil ||
+		p.RMask != nil || p.Preview || isGif {
+		return 0, 0, false
+	}
+	if p.SeamsSVGPath != "" || p.RemovedPath != "" || p.EnergyCSVPath != "" || p.GhostPath != "" ||
+		p.HeatmapPath != "" || p.DisplaceMap != "" || p.TrackCoords {
+		return 0, 0, false
+	}
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	width, height := p.NewWidth, p.NewHeight
+	if width == 0 {
+		width = dx
+	}
+	if height == 0 {
+		height = dy
+	}
+	if width <= 0 || height <= 0 || dx == 0 || dy == 0 {
+		return 0, 0, false
+	}
+
+	change := func(size, newSize int) float64 {
+		return math.Abs(float64(newSize-size)) / float64(size) * 100
+	}
+	if change(dx, width) >= p.MinReductionPercent || change(dy, height) >= p.MinReductionPercent {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// scaleBelowMinReduction scales the image to the requested size, which is returned unaltered if it
+// already has the requested size. The scaling doesn't remove any seam, so there is nothing to track
+// for the reloaded masks.
+func (p *Processor) scaleBelowMinReduction(img *image.NRGBA, width, height int) image.Image {
+	p.tracker = nil
+	if img.Bounds().Dx() == width && img.Bounds().Dy() == height {
+		return img
+	}
+	return imaging.Resize(img, width, height, imaging.Lanczos)
+}
@@ -0,0 +1,89 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinReduction_ShouldScaleTheSmallReductions(t *testing.T) {
+	assert := assert.New(t)
+
+	img := subjectsImage(200, 100, 10, 150)
+	p := &Processor{NewWidth: 192, SobelThreshold: 2, MinReductionPercent: 5}
+
+	res, err := p.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 192, 100), res.Bounds())
+	assert.Equal(imaging.Resize(img, 192, 100, imaging.Lanczos).Pix, p.imgToNRGBA(res).Pix)
+
+	// The reduction exceeding the threshold is carved.
+	p = &Processor{NewWidth: 180, SobelThreshold: 2, MinReductionPercent: 5}
+	res, err = p.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 180, 100), res.Bounds())
+	assert.NotEqual(imaging.Resize(img, 180, 100, imaging.Lanczos).Pix, p.imgToNRGBA(res).Pix)
+}
+
+func TestMinReduction_ShouldConsiderBothAxes(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for _, tc := range []struct {
+		width, height int
+		percent       float64
+		skip          bool
+	}{
+		{192, 0, 5, true},
+		{0, 96, 5, true},
+		{192, 96, 5, true},
+		{192, 90, 5, false},
+		{190, 0, 5, false},
+		{208, 0, 5, true},
+		{192, 0, 0, false},
+		{200, 100, 1, true},
+	} {
+		p := &Processor{NewWidth: tc.width, NewHeight: tc.height, MinReductionPercent: tc.percent}
+		_, _, ok := p.belowMinReduction(img)
+		assert.Equal(tc.skip, ok, "%dx%d within %v%%", tc.width, tc.height, tc.percent)
+	}
+
+	// The options depending on the carving are always carved.
+	p := &Processor{NewWidth: 192, MinReductionPercent: 5, Percentage: true}
+	_, _, ok := p.belowMinReduction(img)
+	assert.False(ok)
+	p = &Processor{NewWidth: 192, MinReductionPercent: 5, RMask: image.NewNRGBA(img.Bounds())}
+	_, _, ok = p.belowMinReduction(img)
+	assert.False(ok)
+}
+
+func TestMinReduction_ShouldCarveForTheSeamOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	img := subjectsImage(200, 100, 10, 150)
+	var in, out bytes.Buffer
+	assert.NoError(png.Encode(&in, img))
+
+	// The displacement map is built from the tracked seams, so the small reduction is carved.
+	path := filepath.Join(t.TempDir(), "map.png")
+	p := &Processor{NewWidth: 192, SobelThreshold: 2, MinReductionPercent: 5, OutputFormat: FormatPNG, DisplaceMap: path}
+	_, _, ok := p.belowMinReduction(img)
+	assert.False(ok)
+	assert.NoError(p.Process(&in, &out))
+	assert.FileExists(path)
+
+	for _, p := range []*Processor{
+		{NewWidth: 192, MinReductionPercent: 5, SeamsSVGPath: "seams.svg"},
+		{NewWidth: 192, MinReductionPercent: 5, GhostPath: "ghost.png"},
+		{NewWidth: 192, MinReductionPercent: 5, HeatmapPath: "heatmap.png"},
+		{NewWidth: 192, MinReductionPercent: 5, TrackCoords: true},
+	} {
+		_, _, ok := p.belowMinReduction(img)
+		assert.False(ok)
+	}
+}
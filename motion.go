@@ -0,0 +1,62 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/esimov/caire/filters"
+)
+
+const (
+	// motionNoise is the luminance difference below which the pixels are considered static.
+	motionNoise = 8
+	// motionFull is the luminance difference from which the pixels get the maximum protection.
+	motionFull = 48
+	// motionBlur is the blur radius of the difference map, which expands the protection
+	// over the whole moving subject, not only over its edges.
+	motionBlur = 6
+)
+
+// mergeMotion merges the motion between the image and the reference image (the other frame of
+// a burst or of a stereo pair) into the bias map. The magnitude of the inter-frame difference is
+// used as a cheap estimate of the optical flow: the moving subjects are protected, while the
+// static background is left to the energy map.
+func mergeMotion(bias, img, ref *image.NRGBA) error {
+	if ref == nil {
+		return nil
+	}
+	if ref.Bounds().Size() != img.Bounds().Size() {
+		return fmt.Errorf("the reference image size %v should match the image size %v",
+			ref.Bounds().Size(), img.Bounds().Size())
+	}
+
+	b, rb := img.Bounds(), ref.Bounds()
+	diff := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			i := img.PixOffset(b.Min.X+x, b.Min.Y+y)
+			j := ref.PixOffset(rb.Min.X+x, rb.Min.Y+y)
+			d := math.Abs(float64(filters.Luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2])) -
+				float64(filters.Luma(ref.Pix[j], ref.Pix[j+1], ref.Pix[j+2])))
+
+			// Map the difference over the noise level to the [0, 255] range.
+			v := uint8(math.Round(math.Max(0, math.Min(1, (d-motionNoise)/(motionFull-motionNoise))) * 0xff))
+			k := diff.PixOffset(x, y)
+			diff.Pix[k], diff.Pix[k+1], diff.Pix[k+2], diff.Pix[k+3] = v, v, v, 0xff
+		}
+	}
+	diff = NewCarver(b.Dx(), b.Dy()).StackBlur(diff, motionBlur)
+
+	bb := bias.Bounds()
+	for y := 0; y < bb.Dy(); y++ {
+		for x := 0; x < bb.Dx(); x++ {
+			// The blurred difference is stretched, so the interior of the moving subjects is fully protected.
+			m := math.Min(1, 2*float64(diff.Pix[diff.PixOffset(x, y)])/0xff)
+			i := bias.PixOffset(bb.Min.X+x, bb.Min.Y+y)
+			v := min(int(bias.Pix[i])+int(math.Round(m*(biasNeutral-1))), 0xff)
+			bias.Pix[i], bias.Pix[i+1], bias.Pix[i+2] = uint8(v), uint8(v), uint8(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMotion_ShouldProtectTheMovingSubject(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	ref := image.NewNRGBA(img.Bounds())
+	for x := 0; x < 60; x++ {
+		for y := 0; y < 40; y++ {
+			img.Set(x, y, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+			ref.Set(x, y, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+			// The subject moved from the left side to the right side of the frame.
+			if y >= 10 && y < 30 && x >= 40 && x < 50 {
+				img.Set(x, y, color.NRGBA{R: 240, G: 240, B: 240, A: 255})
+			}
+			if y >= 10 && y < 30 && x >= 10 && x < 20 {
+				ref.Set(x, y, color.NRGBA{R: 240, G: 240, B: 240, A: 255})
+			}
+		}
+	}
+
+	bias := renderBiasMap(nil, nil, img.Bounds())
+	assert.NoError(mergeMotion(bias, img, ref))
+	assert.Equal(uint8(0xff), bias.NRGBAAt(45, 20).R)
+	assert.Equal(uint8(biasNeutral), bias.NRGBAAt(30, 2).R)
+
+	assert.Error(mergeMotion(bias, img, image.NewNRGBA(image.Rect(0, 0, 10, 10))))
+
+	// The protected subject is retained by the carving.
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 40, Reference: ref}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 40, 40), res.Bounds())
+
+	var bright int
+	nrgba := res.(*image.NRGBA)
+	for x := 0; x < 40; x++ {
+		if nrgba.NRGBAAt(x, 20).R > 200 {
+			bright++
+		}
+	}
+	assert.GreaterOrEqual(bright, 8)
+}
@@ -0,0 +1,71 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Supported flip directions.
+const (
+	FlipHorizontal = "h"
+	FlipVertical   = "v"
+)
+
+// validateOrientation checks the Rotate and Flip options.
+func (p *Processor) validateOrientation() error {
+	switch p.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("invalid rotation angle: %d, it should be one of 90, 180 or 270", p.Rotate)
+	}
+	switch p.Flip {
+	case "", FlipHorizontal, FlipVertical:
+	default:
+		return fmt.Errorf("invalid flip direction: %q, it should be h or v", p.Flip)
+	}
+	return nil
+}
+
+// orient rotates the image clockwise by the angle defined by the Rotate option,
+// then flips it in the direction defined by the Flip option.
+// It's applied both to the source image and to the masks.
+func (p *Processor) orient(img *image.NRGBA) *image.NRGBA {
+	switch p.Rotate {
+	case 90:
+		img = imaging.Rotate270(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate90(img)
+	}
+	switch p.Flip {
+	case FlipHorizontal:
+		img = imaging.FlipH(img)
+	case FlipVertical:
+		img = imaging.FlipV(img)
+	}
+	return img
+}
+
+// orientRect transforms the rectangle defined in the coordinates of the source image of the
+// provided size, the same way as orient transforms the image.
+func (p *Processor) orientRect(r image.Rectangle, size image.Point) image.Rectangle {
+	w, h := size.X, size.Y
+	switch p.Rotate {
+	case 90:
+		r, w, h = image.Rect(h-r.Max.Y, r.Min.X, h-r.Min.Y, r.Max.X), h, w
+	case 180:
+		r = image.Rect(w-r.Max.X, h-r.Max.Y, w-r.Min.X, h-r.Min.Y)
+	case 270:
+		r, w, h = image.Rect(r.Min.Y, w-r.Max.X, r.Max.Y, w-r.Min.X), h, w
+	}
+	switch p.Flip {
+	case FlipHorizontal:
+		r = image.Rect(w-r.Max.X, r.Min.Y, w-r.Min.X, r.Max.Y)
+	case FlipVertical:
+		r = image.Rect(r.Min.X, h-r.Max.Y, r.Max.X, h-r.Min.Y)
+	}
+	return r
+}
@@ -0,0 +1,39 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrient_ShouldRotateAndFlip(t *testing.T) {
+	assert := assert.New(t)
+
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	proc := &Processor{Rotate: 90}
+	res := proc.orient(img)
+	assert.Equal(image.Rect(0, 0, 1, 2), res.Bounds())
+	// Clockwise rotation: the left pixel moves to the top.
+	assert.Equal(red, res.NRGBAAt(0, 0))
+	assert.Equal(blue, res.NRGBAAt(0, 1))
+
+	proc = &Processor{Rotate: 270}
+	res = proc.orient(img)
+	assert.Equal(blue, res.NRGBAAt(0, 0))
+
+	proc = &Processor{Flip: FlipHorizontal}
+	res = proc.orient(img)
+	assert.Equal(blue, res.NRGBAAt(0, 0))
+	assert.Equal(red, res.NRGBAAt(1, 0))
+
+	assert.Error((&Processor{Rotate: 45}).validateOrientation())
+	assert.Error((&Processor{Flip: "x"}).validateOrientation())
+}
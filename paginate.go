@@ -0,0 +1,166 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/utils"
+)
+
+// minCutBand is the minimum width of the band searched for the cut between two pages,
+// relative to the page width, used for choosing the number of pages automatically.
+const minCutBand = 8
+
+// Page is a page of a paginated image.
+type Page struct {
+	// Image holds the page pixels. The pixels beyond the cuts are transparent.
+	Image *image.NRGBA
+	// X is the position of the page in the source image.
+	X int
+}
+
+// PageCount returns the number of pages of the provided width used by Paginate for splitting
+// an image of the provided width automatically: the smallest number of pages covering the
+// image, leaving a band of at least 1/8 of the page width for each cut.
+func PageCount(imageWidth, pageWidth int) int {
+	if pageWidth <= 0 || imageWidth <= pageWidth {
+		return 1
+	}
+	// The n pages overlap by (n*pageWidth - imageWidth) / (n-1) pixels.
+	n := float64(minCutBand*imageWidth-pageWidth) / float64((minCutBand-1)*pageWidth)
+	return utils.Max(int(math.Ceil(n)), 2)
+}
+
+// Paginate splits a wide image (like a panorama or a scroll capture) into pages of the provided
+// width, or into the number of pages given by PageCount if pages is zero. The pages are spread
+// evenly over the image, overlapping each other, and two neighbouring pages are separated by the
+// lowest energy vertical seam crossing their overlap, instead of a straight cut. This way the page
+// boundaries avoid the salient content, like the figures of a web comic. The pixels of a page
+// beyond the cuts are transparent.
+func (p *Processor) Paginate(src image.Image, width, pages int) ([]Page, error) {
+	img := p.imgToNRGBA(src)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w < minImageSize || h < minImageSize {
+		return nil, fmt.Errorf("the image is too small: %dx%d", w, h)
+	}
+	if width <= 0 {
+		return nil, fmt.Errorf("invalid page width: %d", width)
+	}
+	width = utils.Min(width, w)
+	if pages <= 0 {
+		pages = PageCount(w, width)
+	}
+	if pages*width < w {
+		return nil, fmt.Errorf("%d pages of %dpx can't cover the %dpx wide image", pages, width, w)
+	}
+	if pages > 1 && width == w {
+		return nil, fmt.Errorf("the %dpx wide image fits in a single page", w)
+	}
+
+	// The position of the pages in the source image.
+	start := make([]int, pages)
+	for i := 1; i < pages; i++ {
+		start[i] = int(math.Round(float64(i*(w-width)) / float64(pages-1)))
+	}
+
+	// The cut between the pages i and i+1 crosses their overlap, without reaching the next page,
+	// so the cuts never cross each other. The pixels left of the cut belong to the page i.
+	cuts := make([][]int, pages-1)
+	for i := range cuts {
+		lo, hi := start[i+1], start[i]+width
+		if i+2 < pages {
+			hi = utils.Min(hi, start[i+2])
+		}
+		cuts[i] = p.cutSeam(img, lo, hi)
+	}
+
+	result := make([]Page, pages)
+	for i := range result {
+		page := image.NewNRGBA(image.Rect(0, 0, width, h))
+		for y := 0; y < h; y++ {
+			x0, x1 := 0, w
+			if i > 0 {
+				x0 = cuts[i-1][y]
+			}
+			if i < pages-1 {
+				x1 = cuts[i][y]
+			}
+			x0, x1 = utils.Max(x0, start[i]), utils.Min(x1, start[i]+width)
+			if x0 >= x1 {
+				continue
+			}
+			copy(page.Pix[page.PixOffset(x0-start[i], y):], img.Pix[img.PixOffset(x0, y):img.PixOffset(x1, y)])
+		}
+		result[i] = Page{Image: page, X: start[i]}
+	}
+	return result, nil
+}
+
+// cutSeam returns the position of the lowest energy vertical cut for each row of the image,
+// between the columns lo and hi (inclusive). The cut at x separates the columns x-1 and x.
+func (p *Processor) cutSeam(img *image.NRGBA, lo, hi int) []int {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	cut := make([]int, h)
+	if hi <= lo {
+		for y := range cut {
+			cut[y] = lo
+		}
+		return cut
+	}
+
+	// The energy map is computed over the band only, with a margin
+	// avoiding the spurious energy of the edge detector along the borders.
+	margin := 3 + p.BlurRadius
+	x0, x1 := utils.Max(0, lo-margin), utils.Min(w, hi+1+margin)
+	band := imaging.Crop(img, image.Rect(x0, 0, x1, h))
+	backend := p.getBackend()
+	c := NewCarver(x1-x0, h)
+	energy := backend.sobel(c, band, float64(p.SobelThreshold))
+	if p.BlurRadius > 0 {
+		energy = backend.blur(c, energy, uint32(p.BlurRadius))
+	}
+
+	// The cumulative minimum energy of the connected cuts, like for the seams of the carver.
+	n := hi - lo + 1
+	cost := make([]float64, n*h)
+	for y := 0; y < h; y++ {
+		for i := 0; i < n; i++ {
+			x := utils.Min(lo+i, w-1) - x0
+			e := float64(energy.Pix[energy.PixOffset(x, y)]) / 0xff
+			if y > 0 {
+				prev := cost[(y-1)*n+i]
+				if i > 0 {
+					prev = math.Min(prev, cost[(y-1)*n+i-1])
+				}
+				if i < n-1 {
+					prev = math.Min(prev, cost[(y-1)*n+i+1])
+				}
+				e += prev
+			}
+			cost[y*n+i] = e
+		}
+	}
+
+	// On equal energies the cut closer to the middle of the band is preferred.
+	best, center := 0, float64(n-1)/2
+	for i := 1; i < n; i++ {
+		c, b := cost[(h-1)*n+i], cost[(h-1)*n+best]
+		if c < b-1e-9 || (math.Abs(c-b) <= 1e-9 && math.Abs(float64(i)-center) < math.Abs(float64(best)-center)) {
+			best = i
+		}
+	}
+	cut[h-1] = lo + best
+	for y := h - 2; y >= 0; y-- {
+		i := best
+		for _, j := range []int{best - 1, best + 1} {
+			if j >= 0 && j < n && cost[y*n+j] < cost[y*n+i] {
+				i = j
+			}
+		}
+		best = i
+		cut[y] = lo + best
+	}
+	return cut
+}
@@ -0,0 +1,88 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate_ShouldCountThePages(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(1, PageCount(1000, 1080))
+	assert.Equal(1, PageCount(1080, 1080))
+	// Three pages would leave a band of 120px only for the cuts.
+	assert.Equal(4, PageCount(3000, 1080))
+	assert.Equal(3, PageCount(2000, 1000))
+}
+
+func TestPaginate_ShouldCutAlongTheLowEnergySeams(t *testing.T) {
+	assert := assert.New(t)
+
+	// A noisy image crossed by a flat vertical strip, away from the middle of the overlap.
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 300; x++ {
+			v := uint8(rnd.Intn(256))
+			if x >= 160 && x < 172 {
+				v = 128
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1}
+
+	pages, err := proc.Paginate(img, 180, 2)
+	assert.NoError(err)
+	assert.Len(pages, 2)
+	assert.Equal(0, pages[0].X)
+	assert.Equal(120, pages[1].X)
+
+	// The pages cover the image without overlaps, the cut crossing the flat strip.
+	res := image.NewNRGBA(img.Bounds())
+	for _, page := range pages {
+		assert.Equal(image.Rect(0, 0, 180, 60), page.Image.Bounds())
+		for y := 0; y < 60; y++ {
+			for x := 0; x < 180; x++ {
+				if page.Image.NRGBAAt(x, y).A == 0 {
+					continue
+				}
+				assert.Zero(res.NRGBAAt(page.X+x, y).A, "the pixel (%d, %d) belongs to multiple pages", page.X+x, y)
+			}
+		}
+		draw.Draw(res, page.Image.Bounds().Add(image.Pt(page.X, 0)), page.Image, image.Point{}, draw.Over)
+	}
+	assert.Equal(img.Pix, res.Pix)
+
+	for y := 5; y < 55; y++ {
+		var cut int
+		for cut = 120; pages[1].Image.NRGBAAt(cut-120, y).A == 0; cut++ {
+		}
+		assert.True(cut >= 160 && cut <= 172, "the cut of row %d is at %d", y, cut)
+	}
+}
+
+func TestPaginate_ShouldRejectTheInvalidLayouts(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 60))
+	proc := &Processor{SobelThreshold: 2}
+
+	_, err := proc.Paginate(img, 100, 2)
+	assert.Error(err)
+	_, err = proc.Paginate(img, 0, 0)
+	assert.Error(err)
+	_, err = proc.Paginate(img, 400, 2)
+	assert.Error(err)
+
+	// A single page is returned for the narrow images.
+	pages, err := proc.Paginate(img, 400, 0)
+	assert.NoError(err)
+	assert.Len(pages, 1)
+	assert.Equal(image.Rect(0, 0, 300, 60), pages[0].Image.Bounds())
+}
@@ -0,0 +1,33 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ResizePaletted resizes a paletted image (like GIF or PNG8 images) and returns
+// the resized image using the original palette, instead of promoting it to a full RGBA image.
+// This is useful in pixel art and icon workflows, where the palette should be retained.
+// In case the PaletteDither option is enabled Floyd-Steinberg error diffusion
+// is used for mapping the new colors (introduced by the image enlargement) to the palette.
+func (p *Processor) ResizePaletted(img *image.Paletted) (*image.Paletted, error) {
+	res, err := p.Resize(p.imgToNRGBA(img))
+	if err != nil {
+		return nil, err
+	}
+	return p.toPaletted(res, img.Palette), nil
+}
+
+// toPaletted converts the source image to a paletted image using the provided color palette.
+func (p *Processor) toPaletted(src image.Image, pal color.Palette) *image.Paletted {
+	bounds := src.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), pal)
+
+	if p.PaletteDither {
+		draw.FloydSteinberg.Draw(dst, dst.Bounds(), src, bounds.Min)
+	} else {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	}
+	return dst
+}
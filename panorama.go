@@ -0,0 +1,46 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+)
+
+// maxFormatSize is the maximum width and height of the JPEG and GIF images, which are storing
+// the image dimensions on 16 bits. The wider images (like the stitched panoramas) can be encoded as PNG.
+const maxFormatSize = 1<<16 - 1
+
+// checkOutputSize returns an error in case the output image would exceed the dimensions supported
+// by the output format, size being the size of the oriented source image. Carving a very wide
+// panorama takes a long time, so the problem is reported before carving, instead of failing
+// only at the encoding of the result. The enlarged images are limited by MaxPixels like the
+// decoded images, so an oversized output is rejected before it's allocated.
+func (p *Processor) checkOutputSize(size image.Point, format string) error {
+	// The size obtained with the target pixel count or aspect ratio is not known in advance.
+	if p.TargetPixels > 0 || p.TargetRatio > 0 || p.Grid != nil {
+		return nil
+	}
+	width, height := size.X, size.Y
+	if p.NewWidth > 0 {
+		width = p.NewWidth
+		if p.Percentage {
+			width = size.X * p.NewWidth / 100
+		}
+	}
+	if p.NewHeight > 0 {
+		height = p.NewHeight
+		if p.Percentage {
+			height = size.Y * p.NewHeight / 100
+		}
+	}
+	if max := p.maxPixels(); max > 0 && int64(width)*int64(height) > max {
+		return &ImageTooLargeError{Width: width, Height: height, MaxPixels: max}
+	}
+	if format != FormatJPEG && format != FormatGIF {
+		return nil
+	}
+	if width > maxFormatSize || height > maxFormatSize {
+		return fmt.Errorf("the %dx%d output image exceeds the maximum size of the %s format (%d pixels), use the PNG format instead",
+			width, height, format, maxFormatSize)
+	}
+	return nil
+}
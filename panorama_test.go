@@ -0,0 +1,85 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// panorama returns a very wide image with a vertical stripe every 97 columns.
+func panorama(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(80 + (x*7+y*13)%32)
+			if x%97 == 0 {
+				v = 250
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPanorama_ShouldCarveAtTheBoundarySizes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, width := range []int{1<<15 - 1, 1 << 15, 1<<16 - 1, 1 << 16, 50_000} {
+		img := panorama(width, 3)
+
+		p := &Processor{SobelThreshold: 2, BlurRadius: 2, NewWidth: width - 2}
+		res, err := p.Resize(img)
+		assert.NoError(err, "width %d", width)
+		assert.Equal(image.Rect(0, 0, width-2, 3), res.Bounds(), "width %d", width)
+
+		// The stripes have the highest energy, so they are kept.
+		dst := res.(*image.NRGBA)
+		stripes := 0
+		for x := 0; x < dst.Bounds().Dx(); x++ {
+			if dst.NRGBAAt(x, 1).R == 250 {
+				stripes++
+			}
+		}
+		assert.Equal((width+96)/97, stripes, "width %d", width)
+	}
+}
+
+func TestPanorama_ShouldBlurTheWideImages(t *testing.T) {
+	assert := assert.New(t)
+
+	img := panorama(1<<16+1, 2)
+	c := NewCarver(img.Bounds().Dx(), img.Bounds().Dy())
+	res := c.StackBlur(img, 3)
+	assert.Equal(image.Rect(0, 0, 1<<16+1, 2), res.Bounds())
+
+	// The last column is blurred together with its neighbours in the same row.
+	last := res.NRGBAAt(1<<16, 1)
+	assert.Greater(last.R, uint8(80))
+	assert.Less(last.R, uint8(250))
+}
+
+func TestPanorama_ShouldRejectTheOversizedOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, panorama(1<<16+10, 2)))
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 1<<16 + 8, OutputFormat: FormatJPEG}
+	err := p.Process(bytes.NewReader(src.Bytes()), &bytes.Buffer{})
+	assert.ErrorContains(err, "exceeds the maximum size of the jpeg format")
+
+	// The output fits in the JPEG format once the panorama is reduced enough.
+	p.NewWidth, p.Percentage = 50, true
+	assert.NoError(p.checkOutputSize(image.Pt(1<<16+10, 2), FormatJPEG))
+
+	p = &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 1<<16 + 8, OutputFormat: FormatPNG}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	res, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(1<<16+8, res.Bounds().Dx())
+}
@@ -0,0 +1,50 @@
+package caire
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minParallelChunk is the minimum number of items processed by a goroutine.
+// Below this size the goroutine overhead outweighs the gain of the parallel processing.
+const minParallelChunk = 4096
+
+// seamWorkers returns the number of goroutines used for computing the energy map of an image.
+// The zero value processes the image sequentially, while a negative value uses all the CPUs.
+// The CPU limit caps the number of goroutines to the allowed share of the CPUs.
+func (p *Processor) seamWorkers() int {
+	workers := max(1, p.SeamWorkers)
+	if p.SeamWorkers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if p.CPULimit > 0 && p.CPULimit < 1 {
+		workers = min(workers, cpuShare(p.CPULimit))
+	}
+	return workers
+}
+
+// runner is the filters.Runner splitting the pixels of the image filters between the workers.
+func (c *Carver) runner(n int, fn func(start, end int)) {
+	c.parallel(n, minParallelChunk, fn)
+}
+
+// parallel splits the [0, n) range into consecutive chunks of at least grain items
+// and calls fn over them concurrently, using at most c.workers goroutines.
+func (c *Carver) parallel(n, grain int, fn func(start, end int)) {
+	workers := min(c.workers, n/max(1, grain))
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for start := 0; start < n; start += chunk {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, min(start+chunk, n))
+	}
+	wg.Wait()
+}
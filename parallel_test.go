@@ -0,0 +1,44 @@
+package caire
+
+import (
+	"image"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallel_ShouldCoverTheRange(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, workers := range []int{0, 1, 3, 8} {
+		c := &Carver{workers: workers}
+		seen := make([]int32, 10000)
+		var calls int32
+		c.parallel(len(seen), 1000, func(start, end int) {
+			atomic.AddInt32(&calls, 1)
+			for i := start; i < end; i++ {
+				atomic.AddInt32(&seen[i], 1)
+			}
+		})
+		for i := range seen {
+			assert.Equal(int32(1), seen[i])
+		}
+		assert.Equal(int32(max(1, workers)), calls)
+	}
+}
+
+func TestParallel_ShouldProduceTheSameSeams(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 120))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*31 + i/997)
+	}
+
+	seq, err := (&Processor{SobelThreshold: 2, BlurRadius: 2, NewWidth: 180}).Resize(img)
+	assert.NoError(err)
+	par, err := (&Processor{SobelThreshold: 2, BlurRadius: 2, NewWidth: 180, SeamWorkers: 4}).Resize(img)
+	assert.NoError(err)
+	assert.Equal(seq, par)
+}
@@ -0,0 +1,68 @@
+package caire
+
+import (
+	"bytes"
+	"io"
+)
+
+// Noop reports whether the last Process call has copied the source image to the output
+// unchanged, since it already had the requested size.
+func (p *Processor) Noop() bool {
+	return p.noop
+}
+
+// canPassThrough reports whether the options could alter an image which already has
+// the requested size or require its displacement map, in which case the image has to be
+// decoded and encoded anyway.
+func (p *Processor) canPassThrough() bool {
+	return !p.Percentage && !p.Square && !p.AutoAxis && p.Grid == nil && !p.Preview && !p.Debug &&
+		!p.Preflight && !p.Strict && !p.BlurFaces && p.Rotate == 0 && p.Flip == "" && !p.PNG8 &&
+		p.Watermark == nil && p.Adjustments.IsZero() && p.SeamsSVGPath == "" && p.RemovedPath == "" &&
+		p.EnergyCSVPath == "" && p.GhostPath == "" && p.HeatmapPath == "" && p.DisplaceMap == "" &&
+		!p.JPEGProgressive && !p.JPEGCMYK && !p.TrackCoords
+}
+
+// passThrough copies the source image to w without decoding and encoding it again, in case
+// it already has the requested size and the output format is the same as the input format.
+// This avoids the recompression loss of the JPEG files. Only the image header is decoded
+// for obtaining the image size. It returns true if the image has been copied, otherwise the
+// returned reader should be used in place of r, since the header is consumed from r.
+func (p *Processor) passThrough(r io.Reader, w io.Writer, inFormat, outFormat string) (io.Reader, bool, error) {
+	if !p.canPassThrough() {
+		return r, false, nil
+	}
+	format := inFormat
+	if format == "" {
+		var err error
+		if format, r, err = DetectFormat(r); err != nil || format == "" {
+			return r, false, nil
+		}
+	}
+	if format != outFormat {
+		return r, false, nil
+	}
+	decodeConfig, _, err := decoders(format)
+	if err != nil {
+		return r, false, nil
+	}
+
+	// The header consumed by the config decoder is replayed for the copy or the image decoder.
+	var header bytes.Buffer
+	cfg, err := decodeConfig(io.TeeReader(r, &header))
+	r = io.MultiReader(&header, r)
+	if err != nil {
+		return r, false, nil
+	}
+	if max := p.maxPixels(); max > 0 && int64(cfg.Width)*int64(cfg.Height) > max {
+		return r, false, nil
+	}
+	if (p.NewWidth != 0 && p.NewWidth != cfg.Width) || (p.NewHeight != 0 && p.NewHeight != cfg.Height) {
+		return r, false, nil
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, false, err
+	}
+	p.noop = true
+	return nil, true, nil
+}
@@ -0,0 +1,90 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassThrough_ShouldCopyTheUnchangedImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 8), B: 90, A: 255})
+		}
+	}
+	var src bytes.Buffer
+	assert.NoError(jpeg.Encode(&src, img, &jpeg.Options{Quality: 80}))
+
+	p := &Processor{SobelThreshold: 2, NewWidth: 40, OutputFormat: FormatJPEG}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	assert.True(p.Noop())
+	assert.Equal(src.Bytes(), out.Bytes())
+
+	p.NewHeight = 30
+	out.Reset()
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	assert.True(p.Noop())
+	assert.Equal(src.Bytes(), out.Bytes())
+
+	// The resized, the converted and the transformed images are encoded again.
+	for _, p := range []*Processor{
+		{SobelThreshold: 2, NewWidth: 35, OutputFormat: FormatJPEG},
+		{SobelThreshold: 2, NewWidth: 40, OutputFormat: FormatPNG},
+		{SobelThreshold: 2, NewWidth: 40, OutputFormat: FormatJPEG, Flip: FlipHorizontal},
+		{SobelThreshold: 2, NewWidth: 40, OutputFormat: FormatJPEG, JPEGProgressive: true},
+	} {
+		out.Reset()
+		assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+		assert.False(p.Noop())
+		assert.NotEqual(src.Bytes(), out.Bytes())
+	}
+
+	// The size limit is still enforced.
+	p = &Processor{NewWidth: 40, OutputFormat: FormatJPEG, MaxPixels: 100}
+	var tooLarge *ImageTooLargeError
+	assert.ErrorAs(p.Process(bytes.NewReader(src.Bytes()), &out), &tooLarge)
+	assert.False(p.Noop())
+}
+
+func TestPassThrough_ShouldResetTheStateOfThePreviousImage(t *testing.T) {
+	assert := assert.New(t)
+
+	var small, large bytes.Buffer
+	assert.NoError(jpeg.Encode(&small, texturedImage(40, 30), &jpeg.Options{Quality: 90}))
+	assert.NoError(jpeg.Encode(&large, texturedImage(60, 30), &jpeg.Options{Quality: 90}))
+
+	// The first image is carved, the second one already has the requested size.
+	p := &Processor{SobelThreshold: 2, NewWidth: 40, OutputFormat: FormatJPEG, SimilarityThreshold: 1}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(large.Bytes()), &out))
+	assert.False(p.Noop())
+	assert.NotEmpty(p.Warnings())
+
+	out.Reset()
+	assert.NoError(p.Process(bytes.NewReader(small.Bytes()), &out))
+	assert.True(p.Noop())
+	assert.Empty(p.Warnings())
+	assert.Nil(p.DisplacementMap())
+
+	// The displacement map of the unchanged image is still provided.
+	p.TrackCoords = true
+	assert.NoError(p.Process(bytes.NewReader(large.Bytes()), &out))
+	assert.Equal(60, p.DisplacementMap().SrcWidth)
+
+	out.Reset()
+	assert.NoError(p.Process(bytes.NewReader(small.Bytes()), &out))
+	assert.False(p.Noop())
+	m := p.DisplacementMap()
+	if assert.NotNil(m) {
+		assert.Equal(40, m.SrcWidth)
+		assert.Equal(image.Pt(12, 7), m.Source(12, 7))
+	}
+}
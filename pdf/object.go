@@ -0,0 +1,212 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// The PDF object types used by the parser.
+type (
+	// Name is a PDF name object, stored without the leading slash.
+	Name string
+	// Dict is a PDF dictionary.
+	Dict map[Name]any
+	// Array is a PDF array.
+	Array []any
+	// Ref is an indirect object reference.
+	Ref struct{ Num, Gen int }
+	// raw holds the source of the strings, kept verbatim when the objects are written back.
+	raw []byte
+)
+
+var errSyntax = errors.New("pdf: syntax error")
+
+// lexer parses the PDF objects from a byte slice.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skip skips the white spaces and the comments.
+func (l *lexer) skip() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token returns the next regular token (a keyword or a number).
+func (l *lexer) token() string {
+	l.skip()
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// object parses the next object. The integers followed by a generation number
+// and the R keyword are returned as references.
+func (l *lexer) object() (any, error) {
+	l.skip()
+	if l.pos >= len(l.data) {
+		return nil, errSyntax
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+			l.pos++
+		}
+		return Name(l.data[start:l.pos]), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := make(Dict)
+		for {
+			l.skip()
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(Name)
+			if !ok {
+				return nil, errSyntax
+			}
+			if dict[name], err = l.object(); err != nil {
+				return nil, err
+			}
+		}
+	case c == '[':
+		l.pos++
+		var arr Array
+		for {
+			l.skip()
+			if l.pos >= len(l.data) {
+				return nil, errSyntax
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			obj, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, obj)
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, errSyntax
+		}
+		s := raw(l.data[l.pos : l.pos+end+1])
+		l.pos += end + 1
+		return s, nil
+	case c == '(':
+		start, depth := l.pos, 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					l.pos++
+					return raw(l.data[start:l.pos]), nil
+				}
+			}
+		}
+		return nil, errSyntax
+	}
+
+	tok := l.token()
+	switch tok {
+	case "":
+		return nil, errSyntax
+	case "true", "false":
+		return tok == "true", nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(tok); err == nil {
+		// Look ahead for an indirect reference.
+		save := l.pos
+		if gen, err := strconv.Atoi(l.token()); err == nil && l.token() == "R" {
+			return Ref{Num: n, Gen: gen}, nil
+		}
+		l.pos = save
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(tok, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected token %q", errSyntax, tok)
+}
+
+// write serializes the object in PDF syntax.
+func write(buf *bytes.Buffer, obj any) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		buf.WriteString("/" + string(v))
+	case raw:
+		buf.Write(v)
+	case Ref:
+		fmt.Fprintf(buf, "%d %d R", v.Num, v.Gen)
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			write(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		// The keys are sorted, so the output is deterministic.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, string(key))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, key := range keys {
+			buf.WriteString(" /" + key + " ")
+			write(buf, v[Name(key)])
+		}
+		buf.WriteString(" >>")
+	}
+}
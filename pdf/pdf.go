@@ -0,0 +1,344 @@
+// Package pdf resizes the raster images embedded in PDF documents.
+//
+// The document is not rewritten: the resized images are appended to the original file
+// as an incremental update, replacing the image objects under the same object numbers.
+// The pages are drawing the images into the boxes defined by their content streams,
+// independently of the image size in pixels, so the layout of the document is preserved.
+//
+// Only the JPEG images and the uncompressed or Flate compressed 8 bit RGB and grayscale
+// images without predictors are supported. The images having transparency masks or decode arrays are
+// left unchanged, together with the images which can't be decoded.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// DefaultQuality is the JPEG quality of the resized images.
+const DefaultQuality = 85
+
+// ResizeFunc resizes an image extracted from the document.
+// It should return a nil image for leaving the image unchanged.
+type ResizeFunc func(img image.Image) (image.Image, error)
+
+// Result reports the number of the processed images.
+type Result struct {
+	// Images is the number of images found in the document.
+	Images int
+	// Resized is the number of images replaced by their resized version.
+	Resized int
+	// Skipped is the number of images which are not supported.
+	Skipped int
+}
+
+// object is an indirect object of the document.
+type object struct {
+	num, gen int
+	// offset is the position of the object value.
+	offset int
+	dict   Dict
+	// stream holds the position of the stream data, if the object is a stream.
+	stream [2]int
+}
+
+type document struct {
+	data    []byte
+	objects map[int]*object
+}
+
+var objRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parse indexes the indirect objects of the document. The objects redefined by
+// the incremental updates are overriding the previous definitions.
+func parse(data []byte) (*document, error) {
+	doc := &document{data: data, objects: make(map[int]*object)}
+
+	for pos := 0; pos < len(data); {
+		loc := objRe.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		gen, _ := strconv.Atoi(string(data[pos+loc[4] : pos+loc[5]]))
+		obj := &object{num: num, gen: gen, offset: pos + loc[1]}
+		pos += loc[1]
+
+		l := &lexer{data: data, pos: obj.offset}
+		val, err := l.object()
+		if err != nil {
+			continue
+		}
+		if dict, ok := val.(Dict); ok {
+			obj.dict = dict
+			save := l.pos
+			if l.token() == "stream" {
+				start := l.pos
+				if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+					start += 2
+				} else if start < len(data) && data[start] == '\n' {
+					start++
+				}
+				end := bytes.Index(data[start:], []byte("endstream"))
+				if end < 0 {
+					return nil, fmt.Errorf("pdf: unterminated stream in object %d", num)
+				}
+				obj.stream = [2]int{start, start + end}
+				// The stream content is skipped, so its bytes are never taken as object headers.
+				pos = start + end
+			} else {
+				l.pos = save
+			}
+		}
+		doc.objects[num] = obj
+	}
+	if len(doc.objects) == 0 {
+		return nil, errors.New("pdf: no objects found, the file is not a PDF document")
+	}
+	return doc, nil
+}
+
+// resolve returns the value of the indirect reference, or the value itself otherwise.
+func (d *document) resolve(v any) any {
+	ref, ok := v.(Ref)
+	if !ok {
+		return v
+	}
+	obj, ok := d.objects[ref.Num]
+	if !ok {
+		return nil
+	}
+	if obj.dict != nil {
+		return obj.dict
+	}
+	l := &lexer{data: d.data, pos: obj.offset}
+	val, err := l.object()
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// streamData returns the raw (encoded) data of the stream object.
+func (d *document) streamData(obj *object) []byte {
+	start, end := obj.stream[0], obj.stream[1]
+	if n, ok := d.resolve(obj.dict["Length"]).(int); ok && n >= 0 && start+n <= len(d.data) {
+		return d.data[start : start+n]
+	}
+	// Fall back to the position of the endstream keyword, without the end of line marker.
+	return bytes.TrimRight(d.data[start:end], "\r\n")
+}
+
+// trailer returns the trailer dictionary and the offset of the last cross-reference section.
+func (d *document) trailer() (Dict, int, error) {
+	idx := bytes.LastIndex(d.data, []byte("startxref"))
+	if idx < 0 {
+		return nil, 0, errors.New("pdf: missing startxref")
+	}
+	l := &lexer{data: d.data, pos: idx + len("startxref")}
+	startxref, err := strconv.Atoi(l.token())
+	if err != nil || startxref >= len(d.data) {
+		return nil, 0, errors.New("pdf: invalid startxref")
+	}
+
+	l.pos = startxref
+	if l.token() == "xref" {
+		// Classic cross-reference table followed by the trailer dictionary.
+		t := bytes.Index(d.data[startxref:], []byte("trailer"))
+		if t < 0 {
+			return nil, 0, errors.New("pdf: missing trailer")
+		}
+		l.pos = startxref + t + len("trailer")
+	} else {
+		// Cross-reference stream, the stream dictionary holds the trailer entries.
+		l.pos = startxref
+		l.token()
+		l.token()
+		if l.token() != "obj" {
+			return nil, 0, errors.New("pdf: invalid cross-reference section")
+		}
+	}
+	val, err := l.object()
+	if err != nil {
+		return nil, 0, err
+	}
+	dict, ok := val.(Dict)
+	if !ok {
+		return nil, 0, errors.New("pdf: invalid trailer")
+	}
+	return dict, startxref, nil
+}
+
+// decodeImage decodes the image XObject. It returns a nil image if the image is not supported.
+func (d *document) decodeImage(obj *object) (image.Image, error) {
+	dict := obj.dict
+	if dict["SMask"] != nil || dict["Mask"] != nil || dict["Decode"] != nil || dict["ImageMask"] == true {
+		return nil, nil
+	}
+
+	filter := d.resolve(dict["Filter"])
+	if arr, ok := filter.(Array); ok && len(arr) == 1 {
+		filter = d.resolve(arr[0])
+	}
+	data := d.streamData(obj)
+
+	switch filter {
+	case Name("DCTDecode"):
+		return jpeg.Decode(bytes.NewReader(data))
+	case nil, Name("FlateDecode"):
+		if dict["DecodeParms"] != nil || d.resolve(dict["BitsPerComponent"]) != 8 {
+			return nil, nil
+		}
+		width, _ := d.resolve(dict["Width"]).(int)
+		height, _ := d.resolve(dict["Height"]).(int)
+		if width <= 0 || height <= 0 {
+			return nil, nil
+		}
+
+		var channels int
+		switch d.resolve(dict["ColorSpace"]) {
+		case Name("DeviceRGB"):
+			channels = 3
+		case Name("DeviceGray"):
+			channels = 1
+		default:
+			return nil, nil
+		}
+
+		var r io.Reader = bytes.NewReader(data)
+		if filter != nil {
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		}
+		pix := make([]byte, width*height*channels)
+		if _, err := io.ReadFull(r, pix); err != nil {
+			return nil, err
+		}
+
+		if channels == 1 {
+			return &image.Gray{Pix: pix, Stride: width, Rect: image.Rect(0, 0, width, height)}, nil
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			copy(img.Pix[4*i:4*i+3], pix[3*i:3*i+3])
+			img.Pix[4*i+3] = 0xff
+		}
+		return img, nil
+	}
+	return nil, nil
+}
+
+// Resize resizes the images embedded in the PDF document using the resize function and
+// writes the updated document to w. The resized images are encoded as JPEG with the
+// provided quality (DefaultQuality is used if it's zero).
+func Resize(src []byte, w io.Writer, quality int, resize ResizeFunc) (*Result, error) {
+	if quality <= 0 {
+		quality = DefaultQuality
+	}
+	doc, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	trailer, startxref, err := doc.trailer()
+	if err != nil {
+		return nil, err
+	}
+
+	nums := make([]int, 0, len(doc.objects))
+	for num, obj := range doc.objects {
+		if obj.dict != nil && obj.stream[1] > 0 && doc.resolve(obj.dict["Subtype"]) == Name("Image") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	var (
+		res     = &Result{Images: len(nums)}
+		update  bytes.Buffer
+		offsets = make(map[int]int)
+	)
+	update.Write(src)
+	if !bytes.HasSuffix(src, []byte("\n")) {
+		update.WriteByte('\n')
+	}
+
+	for _, num := range nums {
+		obj := doc.objects[num]
+		img, err := doc.decodeImage(obj)
+		if err != nil || img == nil {
+			res.Skipped++
+			continue
+		}
+		resized, err := resize(img)
+		if err != nil {
+			return nil, fmt.Errorf("pdf: cannot resize the image of object %d: %w", num, err)
+		}
+		if resized == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+
+		dict := make(Dict, len(obj.dict))
+		for key, val := range obj.dict {
+			dict[key] = val
+		}
+		delete(dict, "DecodeParms")
+		dict["Width"] = resized.Bounds().Dx()
+		dict["Height"] = resized.Bounds().Dy()
+		dict["BitsPerComponent"] = 8
+		dict["Filter"] = Name("DCTDecode")
+		dict["Length"] = buf.Len()
+		dict["ColorSpace"] = Name("DeviceRGB")
+		if _, ok := resized.(*image.Gray); ok {
+			dict["ColorSpace"] = Name("DeviceGray")
+		}
+
+		offsets[num] = update.Len()
+		fmt.Fprintf(&update, "%d %d obj\n", num, obj.gen)
+		write(&update, dict)
+		update.WriteString("\nstream\n")
+		update.Write(buf.Bytes())
+		update.WriteString("\nendstream\nendobj\n")
+		res.Resized++
+	}
+
+	if res.Resized > 0 {
+		xref := update.Len()
+		update.WriteString("xref\n")
+		for _, num := range nums {
+			if off, ok := offsets[num]; ok {
+				fmt.Fprintf(&update, "%d 1\n%010d %05d n \n", num, off, doc.objects[num].gen)
+			}
+		}
+
+		next := Dict{"Prev": startxref}
+		for _, key := range []Name{"Size", "Root", "Info", "ID", "Encrypt"} {
+			if val, ok := trailer[key]; ok {
+				next[key] = val
+			}
+		}
+		update.WriteString("trailer\n")
+		write(&update, next)
+		fmt.Fprintf(&update, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	}
+
+	if _, err := w.Write(update.Bytes()); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
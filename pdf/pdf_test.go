@@ -0,0 +1,127 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// buildPDF creates a single page document drawing a JPEG and a Flate compressed image.
+func buildPDF(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var flate bytes.Buffer
+	zw := zlib.NewWriter(&flate)
+	zw.Write(bytes.Repeat([]byte{0x80}, 30*10))
+	zw.Close()
+
+	content := "q 200 0 0 100 0 0 cm /Im1 Do Q q 100 0 0 50 0 100 cm /Im2 Do Q"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R /Resources << /XObject << /Im1 5 0 R /Im2 6 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 40 /Height 20 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length 7 0 R >>\nstream\n%s\nendstream", jpg.Bytes()),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 30 /Height 10 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter [/FlateDecode] /Length %d >>\nstream\n%s\nendstream", flate.Len(), flate.Bytes()),
+		fmt.Sprintf("%d", jpg.Len()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// halve returns the left half of the image.
+func halve(img image.Image) (image.Image, error) {
+	b := img.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx()/2, b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx()/2; x++ {
+			dst.Set(x, y, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+	return dst, nil
+}
+
+func TestPdf_ShouldResizeTheImages(t *testing.T) {
+	src := buildPDF(t)
+
+	var out bytes.Buffer
+	res, err := Resize(src, &out, 0, halve)
+	if err != nil {
+		t.Fatalf("resize failed: %v", err)
+	}
+	if res.Images != 2 || res.Resized != 2 || res.Skipped != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if !bytes.HasPrefix(out.Bytes(), src) {
+		t.Fatal("the original document should be preserved by the incremental update")
+	}
+
+	doc, err := parse(out.Bytes())
+	if err != nil {
+		t.Fatalf("cannot parse the resized document: %v", err)
+	}
+	for num, width := range map[int]int{5: 20, 6: 15} {
+		obj := doc.objects[num]
+		if got := obj.dict["Width"]; got != width {
+			t.Errorf("object %d: expected width %d, got %v", num, width, got)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(doc.streamData(obj)))
+		if err != nil {
+			t.Fatalf("object %d: cannot decode the image: %v", num, err)
+		}
+		if img.Bounds().Dx() != width {
+			t.Errorf("object %d: expected image width %d, got %d", num, width, img.Bounds().Dx())
+		}
+	}
+
+	trailer, _, err := doc.trailer()
+	if err != nil {
+		t.Fatalf("cannot read the trailer: %v", err)
+	}
+	if trailer["Root"] != (Ref{Num: 1}) || trailer["Prev"] == nil {
+		t.Errorf("unexpected trailer: %v", trailer)
+	}
+}
+
+func TestPdf_ShouldKeepTheUnchangedDocument(t *testing.T) {
+	src := buildPDF(t)
+
+	var out bytes.Buffer
+	res, err := Resize(src, &out, 0, func(image.Image) (image.Image, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("resize failed: %v", err)
+	}
+	if res.Resized != 0 || !bytes.Equal(out.Bytes(), src) {
+		t.Errorf("the document should not be changed, got %+v", res)
+	}
+}
+
+func TestPdf_ShouldRejectInvalidDocuments(t *testing.T) {
+	if _, err := Resize([]byte("not a pdf"), &bytes.Buffer{}, 0, halve); err == nil {
+		t.Error("expected an error for an invalid document")
+	}
+}
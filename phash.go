@@ -0,0 +1,136 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// DefaultSimilarityThreshold is the default maximum distance between the perceptual hashes
+// of the source and the resized image, used in strict mode if no threshold is provided.
+// Halving the width of a photo by carving typically yields a distance below 20, while the
+// distance of two unrelated images is around half of the 64 bits.
+const DefaultSimilarityThreshold = 24
+
+// WarnSimilarity is the code of the warning raised when the resized image doesn't resemble the source.
+const WarnSimilarity = "similarity"
+
+// phashSize is the size of the downscaled image on which the perceptual hash is computed.
+const phashSize = 32
+
+// SimilarityError is returned by the processors using the StrictSimilarity option,
+// when the resized image is too different from the source image.
+type SimilarityError struct {
+	Distance  int
+	Threshold int
+}
+
+func (e *SimilarityError) Error() string {
+	return fmt.Sprintf("the resized image doesn't resemble the source image (perceptual hash distance %d, threshold %d)",
+		e.Distance, e.Threshold)
+}
+
+// PerceptualHash computes the 64 bits perceptual hash (pHash) of the image. The image is reduced
+// to 32x32 pixels, ignoring its aspect ratio, and the bits are set for the low frequencies of the
+// discrete cosine transform of the luminance which are above their median. Unlike the pixel
+// comparisons, the hash is insensitive to the size and the small distortions of the image.
+func PerceptualHash(img image.Image) uint64 {
+	small := imaging.Resize(img, phashSize, phashSize, imaging.Box)
+
+	var lum [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			i := small.PixOffset(x, y)
+			// The luminance is rounded to the nearest level, rather than truncated like for the grayscale images.
+			lum[y][x] = math.Round(0.299*float64(small.Pix[i]) + 0.587*float64(small.Pix[i+1]) + 0.114*float64(small.Pix[i+2]))
+		}
+	}
+
+	// The 2D transform is computed separately on the rows and on the columns,
+	// keeping only the 8x8 lowest frequencies.
+	var (
+		rows   [phashSize][8]float64
+		coeffs [64]float64
+	)
+	for y := 0; y < phashSize; y++ {
+		for u := 0; u < 8; u++ {
+			rows[y][u] = dct(lum[y][:], u)
+		}
+	}
+	var col [phashSize]float64
+	for u := 0; u < 8; u++ {
+		for y := 0; y < phashSize; y++ {
+			col[y] = rows[y][u]
+		}
+		for v := 0; v < 8; v++ {
+			coeffs[v*8+u] = dct(col[:], v)
+		}
+	}
+
+	// The DC coefficient (the mean luminance) is excluded from the median.
+	sorted := make([]float64, 63)
+	copy(sorted, coeffs[1:])
+	sort.Float64s(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// dct returns the coefficient of the k-th frequency of the discrete cosine transform (DCT-II).
+func dct(values []float64, k int) float64 {
+	n := float64(len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v * math.Cos(math.Pi*float64(k)*(float64(i)+0.5)/n)
+	}
+	return sum
+}
+
+// HashDistance returns the number of different bits (the Hamming distance) of two perceptual hashes.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// similarityThreshold returns the maximum perceptual hash distance accepted by the
+// similarity check, or zero if the check is disabled.
+func (p *Processor) similarityThreshold() int {
+	if p.SimilarityThreshold > 0 {
+		return p.SimilarityThreshold
+	}
+	if p.StrictSimilarity {
+		return DefaultSimilarityThreshold
+	}
+	return 0
+}
+
+// checkSimilarity compares the perceptual hashes of the source and the resized image. It raises
+// a warning if their distance exceeds the threshold, or fails in case of the strict similarity.
+func (p *Processor) checkSimilarity(src, res image.Image) error {
+	threshold := p.similarityThreshold()
+	if threshold == 0 {
+		return nil
+	}
+	dist := HashDistance(PerceptualHash(src), PerceptualHash(res))
+	if dist <= threshold {
+		return nil
+	}
+	if p.StrictSimilarity {
+		return &SimilarityError{Distance: dist, Threshold: threshold}
+	}
+	p.warnings = append(p.warnings, Warning{
+		Code:    WarnSimilarity,
+		Message: fmt.Sprintf("the resized image doesn't resemble the source image (perceptual hash distance %d)", dist),
+		Score:   float64(dist),
+	})
+	return nil
+}
@@ -0,0 +1,70 @@
+package caire
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerceptualHash_ShouldIgnoreTheSize(t *testing.T) {
+	assert := assert.New(t)
+
+	img := subjectsImage(200, 120, 10, 120)
+	hash := PerceptualHash(img)
+
+	assert.Equal(0, HashDistance(hash, hash))
+	assert.LessOrEqual(HashDistance(hash, PerceptualHash(imaging.Resize(img, 120, 120, imaging.Lanczos))), 4)
+	assert.LessOrEqual(HashDistance(hash, PerceptualHash(imaging.Blur(img, 1))), 4)
+	assert.Greater(HashDistance(hash, PerceptualHash(imaging.FlipH(img))), DefaultSimilarityThreshold)
+	assert.Greater(HashDistance(hash, PerceptualHash(imaging.Invert(img))), DefaultSimilarityThreshold)
+}
+
+func TestSimilarity_ShouldReportTheDissimilarResults(t *testing.T) {
+	assert := assert.New(t)
+
+	img := subjectsImage(200, 120, 10, 120)
+	broken := imaging.Invert(img)
+
+	p := &Processor{}
+	assert.NoError(p.checkSimilarity(img, broken))
+	assert.Empty(p.Warnings())
+
+	p.SimilarityThreshold = DefaultSimilarityThreshold
+	assert.NoError(p.checkSimilarity(img, img))
+	assert.Empty(p.Warnings())
+	assert.NoError(p.checkSimilarity(img, broken))
+	if assert.Len(p.Warnings(), 1) {
+		assert.Equal(WarnSimilarity, p.Warnings()[0].Code)
+		assert.Greater(p.Warnings()[0].Score, float64(DefaultSimilarityThreshold))
+	}
+
+	p = &Processor{StrictSimilarity: true}
+	err := p.checkSimilarity(img, broken)
+	var serr *SimilarityError
+	if assert.True(errors.As(err, &serr)) {
+		assert.Equal(DefaultSimilarityThreshold, serr.Threshold)
+		assert.Greater(serr.Distance, serr.Threshold)
+	}
+	assert.Empty(p.Warnings())
+}
+
+func TestSimilarity_ShouldAcceptTheCarvedImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var in bytes.Buffer
+	assert.NoError(png.Encode(&in, subjectsImage(200, 120, 10, 120)))
+
+	p := &Processor{NewWidth: 150, SobelThreshold: 2, OutputFormat: FormatPNG, StrictSimilarity: true}
+	var out bytes.Buffer
+	assert.NoError(p.Process(&in, &out))
+	assert.Empty(p.Warnings())
+
+	img, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 150, 120), img.Bounds())
+}
@@ -0,0 +1,208 @@
+package caire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+	pigo "github.com/esimov/pigo/core"
+	"gopkg.in/yaml.v3"
+)
+
+// The operations supported by the pipeline.
+const (
+	// OpResize scales the image, without taking the content into account.
+	OpResize = "resize"
+	// OpCarve resizes the image with the content aware seam carving.
+	OpCarve = "carve"
+	// OpCrop extracts a rectangular area of the image.
+	OpCrop = "crop"
+	// OpSharpen sharpens the image using the unsharp masking.
+	OpSharpen = "sharpen"
+	// OpWatermark overlays a watermark image.
+	OpWatermark = "watermark"
+)
+
+// Operation is a single step of a pipeline.
+type Operation struct {
+	// Op is the operation name: resize, carve, crop, sharpen or watermark.
+	Op string `yaml:"op" json:"op"`
+	// Width and Height are the new image size for the resize and carve operations
+	// (a zero value preserves the aspect ratio) and the size of the cropped area.
+	Width  int `yaml:"width,omitempty" json:"width,omitempty"`
+	Height int `yaml:"height,omitempty" json:"height,omitempty"`
+	// X and Y are the top left corner of the cropped area.
+	X int `yaml:"x,omitempty" json:"x,omitempty"`
+	Y int `yaml:"y,omitempty" json:"y,omitempty"`
+	// BlurRadius, SobelThreshold and FaceDetect are the seam carving options.
+	BlurRadius     int  `yaml:"blur,omitempty" json:"blur,omitempty"`
+	SobelThreshold int  `yaml:"sobel,omitempty" json:"sobel,omitempty"`
+	FaceDetect     bool `yaml:"face,omitempty" json:"face,omitempty"`
+	// Sigma is the strength of the sharpening.
+	Sigma float64 `yaml:"sigma,omitempty" json:"sigma,omitempty"`
+	// Watermark describes the overlaid image of the watermark operation.
+	Watermark *Watermark `yaml:"watermark,omitempty" json:"watermark,omitempty"`
+}
+
+// Pipeline is an ordered list of operations executed on each image in one pass.
+// Since the seam carving relies on package level state, the pipelines should not run concurrently.
+type Pipeline struct {
+	Steps []Operation `yaml:"steps" json:"steps"`
+
+	detector *pigo.Pigo
+}
+
+// ParsePipeline parses the pipeline specification. Since YAML is a superset of JSON,
+// the specification can be provided in either of the two formats:
+//
+//	steps:
+//	  - op: carve
+//	    width: 800
+//	  - op: sharpen
+//	    sigma: 0.8
+func ParsePipeline(data []byte) (*Pipeline, error) {
+	pl := new(Pipeline)
+	if err := yaml.Unmarshal(data, pl); err != nil {
+		return nil, fmt.Errorf("invalid pipeline specification: %v", err)
+	}
+	if len(pl.Steps) == 0 {
+		return nil, errors.New("the pipeline has no steps")
+	}
+	for i, op := range pl.Steps {
+		if err := op.validate(); err != nil {
+			return nil, fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return pl, nil
+}
+
+// validate checks the operation parameters.
+func (op Operation) validate() error {
+	switch op.Op {
+	case OpResize, OpCarve:
+		if op.Width < 0 || op.Height < 0 || op.Width == 0 && op.Height == 0 {
+			return fmt.Errorf("the %s operation requires a positive width or height", op.Op)
+		}
+	case OpCrop:
+		if op.Width <= 0 || op.Height <= 0 {
+			return errors.New("the crop operation requires a positive width and height")
+		}
+	case OpSharpen:
+		if op.Sigma <= 0 {
+			return errors.New("the sharpen operation requires a positive sigma")
+		}
+	case OpWatermark:
+		if op.Watermark == nil || op.Watermark.Path == "" {
+			return errors.New("the watermark operation requires the watermark path")
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// Run executes the pipeline steps on the image.
+func (pl *Pipeline) Run(ctx context.Context, src image.Image) (*image.NRGBA, error) {
+	img := imaging.Clone(src)
+
+	for i, op := range pl.Steps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res, err := pl.apply(ctx, op, img)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, op.Op, err)
+		}
+		img = res
+	}
+	return img, nil
+}
+
+// apply executes a single operation.
+func (pl *Pipeline) apply(ctx context.Context, op Operation, img *image.NRGBA) (*image.NRGBA, error) {
+	switch op.Op {
+	case OpResize:
+		return imaging.Resize(img, op.Width, op.Height, imaging.Lanczos), nil
+	case OpCarve:
+		return pl.carve(ctx, op, img)
+	case OpCrop:
+		rect := image.Rect(op.X, op.Y, op.X+op.Width, op.Y+op.Height)
+		if !rect.In(img.Bounds()) {
+			return nil, fmt.Errorf("the cropped area %v is outside of the image", rect)
+		}
+		return imaging.Crop(img, rect), nil
+	case OpSharpen:
+		return imaging.Sharpen(img, op.Sigma), nil
+	case OpWatermark:
+		logo, err := op.Watermark.load()
+		if err != nil {
+			return nil, err
+		}
+		if err := op.Watermark.apply(img, logo); err != nil {
+			return nil, err
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// carve resizes the image with the seam carving algorithm.
+func (pl *Pipeline) carve(ctx context.Context, op Operation, img *image.NRGBA) (*image.NRGBA, error) {
+	p := &Processor{
+		NewWidth:       op.Width,
+		NewHeight:      op.Height,
+		BlurRadius:     op.BlurRadius,
+		SobelThreshold: op.SobelThreshold,
+		FaceDetect:     op.FaceDetect,
+		ctx:            ctx,
+	}
+	if p.FaceDetect {
+		// The classifier is unpacked only once for all the images.
+		if pl.detector == nil {
+			det, err := p.unpackCascade()
+			if err != nil {
+				return nil, err
+			}
+			pl.detector = det
+		}
+		p.FaceDetector = pl.detector
+	}
+
+	defer lockCarving()()
+	isGif, resizeXY = false, p.NewWidth != 0 && p.NewHeight != 0
+	res, err := p.resizeLocked(img)
+	if err != nil {
+		return nil, err
+	}
+	return p.imgToNRGBA(res), nil
+}
+
+// prescaleSize returns the size of the leading resize step. The JPEG images are decoded directly at
+// a reduced scale in this case, instead of downscaling the full resolution image afterwards.
+func (pl *Pipeline) prescaleSize() image.Point {
+	if len(pl.Steps) == 0 || pl.Steps[0].Op != OpResize {
+		return image.Point{}
+	}
+	return image.Pt(pl.Steps[0].Width, pl.Steps[0].Height)
+}
+
+// Process decodes the image from r, runs the pipeline and encodes the result into w.
+// An empty format means the output format is the same as the input one.
+// The JPEG images, whose first step is a resize, are downscaled while decoding them.
+func (pl *Pipeline) Process(ctx context.Context, r io.Reader, w io.Writer, format string) error {
+	src, inFormat, err := decodeWith(r, "", decodeOptions{maxPixels: DefaultMaxPixels, size: pl.prescaleSize()})
+	if err != nil {
+		return err
+	}
+	img, err := pl.Run(ctx, src)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = inFormat
+	}
+	return encodeImage(w, img, format)
+}
@@ -0,0 +1,80 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline_ShouldParseTheSpecification(t *testing.T) {
+	assert := assert.New(t)
+
+	pl, err := ParsePipeline([]byte(`
+steps:
+  - op: carve
+    width: 32
+    sobel: 4
+  - op: crop
+    x: 2
+    y: 2
+    width: 20
+    height: 20
+  - op: watermark
+    watermark:
+      path: logo.png
+      anchor: top-left
+`))
+	assert.NoError(err)
+	assert.Len(pl.Steps, 3)
+	assert.Equal(Operation{Op: OpCarve, Width: 32, SobelThreshold: 4}, pl.Steps[0])
+	assert.Equal(Operation{Op: OpCrop, X: 2, Y: 2, Width: 20, Height: 20}, pl.Steps[1])
+	assert.Equal(&Watermark{Path: "logo.png", Anchor: "top-left"}, pl.Steps[2].Watermark)
+
+	pl, err = ParsePipeline([]byte(`{"steps": [{"op": "sharpen", "sigma": 0.8}]}`))
+	assert.NoError(err)
+	assert.Equal(0.8, pl.Steps[0].Sigma)
+
+	for _, spec := range []string{
+		`steps: []`,
+		`steps: [{op: rotate}]`,
+		`steps: [{op: resize}]`,
+		`steps: [{op: crop, width: 10}]`,
+		`steps: [{op: sharpen}]`,
+		`steps: [{op: watermark}]`,
+		`steps: {`,
+	} {
+		_, err := ParsePipeline([]byte(spec))
+		assert.Error(err, spec)
+	}
+}
+
+func TestPipeline_ShouldRunTheSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+
+	pl := &Pipeline{Steps: []Operation{
+		{Op: OpCarve, Width: 32, SobelThreshold: 4},
+		{Op: OpResize, Height: 60},
+		{Op: OpCrop, X: 4, Y: 4, Width: 50, Height: 40},
+		{Op: OpSharpen, Sigma: 0.5},
+	}}
+	res, err := pl.Run(context.Background(), img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 40), res.Bounds())
+
+	var src, dst bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+	assert.NoError(pl.Process(context.Background(), &src, &dst, ""))
+	out, err := png.Decode(&dst)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 40), out.Bounds())
+
+	pl.Steps = append(pl.Steps, Operation{Op: OpCrop, X: 40, Y: 0, Width: 20, Height: 20})
+	_, err = pl.Run(context.Background(), img)
+	assert.ErrorContains(err, "step 5 (crop)")
+}
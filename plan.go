@@ -0,0 +1,159 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"io"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+// Plan holds the analysis of a source image shared by the renders of multiple target sizes:
+// the decoded image, the masks, the face detection results and the energy maps of the first
+// carving step, computed for both resizing directions. The analysis is immutable once the
+// plan is created, so the renders can be requested from multiple goroutines, although the
+// carving itself is serialized by the package level lock.
+type Plan struct {
+	proc  *Processor
+	img   *image.NRGBA
+	cache *LRUCache
+	// faces holds the detection results of the intermediate images in memory. It's accessed
+	// only while the carving lock is held, which serializes the renders anyway.
+	faces *faceCache
+}
+
+// Plan decodes the image and performs the analysis shared by the renders of different sizes,
+// which are produced with the To method of the returned plan. The options of the processor
+// are copied into the plan, so the processor can be reused afterwards.
+func (p *Processor) Plan(r io.Reader) (*Plan, error) {
+	inFormat, err := ParseFormat(p.InputFormat)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := decodeWith(r, inFormat, decodeOptions{maxPixels: p.maxPixels(), maxMem: p.MaxDecodeMem})
+	if err != nil {
+		return nil, err
+	}
+	return p.PlanImage(src)
+}
+
+// PlanImage is like Plan, but it analyzes an already decoded image.
+func (p *Processor) PlanImage(src image.Image) (*Plan, error) {
+	proc := p.Clone()
+	// The renders are returned to the caller, they are not shown in the preview window.
+	proc.Preview = false
+
+	img := proc.orient(proc.imgToNRGBA(src))
+	if err := proc.preflight(img); err != nil {
+		return nil, err
+	}
+	if err := proc.validateMaskFit(); err != nil {
+		return nil, err
+	}
+	if err := proc.validateColorKeys(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if (proc.FaceDetect || proc.BlurFaces) && proc.FaceDetector == nil {
+		if proc.FaceDetector, err = proc.unpackCascade(); err != nil {
+			return nil, err
+		}
+	}
+	if len(proc.MaskPath) > 0 {
+		if proc.Mask, err = proc.loadMask(proc.MaskPath, proc.ProtectColor); err != nil {
+			return nil, err
+		}
+		if proc.Mask, err = proc.fitMask("mask", proc.Mask, img.Bounds().Size()); err != nil {
+			return nil, err
+		}
+	}
+	if len(proc.RMaskPath) > 0 {
+		if proc.RMask, err = proc.loadMask(proc.RMaskPath, proc.RemoveColor); err != nil {
+			return nil, err
+		}
+		if proc.RMask, err = proc.fitMask("rmask", proc.RMask, img.Bounds().Size()); err != nil {
+			return nil, err
+		}
+	}
+	if len(proc.WeightMaskPath) > 0 {
+		if proc.WeightMask, err = proc.decodeMask(proc.WeightMaskPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(proc.ReferencePath) > 0 {
+		if proc.Reference, err = proc.decodeMask(proc.ReferencePath); err != nil {
+			return nil, err
+		}
+	}
+
+	// The plan holds the sobel image and the blurred energy map of both directions.
+	size := int64(img.Bounds().Dx()) * int64(img.Bounds().Dy()) * 4
+	pl := &Plan{
+		proc:  proc,
+		img:   img,
+		cache: NewLRUCache(4 * size),
+		faces: &faceCache{steps: make(map[string][]pigo.Detection)},
+	}
+	if err := pl.analyze(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// analyze computes the energy maps of the first carving step and detects the faces on the source
+// image, for both the horizontal and the vertical resizing. The results are stored in the caches
+// of the plan, where the renders are looking them up.
+func (pl *Plan) analyze() error {
+	p := pl.proc.Clone()
+	defer lockCarving()()
+
+	// The analysis is not affected by the seams table used for enlargement.
+	energySeams = energySeams[:0]
+	p.NewWidth, p.NewHeight = 0, 0
+	p.Cache, p.faceCache = pl.cache, pl.faces
+	p.GuiDebug = image.NewNRGBA(pl.img.Bounds())
+
+	var err error
+	if p.backend, err = newBackend(p.Backend); err != nil {
+		return err
+	}
+	hash := imageHash(pl.img, 0)
+	for _, vRes := range []bool{false, true} {
+		img := pl.img
+		if vRes {
+			img = rotate(img, true)
+		}
+		c := NewCarver(img.Bounds().Dx(), img.Bounds().Dy())
+		p.vRes, p.energyHash = vRes, hash
+		if _, err := c.ComputeSeams(p, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Image returns the decoded source image of the plan. It should not be modified.
+func (pl *Plan) Image() *image.NRGBA {
+	return pl.img
+}
+
+// To resizes the source image of the plan to the provided size, reusing the shared analysis.
+// A zero width or height preserves the corresponding side, like the NewWidth and NewHeight
+// options of the processor. It's safe to call from multiple goroutines, but the renders are
+// carved one at a time, since the seam carver relies on package level state.
+func (pl *Plan) To(width, height int) (image.Image, error) {
+	if width == 0 && height == 0 {
+		return nil, errors.New("please provide the new width or the new height of the image")
+	}
+	p := pl.proc.Clone()
+	p.NewWidth, p.NewHeight = width, height
+	p.Cache = pl.cache
+	p.GuiDebug = image.NewNRGBA(pl.img.Bounds())
+
+	defer lockCarving()()
+	p.faceCache = pl.faces
+	isGif, resizeXY = false, width != 0 && height != 0
+
+	return p.resizeLocked(pl.img)
+}
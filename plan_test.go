@@ -0,0 +1,93 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan_ShouldRenderConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	tmpl := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 10}
+	plan, err := tmpl.Plan(bytes.NewReader(src.Bytes()))
+	assert.NoError(err)
+	// The energy maps of both directions are computed upfront.
+	assert.Equal(4, plan.cache.Len())
+	assert.Equal(10, tmpl.NewWidth)
+
+	sizes := []image.Point{{30, 0}, {0, 20}, {44, 0}, {32, 26}}
+	results := make([]image.Image, len(sizes))
+	errs := make([]error, len(sizes))
+
+	var wg sync.WaitGroup
+	for i, size := range sizes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = plan.To(size.X, size.Y)
+		}()
+	}
+	wg.Wait()
+
+	for i, size := range sizes {
+		assert.NoError(errs[i])
+
+		// The result is the same as the processing without the plan.
+		p := tmpl.Clone()
+		p.NewWidth, p.NewHeight = size.X, size.Y
+		var out bytes.Buffer
+		p.OutputFormat = FormatPNG
+		assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+		want, err := png.Decode(&out)
+		assert.NoError(err)
+		assert.Equal(want.Bounds(), results[i].Bounds())
+		assert.Equal(p.imgToNRGBA(want).Pix, p.imgToNRGBA(results[i]).Pix)
+	}
+
+	_, err = plan.To(0, 0)
+	assert.Error(err)
+}
+
+func TestPlan_ShouldLoadTheReferenceImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	// The subject was on the left side of the reference frame.
+	ref := texturedImage(40, 30)
+	fillGray(ref, image.Rect(5, 5, 15, 25), 240)
+	path := filepath.Join(t.TempDir(), "ref.png")
+	f, err := os.Create(path)
+	assert.NoError(err)
+	assert.NoError(png.Encode(f, ref))
+	assert.NoError(f.Close())
+
+	tmpl := &Processor{SobelThreshold: 2, BlurRadius: 1, ReferencePath: path}
+	plan, err := tmpl.Plan(bytes.NewReader(src.Bytes()))
+	assert.NoError(err)
+	assert.NotNil(plan.proc.Reference)
+	res, err := plan.To(30, 0)
+	assert.NoError(err)
+
+	p := tmpl.Clone()
+	p.NewWidth = 30
+	p.OutputFormat = FormatPNG
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(src.Bytes()), &out))
+	want, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(p.imgToNRGBA(want).Pix, p.imgToNRGBA(res).Pix)
+}
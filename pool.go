@@ -0,0 +1,148 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+// ErrPoolClosed is returned for the jobs submitted to a closed pool.
+var ErrPoolClosed = errors.New("the processor pool is closed")
+
+// processMu serializes the carving operations of the processors,
+// since the seam carver relies on package level state.
+var processMu sync.Mutex
+
+// Job is a resizing operation submitted to a processor pool.
+type Job struct {
+	// Ctx is used for aborting the job. The background context is used if it's nil.
+	Ctx context.Context
+	Src io.Reader
+	Dst io.Writer
+	// Configure can be used for overriding the options of the pool (ex. the new size) for this job only.
+	Configure func(*Processor)
+}
+
+type poolJob struct {
+	Job
+	res chan error
+}
+
+// Pool is a set of pre-warmed workers processing the submitted jobs. The face classifier
+// is unpacked only once and each worker reuses its input and output buffers between jobs.
+// The reading of the source and the writing of the result are running concurrently,
+// while the carving itself is serialized, since the seam carver relies on package level state:
+// the workers take the same package level lock as all the other processors (see Clone), so
+// only one image is carved at a time in the process, whatever the number of workers.
+type Pool struct {
+	opts     Processor
+	detector *pigo.Pigo
+	jobs     chan poolJob
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool starts a pool of n workers using the provided processor options.
+func NewPool(n int, opts Processor) (*Pool, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of pool workers: %d", n)
+	}
+	pool := &Pool{
+		opts: opts,
+		jobs: make(chan poolJob),
+	}
+
+	if opts.FaceDetect || opts.BlurFaces {
+		det, err := opts.unpackCascade()
+		if err != nil {
+			return nil, err
+		}
+		pool.detector = det
+	}
+
+	pool.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go pool.work()
+	}
+	return pool, nil
+}
+
+// Submit queues the job and returns a channel which receives the outcome of the job.
+func (pool *Pool) Submit(job Job) <-chan error {
+	res := make(chan error, 1)
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.closed {
+		res <- ErrPoolClosed
+		return res
+	}
+	pool.jobs <- poolJob{Job: job, res: res}
+	return res
+}
+
+// Close waits for the running jobs to complete and stops the workers.
+func (pool *Pool) Close() {
+	pool.mu.Lock()
+	if !pool.closed {
+		pool.closed = true
+		close(pool.jobs)
+	}
+	pool.mu.Unlock()
+
+	pool.wg.Wait()
+}
+
+// work processes the jobs received from the queue.
+func (pool *Pool) work() {
+	defer pool.wg.Done()
+
+	var in, out bytes.Buffer
+	for job := range pool.jobs {
+		job.res <- pool.process(job.Job, &in, &out)
+	}
+}
+
+// process runs a single job using the worker buffers.
+func (pool *Pool) process(job Job, in, out *bytes.Buffer) error {
+	ctx := job.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	in.Reset()
+	out.Reset()
+	if _, err := io.Copy(in, job.Src); err != nil {
+		return fmt.Errorf("unable to read the source image: %v", err)
+	}
+
+	// Each job gets a fresh copy of the options, and Process resets the package level state,
+	// so no state is leaking between the jobs.
+	proc := pool.opts.Clone()
+	proc.Preview = false
+	proc.FaceDetector = pool.detector
+	if job.Configure != nil {
+		job.Configure(proc)
+	}
+	// The output format is derived from the destination, since the result is encoded into a buffer.
+	format, err := proc.outputFormat(job.Dst)
+	if err != nil {
+		return err
+	}
+	proc.OutputFormat = format
+
+	if err := proc.ProcessContext(ctx, bytes.NewReader(in.Bytes()), out); err != nil {
+		return err
+	}
+
+	_, err = out.WriteTo(job.Dst)
+	return err
+}
@@ -0,0 +1,52 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_ShouldProcessTheSubmittedJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(40, 30)
+	var src bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	pool, err := NewPool(2, Processor{
+		SobelThreshold: 2,
+		BlurRadius:     1,
+		NewWidth:       35,
+		OutputFormat:   FormatPNG,
+	})
+	assert.NoError(err)
+
+	outputs := make([]bytes.Buffer, 4)
+	results := make([]<-chan error, len(outputs))
+	for i := range outputs {
+		width := 30 + i
+		results[i] = pool.Submit(Job{
+			Src: bytes.NewReader(src.Bytes()),
+			Dst: &outputs[i],
+			Configure: func(p *Processor) {
+				p.NewWidth = width
+			},
+		})
+	}
+
+	for i, res := range results {
+		assert.NoError(<-res)
+		out, err := png.Decode(&outputs[i])
+		assert.NoError(err)
+		assert.Equal(image.Rect(0, 0, 30+i, 30), out.Bounds())
+	}
+
+	pool.Close()
+	assert.ErrorIs(<-pool.Submit(Job{Src: &src, Dst: &bytes.Buffer{}}), ErrPoolClosed)
+
+	_, err = NewPool(0, Processor{})
+	assert.Error(err)
+}
@@ -0,0 +1,148 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/filters"
+	"github.com/esimov/caire/utils"
+)
+
+// maxPreflightSize is the maximum size of the image used by the pre-flight analysis.
+const maxPreflightSize = 256
+
+// The pre-flight warning codes.
+const (
+	WarnUniform = "uniform"
+	WarnText    = "text"
+	WarnNoise   = "noise"
+)
+
+// Warning describes an input on which the seam carving is expected to perform poorly.
+type Warning struct {
+	// Code identifies the warning type: "uniform", "text", "noise" or "similarity".
+	Code string
+	// Message is the human readable description of the problem.
+	Message string
+	// Score is the metric which has triggered the warning.
+	Score float64
+}
+
+// PreflightError is returned in strict mode if the pre-flight analysis has raised any warning.
+type PreflightError struct {
+	Warnings []Warning
+}
+
+func (e *PreflightError) Error() string {
+	codes := make([]string, len(e.Warnings))
+	for i, w := range e.Warnings {
+		codes[i] = w.Code
+	}
+	return fmt.Sprintf("the image is not suitable for seam carving (%s), use a plain scaler instead",
+		strings.Join(codes, ", "))
+}
+
+// Warnings returns the warnings raised by the pre-flight analysis and the similarity check
+// of the last processed image.
+func (p *Processor) Warnings() []Warning {
+	return p.warnings
+}
+
+// Analyze runs a cheap analysis over a downscaled copy of the image and reports the cases,
+// where the seam carving is expected to perform poorly: the uniform images, which can be
+// resized with a plain scaler without any loss, the text heavy screenshots, where each
+// seam cuts through the glyphs and the images dominated by noise.
+func Analyze(img *image.NRGBA) []Warning {
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	if dx < 3 || dy < 3 {
+		return nil
+	}
+	scale := math.Min(1, float64(maxPreflightSize)/float64(utils.Max(dx, dy)))
+	if scale < 1 {
+		img = imaging.Resize(img, int(math.Round(float64(dx)*scale)), int(math.Round(float64(dy)*scale)), imaging.Box)
+		dx, dy = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	gray := make([]int, dx*dy)
+	for y := 0; y < dy; y++ {
+		for x := 0; x < dx; x++ {
+			i := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+			gray[y*dx+x] = int(filters.Luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2]))
+		}
+	}
+
+	var (
+		flat, edges int
+		residual    float64
+		hist        [32]int
+	)
+	for y := 1; y < dy-1; y++ {
+		for x := 1; x < dx-1; x++ {
+			i := y*dx + x
+			v := gray[i]
+			gx := utils.Abs(gray[i+1] - gray[i-1])
+			gy := utils.Abs(gray[i+dx] - gray[i-dx])
+
+			switch grad := gx + gy; {
+			case grad < 4:
+				flat++
+			case grad > 64:
+				edges++
+			}
+			// The difference from the mean of the neighbors is high for the isolated pixels.
+			mean := (gray[i-1] + gray[i+1] + gray[i-dx] + gray[i+dx]) / 4
+			residual += float64(utils.Abs(v - mean))
+			hist[v>>3]++
+		}
+	}
+	total := float64((dx - 2) * (dy - 2))
+	flatRatio := float64(flat) / total
+	edgeRatio := float64(edges) / total
+	residual /= total
+
+	// The ratio of the pixels belonging to the two dominant luminance levels.
+	bins := hist[:]
+	sort.Sort(sort.Reverse(sort.IntSlice(bins)))
+	dominance := float64(bins[0]+bins[1]) / total
+
+	var warnings []Warning
+	switch {
+	case flatRatio > 0.95:
+		warnings = append(warnings, Warning{
+			Code:    WarnUniform,
+			Message: "the image is nearly uniform, the result would be identical with a plain scaler",
+			Score:   flatRatio,
+		})
+	case dominance > 0.85 && edgeRatio > 0.03:
+		warnings = append(warnings, Warning{
+			Code:    WarnText,
+			Message: "the image looks like a text heavy screenshot, the seams would cut through the glyphs",
+			Score:   dominance,
+		})
+	}
+	if residual > 24 && edgeRatio > 0.4 {
+		warnings = append(warnings, Warning{
+			Code:    WarnNoise,
+			Message: "the image is dominated by noise, the energy map is not reliable",
+			Score:   residual,
+		})
+	}
+	return warnings
+}
+
+// preflight runs the pre-flight analysis when requested and fails in strict mode if any warning is raised.
+func (p *Processor) preflight(img *image.NRGBA) error {
+	p.warnings = nil
+	if !p.Preflight && !p.Strict {
+		return nil
+	}
+	p.warnings = Analyze(img)
+	if p.Strict && len(p.warnings) > 0 {
+		return &PreflightError{Warnings: p.warnings}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package caire
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight_ShouldDetectThePathologicalInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	uniform := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	text := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	noise := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+
+	rnd := rand.New(rand.NewSource(1))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 200; y++ {
+			uniform.Set(x, y, color.NRGBA{R: 200, G: 210, B: 220, A: 255})
+
+			// Dark "glyphs" on rows of text over a white background.
+			c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if y%20 < 10 && (x%7 == 0 || (x%7 < 4 && y%20 == 5)) {
+				c = color.NRGBA{A: 255}
+			}
+			text.Set(x, y, c)
+
+			v := uint8(rnd.Intn(256))
+			noise.Set(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	codes := func(warnings []Warning) []string {
+		var res []string
+		for _, w := range warnings {
+			res = append(res, w.Code)
+		}
+		return res
+	}
+	assert.Equal([]string{WarnUniform}, codes(Analyze(uniform)))
+	assert.Equal([]string{WarnText}, codes(Analyze(text)))
+	assert.Equal([]string{WarnNoise}, codes(Analyze(noise)))
+
+	f, err := os.Open(filepath.Join("./testdata", "sample.jpg"))
+	assert.NoError(err)
+	defer f.Close()
+
+	src, _, err := image.Decode(f)
+	assert.NoError(err)
+	proc := &Processor{}
+	assert.Empty(Analyze(proc.imgToNRGBA(src)))
+}
+
+func TestPreflight_ShouldAbortInStrictMode(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, img))
+
+	proc := &Processor{NewWidth: 50, Strict: true, OutputFormat: FormatPNG}
+	err := proc.Process(&buf, &bytes.Buffer{})
+
+	var perr *PreflightError
+	assert.True(errors.As(err, &perr))
+	assert.Equal(WarnUniform, perr.Warnings[0].Code)
+	assert.Equal(perr.Warnings, proc.Warnings())
+}
@@ -0,0 +1,73 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"sync"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+// warmupSize is the size of the synthetic image used for warming up the energy computation.
+const warmupSize = 64
+
+// cascadeCache holds the last unpacked face detection cascade. The classifier is read only
+// once unpacked, so it can be shared by the processors using the same cascade data.
+var cascadeCache struct {
+	sync.Mutex
+	data []byte
+	det  *pigo.Pigo
+}
+
+// cachedCascade returns the classifier unpacked from the provided data, unpacking it only once.
+func cachedCascade(data []byte, unpack func([]byte) (*pigo.Pigo, error)) (*pigo.Pigo, error) {
+	cascadeCache.Lock()
+	defer cascadeCache.Unlock()
+
+	if cascadeCache.det != nil && bytes.Equal(cascadeCache.data, data) {
+		return cascadeCache.det, nil
+	}
+	det, err := unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	cascadeCache.data, cascadeCache.det = data, det
+	return det, nil
+}
+
+// Preload prepares the resources used by the processors, so the latency sensitive services
+// can pay the initialization cost at startup instead of on the first request. It unpacks the
+// face detection cascade (which is then shared by all the processors) and runs the energy
+// computation of the provided backends over a small synthetic image, which compiles the GPU
+// kernels in case of the OpenCL backend. If no backend is provided, the CPU backend is warmed up.
+func Preload(backends ...Backend) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+
+	det, err := (&Processor{}).unpackCascade()
+	if err != nil {
+		return err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, warmupSize, warmupSize))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+
+	if len(backends) == 0 {
+		backends = []Backend{BackendCPU}
+	}
+	for _, b := range backends {
+		backend, err := newBackend(b)
+		if err != nil {
+			return err
+		}
+		c := NewCarver(warmupSize, warmupSize)
+		backend.grayscale(c, img)
+		backend.blur(c, backend.sobel(c, img, 2), 1)
+		backend.accumulate(c)
+	}
+
+	(&Processor{FaceDetector: det}).detectFaces(NewCarver(warmupSize, warmupSize), img)
+	return nil
+}
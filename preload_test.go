@@ -0,0 +1,21 @@
+package caire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreload_ShouldShareTheCascade(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(Preload())
+	det1, err := (&Processor{}).unpackCascade()
+	assert.NoError(err)
+	det2, err := (&Processor{}).unpackCascade()
+	assert.NoError(err)
+	assert.Same(det1, det2)
+
+	_, err = newBackend(BackendOpenCL)
+	assert.Equal(err, Preload(BackendOpenCL))
+}
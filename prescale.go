@@ -0,0 +1,32 @@
+package caire
+
+import (
+	"image"
+
+	"github.com/esimov/caire/jpegdec"
+)
+
+// prescale returns the DCT scaling denominator used for decoding a JPEG image of the provided
+// dimensions, which is resized to size right after decoding: the largest one keeping the decoded
+// image at least as large as the size, so the resampling never enlarges it. A zero width or height
+// of the size preserves the aspect ratio. It returns 1 if the image should be decoded at full size.
+func prescale(width, height int, size image.Point) int {
+	if width <= 0 || height <= 0 || size.X < 0 || size.Y < 0 || size == (image.Point{}) {
+		return 1
+	}
+	tw, th := size.X, size.Y
+	if tw == 0 {
+		tw = width * th / height
+	}
+	if th == 0 {
+		th = height * tw / width
+	}
+
+	scale := 1
+	for _, s := range jpegdec.Scales {
+		if (width+s-1)/s >= tw && (height+s-1)/s >= th {
+			scale = s
+		}
+	}
+	return scale
+}
@@ -0,0 +1,77 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrescale_ShouldKeepTheImageLargerThanTheSize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(1, prescale(4000, 3000, image.Point{}))
+	assert.Equal(4, prescale(4000, 3000, image.Pt(800, 0)))
+	assert.Equal(4, prescale(4000, 3000, image.Pt(0, 600)))
+	assert.Equal(2, prescale(4000, 3000, image.Pt(800, 800)))
+	assert.Equal(8, prescale(4000, 3000, image.Pt(400, 300)))
+	assert.Equal(8, prescale(4000, 3000, image.Pt(100, 0)))
+	assert.Equal(1, prescale(4000, 3000, image.Pt(2001, 0)))
+	// The rounded up scaled size still covers the size.
+	assert.Equal(2, prescale(101, 67, image.Pt(51, 0)))
+}
+
+func TestPrescale_ShouldDecodeTheJPEGScaledInPipelines(t *testing.T) {
+	assert := assert.New(t)
+
+	var src bytes.Buffer
+	assert.NoError(jpeg.Encode(&src, image.NewNRGBA(image.Rect(0, 0, 200, 160)), nil))
+
+	pl := &Pipeline{Steps: []Operation{{Op: OpResize, Width: 40}}}
+	img, _, err := decodeWith(bytes.NewReader(src.Bytes()), "", decodeOptions{size: pl.prescaleSize()})
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 40), img.Bounds())
+
+	var dst bytes.Buffer
+	assert.NoError(pl.Process(context.Background(), bytes.NewReader(src.Bytes()), &dst, FormatPNG))
+	out, err := png.Decode(&dst)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 40, 32), out.Bounds())
+
+	// The pipelines starting with other steps decode the image at full size.
+	pl.Steps = []Operation{{Op: OpSharpen, Sigma: 0.5}, {Op: OpResize, Width: 40}}
+	assert.Equal(image.Point{}, pl.prescaleSize())
+}
+
+func Benchmark_Prescale(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("./testdata", "sample.jpg"))
+	if err != nil {
+		b.Fatalf("could not load sample image: %v", err)
+	}
+	pl := &Pipeline{Steps: []Operation{{Op: OpResize, Width: 100}}}
+
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			img, _, err := decode(bytes.NewReader(data), "", DefaultMaxPixels)
+			if err != nil {
+				b.Fatal(err)
+			}
+			pl.Run(context.Background(), img)
+		}
+	})
+	b.Run("dct", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			img, _, err := decodeWith(bytes.NewReader(data), "", decodeOptions{size: pl.prescaleSize()})
+			if err != nil {
+				b.Fatal(err)
+			}
+			pl.Run(context.Background(), img)
+		}
+	})
+}
@@ -1,9 +1,14 @@
+//go:build !headless
+
 package caire
 
 import (
 	"os"
 )
 
+// previewAvailable reports whether the preview window is supported by the build.
+const previewAvailable = true
+
 // showPreview spawns a new Gio GUI window and updates its content with the resized image received from a channel.
 func (p *Processor) showPreview(
 	imgWorker <-chan worker,
@@ -22,8 +27,13 @@ func (p *Processor) showPreview(
 		if err := gui.Run(); err != nil {
 			errChan <- err
 		}
+		// In case the process has been aborted by the user the carving goroutine is canceled
+		// and the caller terminates the execution with a non-zero exit status.
+		if gui.proc.isAborted {
+			return
+		}
 		// It's important to call os.Exit(0) in order to terminate
-		// the execution of the GUI app when pressing ESC key.
+		// the execution of the GUI app when the window is closed.
 		os.Exit(0)
 	}()
 }
@@ -0,0 +1,18 @@
+//go:build headless
+
+package caire
+
+// previewAvailable reports whether the preview window is supported by the build.
+// The headless builds don't depend on Gio, which requires cgo on most platforms.
+const previewAvailable = false
+
+// showPreview is a no-op, since the headless builds can't open the preview window.
+func (p *Processor) showPreview(
+	imgWorker <-chan worker,
+	errChan chan<- error,
+	guiParams struct {
+		width  int
+		height int
+	},
+) {
+}
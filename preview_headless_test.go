@@ -0,0 +1,23 @@
+//go:build headless
+
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadless_ShouldRejectThePreview(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+
+	proc := &Processor{NewWidth: 30, Preview: true}
+	err := proc.Process(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+	assert.ErrorContains(err, "headless")
+}
@@ -0,0 +1,63 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/esimov/caire/utils"
+)
+
+// PreviewCheckerboard is the value of the PreviewBackground option showing a checkerboard
+// around and behind the image in the preview window, which reveals its transparent areas.
+const PreviewCheckerboard = "checkerboard"
+
+// checkerCell is the size of the checkerboard squares in device independent pixels.
+const checkerCell = 8
+
+// checkerColors are the colors of the checkerboard squares.
+var checkerColors = [2]color.NRGBA{
+	{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff},
+	{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+}
+
+// previewBackground is the background of the preview window: a solid color or a checkerboard.
+type previewBackground struct {
+	checker bool
+	color   color.NRGBA
+}
+
+// parsePreviewBackground parses the PreviewBackground option, which is either a hexadecimal
+// color (#rgb, #rrggbb or #rrggbbaa) or "checkerboard". The empty value is transparent.
+func parsePreviewBackground(s string) (previewBackground, error) {
+	if s == "" {
+		return previewBackground{}, nil
+	}
+	if strings.EqualFold(s, PreviewCheckerboard) {
+		return previewBackground{checker: true}, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if n := len(hex); n != 3 && n != 6 && n != 8 {
+		return previewBackground{}, fmt.Errorf("invalid preview background: %q, expected a hex color or %q", s, PreviewCheckerboard)
+	}
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return previewBackground{}, fmt.Errorf("invalid preview background: %q, expected a hex color or %q", s, PreviewCheckerboard)
+	}
+	return previewBackground{color: utils.HexToRGBA(hex)}, nil
+}
+
+// checkerboard returns an opaque checkerboard image of the provided size, with squares of cell pixels.
+func checkerboard(size image.Point, cell int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rectangle{Max: size})
+	cell = max(cell, 1)
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			c := checkerColors[(x/cell+y/cell)%2]
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return img
+}
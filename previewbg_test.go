@@ -0,0 +1,46 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewBackground_ShouldParseTheOption(t *testing.T) {
+	assert := assert.New(t)
+
+	bg, err := parsePreviewBackground("")
+	assert.NoError(err)
+	assert.Equal(previewBackground{}, bg)
+
+	bg, err = parsePreviewBackground("Checkerboard")
+	assert.NoError(err)
+	assert.True(bg.checker)
+
+	bg, err = parsePreviewBackground("#f80")
+	assert.NoError(err)
+	assert.Equal(color.NRGBA{R: 0xff, G: 0x88, A: 0xff}, bg.color)
+
+	bg, err = parsePreviewBackground("10203040")
+	assert.NoError(err)
+	assert.Equal(color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}, bg.color)
+
+	for _, s := range []string{"#12345", "#gg0000", "stripes"} {
+		_, err = parsePreviewBackground(s)
+		assert.ErrorContains(err, "invalid preview background")
+	}
+}
+
+func TestPreviewBackground_ShouldDrawTheCheckerboard(t *testing.T) {
+	assert := assert.New(t)
+
+	img := checkerboard(image.Pt(20, 10), 4)
+	assert.Equal(image.Rect(0, 0, 20, 10), img.Bounds())
+	assert.Equal(checkerColors[0], img.NRGBAAt(0, 0))
+	assert.Equal(checkerColors[0], img.NRGBAAt(3, 3))
+	assert.Equal(checkerColors[1], img.NRGBAAt(4, 0))
+	assert.Equal(checkerColors[1], img.NRGBAAt(0, 4))
+	assert.Equal(checkerColors[0], img.NRGBAAt(4, 4))
+}
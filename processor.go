@@ -1,6 +1,8 @@
 package caire
 
 import (
+	"bufio"
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
@@ -9,23 +11,25 @@ import (
 	"image/color/palette"
 	"image/draw"
 	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"math"
+	"math/rand"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/disintegration/imaging"
+	"github.com/esimov/caire/assets"
 	"github.com/esimov/caire/utils"
 	pigo "github.com/esimov/pigo/core"
-	"golang.org/x/image/bmp"
 )
 
 //go:embed data/facefinder
 var cascadeFile []byte
 
+// minImageSize is the minimum width and height of the images which can be carved.
+const minImageSize = 2
+
 var (
 	g      *gif.GIF
 	rCount int
@@ -71,17 +75,158 @@ type Processor struct {
 	Preview        bool
 	FaceDetect     bool
 	ShapeType      string
+	ShapeSize      float64
 	SeamColor      string
 	MaskPath       string
 	RMaskPath      string
+	WeightMaskPath string
+	WeightMask     *image.NRGBA
+	MaskFit        string
+	ReferencePath  string
+	Reference      *image.NRGBA
+	Screenshot     bool
+	UseExifSubject bool
 	Mask           *image.NRGBA
 	RMask          *image.NRGBA
 	GuiDebug       *image.NRGBA
 	FaceAngle      float64
 	FaceDetector   *pigo.Pigo
 	Spinner        *utils.Spinner
-
-	vRes bool
+	KeepPalette    bool
+	PaletteDither  bool
+	PNG8           bool
+	Backend        Backend
+	InputFormat    string
+	OutputFormat   string
+	Background     color.NRGBA
+	ChannelWeights [3]float64
+	SeamsSVGPath   string
+	RemovedPath    string
+	EnergyCSVPath  string
+	GhostPath      string
+	HeatmapPath    string
+	DisplaceMap    string
+	TrackCoords    bool
+	Grid           *Grid
+	Tileable       bool
+	AutoAxis       bool
+	TargetPixels   int
+	TargetRatio    float64
+	BlurFaces      bool
+	PixelateFaces  bool
+	Watermark      *Watermark
+	Adjustments    Adjustments
+	Rotate         int
+	Flip           string
+	EnergyRules    []EnergyRule
+	Regions        []Region
+	Preflight      bool
+	Strict         bool
+	FaceCacheDir   string
+	MaxPixels      int64
+	MaxDecodeMem   int64
+	FastMode       bool
+	Bandwidth      int
+	ForwardEnergy  bool
+	Quality        string
+	AutoTune       bool
+	CPULimit       float64
+	SeamWorkers    int
+	Timeout        time.Duration
+	PartialOutput  bool
+	Assets         *assets.Loader
+	Jitter         *Jitter
+	Tracer         Tracer
+	WatchMasks     bool
+	Cache          Cache
+
+	// MaxSeamsPerRegion limits the number of seams crossing any band of 64 columns (or rows,
+	// in case of the vertical resizing), which spreads the distortion across the image.
+	MaxSeamsPerRegion int
+	// ColorBlockKeep is the minimum share of their area kept by the large homogeneous color
+	// regions (like the brand color blocks), which would otherwise be carved away entirely.
+	ColorBlockKeep float64
+	// WeightMaskFeather is the radius of the guided filter refining the edges of the weight
+	// mask, so the protection falls off along the image edges instead of the painted ones.
+	WeightMaskFeather int
+	// AutoMethod compares the carving with the plain scaling and cropping on a downscaled copy
+	// of the image, and resizes the image with the method producing the best result.
+	AutoMethod bool
+	// MinReductionPercent skips the carving if the requested size differs from the image size
+	// by less than the percentage in both axes, in which case the image is simply scaled.
+	MinReductionPercent float64
+	// RecordPath is the animated GIF file in which the frames of the preview window are recorded.
+	RecordPath string
+	// PreviewBackground is the background of the preview window around and behind the image:
+	// a hex color or "checkerboard", which reveals the transparent areas of the image.
+	PreviewBackground string
+	// HighContrast shows the preview window with high contrast colors and without the animations.
+	HighContrast bool
+	// DebugSnapshot records the energy maps and the seams of the selected carving steps.
+	DebugSnapshot *DebugSnapshot
+	// JPEGProgressive encodes the JPEG outputs progressively, with optimized Huffman tables.
+	JPEGProgressive bool
+	// JPEGCMYK encodes the JPEG outputs in the CMYK color space, used by the print workflows.
+	// The CMYK outputs are always encoded progressively.
+	JPEGCMYK bool
+	// OnProgress is called after each removed or inserted seam, reporting the advancement
+	// of the carving. It's invoked synchronously, so it should return quickly.
+	OnProgress func(Progress)
+	// SimilarityThreshold is the maximum distance between the perceptual hashes of the source
+	// and the resized image. A warning is raised above it, catching the failed carvings in the
+	// unattended batches. If zero, the similarity is not checked.
+	SimilarityThreshold int
+	// StrictSimilarity fails with a *SimilarityError instead of raising a warning. If no
+	// threshold is provided, the DefaultSimilarityThreshold is used.
+	StrictSimilarity bool
+	// ProtectColor and RemoveColor are the key colors of the protection and the removal masks:
+	// the mask files are copies of the image painted with the key color in any image editor,
+	// the matching pixels being converted to the binary mask. If empty, the masks are expected
+	// to be binary images.
+	ProtectColor string
+	RemoveColor  string
+	// ColorTolerance is the maximum difference of the color channels from the key color,
+	// between 0 and 255, for the pixels still matching it.
+	ColorTolerance int
+	// FixedPoint computes the energy map and searches the seams with the integer arithmetic only,
+	// so the same seams are removed on every platform, at a small performance cost. Otherwise the
+	// floating point results may slightly differ between the architectures (like amd64 and arm64)
+	// and the backends. It doesn't cover the face detection.
+	FixedPoint bool
+
+	vRes         bool
+	palette      color.Palette
+	backend      energyBackend
+	tracker      *coordTracker
+	maskWatch    *maskWatcher
+	rmaskWatch   *maskWatcher
+	removedSeams *seamRecorder
+	energyLog    *energyLogger
+	progress     *progressCounter
+	biasMap      *image.NRGBA
+	bands        *seamBands
+	blocks       *colorBlocks
+	subject      *Region
+	noop         bool
+	axisDecision *AxisDecision
+	tuneDecision *TuneDecision
+	tuning       bool
+	choosing     bool
+	method       *MethodDecision
+	faceMargin   float64
+	onStep       func(*image.NRGBA, SeamInfo) error
+	framesErr    error
+	warnings     []Warning
+	faceCache    *faceCache
+	energyHash   string
+	jitterRand   *rand.Rand
+	partial      *image.NRGBA
+	inPlace      *inPlaceCarver
+	energyTime   *energyTimer
+	deadline     context.Context
+	ctx          context.Context
+	traceCtx     context.Context
+	cancel       context.CancelFunc
 }
 
 var (
@@ -91,16 +236,25 @@ var (
 	enlargeVertFn  enlargeFn
 )
 
-// resize implements the Resize method of the Carver interface.
-// It returns the concrete resize operation method.
-func resize(s SeamCarver, img *image.NRGBA) (image.Image, error) {
-	return s.Resize(img)
-}
-
 // Resize is the main entry point for the image resize operation.
 // The new image can be resized either horizontally or vertically (or both).
 // Depending on the provided options the image can be either reduced or enlarged.
 func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
+	defer lockCarving()()
+	return p.resizeLocked(img)
+}
+
+// resizeLocked resizes the image while the carving lock is held by the caller. The operations
+// nested into a resizing (ex. the cells of a grid, or the retries of the auto tuning) call it directly.
+func (p *Processor) resizeLocked(img *image.NRGBA) (image.Image, error) {
+	// The seams table used for enlargement is dropped on return, so it doesn't
+	// alter the energy map of the following operations.
+	defer func() { energySeams = energySeams[:0] }()
+	defer p.timeEnergy()()
+
+	if p.Timeout > 0 && p.deadline == nil {
+		return p.resizeTimeout(img)
+	}
 	var c = NewCarver(img.Bounds().Dx(), img.Bounds().Dy())
 	var (
 		newImg    image.Image
@@ -109,7 +263,79 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		pw, ph    int
 		err       error
 	)
+	if width, height, ok := p.belowMinReduction(img); ok {
+		return p.scaleBelowMinReduction(img, width, height), nil
+	}
+	// The Gif frames and the grid cells are recorded while carving, so they can't be retried.
+	if p.AutoMethod && !p.choosing && p.Grid == nil && !isGif {
+		return p.chooseMethod(img)
+	}
+	if p.AutoTune && !p.tuning && p.Grid == nil && !isGif {
+		return p.autoTune(img)
+	}
+	if p.AutoAxis {
+		if err := p.detectAxis(img); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rasterize the energy rules, the regions, the weight mask and the motion relative
+	// to the reference image into a bias map, which is carried along with the image.
+	if len(p.EnergyRules) > 0 || len(p.Regions) > 0 || p.WeightMask != nil || p.Reference != nil || p.Screenshot || p.subject != nil {
+		if err := validateRegions(p.Regions); err != nil {
+			return nil, err
+		}
+		regions := p.Regions
+		if p.Screenshot {
+			// The detected UI elements are protected in addition to the user defined regions.
+			regions = append(regions[:len(regions):len(regions)], DetectUIElements(img)...)
+		}
+		if p.subject != nil {
+			regions = append(regions[:len(regions):len(regions)], *p.subject)
+		}
+		p.biasMap = renderBiasMap(p.EnergyRules, regions, img.Bounds())
+		weight, err := p.fitMask("weight mask", p.WeightMask, img.Bounds().Size())
+		if err != nil {
+			return nil, err
+		}
+		weight = featherWeightMask(img, weight, p.WeightMaskFeather)
+		if err := mergeWeightMask(p.biasMap, weight); err != nil {
+			return nil, err
+		}
+		if err := mergeMotion(p.biasMap, img, p.Reference); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := ParseQuality(p.Quality); err != nil {
+		return nil, err
+	}
+
+	p.bands = nil
+	p.blocks = nil
+	p.DebugSnapshot.reset()
+
+	// Each image gets its own random sequence, so the result depends only on the jitter seed.
+	if p.jitterRand, err = newJitterRand(p.Jitter); err != nil {
+		return nil, err
+	}
+
+	if p.Grid != nil {
+		res, err := p.resizeGrid(img)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
 	rCount = 0
+	// The seams table used for enlargement is specific to each image.
+	energySeams = energySeams[:0]
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+
+	if p.backend, err = newBackend(p.Backend); err != nil {
+		return nil, err
+	}
 
 	if p.NewWidth > c.Width {
 		newWidth = p.NewWidth - (p.NewWidth - (p.NewWidth - c.Width))
@@ -209,6 +435,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		if resizeXY {
 			dx, dy = img.Bounds().Dy(), img.Bounds().Dx()
 			img = c.RotateImage90(img)
+			p.rotateBiasMap(c.RotateImage90)
 		}
 		if dx > p.NewHeight {
 			img, err = p.shrink(c, img)
@@ -217,6 +444,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 			}
 			if resizeXY {
 				img = c.RotateImage270(img)
+				p.rotateBiasMap(c.RotateImage270)
 			}
 			if p.NewWidth > 0 && p.NewWidth != dy {
 				if p.NewWidth <= dy {
@@ -239,6 +467,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		} else {
 			if resizeXY {
 				img = c.RotateImage270(img)
+				p.rotateBiasMap(c.RotateImage270)
 			}
 		}
 		rCount++
@@ -253,6 +482,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		if resizeXY {
 			dx, dy = img.Bounds().Dy(), img.Bounds().Dx()
 			img = c.RotateImage90(img)
+			p.rotateBiasMap(c.RotateImage90)
 		}
 		if dx < p.NewHeight {
 			img, err = p.enlarge(c, img)
@@ -261,6 +491,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 			}
 			if resizeXY {
 				img = c.RotateImage270(img)
+				p.rotateBiasMap(c.RotateImage270)
 			}
 			if p.NewWidth > 0 && p.NewWidth != dy {
 				if p.NewWidth <= dy {
@@ -283,6 +514,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		} else {
 			if resizeXY {
 				img = c.RotateImage270(img)
+				p.rotateBiasMap(c.RotateImage270)
 			}
 		}
 		rCount++
@@ -345,6 +577,14 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		}
 	}
 
+	// The seam carver needs at least two pixels on both axes, otherwise there is no seam to be found.
+	if c.Width < minImageSize || c.Height < minImageSize {
+		return nil, fmt.Errorf("the image is too small to be resized: %dx%d", c.Width, c.Height)
+	}
+	if (p.NewWidth != 0 && p.NewWidth < minImageSize) || (p.NewHeight != 0 && p.NewHeight < minImageSize) {
+		return nil, fmt.Errorf("the new image size should be at least %dx%d pixels", minImageSize, minImageSize)
+	}
+
 	// Rescale the image when it is resized both horizontally and vertically.
 	// First the image is scaled down or up by preserving the image aspect ratio,
 	// then the seam carving algorithm is applied only to the remaining pixels.
@@ -368,6 +608,18 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 		}
 	}
 
+	// The energy map of the first step depends only on the source image and on the energy options,
+	// so it can be shared between the resizing operations producing multiple sizes of the same image.
+	p.energyHash = imageHash(img, 0)
+
+	// Keep track of the original coordinates of the carved pixels.
+	// The tracker is also used for remapping the reloaded masks to the carved image.
+	p.tracker = nil
+	if p.SeamsSVGPath != "" || p.GhostPath != "" || p.HeatmapPath != "" || p.DisplaceMap != "" || p.TrackCoords || p.maskWatch != nil || p.rmaskWatch != nil || p.tuning {
+		p.tracker = newCoordTracker(img.Bounds().Dx(), img.Bounds().Dy(), srcW, srcH)
+	}
+	p.progress = p.newProgressCounter(img, newWidth > 0 && p.NewWidth != c.Width, newHeight > 0 && p.NewHeight != c.Height)
+
 	// Run the carver function if the desired image width is not identical with the rescaled image width.
 	if newWidth > 0 && p.NewWidth != c.Width {
 		if p.NewWidth > c.Width {
@@ -394,6 +646,7 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 			if len(p.RMaskPath) > 0 {
 				p.RMask = c.RotateImage90(p.RMask)
 			}
+			p.rotateBiasMap(c.RotateImage90)
 		}
 		if p.NewHeight > c.Height {
 			img, err = enlargeVertFn(c, img)
@@ -415,16 +668,19 @@ func (p *Processor) Resize(img *image.NRGBA) (image.Image, error) {
 			if len(p.RMaskPath) > 0 {
 				p.RMask = c.RotateImage270(p.RMask)
 			}
+			p.rotateBiasMap(c.RotateImage270)
 		}
 	}
 	// Signal that the process is done and no more data is sent through the channel.
-	go func() {
-		imgWorker <- worker{
-			carver: nil,
-			img:    nil,
-			done:   true,
-		}
-	}()
+	if p.Preview {
+		go func() {
+			imgWorker <- worker{
+				carver: nil,
+				img:    nil,
+				done:   true,
+			}
+		}()
+	}
 
 	return img, nil
 }
@@ -451,6 +707,9 @@ func (p *Processor) calculateFitness(img *image.NRGBA, c *Carver) *image.NRGBA {
 		if len(p.RMaskPath) > 0 {
 			p.RMask = imaging.Resize(p.RMask, 0, int(sw), imaging.Lanczos)
 		}
+		if p.biasMap != nil {
+			p.biasMap = imaging.Resize(p.biasMap, 0, int(sw), imaging.Lanczos)
+		}
 	} else {
 		newImg = imaging.Resize(img, 0, int(sh), imaging.Lanczos)
 		if len(p.MaskPath) > 0 {
@@ -459,6 +718,9 @@ func (p *Processor) calculateFitness(img *image.NRGBA, c *Carver) *image.NRGBA {
 		if len(p.RMaskPath) > 0 {
 			p.RMask = imaging.Resize(p.RMask, 0, int(sh), imaging.Lanczos)
 		}
+		if p.biasMap != nil {
+			p.biasMap = imaging.Resize(p.biasMap, 0, int(sh), imaging.Lanczos)
+		}
 	}
 	dx, dy := newImg.Bounds().Max.X, newImg.Bounds().Max.Y
 	c.Width = dx
@@ -474,76 +736,165 @@ func (p *Processor) calculateFitness(img *image.NRGBA, c *Carver) *image.NRGBA {
 // We are using the io package, since we can provide different input and output types,
 // as long as they implement the io.Reader and io.Writer interface.
 func (p *Processor) Process(r io.Reader, w io.Writer) error {
+	return p.ProcessContext(context.Background(), r, w)
+}
+
+// ProcessContext is like Process, but the resizing operation can be aborted by canceling
+// the provided context. In this case the error returned by the context is propagated back.
+func (p *Processor) ProcessContext(ctx context.Context, r io.Reader, w io.Writer) error {
 	var err error
 
-	if p.FaceDetect {
-		// Instantiate a new Pigo object in case the face detection option is used.
-		p.FaceDetector = pigo.NewPigo()
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	defer p.cancel()
 
+	// The classifier might be already unpacked, for example by a processor pool.
+	if (p.FaceDetect || p.BlurFaces) && p.FaceDetector == nil {
 		// Unpack the binary file. This will return the number of cascade trees,
 		// the tree depth, the threshold and the prediction from tree's leaf nodes.
-		p.FaceDetector, err = p.FaceDetector.Unpack(cascadeFile)
-		if err != nil {
-			return fmt.Errorf("error unpacking the cascade file: %v", err)
+		if p.FaceDetector, err = p.unpackCascade(); err != nil {
+			return err
 		}
 	}
 
-	if p.NewWidth != 0 && p.NewHeight != 0 {
-		resizeXY = true
+	inFormat, err := ParseFormat(p.InputFormat)
+	if err != nil {
+		return err
+	}
+	format, err := p.outputFormat(w)
+	if err != nil {
+		return err
+	}
+
+	// The state of the previous image is discarded, even if the image is copied unchanged.
+	p.noop, p.tracker, p.warnings = false, nil, nil
+	var copied bool
+	if r, copied, err = p.passThrough(r, w, inFormat, format); copied || err != nil {
+		return err
+	}
+
+	// The EXIF metadata is peeked from the beginning of the file, prior to decoding the image.
+	var exif []byte
+	if p.UseExifSubject {
+		br := bufio.NewReaderSize(r, exifMaxSize)
+		exif, _ = br.Peek(exifMaxSize)
+		r = br
 	}
 
-	src, _, err := image.Decode(r)
+	endDecode := p.startSpan(SpanDecode)
+	src, _, err := decodeWith(r, inFormat, decodeOptions{maxPixels: p.maxPixels(), maxMem: p.MaxDecodeMem})
+	endDecode()
 	if err != nil {
 		return err
 	}
 
-	img := p.imgToNRGBA(src)
-	p.GuiDebug = image.NewNRGBA(img.Bounds())
+	// The images are decoded concurrently, while the carving is serialized.
+	defer lockCarving()()
 
-	if len(p.MaskPath) > 0 {
-		mf, err := os.Open(p.MaskPath)
-		if err != nil {
-			return fmt.Errorf("could not open the mask file: %v", err)
-		}
+	// Reset the package level state explicitly, since the processors can be reused for multiple
+	// images, and the previous image might have been a Gif animation.
+	resizeXY = p.NewWidth != 0 && p.NewHeight != 0
+	isGif, g = false, nil
 
-		ctype, err := utils.DetectContentType(mf.Name())
-		if err != nil {
+	p.palette = nil
+	if pimg, ok := src.(*image.Paletted); ok && p.KeepPalette {
+		p.palette = pimg.Palette
+	}
+
+	if err := p.validateOrientation(); err != nil {
+		return err
+	}
+
+	// The JPEG images are carved directly in the YCbCr color space if the options allow it,
+	// avoiding the conversion to RGB and back.
+	var img *image.NRGBA
+	ycc, direct := src.(*image.YCbCr)
+	if direct = p.supportsYCbCr(src, format); direct {
+		p.warnings = nil
+		p.GuiDebug = image.NewNRGBA(ycc.Bounds())
+	} else {
+		img = p.orient(p.imgToNRGBA(src))
+		if err := p.preflight(img); err != nil {
 			return err
 		}
-		if !strings.Contains(ctype.(string), "image") {
-			return fmt.Errorf("the mask should be an image file")
+		p.GuiDebug = image.NewNRGBA(img.Bounds())
+	}
+
+	p.faceCache = nil
+	if p.FaceDetect && p.FaceCacheDir != "" {
+		if p.faceCache, err = loadFaceCache(p.FaceCacheDir, img); err != nil {
+			return err
 		}
+	}
+	p.subject = nil
+	if rect, ok := exifSubject(exif, src.Bounds().Size()); ok {
+		p.subject = &Region{Name: "exif-subject", Rect: p.orientRect(rect, src.Bounds().Size()), Priority: PriorityMustKeep}
+	}
 
-		mask, _, err := image.Decode(mf)
-		if err != nil {
-			return fmt.Errorf("could not decode the mask file: %v", err)
+	if err := p.validateMaskFit(); err != nil {
+		return err
+	}
+	if err := p.validateColorKeys(); err != nil {
+		return err
+	}
+	size := src.Bounds().Size()
+	if img != nil {
+		size = img.Bounds().Size()
+	}
+	if err := p.checkOutputSize(size, format); err != nil {
+		return err
+	}
+	if len(p.MaskPath) > 0 {
+		if p.Mask, err = p.loadMask(p.MaskPath, p.ProtectColor); err != nil {
+			return err
+		}
+		if p.Mask, err = p.fitMask("mask", p.Mask, size); err != nil {
+			return err
 		}
-		p.Mask = p.Dither(p.imgToNRGBA(mask))
 		p.GuiDebug = p.Mask
 	}
 
 	if len(p.RMaskPath) > 0 {
-		rmf, err := os.Open(p.RMaskPath)
-		if err != nil {
-			return fmt.Errorf("could not open the mask file: %v", err)
+		if p.RMask, err = p.loadMask(p.RMaskPath, p.RemoveColor); err != nil {
+			return err
 		}
-
-		ctype, err := utils.DetectContentType(rmf.Name())
-		if err != nil {
+		if p.RMask, err = p.fitMask("rmask", p.RMask, size); err != nil {
 			return err
 		}
-		if !strings.Contains(ctype.(string), "image") {
-			return fmt.Errorf("the mask should be an image file")
+		p.GuiDebug = p.RMask
+	}
+
+	if len(p.WeightMaskPath) > 0 {
+		if p.WeightMask, err = p.decodeMask(p.WeightMaskPath); err != nil {
+			return err
 		}
+	}
 
-		rmask, _, err := image.Decode(rmf)
-		if err != nil {
-			return fmt.Errorf("could not decode the mask file: %v", err)
+	if len(p.ReferencePath) > 0 {
+		if p.Reference, err = p.decodeMask(p.ReferencePath); err != nil {
+			return err
 		}
-		p.RMask = p.Dither(p.imgToNRGBA(rmask))
-		p.GuiDebug = p.RMask
 	}
 
+	p.maskWatch, p.rmaskWatch = nil, nil
+	if p.WatchMasks {
+		p.maskWatch = newMaskWatcher(p.MaskPath)
+		p.rmaskWatch = newMaskWatcher(p.RMaskPath)
+	}
+
+	if p.Preview && !previewAvailable {
+		return errors.New("the preview is not available in the headless builds, disable it to process the image")
+	}
+	if len(p.RecordPath) > 0 {
+		if !p.Preview {
+			return errors.New("the recording requires the preview window")
+		}
+		if err := checkRecordPath(p.RecordPath); err != nil {
+			return err
+		}
+	}
+	if _, err := parsePreviewBackground(p.PreviewBackground); err != nil {
+		return err
+	}
 	if p.Preview {
 		guiWidth := img.Bounds().Max.X
 		guiHeight := img.Bounds().Max.Y
@@ -570,58 +921,138 @@ func (p *Processor) Process(r io.Reader, w io.Writer) error {
 		go p.showPreview(imgWorker, errs, guiParams)
 	}
 
-	switch w := w.(type) {
-	case *os.File:
-		ext := filepath.Ext(w.Name())
-		switch ext {
-		case "", ".jpg", ".jpeg":
-			res, err := resize(p, img)
-			if err != nil {
-				return err
-			}
-			return jpeg.Encode(w, res, &jpeg.Options{Quality: 100})
-		case ".png":
-			res, err := resize(p, img)
-			if err != nil {
-				return err
-			}
-			return png.Encode(w, res)
-		case ".bmp":
-			res, err := resize(p, img)
-			if err != nil {
-				return err
-			}
-			return bmp.Encode(w, res)
-		case ".gif":
-			g = new(gif.GIF)
-			isGif = true
-			_, err := resize(p, img)
-			if err != nil {
-				return err
-			}
-			return writeGifToFile(w.Name(), g)
-		default:
-			return errors.New("unsupported image format")
+	p.removedSeams = nil
+	if p.RemovedPath != "" {
+		p.removedSeams = &seamRecorder{}
+	}
+	p.energyLog = nil
+	if p.EnergyCSVPath != "" {
+		p.energyLog = &energyLogger{}
+	}
+
+	if direct {
+		err = p.encodeYCbCr(w, ycc)
+	} else {
+		err = p.encode(w, img, format)
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.writeRemovedFile(p.RemovedPath); err != nil {
+		return err
+	}
+	if err := p.writeEnergyCSV(p.EnergyCSVPath); err != nil {
+		return err
+	}
+	if err := p.faceCache.save(); err != nil {
+		return err
+	}
+	if p.HeatmapPath != "" {
+		if err := p.writeHeatmapFile(p.HeatmapPath); err != nil {
+			return err
 		}
-	default:
-		res, err := resize(p, img)
-		if err != nil {
+	}
+	if p.DisplaceMap != "" {
+		if err := p.writeDisplacementFile(p.DisplaceMap); err != nil {
 			return err
 		}
-		return jpeg.Encode(w, res, &jpeg.Options{Quality: 100})
 	}
+	if p.SeamsSVGPath != "" {
+		return p.writeSeamsSVGFile(p.SeamsSVGPath)
+	}
+	return nil
+}
+
+// loadMask opens and decodes the mask file, then converts it to binary format.
+// If a key color is provided, the pixels matching it are selected instead.
+func (p *Processor) loadMask(path, key string) (*image.NRGBA, error) {
+	mask, err := p.decodeMask(path)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		col, err := ParseColorKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return ColorKeyMask(mask, col, p.ColorTolerance), nil
+	}
+	return p.Dither(mask), nil
+}
+
+// decodeMask opens and decodes the mask file, applying the same orientation as for the image.
+func (p *Processor) decodeMask(path string) (*image.NRGBA, error) {
+	mf, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the mask file: %v", err)
+	}
+	defer mf.Close()
+
+	ctype, err := utils.DetectContentType(mf.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(ctype.(string), "image") {
+		return nil, fmt.Errorf("the mask should be an image file")
+	}
+
+	mask, _, err := image.Decode(mf)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the mask file: %v", err)
+	}
+	return p.orient(p.imgToNRGBA(mask)), nil
 }
 
 // shrink reduces the image dimension either horizontally or vertically.
 func (p *Processor) shrink(c *Carver, img *image.NRGBA) (*image.NRGBA, error) {
+	if err := p.getContext().Err(); err != nil {
+		p.keepPartial(c, img)
+		return nil, err
+	}
 	width, height := img.Bounds().Max.X, img.Bounds().Max.Y
 	c = NewCarver(width, height)
+	c.workers = p.seamWorkers()
+	p.reloadMasks(c)
+	p.trackSeamBands(img)
+	p.trackColorBlocks(img)
+	p.energyLog.begin()
 
-	if _, err := c.ComputeSeams(p, img); err != nil {
+	var (
+		seams  []Seam
+		energy *image.NRGBA
+		err    error
+	)
+	if p.useFastMode(img) {
+		if seams, err = c.findSeamCoarseToFine(p, img); err != nil {
+			return nil, err
+		}
+		p.energyHash = ""
+	} else {
+		if energy, err = c.ComputeSeams(p, img); err != nil {
+			return nil, err
+		}
+		seams = c.FindLowestEnergySeams(p)
+	}
+	if err := c.checkSeam(seams); err != nil {
 		return nil, err
 	}
-	seams := c.FindLowestEnergySeams(p)
+	if energy != nil {
+		p.recordSnapshot(c, energy, seams, false)
+	}
+	p.energyLog.record(energy, seams, p.vRes, false)
+	p.removedSeams.record(img, seams, p.vRes)
 	img = c.RemoveSeam(img, seams, p.Debug)
+	if p.tracker != nil {
+		p.tracker.remove(seams, p.vRes)
+	}
+	p.bands.remove(seams)
+	p.blocks.remove(seams)
+	p.progress.advance()
+	if p.onStep != nil {
+		if err := p.notifyStep(c, img, seams, false); err != nil {
+			return nil, err
+		}
+	}
 
 	if len(p.MaskPath) > 0 {
 		p.Mask = c.RemoveSeam(p.Mask, seams, false)
@@ -631,36 +1062,69 @@ func (p *Processor) shrink(c *Carver, img *image.NRGBA) (*image.NRGBA, error) {
 		p.RMask = c.RemoveSeam(p.RMask, seams, false)
 		draw.Draw(p.GuiDebug, img.Bounds(), p.RMask, image.Point{}, draw.Over)
 	}
+	if p.biasMap != nil {
+		p.biasMap = c.RemoveSeam(p.biasMap, seams, false)
+	}
 
 	if isGif {
 		p.encodeImgToGif(c, img, g)
 	}
 
-	go func() {
-		select {
-		case imgWorker <- worker{
-			carver: c,
-			img:    img,
-			debug:  p.GuiDebug,
-			done:   false,
-		}:
-		case <-errs:
-			return
-		}
-	}()
+	// Transfer the image to the GUI. The channel is consumed only in preview mode,
+	// otherwise the goroutines would be blocked forever.
+	if p.Preview {
+		go func() {
+			select {
+			case imgWorker <- worker{
+				carver: c,
+				img:    img,
+				debug:  p.GuiDebug,
+				done:   false,
+			}:
+			case <-errs:
+				return
+			}
+		}()
+	}
 	return img, nil
 }
 
 // enlarge increases the image dimension either horizontally or vertically.
 func (p *Processor) enlarge(c *Carver, img *image.NRGBA) (*image.NRGBA, error) {
+	if err := p.getContext().Err(); err != nil {
+		p.keepPartial(c, img)
+		return nil, err
+	}
 	width, height := img.Bounds().Max.X, img.Bounds().Max.Y
 	c = NewCarver(width, height)
+	c.workers = p.seamWorkers()
+	p.reloadMasks(c)
+	p.trackSeamBands(img)
+	p.trackColorBlocks(img)
+	p.energyLog.begin()
 
-	if _, err := c.ComputeSeams(p, img); err != nil {
+	energy, err := c.ComputeSeams(p, img)
+	if err != nil {
 		return nil, err
 	}
 	seams := c.FindLowestEnergySeams(p)
+	if err := c.checkSeam(seams); err != nil {
+		return nil, err
+	}
+	p.recordSnapshot(c, energy, seams, true)
+	p.energyLog.record(energy, seams, p.vRes, true)
 	img = c.AddSeam(img, seams, p.Debug)
+	if p.tracker != nil {
+		p.tracker.insert(seams, p.vRes)
+	}
+	p.bands.insert(seams)
+	p.blocks.insert(seams)
+	p.progress.advance()
+	if p.onStep != nil {
+		if err := p.notifyStep(c, img, seams, true); err != nil {
+			return nil, err
+		}
+	}
 
 	if len(p.MaskPath) > 0 {
 		p.Mask = c.AddSeam(p.Mask, seams, false)
@@ -670,26 +1134,48 @@ func (p *Processor) enlarge(c *Carver, img *image.NRGBA) (*image.NRGBA, error) {
 		p.RMask = c.AddSeam(p.RMask, seams, false)
 		p.GuiDebug = p.RMask
 	}
+	if p.biasMap != nil {
+		p.biasMap = c.AddSeam(p.biasMap, seams, false)
+	}
 
 	if isGif {
 		p.encodeImgToGif(c, img, g)
 	}
 
-	go func() {
-		select {
-		case imgWorker <- worker{
-			carver: c,
-			img:    img,
-			debug:  p.GuiDebug,
-			done:   false,
-		}:
-		case <-errs:
-			return
-		}
-	}()
+	// Transfer the image to the GUI. The channel is consumed only in preview mode,
+	// otherwise the goroutines would be blocked forever.
+	if p.Preview {
+		go func() {
+			select {
+			case imgWorker <- worker{
+				carver: c,
+				img:    img,
+				debug:  p.GuiDebug,
+				done:   false,
+			}:
+			case <-errs:
+				return
+			}
+		}()
+	}
 	return img, nil
 }
 
+// getContext returns the context of the currently running operation.
+func (p *Processor) getContext() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// Abort cancels the currently running resizing operation.
+func (p *Processor) Abort() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
 // imgToNRGBA converts any image type to *image.NRGBA with min-point at (0, 0).
 func (p *Processor) imgToNRGBA(img image.Image) *image.NRGBA {
 	srcBounds := img.Bounds()
@@ -732,6 +1218,22 @@ func (p *Processor) imgToNRGBA(img image.Image) *image.NRGBA {
 				di += 4
 			}
 		}
+	case *image.CMYK:
+		// CMYK images are common in print workflows. The JPEG decoder
+		// already takes care of the inverted Adobe CMYK encoding.
+		for dstY := 0; dstY < dstH; dstY++ {
+			di := dst.PixOffset(0, dstY)
+			si := src.PixOffset(srcMinX, srcMinY+dstY)
+			for dstX := 0; dstX < dstW; dstX++ {
+				r, g, b := color.CMYKToRGB(src.Pix[si+0], src.Pix[si+1], src.Pix[si+2], src.Pix[si+3])
+				dst.Pix[di+0] = r
+				dst.Pix[di+1] = g
+				dst.Pix[di+2] = b
+				dst.Pix[di+3] = 0xff
+				di += 4
+				si += 4
+			}
+		}
 	default:
 		for dstY := 0; dstY < dstH; dstY++ {
 			di := dst.PixOffset(0, dstY)
@@ -750,10 +1252,14 @@ func (p *Processor) imgToNRGBA(img image.Image) *image.NRGBA {
 
 // encodeImgToGif encodes the provided image to a Gif file.
 func (p *Processor) encodeImgToGif(c *Carver, src image.Image, g *gif.GIF) {
+	pal := color.Palette(palette.Plan9)
+	if p.palette != nil {
+		pal = p.palette
+	}
 	dx, dy := src.Bounds().Max.X, src.Bounds().Max.Y
-	dst := image.NewPaletted(image.Rect(0, 0, dx, dy), palette.Plan9)
+	dst := image.NewPaletted(image.Rect(0, 0, dx, dy), pal)
 	if p.NewHeight != 0 {
-		dst = image.NewPaletted(image.Rect(0, 0, dy, dx), palette.Plan9)
+		dst = image.NewPaletted(image.Rect(0, 0, dy, dx), pal)
 	}
 
 	if p.NewWidth > dx {
@@ -778,13 +1284,3 @@ func (p *Processor) encodeImgToGif(c *Carver, src image.Image, g *gif.GIF) {
 	g.Image = append(g.Image, dst)
 	g.Delay = append(g.Delay, 0)
 }
-
-// writeGifToFile writes the encoded Gif file to the destination file.
-func writeGifToFile(path string, g *gif.GIF) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return gif.EncodeAll(f, g)
-}
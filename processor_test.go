@@ -2,6 +2,7 @@ package caire
 
 import (
 	"image"
+	"image/color"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,23 @@ func TestResize_EnlargeImageHeight(t *testing.T) {
 
 	assert.NotEqual(imgHeight, newHeight)
 }
+
+func TestResize_ShouldKeepPalette(t *testing.T) {
+	assert := assert.New(t)
+
+	pal := color.Palette{color.Black, color.White, color.NRGBA{R: 0xff, A: 0xff}}
+	img := image.NewPaletted(image.Rect(0, 0, imgWidth, imgHeight), pal)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % len(pal))
+	}
+
+	proc := &Processor{
+		NewWidth:       imgWidth / 2,
+		BlurRadius:     1,
+		SobelThreshold: 4,
+	}
+	res, err := proc.ResizePaletted(img)
+	assert.NoError(err)
+	assert.Equal(imgWidth/2, res.Bounds().Dx())
+	assert.Equal(pal, res.Palette)
+}
@@ -0,0 +1,64 @@
+package caire
+
+import (
+	"image"
+	"time"
+
+	"github.com/esimov/caire/utils"
+)
+
+// Progress reports the advancement of the seam carving, sent to the OnProgress hook after each seam.
+type Progress struct {
+	// Seams is the number of seams removed or inserted so far, out of Total.
+	Seams int
+	Total int
+	// Percent is the completed part of the carving, in the [0, 100] range.
+	Percent float64
+	// Elapsed is the time spent carving, while ETA is the estimated remaining time,
+	// extrapolated from the average duration of the seams processed so far.
+	Elapsed time.Duration
+	ETA     time.Duration
+}
+
+// progressCounter counts the processed seams and notifies the progress hook.
+type progressCounter struct {
+	seams int
+	total int
+	start time.Time
+	fn    func(Progress)
+}
+
+// newProgressCounter returns a counter of the seams needed for carving the image to the new size.
+// It returns nil if there is no progress hook or there is nothing to carve.
+func (p *Processor) newProgressCounter(img *image.NRGBA, carveX, carveY bool) *progressCounter {
+	if p.OnProgress == nil {
+		return nil
+	}
+	var total int
+	if carveX {
+		total += utils.Abs(img.Bounds().Dx() - p.NewWidth)
+	}
+	if carveY {
+		total += utils.Abs(img.Bounds().Dy() - p.NewHeight)
+	}
+	if total == 0 {
+		return nil
+	}
+	return &progressCounter{total: total, start: time.Now(), fn: p.OnProgress}
+}
+
+// advance records a processed seam and reports the progress.
+func (pc *progressCounter) advance() {
+	if pc == nil {
+		return
+	}
+	pc.seams = min(pc.seams+1, pc.total)
+	elapsed := time.Since(pc.start)
+	pc.fn(Progress{
+		Seams:   pc.seams,
+		Total:   pc.total,
+		Percent: float64(pc.seams) * 100 / float64(pc.total),
+		Elapsed: elapsed,
+		ETA:     elapsed / time.Duration(pc.seams) * time.Duration(pc.total-pc.seams),
+	})
+}
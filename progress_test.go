@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress_ShouldReportEachSeam(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, subjectsImage(60, 40, 5, 30)))
+
+	var events []Progress
+	p := &Processor{
+		NewWidth:       50,
+		NewHeight:      44,
+		SobelThreshold: 2,
+		OutputFormat:   FormatPNG,
+		OnProgress: func(pr Progress) {
+			events = append(events, pr)
+		},
+	}
+	var out bytes.Buffer
+	assert.NoError(p.Process(bytes.NewReader(buf.Bytes()), &out))
+	res, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 44), res.Bounds())
+
+	// 10 seams are removed horizontally and 4 are inserted vertically.
+	assert.Len(events, 14)
+	for i, ev := range events {
+		assert.Equal(i+1, ev.Seams)
+		assert.Equal(14, ev.Total)
+		assert.GreaterOrEqual(ev.ETA, time.Duration(0))
+	}
+	last := events[len(events)-1]
+	assert.Equal(100.0, last.Percent)
+	assert.Zero(last.ETA)
+}
+
+func TestProgress_ShouldSkipTheScaledImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int
+	p := &Processor{
+		NewWidth:            98,
+		SobelThreshold:      2,
+		MinReductionPercent: 5,
+		OnProgress:          func(Progress) { calls++ },
+	}
+	_, err := p.Resize(subjectsImage(100, 50, 5, 70))
+	assert.NoError(err)
+	assert.Zero(calls)
+}
@@ -0,0 +1,46 @@
+package caire
+
+import "fmt"
+
+// The seam carving quality presets, configuring the speed and quality related options together.
+const (
+	// QualityFast searches the seams on a downscaled copy of the image and refines them in a narrow band.
+	QualityFast = "fast"
+	// QualityBalanced is the default, using the regular seam search.
+	QualityBalanced = "balanced"
+	// QualityBest uses the forward energy, which better preserves the straight edges and the structures.
+	QualityBest = "best"
+)
+
+// qualityPreset holds the option values of a quality preset.
+type qualityPreset struct {
+	fastMode      bool
+	bandwidth     int
+	forwardEnergy bool
+}
+
+var qualityPresets = map[string]qualityPreset{
+	QualityFast:     {fastMode: true, bandwidth: 2},
+	QualityBalanced: {bandwidth: defaultBandwidth},
+	QualityBest:     {bandwidth: defaultBandwidth, forwardEnergy: true},
+}
+
+// ParseQuality validates the quality preset name. An empty name stands for the balanced preset.
+func ParseQuality(name string) (string, error) {
+	if name == "" {
+		return QualityBalanced, nil
+	}
+	if _, ok := qualityPresets[name]; !ok {
+		return "", fmt.Errorf("unsupported quality preset: %q", name)
+	}
+	return name, nil
+}
+
+// preset returns the options of the selected quality preset. The options set explicitly
+// on the processor (FastMode, Bandwidth and ForwardEnergy) take precedence over the preset.
+func (p *Processor) preset() qualityPreset {
+	if preset, ok := qualityPresets[p.Quality]; ok {
+		return preset
+	}
+	return qualityPresets[QualityBalanced]
+}
@@ -0,0 +1,71 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuality_ShouldResolveThePresets(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+
+	p := &Processor{}
+	assert.False(p.useFastMode(img))
+	assert.False(p.useForwardEnergy())
+	assert.Equal(defaultBandwidth, p.bandwidth())
+
+	p = &Processor{Quality: QualityFast}
+	assert.True(p.useFastMode(img))
+	assert.Equal(2, p.bandwidth())
+
+	// The explicit options take precedence over the preset.
+	p.Bandwidth = 5
+	assert.Equal(5, p.bandwidth())
+
+	p = &Processor{Quality: QualityBest}
+	assert.True(p.useForwardEnergy())
+	p.Tileable = true
+	assert.False(p.useForwardEnergy())
+
+	_, err := ParseQuality("ultra")
+	assert.Error(err)
+	q, err := ParseQuality("")
+	assert.NoError(err)
+	assert.Equal(QualityBalanced, q)
+
+	p = &Processor{Quality: "ultra", NewWidth: 90}
+	_, err = p.Resize(img)
+	assert.Error(err)
+}
+
+func TestQuality_ShouldTraceTheForwardEnergySeams(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), uint8(x * y), 255})
+		}
+	}
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, ForwardEnergy: true}
+	c := NewCarver(40, 30)
+	_, err := c.ComputeSeams(p, img)
+	assert.NoError(err)
+
+	seams := c.FindLowestEnergySeams(p)
+	assert.Len(seams, 30)
+	for i := 1; i < len(seams); i++ {
+		assert.Equal(seams[i-1].Y-1, seams[i].Y)
+		assert.InDelta(seams[i-1].X, seams[i].X, 1)
+	}
+
+	p = &Processor{SobelThreshold: 2, BlurRadius: 1, Quality: QualityBest, NewWidth: 32}
+	res, err := p.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+}
@@ -0,0 +1,133 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// maxQuantizeColors is the number of colors of the 8-bit palettes.
+const maxQuantizeColors = 256
+
+// colorBox is a box of the RGBA color space, holding the histogram entries it contains.
+type colorBox struct {
+	colors []histColor
+	count  int
+	// ch is the channel having the largest range of values in the box.
+	ch, rng int
+}
+
+// histColor is a distinct color of the image, together with the number of its pixels.
+type histColor struct {
+	c     [4]uint8
+	count int
+}
+
+// quantize returns a palette of at most n colors representing the image, using the median cut
+// algorithm over the RGBA color histogram, so the transparency of the image is preserved.
+func quantize(img image.Image, n int) color.Palette {
+	hist := make(map[[4]uint8]int)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			hist[[4]uint8{c.R, c.G, c.B, c.A}]++
+		}
+	}
+
+	colors := make([]histColor, 0, len(hist))
+	for c, count := range hist {
+		colors = append(colors, histColor{c, count})
+	}
+	// Sort the histogram for a deterministic palette, the map iteration being random.
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	boxes := []*colorBox{newColorBox(colors)}
+	for len(boxes) < n {
+		// Split the box having the largest channel range weighted by its pixel count.
+		best, bestScore := -1, 0
+		for i, box := range boxes {
+			if score := box.rng * box.count; box.rng > 0 && score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		lo, hi := boxes[best].split()
+		boxes[best] = lo
+		boxes = append(boxes, hi)
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		pal = append(pal, box.average())
+	}
+	return pal
+}
+
+// newColorBox returns the box containing the colors, finding its widest channel.
+func newColorBox(colors []histColor) *colorBox {
+	box := &colorBox{colors: colors, rng: -1}
+	var lo, hi [4]int
+	for k := range lo {
+		lo[k] = 255
+	}
+	for _, c := range colors {
+		box.count += c.count
+		for k := range lo {
+			lo[k], hi[k] = min(lo[k], int(c.c[k])), max(hi[k], int(c.c[k]))
+		}
+	}
+	for k := range lo {
+		if hi[k]-lo[k] > box.rng {
+			box.ch, box.rng = k, hi[k]-lo[k]
+		}
+	}
+	return box
+}
+
+// split divides the box along its widest channel at the median pixel.
+func (box *colorBox) split() (*colorBox, *colorBox) {
+	sort.SliceStable(box.colors, func(i, j int) bool {
+		return box.colors[i].c[box.ch] < box.colors[j].c[box.ch]
+	})
+	half, acc, cut := box.count/2, 0, 1
+	for i, c := range box.colors[:len(box.colors)-1] {
+		if acc += c.count; acc >= half {
+			cut = i + 1
+			break
+		}
+	}
+	return newColorBox(box.colors[:cut]), newColorBox(box.colors[cut:])
+}
+
+// average returns the mean color of the box weighted by the pixel counts.
+func (box *colorBox) average() color.Color {
+	var sum [4]int
+	for _, c := range box.colors {
+		for k := range sum {
+			sum[k] += int(c.c[k]) * c.count
+		}
+	}
+	if box.count == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8((sum[0] + box.count/2) / box.count),
+		G: uint8((sum[1] + box.count/2) / box.count),
+		B: uint8((sum[2] + box.count/2) / box.count),
+		A: uint8((sum[3] + box.count/2) / box.count),
+	}
+}
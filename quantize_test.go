@@ -0,0 +1,55 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantize_ShouldLimitThePalette(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), uint8(x + y), 255})
+		}
+	}
+	pal := quantize(img, maxQuantizeColors)
+	assert.Len(pal, maxQuantizeColors)
+
+	// The images having fewer colors are represented exactly, including the transparency.
+	small := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	small.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	small.Set(1, 0, color.NRGBA{0, 0, 255, 128})
+	pal = quantize(small, maxQuantizeColors)
+	assert.Len(pal, 3)
+	assert.Contains(pal, color.Color(color.NRGBA{255, 0, 0, 255}))
+	assert.Contains(pal, color.Color(color.NRGBA{0, 0, 255, 128}))
+	assert.Contains(pal, color.Color(color.NRGBA{}))
+}
+
+func TestQuantize_ShouldEncodePNG8(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for x := 0; x < 60; x++ {
+		for y := 0; y < 40; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 4), uint8(y * 6), 120, 255})
+		}
+	}
+	var in, out bytes.Buffer
+	assert.NoError(png.Encode(&in, img))
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 50, OutputFormat: FormatPNG, PNG8: true, PaletteDither: true}
+	assert.NoError(p.Process(&in, &out))
+
+	res, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.IsType(&image.Paletted{}, res)
+	assert.Equal(image.Rect(0, 0, 50, 40), res.Bounds())
+}
@@ -0,0 +1,104 @@
+package caire
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// recordInterval is the minimum time between the recorded frames of the preview window.
+	recordInterval = 100 * time.Millisecond
+	// maxRecordFrames limits the length of the recording, the later frames are dropped.
+	maxRecordFrames = 1200
+)
+
+// previewRecorder collects the frames rendered in the preview window and saves them as an
+// animated GIF, reproducing the timing of the preview.
+type previewRecorder struct {
+	path   string
+	frames []*image.Paletted
+	delays []int
+	last   time.Time
+	err    error
+}
+
+// checkRecordPath verifies that the recording can be saved in the format of the provided file.
+func checkRecordPath(path string) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gif":
+		return nil
+	case ".webp":
+		return errors.New("the WebP encoder is not available in this build, record the preview into a GIF file")
+	default:
+		return fmt.Errorf("unsupported recording format: %q", ext)
+	}
+}
+
+// newPreviewRecorder returns a recorder saving the frames into the provided file.
+func newPreviewRecorder(path string) (*previewRecorder, error) {
+	if err := checkRecordPath(path); err != nil {
+		return nil, err
+	}
+	return &previewRecorder{path: path}, nil
+}
+
+// due reports whether the next frame should be recorded.
+func (r *previewRecorder) due(now time.Time) bool {
+	return r.err == nil && len(r.frames) < maxRecordFrames &&
+		(len(r.frames) == 0 || now.Sub(r.last) >= recordInterval)
+}
+
+// add appends the frame to the recording. The display time of the previous frame
+// is the time passed since it has been captured.
+func (r *previewRecorder) add(img image.Image, now time.Time) {
+	if n := len(r.delays); n > 0 {
+		r.delays[n-1] = max(1, int(now.Sub(r.last)/(10*time.Millisecond)))
+	}
+	dst := image.NewPaletted(image.Rectangle{Max: img.Bounds().Size()}, palette.Plan9)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	r.frames = append(r.frames, dst)
+	r.delays = append(r.delays, int(recordInterval/(10*time.Millisecond)))
+	r.last = now
+}
+
+// fail stops the recording, the error being reported when the recording is saved.
+func (r *previewRecorder) fail(err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("unable to record the preview: %v", err)
+	}
+}
+
+// save encodes the recorded frames into the file. The window can be resized while recording,
+// so the size of the animation is the size of the largest frame.
+func (r *previewRecorder) save() error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.frames) == 0 {
+		return nil
+	}
+	g := &gif.GIF{Image: r.frames, Delay: r.delays}
+	for _, frame := range r.frames {
+		g.Config.Width = max(g.Config.Width, frame.Bounds().Dx())
+		g.Config.Height = max(g.Config.Height, frame.Bounds().Dy())
+	}
+
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("unable to create the recording file: %v", err)
+	}
+	defer f.Close()
+
+	if err := gif.EncodeAll(f, g); err != nil {
+		return err
+	}
+	return f.Close()
+}
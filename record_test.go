@@ -0,0 +1,62 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord_ShouldValidateTheFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkRecordPath("preview.GIF"))
+	assert.ErrorContains(checkRecordPath("preview.webp"), "WebP")
+	assert.ErrorContains(checkRecordPath("preview.png"), "unsupported recording format")
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 20, 20))))
+	p := &Processor{NewWidth: 10, RecordPath: "preview.gif"}
+	assert.ErrorContains(p.Process(&buf, io.Discard), "requires the preview window")
+}
+
+func TestRecord_ShouldSaveTheFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "preview.gif")
+	rec, err := newPreviewRecorder(path)
+	assert.NoError(err)
+
+	start := time.Now()
+	frame := func(w, h int, c color.Color) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	}
+	assert.True(rec.due(start))
+	rec.add(frame(40, 30, color.White), start)
+	assert.False(rec.due(start.Add(recordInterval / 2)))
+	assert.True(rec.due(start.Add(recordInterval)))
+	// The window has been enlarged between the frames.
+	rec.add(frame(50, 30, color.Black), start.Add(250*time.Millisecond))
+	assert.NoError(rec.save())
+
+	f, err := os.Open(path)
+	assert.NoError(err)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	assert.NoError(err)
+	assert.Len(g.Image, 2)
+	assert.Equal([]int{25, 10}, g.Delay)
+	assert.Equal(50, g.Config.Width)
+	assert.Equal(30, g.Config.Height)
+}
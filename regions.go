@@ -0,0 +1,60 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+)
+
+// Priority expresses the importance of an image region, between -1 and 1.
+// The positive values protect the region, while the negative values favor its removal.
+type Priority float64
+
+// The predefined region priorities.
+const (
+	PriorityMustKeep     Priority = 1
+	PriorityPreferKeep   Priority = 0.5
+	PriorityNeutral      Priority = 0
+	PriorityPreferRemove Priority = -0.5
+	PriorityMustRemove   Priority = -1
+)
+
+// Region is a named image area with an assigned priority, used for retargeting the image
+// without providing mask files. The rectangle is expressed in the coordinates of the source
+// image (after the rotation and flip options have been applied).
+type Region struct {
+	Name     string
+	Rect     image.Rectangle
+	Priority Priority
+}
+
+// validateRegions checks that the region priorities are in the accepted range.
+func validateRegions(regions []Region) error {
+	for _, r := range regions {
+		if r.Priority < PriorityMustRemove || r.Priority > PriorityMustKeep {
+			return fmt.Errorf("invalid priority %v of the region %q: it should be between -1 and 1", r.Priority, r.Name)
+		}
+	}
+	return nil
+}
+
+// regionPriority returns the priority of the pixel covered by the regions. In case of
+// overlapping regions the most specific (smallest) region wins, so the regions can be
+// nested into each other. The regions of equal size are resolved by the higher priority.
+func regionPriority(regions []Region, x, y int) (Priority, bool) {
+	var (
+		pr    Priority
+		area  int
+		found bool
+	)
+	pt := image.Pt(x, y)
+	for _, r := range regions {
+		if !pt.In(r.Rect) {
+			continue
+		}
+		a := r.Rect.Dx() * r.Rect.Dy()
+		if !found || a < area || (a == area && r.Priority > pr) {
+			pr, area, found = r.Priority, a, true
+		}
+	}
+	return pr, found
+}
@@ -0,0 +1,46 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegions_ShouldRetargetByPriority(t *testing.T) {
+	assert := assert.New(t)
+
+	regions := []Region{
+		{Name: "logo", Rect: image.Rect(0, 0, 4, 4), Priority: PriorityPreferRemove},
+		{Name: "product", Rect: image.Rect(2, 2, 6, 6), Priority: PriorityMustKeep},
+	}
+	bias := renderBiasMap(nil, regions, image.Rect(0, 0, 8, 8))
+	assert.Equal(uint8(128), bias.NRGBAAt(7, 7).R)
+	assert.Equal(uint8(64), bias.NRGBAAt(0, 0).R)
+	assert.Equal(uint8(255), bias.NRGBAAt(3, 3).R)
+
+	img := texturedImage(40, 30)
+
+	proc := &Processor{
+		SobelThreshold: 4,
+		NewWidth:       36,
+		SeamsSVGPath:   "seams.svg",
+		Regions: []Region{
+			{Name: "background", Rect: image.Rect(0, 0, 40, 30), Priority: PriorityPreferKeep},
+			{Name: "banner", Rect: image.Rect(5, 0, 15, 30), Priority: PriorityMustRemove},
+		},
+	}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+
+	assert.Len(proc.tracker.removed, 4)
+	for _, seam := range proc.tracker.removed {
+		for _, pt := range seam {
+			assert.True(pt.X >= 5 && pt.X < 15)
+		}
+	}
+
+	proc.Regions[0].Priority = 2
+	_, err = proc.Resize(img)
+	assert.Error(err)
+}
@@ -0,0 +1,120 @@
+package caire
+
+import (
+	"image"
+
+	"github.com/esimov/caire/utils"
+)
+
+// DisplacementMap maps the coordinates between the source image and the resized image.
+// It can be used for updating the annotations of the image (like the bounding boxes of the
+// object detection labels or the keypoints) after the image has been carved.
+type DisplacementMap struct {
+	// Width and Height are the size of the resized image.
+	Width, Height int
+	// SrcWidth and SrcHeight are the size of the source image.
+	SrcWidth, SrcHeight int
+
+	src []image.Point
+	dst []int32
+}
+
+// DisplacementMap returns the displacement map of the last resizing operation.
+// The pixel coordinates are tracked only if the TrackCoords or the DisplaceMap
+// options are set, otherwise it returns nil.
+func (p *Processor) DisplacementMap() *DisplacementMap {
+	t := p.tracker
+	if t == nil {
+		return nil
+	}
+	m := &DisplacementMap{
+		Width:     t.width,
+		Height:    t.height,
+		SrcWidth:  t.origW,
+		SrcHeight: t.origH,
+		src:       make([]image.Point, t.width*t.height),
+		dst:       make([]int32, t.origW*t.origH),
+	}
+	for i := range m.dst {
+		m.dst[i] = -1
+	}
+	for y := 0; y < t.height; y++ {
+		for x := 0; x < t.width; x++ {
+			i := y*t.width + x
+			pt := t.at(x, y)
+			m.src[i] = pt
+			if pt.In(image.Rect(0, 0, m.SrcWidth, m.SrcHeight)) {
+				m.dst[pt.Y*m.SrcWidth+pt.X] = int32(i)
+			}
+		}
+	}
+	return m
+}
+
+// Source returns the coordinates in the source image of the resized image pixel.
+func (m *DisplacementMap) Source(x, y int) image.Point {
+	x, y = clamp(x, 0, m.Width-1), clamp(y, 0, m.Height-1)
+	return m.src[y*m.Width+x]
+}
+
+// RemapPoint returns the position of the source image pixel in the resized image.
+// The removed pixels are mapped to the position of the nearest retained pixel.
+func (m *DisplacementMap) RemapPoint(pt image.Point) image.Point {
+	x, y := clamp(pt.X, 0, m.SrcWidth-1), clamp(pt.Y, 0, m.SrcHeight-1)
+	if i := m.dst[y*m.SrcWidth+x]; i >= 0 {
+		return image.Pt(int(i)%m.Width, int(i)/m.Width)
+	}
+
+	// Search the nearest retained pixel on the rings of increasing size around the point.
+	// The pixels of the ring d are at least d pixels away, so the search stops when
+	// a pixel closer than the current ring has already been found.
+	best, bestDist := int32(-1), 0
+	for d := 1; d < max(m.SrcWidth, m.SrcHeight) && (best < 0 || d*d <= bestDist); d++ {
+		for dy := -d; dy <= d; dy++ {
+			for dx := -d; dx <= d; dx++ {
+				if max(utils.Abs(dx), utils.Abs(dy)) != d {
+					continue
+				}
+				sx, sy := x+dx, y+dy
+				if sx < 0 || sy < 0 || sx >= m.SrcWidth || sy >= m.SrcHeight {
+					continue
+				}
+				if i := m.dst[sy*m.SrcWidth+sx]; i >= 0 && (best < 0 || dx*dx+dy*dy < bestDist) {
+					best, bestDist = i, dx*dx+dy*dy
+				}
+			}
+		}
+	}
+	if best < 0 {
+		return image.Point{}
+	}
+	return image.Pt(int(best)%m.Width, int(best)/m.Width)
+}
+
+// RemapRect returns the bounding box in the resized image of the source image rectangle.
+// The seams are not straight lines, so the box is computed from the remapped border pixels.
+func (m *DisplacementMap) RemapRect(r image.Rectangle) image.Rectangle {
+	r = r.Canon().Intersect(image.Rect(0, 0, m.SrcWidth, m.SrcHeight))
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+
+	var res image.Rectangle
+	add := func(x, y int) {
+		pt := m.RemapPoint(image.Pt(x, y))
+		res = res.Union(image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))})
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		add(x, r.Min.Y)
+		add(x, r.Max.Y-1)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		add(r.Min.X, y)
+		add(r.Max.X-1, y)
+	}
+	return res
+}
+
+func clamp(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
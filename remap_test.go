@@ -0,0 +1,65 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemap_ShouldFollowTheCarvedPixels(t *testing.T) {
+	assert := assert.New(t)
+
+	// The image has a vertical band in the middle, which should be kept.
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 8), B: uint8(x * y), A: 255})
+		}
+	}
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32}
+	_, err := p.Resize(img)
+	assert.NoError(err)
+	assert.Nil(p.DisplacementMap())
+
+	p.TrackCoords = true
+	_, err = p.Resize(img)
+	assert.NoError(err)
+
+	m := p.DisplacementMap()
+	assert.Equal(32, m.Width)
+	assert.Equal(40, m.SrcWidth)
+
+	// The retained pixels are mapped back and forth.
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 32; x++ {
+			assert.Equal(image.Pt(x, y), m.RemapPoint(m.Source(x, y)))
+		}
+	}
+
+	// The removed pixels are mapped to the nearest retained pixel.
+	dist := func(a, b image.Point) int {
+		return (a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y)
+	}
+	for _, removed := range p.tracker.removed[0] {
+		nearest := 40 * 40
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 32; x++ {
+				nearest = min(nearest, dist(m.Source(x, y), removed))
+			}
+		}
+		pt := m.RemapPoint(removed)
+		assert.Equal(nearest, dist(m.Source(pt.X, pt.Y), removed))
+	}
+
+	box := m.RemapRect(image.Rect(0, 0, 40, 30))
+	assert.Equal(image.Rect(0, 0, 32, 30), box)
+
+	box = m.RemapRect(image.Rect(10, 5, 20, 15))
+	assert.Equal(5, box.Min.Y)
+	assert.Equal(15, box.Max.Y)
+	assert.LessOrEqual(box.Dx(), 10)
+	assert.True(m.RemapRect(image.Rect(50, 50, 60, 60)).Empty())
+}
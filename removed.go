@@ -0,0 +1,98 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// seamRecorder keeps the content of the removed seams, used for auditing the discarded pixels.
+type seamRecorder struct {
+	// cols holds the pixels of the vertical seams (width reduction) from top to bottom,
+	// rows holds the pixels of the horizontal seams (height reduction) from left to right.
+	cols, rows [][]color.NRGBA
+}
+
+// record stores the pixels of the seam, prior to be removed from the image.
+// In case the image is rotated (vertical resizing), the seam is a horizontal one.
+func (r *seamRecorder) record(img *image.NRGBA, seams []Seam, rotated bool) {
+	if r == nil {
+		return
+	}
+	pts := make([]Seam, len(seams))
+	copy(pts, seams)
+	// The seam is ordered from the last row to the first one. The first row of the
+	// rotated image corresponds to the last column of the original image.
+	sort.Slice(pts, func(i, j int) bool {
+		if rotated {
+			return pts[i].Y > pts[j].Y
+		}
+		return pts[i].Y < pts[j].Y
+	})
+
+	pixels := make([]color.NRGBA, len(pts))
+	for i, s := range pts {
+		pixels[i] = img.NRGBAAt(s.X, s.Y)
+	}
+	if rotated {
+		r.rows = append(r.rows, pixels)
+	} else {
+		r.cols = append(r.cols, pixels)
+	}
+}
+
+// image stitches the removed seams together: the vertical seams are placed side by side
+// at the top of the image, while the horizontal seams are stacked under them.
+// The pixels not covered by any seam are left transparent.
+func (r *seamRecorder) image() *image.NRGBA {
+	var colsH, rowsW int
+	for _, col := range r.cols {
+		colsH = max(colsH, len(col))
+	}
+	for _, row := range r.rows {
+		rowsW = max(rowsW, len(row))
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, max(len(r.cols), rowsW), colsH+len(r.rows)))
+	for x, col := range r.cols {
+		for y, c := range col {
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	for y, row := range r.rows {
+		for x, c := range row {
+			dst.SetNRGBA(x, colsH+y, c)
+		}
+	}
+	return dst
+}
+
+// writeRemovedFile writes the image composed of the removed seams into the provided file.
+// The image format is derived from the file extension. Nothing is written if no seam has been removed.
+func (p *Processor) writeRemovedFile(path string) error {
+	if p.removedSeams == nil || len(p.removedSeams.cols)+len(p.removedSeams.rows) == 0 {
+		return nil
+	}
+	format, err := ParseFormat(filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	img := p.removedSeams.image()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the removed content file: %v", err)
+	}
+	defer f.Close()
+
+	if format == FormatJPEG {
+		img = p.flatten(img)
+	}
+	if err := encodeImage(f, img, format); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoved_ShouldStitchTheRemovedSeams(t *testing.T) {
+	assert := assert.New(t)
+
+	img := texturedImage(30, 20)
+
+	proc := &Processor{
+		SobelThreshold: 4,
+		NewWidth:       25,
+		removedSeams:   &seamRecorder{},
+	}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Len(proc.removedSeams.cols, 5)
+	for _, col := range proc.removedSeams.cols {
+		assert.Len(col, 20)
+	}
+
+	proc = &Processor{
+		SobelThreshold: 4,
+		NewHeight:      17,
+		removedSeams:   &seamRecorder{},
+	}
+	_, err = proc.Resize(img)
+	assert.NoError(err)
+	assert.Len(proc.removedSeams.rows, 3)
+
+	for _, row := range proc.removedSeams.rows {
+		assert.Len(row, 30)
+	}
+	assert.Equal(image.Rect(0, 0, 30, 3), proc.removedSeams.image().Bounds())
+}
+
+func TestRemoved_ShouldKeepTheSeamOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	seam := []Seam{{X: 1, Y: 2}, {X: 0, Y: 1}, {X: 1, Y: 0}}
+
+	rec := &seamRecorder{}
+	rec.record(img, seam, false)
+	rec.record(img, seam, true)
+
+	assert.Equal([]color.NRGBA{
+		{R: 1, G: 0, A: 255}, {R: 0, G: 1, A: 255}, {R: 1, G: 2, A: 255},
+	}, rec.cols[0])
+	// The first row of the rotated image is the last column of the canonical image.
+	assert.Equal([]color.NRGBA{
+		{R: 1, G: 2, A: 255}, {R: 0, G: 1, A: 255}, {R: 1, G: 0, A: 255},
+	}, rec.rows[0])
+
+	dst := rec.image()
+	assert.Equal(image.Rect(0, 0, 3, 4), dst.Bounds())
+	assert.Equal(color.NRGBA{R: 1, G: 2, A: 255}, dst.NRGBAAt(0, 3))
+	assert.Equal(color.NRGBA{}, dst.NRGBAAt(2, 0))
+
+	var nilRec *seamRecorder
+	nilRec.record(img, seam, false)
+}
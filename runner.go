@@ -0,0 +1,393 @@
+package caire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esimov/caire/utils"
+)
+
+// maxWorkers sets the maximum number of concurrently running workers.
+const maxWorkers = 20
+
+// ValidExtensions contains the supported image extensions, shared by the runner and the worker server.
+var ValidExtensions = []string{".jpg", ".png", ".jpeg", ".bmp", ".gif"}
+
+// Runner resizes the images of a source into a destination, using the options of a processor.
+// The source can be an image file, the URL of an image, a stream, or a directory, whose images
+// are processed recursively and concurrently. Unlike the command line tool, the runner doesn't
+// print anything: the outcome of each image is reported through the OnResult hook and the
+// returned summary.
+type Runner struct {
+	// Processor holds the resizing options. The images of a directory are processed by its clones,
+	// the single images by the processor itself.
+	Processor *Processor
+	// Src is the path of the source image or directory, or the URL of the source image.
+	// If empty, the source image is read from Input.
+	Src   string
+	Input io.Reader
+	// Dst is the path of the destination image, or of the destination directory if the source
+	// is a directory. If empty, the resized image is written into Output.
+	Dst    string
+	Output io.Writer
+	// Workers is the number of images of a directory processed concurrently.
+	// If zero, it's the number of CPUs.
+	Workers int
+	// SeamWorkers is the number of goroutines used inside an image, in case the processor
+	// doesn't define it. If zero, all the CPUs are used, since the images are carved one at a time.
+	SeamWorkers int
+	// Fetcher is used for downloading the source image in case it's provided as an URL.
+	// If nil, a fetcher with the default settings is used.
+	Fetcher *utils.Fetcher
+	// NameFunc derives the output path of the images processed from a directory, receiving
+	// the path of the source image. The relative paths are resolved against the destination
+	// directory and the missing folders are created, so custom layouts (date folders, hash
+	// names) can be implemented. If nil, the source file name is kept in the destination folder.
+	NameFunc func(src string) string
+	// OnResult is called after each processed image, from the goroutine calling Run,
+	// so it can be used for reporting the progress of a batch.
+	OnResult func(RunResult)
+}
+
+// RunResult is the outcome of an image processed by the runner.
+type RunResult struct {
+	// Src and Dst are the source and destination paths, empty for the streams.
+	Src, Dst string
+	Err      error
+	Elapsed  time.Duration
+	// Processor is the processor which has resized the image, holding the decisions
+	// of the automatic options (ex. AxisDecision) and the warnings.
+	Processor *Processor
+}
+
+// RunSummary is the summary of the images processed by the runner.
+type RunSummary struct {
+	Processed int
+	Failed    int
+	Results   []RunResult
+	Elapsed   time.Duration
+}
+
+// Run resizes the source images. The processing of a directory stops at the first error
+// of walking the directory, while the errors of the individual images are reported in the
+// summary. The error is returned only if the source or the destination can't be accessed.
+// The destination file of the failed images is removed.
+func (r *Runner) Run(ctx context.Context) (*RunSummary, error) {
+	p := r.Processor
+	if p == nil {
+		return nil, errors.New("the runner requires a processor")
+	}
+	start := time.Now()
+	sum := new(RunSummary)
+	report := func(res RunResult) {
+		if res.Err != nil {
+			sum.Failed++
+		} else {
+			sum.Processed++
+		}
+		sum.Results = append(sum.Results, res)
+		if r.OnResult != nil {
+			r.OnResult(res)
+		}
+	}
+
+	if r.Src == "" {
+		if r.Input == nil {
+			return nil, errors.New("the runner requires a source path or an input stream")
+		}
+		if err := r.checkOutput(); err != nil {
+			return nil, err
+		}
+		r.setSeamWorkers(p)
+		report(r.process(ctx, p, r.Input, r.Dst))
+		sum.Elapsed = time.Since(start)
+		return sum, nil
+	}
+
+	src := r.Src
+	if utils.IsValidUrl(src) {
+		fetcher := r.Fetcher
+		if fetcher == nil {
+			fetcher = utils.NewFetcher()
+		}
+		f, err := utils.DownloadImageWith(ctx, fetcher, src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the source image: %w", err)
+		}
+		defer os.Remove(f.Name())
+		f.Close()
+		src = f.Name()
+	}
+
+	fi, err := os.Stat(longPath(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the source image: %w", err)
+	}
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		if r.Dst == "" {
+			return nil, errors.New("the destination of a directory should be a directory")
+		}
+		if err := r.runDir(ctx, p, report); err != nil {
+			return sum, err
+		}
+	case mode.IsRegular() || mode&os.ModeNamedPipe != 0:
+		if err := r.checkOutput(); err != nil {
+			return nil, err
+		}
+		r.setSeamWorkers(p)
+
+		f, err := os.Open(longPath(src))
+		if err != nil {
+			return nil, fmt.Errorf("unable to open the source file: %w", err)
+		}
+		defer f.Close()
+
+		res := r.process(ctx, p, f, r.Dst)
+		// The downloaded images are reported under their URL.
+		res.Src = r.Src
+		report(res)
+	default:
+		return nil, fmt.Errorf("unsupported source file: %s", r.Src)
+	}
+	sum.Elapsed = time.Since(start)
+	return sum, nil
+}
+
+// checkOutput validates the destination of a single image.
+func (r *Runner) checkOutput() error {
+	if r.Dst == "" {
+		if r.Output == nil {
+			return errors.New("the runner requires a destination path or an output stream")
+		}
+		return nil
+	}
+	if ext := filepath.Ext(r.Dst); !isValidExtension(ext, ValidExtensions) && r.Processor.OutputFormat == "" {
+		return fmt.Errorf("%v file type not supported", ext)
+	}
+	return nil
+}
+
+// runDir processes recursively the images of the source directory concurrently.
+func (r *Runner) runDir(ctx context.Context, p *Processor, report func(RunResult)) error {
+	if _, err := os.Stat(longPath(r.Dst)); err != nil {
+		if err := os.Mkdir(longPath(r.Dst), 0755); err != nil {
+			return fmt.Errorf("unable to create the destination folder: %w", err)
+		}
+	}
+	p.Preview = false
+
+	// Limit the concurrently running workers to maxWorkers.
+	workers := r.Workers
+	if workers <= 0 || workers > maxWorkers {
+		workers = runtime.NumCPU()
+	}
+	// Throttle the workers to the share of the CPUs defined by the CPU limit.
+	if p.CPULimit > 0 && p.CPULimit < 1 {
+		workers = min(workers, cpuShare(p.CPULimit))
+	}
+	r.setSeamWorkers(p)
+
+	var (
+		wg      sync.WaitGroup
+		results = make(chan RunResult)
+		done    = make(chan interface{})
+	)
+	paths, errc := walkDir(done, longPath(r.Src), ValidExtensions)
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			r.consumer(ctx, p, results, done, paths)
+		}()
+	}
+	// Close the channel after the values are consumed.
+	go func() {
+		defer close(results)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				close(done)
+				return <-errc
+			}
+			report(res)
+		case <-ctx.Done():
+			close(done)
+			for range results {
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// setSeamWorkers defines the number of goroutines used inside an image. The files are decoded and
+// encoded concurrently, but their carving is serialized by the package level lock, so a single
+// image is carved at a time, using all the CPUs.
+func (r *Runner) setSeamWorkers(p *Processor) {
+	if p.SeamWorkers != 0 {
+		return
+	}
+	p.SeamWorkers = r.SeamWorkers
+	if p.SeamWorkers == 0 {
+		p.SeamWorkers = runtime.GOMAXPROCS(0)
+	}
+}
+
+// consumer reads the path names from the paths channel and calls the resizing processor against the source image.
+func (r *Runner) consumer(
+	ctx context.Context,
+	p *Processor,
+	results chan<- RunResult,
+	done <-chan interface{},
+	paths <-chan string,
+) {
+	for src := range paths {
+		dst, err := r.outputPath(p, src)
+		res := RunResult{Src: src, Dst: dst, Err: err}
+		if err == nil {
+			// The workers are sharing the options, but not the state of the processor.
+			res = r.processFile(ctx, p.Clone(), src, dst)
+		}
+
+		select {
+		case <-done:
+			return
+		case results <- res:
+		}
+	}
+}
+
+// outputPath returns the destination path of the source image processed from a directory,
+// using the NameFunc callback if defined, and creates the missing folders of the path.
+func (r *Runner) outputPath(p *Processor, src string) (string, error) {
+	if r.NameFunc != nil {
+		dst := r.NameFunc(src)
+		if !filepath.IsAbs(dst) {
+			dst = filepath.Join(r.Dst, dst)
+		}
+		if err := os.MkdirAll(longPath(filepath.Dir(dst)), 0755); err != nil {
+			return "", fmt.Errorf("unable to create the destination folder: %w", err)
+		}
+		return dst, nil
+	}
+
+	dst := filepath.Join(r.Dst, filepath.Base(src))
+	// Replace the file extension in case the output format is forced.
+	if format, err := ParseFormat(p.OutputFormat); err == nil && format != "" {
+		dst = strings.TrimSuffix(dst, filepath.Ext(dst)) + "." + formatExt(format)
+	}
+	return dst, nil
+}
+
+// processFile resizes the source image file into the destination file.
+func (r *Runner) processFile(ctx context.Context, p *Processor, src, dst string) RunResult {
+	f, err := os.Open(longPath(src))
+	if err != nil {
+		return RunResult{Src: src, Dst: dst, Err: fmt.Errorf("unable to open the source file: %w", err), Processor: p}
+	}
+	defer f.Close()
+
+	res := r.process(ctx, p, f, dst)
+	res.Src = src
+	return res
+}
+
+// process resizes the source image into the destination file, or into the output stream
+// if the destination is empty. The destination file is removed in case of an error.
+func (r *Runner) process(ctx context.Context, p *Processor, src io.Reader, dst string) RunResult {
+	start := time.Now()
+	res := RunResult{Dst: dst, Processor: p}
+
+	w := r.Output
+	if dst != "" {
+		f, err := os.OpenFile(longPath(dst), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			res.Err = fmt.Errorf("unable to create the destination file: %w", err)
+			return res
+		}
+		defer func() {
+			if err := f.Close(); err != nil && res.Err == nil {
+				res.Err = err
+			}
+			if res.Err != nil {
+				os.Remove(longPath(dst))
+			}
+		}()
+		w = f
+	}
+	res.Err = p.ProcessContext(ctx, src, w)
+	res.Elapsed = time.Since(start)
+	return res
+}
+
+// walkDir starts a new goroutine to walk the specified directory tree
+// in recursive manner and sends the path of each regular file to a new channel.
+// It finishes in case the done channel is getting closed. The extended-length
+// Windows paths are sent in their regular form.
+func walkDir(
+	done <-chan interface{},
+	src string,
+	srcExts []string,
+) (<-chan string, <-chan error) {
+	pathChan := make(chan string)
+	errChan := make(chan error, 1)
+
+	go func() {
+		// Close the paths channel after Walk returns.
+		defer close(pathChan)
+
+		errChan <- filepath.Walk(src, func(path string, f os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !f.Mode().IsRegular() {
+				return nil
+			}
+
+			// Check the file extension.
+			isFileSupported := isValidExtension(filepath.Ext(f.Name()), srcExts)
+
+			// Sniff the content of the files having unknown extension,
+			// like the temporary files or the mislabeled uploads.
+			if !isFileSupported {
+				if format, err := DetectFileFormat(path); err == nil && format != "" {
+					isFileSupported = true
+				}
+			}
+
+			if isFileSupported {
+				select {
+				case <-done:
+					return errors.New("directory walk cancelled")
+				case pathChan <- trimExtendedPath(path):
+				}
+			}
+			return nil
+		})
+	}()
+	return pathChan, errChan
+}
+
+// isValidExtension checks for the supported extensions. The extensions are
+// matched case insensitively, like the file names on Windows and macOS.
+func isValidExtension(ext string, extensions []string) bool {
+	for _, ex := range extensions {
+		if strings.EqualFold(ex, ext) {
+			return true
+		}
+	}
+	return false
+}
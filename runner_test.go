@@ -0,0 +1,117 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_ShouldDeriveTheOutputPath(t *testing.T) {
+	assert := assert.New(t)
+
+	dest := t.TempDir()
+	r := &Runner{Dst: dest}
+	dst, err := r.outputPath(&Processor{}, filepath.Join("photos", "image.png"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dest, "image.png"), dst)
+
+	dst, err = r.outputPath(&Processor{OutputFormat: FormatJPEG}, filepath.Join("photos", "image.png"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dest, "image.jpg"), dst)
+
+	r.NameFunc = func(src string) string {
+		return filepath.Join("2024", "01", "thumb-"+filepath.Base(src))
+	}
+	dst, err = r.outputPath(&Processor{}, filepath.Join("photos", "image.png"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dest, "2024", "01", "thumb-image.png"), dst)
+	info, err := os.Stat(filepath.Dir(dst))
+	assert.NoError(err)
+	assert.True(info.IsDir())
+
+	abs := filepath.Join(t.TempDir(), "out", "image.png")
+	r.NameFunc = func(string) string { return abs }
+	dst, err = r.outputPath(&Processor{}, "image.png")
+	assert.NoError(err)
+	assert.Equal(abs, dst)
+}
+
+func TestRunner_ShouldProcessTheDirectories(t *testing.T) {
+	assert := assert.New(t)
+
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	assert.NoError(os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	for _, name := range []string{"a.png", filepath.Join("sub", "b.png")} {
+		f, err := os.Create(filepath.Join(src, name))
+		assert.NoError(err)
+		assert.NoError(png.Encode(f, subjectsImage(40, 30, 5, 20)))
+		f.Close()
+	}
+	// The corrupted image is reported, without stopping the batch.
+	assert.NoError(os.WriteFile(filepath.Join(src, "c.png"), []byte("not an image"), 0644))
+
+	var reported int
+	r := &Runner{
+		Processor: &Processor{NewWidth: 30, SobelThreshold: 2},
+		Src:       src,
+		Dst:       dst,
+		Workers:   2,
+		OnResult:  func(RunResult) { reported++ },
+	}
+	sum, err := r.Run(context.Background())
+	assert.NoError(err)
+	assert.Equal(2, sum.Processed)
+	assert.Equal(1, sum.Failed)
+	assert.Len(sum.Results, 3)
+	assert.Equal(3, reported)
+
+	for _, res := range sum.Results {
+		if filepath.Base(res.Src) == "c.png" {
+			assert.Error(res.Err)
+			_, err := os.Stat(res.Dst)
+			assert.True(os.IsNotExist(err))
+			continue
+		}
+		assert.NoError(res.Err)
+		assert.NotNil(res.Processor)
+		// The carving is serialized, so each image is carved using all the CPUs.
+		assert.Equal(runtime.GOMAXPROCS(0), res.Processor.SeamWorkers)
+		f, err := os.Open(res.Dst)
+		assert.NoError(err)
+		img, err := png.Decode(f)
+		f.Close()
+		assert.NoError(err)
+		assert.Equal(image.Rect(0, 0, 30, 30), img.Bounds())
+	}
+}
+
+func TestRunner_ShouldProcessTheStreams(t *testing.T) {
+	assert := assert.New(t)
+
+	var in, out bytes.Buffer
+	assert.NoError(png.Encode(&in, subjectsImage(40, 30, 5, 20)))
+
+	r := &Runner{
+		Processor: &Processor{NewWidth: 30, SobelThreshold: 2, OutputFormat: FormatPNG},
+		Input:     &in,
+		Output:    &out,
+	}
+	sum, err := r.Run(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, sum.Processed)
+	img, err := png.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 30, 30), img.Bounds())
+
+	_, err = (&Runner{Processor: &Processor{}, Src: filepath.Join(t.TempDir(), "missing.png"), Dst: "out.png"}).Run(context.Background())
+	assert.Error(err)
+	_, err = (&Runner{Processor: &Processor{}, Input: &in, Dst: "out.txt"}).Run(context.Background())
+	assert.Error(err)
+}
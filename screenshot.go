@@ -0,0 +1,197 @@
+package caire
+
+import (
+	"image"
+	"sort"
+
+	"github.com/esimov/caire/filters"
+	"github.com/esimov/caire/utils"
+)
+
+const (
+	// uiEdgeThreshold is the minimum luminance difference of the neighbouring pixels
+	// considered an edge. The flat UI captures have sharp edges over uniform backgrounds.
+	uiEdgeThreshold = 24
+	// uiGap is the maximum distance of the edges merged into the same element,
+	// which groups the glyphs into text blocks.
+	uiGap = 4
+	// uiMinLine is the minimum length of the straight edges considered panel borders.
+	uiMinLine = 48
+	// uiMaxElements is the maximum number of the detected elements, the largest ones are kept.
+	uiMaxElements = 256
+)
+
+// DetectUIElements finds the rectangular UI elements of a screenshot using the density of the
+// straight edges: the long horizontal and vertical edges are reported as panel borders, while
+// the clusters of the remaining edges (buttons, icons, text blocks) as element rectangles. The
+// elements are returned as protected regions, since the generic energy map performs poorly on
+// the flat UI captures, where the seams are cutting through the text and the widget borders.
+func DetectUIElements(img *image.NRGBA) []Region {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 2 || height < 2 {
+		return nil
+	}
+
+	gray := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			gray[y*width+x] = int(filters.Luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2]))
+		}
+	}
+
+	// The horizontal edges are between vertically adjacent pixels and vice versa.
+	hEdge := make([]bool, width*height)
+	vEdge := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if y+1 < height && utils.Abs(gray[i]-gray[i+width]) > uiEdgeThreshold {
+				hEdge[i] = true
+			}
+			if x+1 < width && utils.Abs(gray[i]-gray[i+1]) > uiEdgeThreshold {
+				vEdge[i] = true
+			}
+		}
+	}
+
+	var regions []Region
+	busy := make([]bool, width*height)
+	for i := range busy {
+		busy[i] = hEdge[i] || vEdge[i]
+	}
+
+	// The long straight edges are the borders of the panels. They are protected as thin
+	// regions and removed from the edge map, otherwise they would merge all the elements.
+	minLine := max(uiMinLine, min(width, height)/4)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; {
+			end := x
+			for end < width && hEdge[y*width+end] {
+				end++
+			}
+			if end-x >= minLine {
+				regions = append(regions, Region{Name: "ui-border", Rect: image.Rect(x, y, end, y+2), Priority: PriorityMustKeep})
+				for i := x; i < end; i++ {
+					busy[y*width+i] = false
+				}
+			}
+			x = end + 1
+		}
+	}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; {
+			end := y
+			for end < height && vEdge[end*width+x] {
+				end++
+			}
+			if end-y >= minLine {
+				regions = append(regions, Region{Name: "ui-border", Rect: image.Rect(x, y, x+2, end), Priority: PriorityMustKeep})
+				for i := y; i < end; i++ {
+					busy[i*width+x] = false
+				}
+			}
+			y = end + 1
+		}
+	}
+
+	var elements []Region
+	for _, rect := range edgeClusters(busy, width, height) {
+		// The single edges are noise, the clusters covering most of the image are not elements.
+		if rect.Dx() < 4 || rect.Dy() < 4 || rect.Dx()*rect.Dy() > width*height/2 {
+			continue
+		}
+		elements = append(elements, Region{Name: "ui-element", Rect: rect, Priority: PriorityMustKeep})
+	}
+	sort.SliceStable(elements, func(i, j int) bool {
+		return elements[i].Rect.Dx()*elements[i].Rect.Dy() > elements[j].Rect.Dx()*elements[j].Rect.Dy()
+	})
+	if len(elements) > uiMaxElements {
+		elements = elements[:uiMaxElements]
+	}
+	regions = append(regions, elements...)
+
+	for i := range regions {
+		regions[i].Rect = regions[i].Rect.Add(bounds.Min).Intersect(bounds)
+	}
+	return regions
+}
+
+// edgeClusters groups the edge pixels closer than uiGap to each other and returns the bounding
+// rectangles of the groups, in the coordinates of the edge map.
+func edgeClusters(edges []bool, width, height int) []image.Rectangle {
+	// Union-find over the edge pixels, merging each pixel with the preceding edge pixels in its neighbourhood.
+	parent := make([]int32, width*height)
+	for i := range parent {
+		parent[i] = int32(i)
+	}
+	var find func(i int32) int32
+	find = func(i int32) int32 {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	union := func(a, b int32) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	// lastX holds the position of the last edge pixel of each row, lastY of each column,
+	// so the neighbours are found without scanning the whole window.
+	lastY := make([]int, width)
+	for i := range lastY {
+		lastY[i] = -uiGap - 1
+	}
+	for y := 0; y < height; y++ {
+		lastX := -uiGap - 1
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if !edges[i] {
+				continue
+			}
+			if x-lastX <= uiGap {
+				union(int32(y*width+lastX), int32(i))
+			}
+			// Connect to the nearest edge pixels of the previous rows in the neighbouring columns.
+			for dx := -uiGap; dx <= uiGap; dx++ {
+				if nx := x + dx; nx >= 0 && nx < width && y-lastY[nx] <= uiGap {
+					union(int32(lastY[nx]*width+nx), int32(i))
+				}
+			}
+			lastX = x
+			lastY[x] = y
+		}
+	}
+
+	boxes := make(map[int32]image.Rectangle)
+	for i, edge := range edges {
+		if !edge {
+			continue
+		}
+		x, y := i%width, i/width
+		px := image.Rect(x, y, x+1, y+1)
+		root := find(int32(i))
+		if r, ok := boxes[root]; ok {
+			boxes[root] = r.Union(px)
+		} else {
+			boxes[root] = px
+		}
+	}
+
+	rects := make([]image.Rectangle, 0, len(boxes))
+	for _, r := range boxes {
+		rects = append(rects, r)
+	}
+	// The map iteration order is random, the rectangles are sorted for deterministic results.
+	sort.Slice(rects, func(i, j int) bool {
+		if rects[i].Min.Y != rects[j].Min.Y {
+			return rects[i].Min.Y < rects[j].Min.Y
+		}
+		return rects[i].Min.X < rects[j].Min.X
+	})
+	return rects
+}
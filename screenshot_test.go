@@ -0,0 +1,84 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenshot_ShouldDetectTheUIElements(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 160, 100))
+	fill := func(r image.Rectangle, c color.NRGBA) {
+		draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+	fill(img.Bounds(), color.NRGBA{R: 240, G: 240, B: 240, A: 255})
+	// A toolbar separated by a border from the content.
+	fill(image.Rect(0, 20, 160, 21), color.NRGBA{R: 120, G: 120, B: 120, A: 255})
+	// A button.
+	fill(image.Rect(100, 50, 140, 66), color.NRGBA{R: 30, G: 90, B: 200, A: 255})
+	// A text block made of glyph-like strokes.
+	for x := 10; x < 60; x += 4 {
+		fill(image.Rect(x, 40, x+2, 48), color.NRGBA{A: 255})
+		fill(image.Rect(x, 52, x+2, 60), color.NRGBA{A: 255})
+	}
+
+	regions := DetectUIElements(img)
+	var border bool
+	var elements []image.Rectangle
+	for _, r := range regions {
+		assert.Equal(PriorityMustKeep, r.Priority)
+		switch r.Name {
+		case "ui-border":
+			if r.Rect.Min.Y >= 18 && r.Rect.Max.Y <= 23 && r.Rect.Dx() == 160 {
+				border = true
+			}
+		case "ui-element":
+			elements = append(elements, r.Rect)
+		}
+	}
+	assert.True(border)
+	assert.Len(elements, 2)
+
+	contains := func(r image.Rectangle) bool {
+		for _, e := range elements {
+			if r.In(e.Inset(-1)) {
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(contains(image.Rect(100, 50, 140, 66)))
+	assert.True(contains(image.Rect(10, 40, 58, 60)))
+
+	assert.Empty(DetectUIElements(image.NewNRGBA(image.Rect(0, 0, 50, 50))))
+}
+
+func TestScreenshot_ShouldKeepTheTextBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 120, 60))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 240, G: 240, B: 240, A: 255}}, image.Point{}, draw.Src)
+	for x := 40; x < 80; x += 4 {
+		draw.Draw(img, image.Rect(x, 20, x+2, 40), &image.Uniform{color.NRGBA{A: 255}}, image.Point{}, draw.Src)
+	}
+
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 50, Screenshot: true}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 60), res.Bounds())
+
+	// None of the strokes are removed.
+	var strokes int
+	nrgba := res.(*image.NRGBA)
+	for x := 0; x < 50; x++ {
+		if nrgba.NRGBAAt(x, 30).R < 100 && (x == 0 || nrgba.NRGBAAt(x-1, 30).R >= 100) {
+			strokes++
+		}
+	}
+	assert.Equal(10, strokes)
+}
@@ -0,0 +1,140 @@
+package caire
+
+import "image"
+
+// seamBandWidth is the width in pixels of the bands in which the seams are counted
+// for the MaxSeamsPerRegion option.
+const seamBandWidth = 64
+
+// seamBands counts the seams crossing the bands of seamBandWidth columns of the image carved
+// along one axis. The band of each pixel is carried along with the seams, so the bands refer
+// to the columns of the image at the beginning of the pass, even if the seams are wandering.
+type seamBands struct {
+	width, height int
+	rotated       bool
+	// band holds the band index of each pixel in row-major order.
+	band []int32
+	// count holds the number of seams crossing each band.
+	count []int
+}
+
+// newSeamBands returns the band tracker of an image of the provided size.
+func newSeamBands(width, height int, rotated bool) *seamBands {
+	b := &seamBands{
+		width:   width,
+		height:  height,
+		rotated: rotated,
+		band:    make([]int32, width*height),
+		count:   make([]int, (width+seamBandWidth-1)/seamBandWidth),
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			b.band[y*width+x] = int32(x / seamBandWidth)
+		}
+	}
+	return b
+}
+
+// trackSeamBands starts counting the seams at the beginning of each pass, since the vertical
+// resizing (carried out on the rotated image) is counting the seams crossing the rows.
+func (p *Processor) trackSeamBands(img *image.NRGBA) {
+	if p.MaxSeamsPerRegion <= 0 {
+		return
+	}
+	if p.bands == nil || p.bands.rotated != p.vRes {
+		p.bands = newSeamBands(img.Bounds().Dx(), img.Bounds().Dy(), p.vRes)
+	}
+}
+
+// crossed increments the seam count of the bands crossed by the seam.
+func (b *seamBands) crossed(seams []Seam) {
+	seen := make(map[int32]bool)
+	for _, s := range seams {
+		if band := b.band[s.Y*b.width+s.X]; !seen[band] {
+			seen[band] = true
+			b.count[band]++
+		}
+	}
+}
+
+// remove counts the seam, then removes its pixels from the tracked bands.
+func (b *seamBands) remove(seams []Seam) {
+	if b == nil {
+		return
+	}
+	b.crossed(seams)
+
+	pos := make([]int, b.height)
+	for _, s := range seams {
+		pos[s.Y] = s.X
+	}
+	dst := make([]int32, 0, (b.width-1)*b.height)
+	for y := 0; y < b.height; y++ {
+		row := b.band[y*b.width : (y+1)*b.width]
+		dst = append(dst, row[:pos[y]]...)
+		dst = append(dst, row[pos[y]+1:]...)
+	}
+	b.band = dst
+	b.width--
+}
+
+// insert counts the seam, then duplicates its pixels in the tracked bands.
+func (b *seamBands) insert(seams []Seam) {
+	if b == nil {
+		return
+	}
+	b.crossed(seams)
+
+	pos := make([]int, b.height)
+	for _, s := range seams {
+		pos[s.Y] = s.X
+	}
+	dst := make([]int32, 0, (b.width+1)*b.height)
+	for y := 0; y < b.height; y++ {
+		row := b.band[y*b.width : (y+1)*b.width]
+		dst = append(dst, row[:pos[y]+1]...)
+		dst = append(dst, row[pos[y]:]...)
+	}
+	b.band = dst
+	b.width++
+}
+
+// saturated reports whether any of the bands has reached the seam limit.
+func (b *seamBands) saturated(limit int) bool {
+	for _, n := range b.count {
+		if n >= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// applySeamBands raises the energy of the bands crossed by MaxSeamsPerRegion seams to the
+// maximum, so the next seams are passing through the other areas of the image. This spreads
+// the distortion across the image, instead of collapsing the content of the low energy areas.
+// When all the bands are saturated the limit can't be enforced and the energy is left unaltered.
+func (p *Processor) applySeamBands(sobel *image.NRGBA) {
+	b := p.bands
+	if b == nil || b.width != sobel.Bounds().Dx() || b.height != sobel.Bounds().Dy() {
+		return
+	}
+	limit := p.MaxSeamsPerRegion
+	var free bool
+	for _, n := range b.count {
+		free = free || n < limit
+	}
+	if !free || !b.saturated(limit) {
+		return
+	}
+
+	bounds := sobel.Bounds()
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if b.count[b.band[y*b.width+x]] < limit {
+				continue
+			}
+			i := sobel.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			sobel.Pix[i], sobel.Pix[i+1], sobel.Pix[i+2] = 0xff, 0xff, 0xff
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeamBands_ShouldCarryTheBands(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newSeamBands(130, 2, false)
+	assert.Len(b.count, 3)
+
+	// The seam crosses the first two bands.
+	b.remove([]Seam{{X: 64, Y: 1}, {X: 63, Y: 0}})
+	assert.Equal([]int{1, 1, 0}, b.count)
+	assert.Equal(129, b.width)
+	assert.Equal(int32(1), b.band[63])
+	assert.Equal(int32(0), b.band[129+63])
+
+	b.insert([]Seam{{X: 128, Y: 1}, {X: 128, Y: 0}})
+	assert.Equal([]int{1, 1, 1}, b.count)
+	assert.Equal(130, b.width)
+	assert.Len(b.band, 260)
+	assert.True(b.saturated(1))
+	assert.False(b.saturated(2))
+}
+
+func TestSeamBands_ShouldLimitTheSeamDensity(t *testing.T) {
+	assert := assert.New(t)
+
+	// A textured image with a flat area, which attracts all the seams without the limit.
+	img := texturedImage(256, 40)
+	fillGray(img, image.Rect(70, 0, 100, 40), 128)
+
+	// busiest returns the highest number of seams crossing the same band.
+	busiest := func(p *Processor) int {
+		_, err := p.Resize(img)
+		assert.NoError(err)
+		bands := make([]int, 4)
+		for _, path := range p.tracker.removed {
+			crossed := make(map[int]bool)
+			for _, pt := range path {
+				crossed[pt.X/seamBandWidth] = true
+			}
+			for band := range crossed {
+				bands[band]++
+			}
+		}
+		return max(bands[0], bands[1], bands[2], bands[3])
+	}
+
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 244, TrackCoords: true}
+	assert.Equal(12, busiest(proc))
+
+	proc.MaxSeamsPerRegion = 4
+	assert.LessOrEqual(busiest(proc), 4)
+	for _, n := range proc.bands.count {
+		assert.LessOrEqual(n, 4)
+	}
+}
@@ -0,0 +1,165 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// SeamHistogram counts the seams passing through each column and row of the source image.
+// When most of the seams are funneled through the same area the content there is visibly
+// distorted, which is a hint for adjusting the options (like the blur radius or the masks).
+type SeamHistogram struct {
+	// Width and Height are the size of the source image.
+	Width, Height int
+	// Columns holds for each column of the source image the number of vertical seams
+	// (changing the width) passing through it, while Rows holds for each row the number
+	// of horizontal seams (changing the height). The removed and the inserted seams are
+	// counted together.
+	Columns, Rows []int
+
+	// counts holds the number of seams crossing each pixel of the source image.
+	counts []int
+}
+
+// SeamHistogram returns the histogram of the seams of the last resizing operation. The seams
+// are tracked only if the TrackCoords or the HeatmapPath options are set, otherwise it returns nil.
+func (p *Processor) SeamHistogram() *SeamHistogram {
+	t := p.tracker
+	if t == nil {
+		return nil
+	}
+	h := &SeamHistogram{
+		Width:   t.origW,
+		Height:  t.origH,
+		Columns: make([]int, t.origW),
+		Rows:    make([]int, t.origH),
+		counts:  make([]int, t.origW*t.origH),
+	}
+	// seen marks the columns or rows already crossed by the current seam, which can pass
+	// through the same column on multiple rows.
+	seen := make([]int, max(t.origW, t.origH))
+	add := func(n int, path []image.Point, horizontal bool) {
+		for _, pt := range path {
+			if !pt.In(image.Rect(0, 0, h.Width, h.Height)) {
+				continue
+			}
+			h.counts[pt.Y*h.Width+pt.X]++
+			if horizontal && seen[pt.Y] != n {
+				seen[pt.Y] = n
+				h.Rows[pt.Y]++
+			} else if !horizontal && seen[pt.X] != n {
+				seen[pt.X] = n
+				h.Columns[pt.X]++
+			}
+		}
+	}
+	// The seams are numbered starting with 1, since 0 marks the unseen columns.
+	n := 0
+	for i, path := range t.removed {
+		n++
+		add(n, path, t.removedH[i])
+	}
+	for i, path := range t.inserted {
+		n++
+		add(n, path, t.insertedH[i])
+	}
+	return h
+}
+
+// Concentration returns the share of the seam crossings falling on the busiest tenth of the
+// columns and rows, in the [0, 1] range. The seams spread uniformly over the image give values
+// close to 0.1, while the values approaching 1 show that the seams were funneled through the same area.
+func (h *SeamHistogram) Concentration() float64 {
+	share := func(bins []int) (int, int) {
+		var total int
+		for _, v := range bins {
+			total += v
+		}
+		sorted := slices.Clone(bins)
+		slices.SortFunc(sorted, func(a, b int) int { return b - a })
+		var top int
+		for _, v := range sorted[:min(len(sorted), max(1, len(sorted)/10))] {
+			top += v
+		}
+		return top, total
+	}
+	ct, cn := share(h.Columns)
+	rt, rn := share(h.Rows)
+	if cn+rn == 0 {
+		return 0
+	}
+	return float64(ct+rt) / float64(cn+rn)
+}
+
+// Heatmap renders the density of the seams crossing the source image, averaged over a small
+// neighbourhood of each pixel, since a pixel is removed by a single seam. The areas not crossed
+// by any seam are black, while the busiest ones are bright yellow.
+func (h *SeamHistogram) Heatmap() *image.NRGBA {
+	w, ht := h.Width, h.Height
+	r := max(2, min(w, ht)/40)
+
+	// The summed-area table of the counts gives the sum of any window in constant time.
+	sat := make([]int, (w+1)*(ht+1))
+	for y := 0; y < ht; y++ {
+		for x := 0; x < w; x++ {
+			sat[(y+1)*(w+1)+x+1] = h.counts[y*w+x] + sat[y*(w+1)+x+1] + sat[(y+1)*(w+1)+x] - sat[y*(w+1)+x]
+		}
+	}
+	density := make([]float64, w*ht)
+	var peak float64
+	for y := 0; y < ht; y++ {
+		for x := 0; x < w; x++ {
+			x0, y0, x1, y1 := max(x-r, 0), max(y-r, 0), min(x+r+1, w), min(y+r+1, ht)
+			sum := sat[y1*(w+1)+x1] - sat[y0*(w+1)+x1] - sat[y1*(w+1)+x0] + sat[y0*(w+1)+x0]
+			density[y*w+x] = float64(sum) / float64((x1-x0)*(y1-y0))
+			peak = max(peak, density[y*w+x])
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, ht))
+	for i, v := range density {
+		var c color.NRGBA
+		if peak > 0 {
+			c = heatColor(v / peak)
+		}
+		c.A = 0xff
+		img.SetNRGBA(i%w, i/w, c)
+	}
+	return img
+}
+
+// heatColor maps the value in the [0, 1] range to the black, red, yellow color ramp.
+func heatColor(v float64) color.NRGBA {
+	if v <= 0.5 {
+		return color.NRGBA{R: uint8(v * 2 * 0xff)}
+	}
+	return color.NRGBA{R: 0xff, G: uint8((v - 0.5) * 2 * 0xff)}
+}
+
+// writeHeatmapFile saves the seam heatmap into the provided file, the image format is derived
+// from the file extension.
+func (p *Processor) writeHeatmapFile(path string) error {
+	h := p.SeamHistogram()
+	if h == nil {
+		return nil
+	}
+	format, err := ParseFormat(filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the heatmap file: %v", err)
+	}
+	defer f.Close()
+
+	if err := encodeImage(f, h.Heatmap(), format); err != nil {
+		return err
+	}
+	return f.Close()
+}
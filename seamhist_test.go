@@ -0,0 +1,62 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeamHistogram_ShouldCountTheSeams(t *testing.T) {
+	assert := assert.New(t)
+
+	// A textured image with a flat vertical band, which attracts all the seams.
+	img := texturedImage(40, 20)
+	fillGray(img, image.Rect(20, 0, 28, 20), 128)
+
+	proc := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 36}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Nil(proc.SeamHistogram())
+
+	proc.TrackCoords = true
+	_, err = proc.Resize(img)
+	assert.NoError(err)
+	h := proc.SeamHistogram()
+	assert.Equal(40, h.Width)
+	assert.Equal(20, h.Height)
+	assert.Len(h.Columns, 40)
+
+	var band, total int
+	for x, n := range h.Columns {
+		if x >= 18 && x < 30 {
+			band += n
+		}
+		total += n
+	}
+	assert.GreaterOrEqual(band, 4)
+	assert.GreaterOrEqual(float64(band)/float64(total), 0.8)
+	for _, n := range h.Rows {
+		assert.Zero(n)
+	}
+	assert.Greater(h.Concentration(), 0.5)
+
+	heatmap := h.Heatmap()
+	assert.Equal(img.Bounds(), heatmap.Bounds())
+	assert.Equal(color.NRGBA{A: 0xff}, heatmap.NRGBAAt(2, 10))
+
+	// The horizontal seams are counted on the rows.
+	proc.NewWidth, proc.NewHeight = 0, 17
+	_, err = proc.Resize(img)
+	assert.NoError(err)
+	h = proc.SeamHistogram()
+	var rows int
+	for _, n := range h.Rows {
+		rows += n
+	}
+	assert.GreaterOrEqual(rows, 3)
+	for _, n := range h.Columns {
+		assert.Zero(n)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the access configuration of a worker exposed to untrusted clients.
+// Since YAML is a superset of JSON, it can be provided in either of the two formats:
+//
+//	options:
+//	  blur: 2
+//	  sobel: 4
+//	allowed_options: [width, height, face, quality]
+//	api_keys:
+//	  - name: frontend
+//	    key: 8f14e45fceea167a
+//	    rate_limit: 60
+type Config struct {
+	// Options holds the default processor options, using the same names as the query parameters.
+	Options map[string]string `yaml:"options" json:"options"`
+	// AllowedOptions lists the options the requests are allowed to override.
+	// If empty, all the options can be overridden.
+	AllowedOptions []string `yaml:"allowed_options" json:"allowed_options"`
+	// APIKeys lists the keys accepted by the worker. If empty, the requests are not authenticated.
+	APIKeys []APIKey `yaml:"api_keys" json:"api_keys"`
+}
+
+// APIKey grants access to the worker.
+type APIKey struct {
+	// Name identifies the client owning the key.
+	Name string `yaml:"name" json:"name"`
+	Key  string `yaml:"key" json:"key"`
+	// RateLimit is the number of resize requests allowed per minute, with bursts of the same size.
+	// If zero, the requests are not limited.
+	RateLimit int `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// LockedOptionError is returned when a request overrides an option locked by the worker configuration.
+type LockedOptionError struct {
+	Name string
+}
+
+func (e *LockedOptionError) Error() string {
+	return fmt.Sprintf("the %s option is locked by the server configuration", e.Name)
+}
+
+// LoadConfig reads the worker configuration from the provided file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(Config)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid server configuration: %v", err)
+	}
+	for _, name := range cfg.AllowedOptions {
+		if !utils.Contains(OptionNames, name) {
+			return nil, fmt.Errorf("invalid server configuration: unknown option %q", name)
+		}
+	}
+	for i, k := range cfg.APIKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("invalid server configuration: the API key %d is empty", i+1)
+		}
+		if k.RateLimit < 0 {
+			return nil, fmt.Errorf("invalid server configuration: the rate limit of the API key %d is negative", i+1)
+		}
+	}
+	return cfg, nil
+}
+
+// Apply configures the worker: its default options are overridden by the configured options.
+func (cfg *Config) Apply(wk *Worker) error {
+	q := url.Values{}
+	for k, v := range cfg.Options {
+		q.Set(k, v)
+	}
+	opts, err := ParseOptions(q, wk.Options)
+	if err != nil {
+		return fmt.Errorf("invalid server configuration: %v", err)
+	}
+	wk.Options = *opts
+	wk.AllowedOptions = cfg.AllowedOptions
+	wk.APIKeys = cfg.APIKeys
+	return nil
+}
+
+// parseOptions returns the processor options of the request. The default options are overridden
+// by the query parameters, unless they are locked. The locked options can still be provided
+// with their default value, this way the dispatchers sending all the options are accepted.
+func (wk *Worker) parseOptions(q url.Values) (*caire.Processor, error) {
+	if len(wk.AllowedOptions) > 0 {
+		defaults := EncodeOptions(&wk.Options)
+		for _, name := range OptionNames {
+			if !q.Has(name) || utils.Contains(wk.AllowedOptions, name) {
+				continue
+			}
+			p, err := ParseOptions(url.Values{name: {q.Get(name)}}, wk.Options)
+			if err != nil {
+				return nil, err
+			}
+			if EncodeOptions(p).Get(name) != defaults.Get(name) {
+				return nil, &LockedOptionError{Name: name}
+			}
+		}
+	}
+	return ParseOptions(q, wk.Options)
+}
+
+// optionsStatus returns the HTTP status code reporting the invalid request options.
+func optionsStatus(err error) int {
+	var locked *LockedOptionError
+	if errors.As(err, &locked) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// authorize authenticates the request and applies the rate limit of its API key to the resize
+// requests. It reports whether the request can be served, otherwise the error is written.
+func (wk *Worker) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if len(wk.APIKeys) == 0 || r.URL.Path == "/health" {
+		return true
+	}
+	key := requestKey(r)
+	var found *APIKey
+	for i := range wk.APIKeys {
+		// All the keys are compared, so the timing doesn't reveal the matching key.
+		if subtle.ConstantTimeCompare([]byte(key), []byte(wk.APIKeys[i].Key)) == 1 {
+			found = &wk.APIKeys[i]
+		}
+	}
+	if found == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="caire"`)
+		http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+		return false
+	}
+	// The status polling and the result downloads are not limited.
+	if r.Method != http.MethodPost || found.RateLimit == 0 {
+		return true
+	}
+	if wait, ok := wk.limiter(found).allow(time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// requestKey returns the API key of the request, sent as a bearer token or in the X-API-Key header.
+func requestKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(key)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// limiter returns the rate limiter of the API key, creating it on the first request.
+func (wk *Worker) limiter(key *APIKey) *rateLimiter {
+	wk.lmu.Lock()
+	defer wk.lmu.Unlock()
+
+	if wk.limiters == nil {
+		wk.limiters = make(map[string]*rateLimiter)
+	}
+	l, ok := wk.limiters[key.Key]
+	if !ok {
+		l = newRateLimiter(key.RateLimit, time.Minute)
+		wk.limiters[key.Key] = l
+	}
+	return l
+}
+
+// rateLimiter is a token bucket allowing n requests per period, with bursts of n requests.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing n requests per period.
+func newRateLimiter(n int, period time.Duration) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(n) / period.Seconds(),
+		burst:  float64(n),
+		tokens: float64(n),
+	}
+}
+
+// allow consumes a token if available, otherwise it returns the time until the next token.
+func (l *rateLimiter) allow(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/esimov/caire"
+)
+
+// The commands accepted by the daemon.
+const (
+	CmdResize = "resize"
+	CmdStatus = "status"
+	CmdCancel = "cancel"
+)
+
+// Command is a request sent to the daemon, encoded as a single line of JSON.
+type Command struct {
+	// ID identifies the resize job, it is used for cancelling the job and it is echoed in the reply.
+	ID string `json:"id,omitempty"`
+	// Cmd is the command name: resize, status or cancel.
+	Cmd string `json:"cmd"`
+	// In and Out are the source and destination image files of the resize command.
+	In  string `json:"in,omitempty"`
+	Out string `json:"out,omitempty"`
+	// Options are the resizing options, using the same names as the worker query parameters.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Reply is the response of the daemon, encoded as a single line of JSON.
+type Reply struct {
+	ID     string  `json:"id,omitempty"`
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Status describes the state of the daemon.
+type Status struct {
+	Running   string   `json:"running,omitempty"`
+	Queued    []string `json:"queued"`
+	Processed int      `json:"processed"`
+	Failed    int      `json:"failed"`
+}
+
+// Daemon is a long-running process controlled through newline delimited JSON commands,
+// received over a Unix socket or a named pipe. This way the editors and the scripts
+// can resize images without paying the startup cost of a new process for each image.
+//
+// The resize commands are replied once the image has been processed, while the status
+// and cancel commands are replied immediately, even if a resize command is in progress.
+type Daemon struct {
+	// Options holds the default processor options, which are overridden by the command options.
+	Options caire.Processor
+
+	// The processor relies on package level state, so the images are processed one at a time.
+	// The slot is acquired with a select, this way the queued jobs can be cancelled.
+	slot chan struct{}
+
+	mu        sync.Mutex
+	jobs      map[string]context.CancelFunc
+	queue     []string
+	running   string
+	processed int
+	failed    int
+	seq       int
+}
+
+// NewDaemon returns a new daemon using the provided default processor options.
+func NewDaemon(opts caire.Processor) *Daemon {
+	return &Daemon{
+		Options: opts,
+		slot:    make(chan struct{}, 1),
+		jobs:    make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve accepts the connections on the listener until the context is cancelled.
+func (d *Daemon) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			d.Handle(ctx, conn, conn)
+		}()
+	}
+}
+
+// Handle reads the commands from r and writes the replies into w, until r is exhausted.
+// It can be used directly with a pair of named pipes.
+func (d *Daemon) Handle(ctx context.Context, r io.Reader, w io.Writer) {
+	var (
+		wg  sync.WaitGroup
+		wmu sync.Mutex
+		enc = json.NewEncoder(w)
+	)
+	reply := func(rep Reply) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		enc.Encode(rep)
+	}
+	defer wg.Wait()
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var cmd Command
+		if err := json.Unmarshal(sc.Bytes(), &cmd); err != nil {
+			reply(Reply{Error: fmt.Sprintf("invalid command: %v", err)})
+			continue
+		}
+
+		switch cmd.Cmd {
+		case CmdResize:
+			id, jobCtx, err := d.enqueue(ctx, cmd.ID)
+			if err != nil {
+				reply(Reply{ID: cmd.ID, Error: err.Error()})
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := d.resize(jobCtx, id, cmd); err != nil {
+					reply(Reply{ID: id, Error: err.Error()})
+					return
+				}
+				reply(Reply{ID: id, OK: true})
+			}()
+		case CmdStatus:
+			reply(Reply{ID: cmd.ID, OK: true, Status: d.status()})
+		case CmdCancel:
+			if err := d.cancel(cmd.ID); err != nil {
+				reply(Reply{ID: cmd.ID, Error: err.Error()})
+				continue
+			}
+			reply(Reply{ID: cmd.ID, OK: true})
+		default:
+			reply(Reply{ID: cmd.ID, Error: fmt.Sprintf("unknown command: %q", cmd.Cmd)})
+		}
+	}
+}
+
+// enqueue registers a new job. A job identifier is generated if none is provided.
+func (d *Daemon) enqueue(ctx context.Context, id string) (string, context.Context, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if id == "" {
+		d.seq++
+		id = fmt.Sprintf("job-%d", d.seq)
+	}
+	if _, ok := d.jobs[id]; ok {
+		return "", nil, fmt.Errorf("duplicate job id: %q", id)
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	d.jobs[id] = cancel
+	d.queue = append(d.queue, id)
+
+	return id, jobCtx, nil
+}
+
+// resize processes a single resize command.
+func (d *Daemon) resize(ctx context.Context, id string, cmd Command) (err error) {
+	defer func() {
+		d.mu.Lock()
+		d.jobs[id]()
+		delete(d.jobs, id)
+		d.removeQueued(id)
+		if d.running == id {
+			d.running = ""
+		}
+		if err != nil {
+			d.failed++
+		} else {
+			d.processed++
+		}
+		d.mu.Unlock()
+	}()
+
+	if cmd.In == "" || cmd.Out == "" {
+		return errors.New("the resize command requires the in and out files")
+	}
+	q := url.Values{}
+	for k, v := range cmd.Options {
+		q.Set(k, v)
+	}
+	proc, err := ParseOptions(q, d.Options)
+	if err != nil {
+		return err
+	}
+	proc.Preview = false
+
+	select {
+	case d.slot <- struct{}{}:
+		defer func() { <-d.slot }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// The job might have been cancelled right before acquiring the slot.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.removeQueued(id)
+	d.running = id
+	d.mu.Unlock()
+
+	src, err := os.Open(cmd.In)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(cmd.Out)
+	if err != nil {
+		return err
+	}
+	if err := proc.ProcessContext(ctx, src, dst); err != nil {
+		dst.Close()
+		os.Remove(cmd.Out)
+		return err
+	}
+	return dst.Close()
+}
+
+// removeQueued removes the job from the queue. The caller should hold the lock.
+func (d *Daemon) removeQueued(id string) {
+	for i, qid := range d.queue {
+		if qid == id {
+			d.queue = append(d.queue[:i], d.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// cancel cancels a queued or running job.
+func (d *Daemon) cancel(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cancel, ok := d.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown job id: %q", id)
+	}
+	cancel()
+	return nil
+}
+
+// status returns a snapshot of the daemon state.
+func (d *Daemon) status() *Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return &Status{
+		Running:   d.running,
+		Queued:    append([]string{}, d.queue...),
+		Processed: d.processed,
+		Failed:    d.failed,
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed demo.html
+var demoPage []byte
+
+// NewDemo returns an http.Handler serving a single page web interface at the root path,
+// where the users can upload an image and adjust the resizing options, following the carving
+// progress. The page relies on the streamed resize requests, the rest of the requests being
+// handled by the worker.
+func NewDemo(wk *Worker) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(demoPage)
+	})
+	mux.Handle("/", wk)
+	return mux
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// Dispatcher shards the images from a directory between the worker machines
+// and gathers the resized images into the destination directory.
+type Dispatcher struct {
+	// Workers contains the addresses of the worker machines.
+	Workers []string
+	// Options holds the resizing options sent to the workers.
+	Options *caire.Processor
+	// Concurrency defines the number of concurrent requests sent to each worker.
+	Concurrency int
+	// Client is the HTTP client used for communicating with the workers.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+	// APIKey is sent to the workers requiring authentication.
+	APIKey string
+}
+
+// Result holds the outcome of an image processed by a worker.
+type Result struct {
+	Path   string
+	Worker string
+	Err    error
+}
+
+// Dispatch walks the source directory recursively and distributes the supported
+// images between the workers. The resized images are saved into the destination
+// directory, keeping their relative path. In case a worker fails, the image is
+// retried on the other workers, up until all of them have been tried.
+// The returned channel is closed when all the images have been processed.
+func (d *Dispatcher) Dispatch(ctx context.Context, src, dst string) <-chan Result {
+	var (
+		jobs    = make(chan string)
+		results = make(chan Result)
+		pending sync.WaitGroup
+		wg      sync.WaitGroup
+	)
+
+	conc := d.Concurrency
+	if conc <= 0 {
+		conc = 1
+	}
+
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+
+		err := filepath.Walk(src, func(path string, f os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !f.Mode().IsRegular() || !utils.Contains(caire.ValidExtensions, strings.ToLower(filepath.Ext(path))) {
+				return nil
+			}
+			pending.Add(1)
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				pending.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+		if err != nil {
+			results <- Result{Path: src, Err: err}
+		}
+	}()
+
+	for _, worker := range d.Workers {
+		worker := workerURL(worker)
+		for i := 0; i < conc; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for path := range jobs {
+					res := Result{Path: path, Worker: worker}
+
+					rel, err := filepath.Rel(src, path)
+					if err != nil {
+						res.Err = err
+					} else {
+						out := filepath.Join(dst, rel)
+						res.Err = d.send(ctx, worker, path, out)
+
+						// In case of a failure retry the image on the other workers.
+						for _, w := range d.Workers {
+							if res.Err == nil || ctx.Err() != nil {
+								break
+							}
+							if w = workerURL(w); w != worker {
+								res.Worker, res.Err = w, d.send(ctx, w, path, out)
+							}
+						}
+					}
+					results <- res
+					pending.Done()
+				}
+			}()
+		}
+	}
+
+	go func() {
+		pending.Wait()
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// send uploads the source image to the worker and writes the resized image to the destination path.
+func (d *Dispatcher) send(ctx context.Context, worker, src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	q := EncodeOptions(d.Options)
+	q.Set("ext", strings.ToLower(filepath.Ext(src)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, worker+"/resize?"+q.Encode(), f)
+	if err != nil {
+		return err
+	}
+	if d.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.APIKey)
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("worker %s: %s: %s", worker, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, res.Body); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// workerURL normalizes the worker address to a base URL.
+func workerURL(addr string) string {
+	addr = strings.TrimRight(addr, "/")
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return addr
+}
@@ -0,0 +1,502 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The states of a queued job.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// The default settings of the job queue.
+const (
+	DefaultMaxAttempts  = 3
+	DefaultRetryDelay   = 10 * time.Second
+	DefaultJobRetention = 24 * time.Hour
+)
+
+// The buckets of the job database.
+var (
+	jobsBucket    = []byte("jobs")
+	sourcesBucket = []byte("sources")
+	resultsBucket = []byte("results")
+)
+
+// Job is a resize job submitted to the job queue.
+type Job struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Priority defines the order of the jobs, the jobs having higher priority are processed first.
+	Priority int `json:"priority"`
+	// Options are the resizing options, using the same names as the worker query parameters.
+	Options map[string]string `json:"options,omitempty"`
+	// Ext is the extension defining the output image format.
+	Ext string `json:"ext"`
+	// Attempts is the number of times the job has been processed.
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// RetryAt is the time the failed job is retried at.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	Created time.Time  `json:"created"`
+	Updated time.Time  `json:"updated"`
+	// URL is the address of the resized image, relative to the worker address, set once the job is done.
+	URL string `json:"url,omitempty"`
+}
+
+// JobQueue is a persistent queue of resize jobs, stored in an embedded bbolt database,
+// so the submitted jobs survive the restarts of the worker. The jobs are processed by
+// the priority, then in the order of submission. The failed jobs are retried with an
+// exponentially increasing delay, except the images rejected for their size, which
+// would fail again. The finished jobs and their results are kept for the retention period.
+type JobQueue struct {
+	// MaxAttempts is the number of times a job is tried. If zero, DefaultMaxAttempts is used.
+	MaxAttempts int
+	// RetryDelay is the delay of the first retry, which is doubled by each further retry.
+	// If zero, DefaultRetryDelay is used.
+	RetryDelay time.Duration
+	// Retention is the duration the finished jobs are kept for. If zero, DefaultJobRetention is used.
+	Retention time.Duration
+
+	db   *bolt.DB
+	wk   *Worker
+	wake chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// OpenJobQueue opens the job database at the provided path, creating it if it doesn't exist,
+// and attaches the queue to the worker, which processes the jobs and exposes them over HTTP.
+// The jobs interrupted by the previous shutdown are queued again.
+func OpenJobQueue(path string, wk *Worker) (*JobQueue, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open the job database: %w", err)
+	}
+	q := &JobQueue{
+		db:   db,
+		wk:   wk,
+		wake: make(chan struct{}, 1),
+		jobs: make(map[string]*Job),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, sourcesBucket, resultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		b := tx.Bucket(jobsBucket)
+		return b.ForEach(func(k, v []byte) error {
+			job := new(Job)
+			if err := json.Unmarshal(v, job); err != nil {
+				return fmt.Errorf("invalid job %q: %w", k, err)
+			}
+			if job.Status == JobRunning {
+				job.Status = JobQueued
+				if err := putJob(tx, job); err != nil {
+					return err
+				}
+			}
+			q.jobs[job.ID] = job
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	wk.jobs = q
+	return q, nil
+}
+
+// Close closes the job database. The queue should not be running.
+func (q *JobQueue) Close() error {
+	return q.db.Close()
+}
+
+// Submit adds a new job to the queue, having the source image and the resizing options.
+func (q *JobQueue) Submit(src []byte, opts url.Values, ext string, priority int) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now()
+	job := &Job{
+		ID:       id,
+		Status:   JobQueued,
+		Priority: priority,
+		Options:  make(map[string]string),
+		Ext:      ext,
+		Created:  now,
+		Updated:  now,
+	}
+	for k := range opts {
+		job.Options[k] = opts.Get(k)
+	}
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(sourcesBucket).Put([]byte(id), src); err != nil {
+			return err
+		}
+		return putJob(tx, job)
+	})
+	if err != nil {
+		return Job{}, err
+	}
+
+	q.mu.Lock()
+	q.jobs[id] = job
+	q.mu.Unlock()
+	q.notify()
+
+	return *job, nil
+}
+
+// Job returns a snapshot of the job having the provided identifier.
+func (q *JobQueue) Job(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Result returns the resized image of the finished job.
+func (q *JobQueue) Result(id string) ([]byte, error) {
+	var res []byte
+	err := q.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(resultsBucket).Get([]byte(id)); v != nil {
+			res = bytes.Clone(v)
+		}
+		return nil
+	})
+	if err == nil && res == nil {
+		err = fmt.Errorf("unknown job result: %q", id)
+	}
+	return res, err
+}
+
+// Run processes the queued jobs until the context is cancelled. The job in progress
+// at the cancellation is interrupted and queued again.
+func (q *JobQueue) Run(ctx context.Context) error {
+	for {
+		job, wait := q.next()
+		if job != nil {
+			if err := q.process(ctx, job); err != nil {
+				return err
+			}
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// notify wakes up the queue, waiting for new jobs.
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next removes the expired jobs and returns the next job to be processed. If no job is ready,
+// it returns the time until the first delayed retry, or the retention period.
+func (q *JobQueue) next() (*Job, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := q.retention()
+
+	var (
+		ready   []*Job
+		expired []string
+	)
+	for id, job := range q.jobs {
+		switch job.Status {
+		case JobDone, JobFailed:
+			if age := now.Sub(job.Updated); age >= q.retention() {
+				expired = append(expired, id)
+			} else {
+				wait = min(wait, q.retention()-age)
+			}
+		case JobQueued:
+			if job.RetryAt != nil && job.RetryAt.After(now) {
+				wait = min(wait, job.RetryAt.Sub(now))
+				continue
+			}
+			ready = append(ready, job)
+		}
+	}
+	if len(expired) > 0 {
+		q.db.Update(func(tx *bolt.Tx) error {
+			for _, id := range expired {
+				for _, name := range [][]byte{jobsBucket, sourcesBucket, resultsBucket} {
+					tx.Bucket(name).Delete([]byte(id))
+				}
+				delete(q.jobs, id)
+			}
+			return nil
+		})
+	}
+	if len(ready) == 0 {
+		return nil, wait
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		if ready[i].Priority != ready[j].Priority {
+			return ready[i].Priority > ready[j].Priority
+		}
+		if !ready[i].Created.Equal(ready[j].Created) {
+			return ready[i].Created.Before(ready[j].Created)
+		}
+		return ready[i].ID < ready[j].ID
+	})
+	return ready[0], 0
+}
+
+// process resizes the image of the job and stores the result. It returns an error only
+// if the job database can't be updated.
+func (q *JobQueue) process(ctx context.Context, job *Job) error {
+	if err := q.update(job, func(j *Job) { j.Status, j.Attempts = JobRunning, j.Attempts+1 }); err != nil {
+		return err
+	}
+	res, err := q.resize(ctx, job)
+	if ctx.Err() != nil {
+		// The job has been interrupted by the shutdown, so it's not counted as an attempt.
+		return q.update(job, func(j *Job) { j.Status, j.Attempts = JobQueued, j.Attempts-1 })
+	}
+	if err != nil {
+		return q.update(job, func(j *Job) {
+			j.Error = err.Error()
+			if j.Attempts >= q.maxAttempts() || errorStatus(err) == http.StatusRequestEntityTooLarge {
+				j.Status, j.RetryAt = JobFailed, nil
+				return
+			}
+			retry := time.Now().Add(q.retryDelay() << (j.Attempts - 1))
+			j.Status, j.RetryAt = JobQueued, &retry
+		})
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(resultsBucket).Put([]byte(job.ID), res); err != nil {
+			return err
+		}
+		if err := tx.Bucket(sourcesBucket).Delete([]byte(job.ID)); err != nil {
+			return err
+		}
+		j := *job
+		j.Status, j.Error, j.RetryAt, j.Updated = JobDone, "", nil, time.Now()
+		if err := putJob(tx, &j); err != nil {
+			return err
+		}
+		*job = j
+		return nil
+	})
+}
+
+// resize processes the source image of the job and returns the resized image.
+func (q *JobQueue) resize(ctx context.Context, job *Job) ([]byte, error) {
+	var src []byte
+	err := q.db.View(func(tx *bolt.Tx) error {
+		src = bytes.Clone(tx.Bucket(sourcesBucket).Get([]byte(job.ID)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, errors.New("the source image of the job is missing")
+	}
+
+	opts := url.Values{}
+	for k, v := range job.Options {
+		opts.Set(k, v)
+	}
+	proc, err := ParseOptions(opts, q.wk.Options)
+	if err != nil {
+		return nil, err
+	}
+	proc.Preview = false
+
+	// The output format is defined by the file extension.
+	out, err := os.CreateTemp("", "caire-*"+job.Ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(out.Name())
+
+	if err := q.wk.process(ctx, proc, bytes.NewReader(src), out); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(out.Name())
+}
+
+// update modifies the job and saves it into the database.
+func (q *JobQueue) update(job *Job, fn func(*Job)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := *job
+	fn(&j)
+	j.Updated = time.Now()
+	if err := q.db.Update(func(tx *bolt.Tx) error { return putJob(tx, &j) }); err != nil {
+		return err
+	}
+	*job = j
+	return nil
+}
+
+// putJob saves the job into the jobs bucket.
+func putJob(tx *bolt.Tx, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(jobsBucket).Put([]byte(job.ID), data)
+}
+
+func (q *JobQueue) maxAttempts() int {
+	if q.MaxAttempts > 0 {
+		return q.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+func (q *JobQueue) retryDelay() time.Duration {
+	if q.RetryDelay > 0 {
+		return q.RetryDelay
+	}
+	return DefaultRetryDelay
+}
+
+func (q *JobQueue) retention() time.Duration {
+	if q.Retention > 0 {
+		return q.Retention
+	}
+	return DefaultJobRetention
+}
+
+// submitJob adds the image received in the request body to the job queue.
+func (wk *Worker) submitJob(w http.ResponseWriter, r *http.Request) {
+	if wk.jobs == nil {
+		http.Error(w, "the job queue is not enabled", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+
+	// The options are validated upfront, so the invalid jobs are rejected right away.
+	if _, err := wk.parseOptions(q); err != nil {
+		http.Error(w, err.Error(), optionsStatus(err))
+		return
+	}
+	ext, err := outputExt(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var priority int
+	if s := q.Get("priority"); s != "" {
+		if priority, err = strconv.Atoi(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid priority parameter: %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+	src, err := io.ReadAll(wk.limitBody(w, r.Body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read the image: %v", err), readStatus(err))
+		return
+	}
+	q.Del("ext")
+	q.Del("priority")
+
+	job, err := wk.jobs.Submit(src, q, ext, priority)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJob(w, http.StatusAccepted, job)
+}
+
+// jobStatus responds with the status of the job.
+func (wk *Worker) jobStatus(w http.ResponseWriter, r *http.Request) {
+	if wk.jobs == nil {
+		http.Error(w, "the job queue is not enabled", http.StatusNotFound)
+		return
+	}
+	job, ok := wk.jobs.Job(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if job.Status == JobDone {
+		job.URL = "/jobs/" + job.ID + "/result"
+	}
+	writeJob(w, http.StatusOK, job)
+}
+
+// jobResult responds with the resized image of the finished job.
+func (wk *Worker) jobResult(w http.ResponseWriter, r *http.Request) {
+	if wk.jobs == nil {
+		http.Error(w, "the job queue is not enabled", http.StatusNotFound)
+		return
+	}
+	job, ok := wk.jobs.Job(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if job.Status != JobDone {
+		http.Error(w, fmt.Sprintf("the job is %s", job.Status), http.StatusConflict)
+		return
+	}
+	res, err := wk.jobs.Result(job.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", mime.TypeByExtension(job.Ext))
+	if _, err := w.Write(res); err != nil {
+		log.Printf("could not write the response: %v", err)
+	}
+}
+
+// writeJob responds with the JSON encoded job.
+func writeJob(w http.ResponseWriter, status int, job Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,86 @@
+// Package server implements the HTTP protocol used for distributing the image resizing
+// operations across multiple machines. A worker receives the source image together with
+// the resizing options and responds with the resized image, while the dispatcher shards
+// the images from a directory between the workers and gathers the results.
+// The daemon offers the same resizing operations to the local processes,
+// through newline delimited JSON commands sent over a Unix socket.
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/esimov/caire"
+)
+
+// OptionNames lists the query parameters accepted by ParseOptions.
+var OptionNames = []string{"width", "height", "blur", "sobel", "perc", "square", "face", "angle", "quality"}
+
+// ParseOptions returns a new processor having the base options overridden by the query parameters.
+func ParseOptions(q url.Values, base caire.Processor) (*caire.Processor, error) {
+	var err error
+	p := base
+
+	parseInt := func(name string, v *int) {
+		if s := q.Get(name); s != "" && err == nil {
+			if *v, err = strconv.Atoi(s); err != nil {
+				err = fmt.Errorf("invalid %s parameter: %q", name, s)
+			}
+		}
+	}
+	parseBool := func(name string, v *bool) {
+		if s := q.Get(name); s != "" && err == nil {
+			if *v, err = strconv.ParseBool(s); err != nil {
+				err = fmt.Errorf("invalid %s parameter: %q", name, s)
+			}
+		}
+	}
+	parseFloat := func(name string, v *float64) {
+		if s := q.Get(name); s != "" && err == nil {
+			if *v, err = strconv.ParseFloat(s, 64); err != nil {
+				err = fmt.Errorf("invalid %s parameter: %q", name, s)
+			}
+		}
+	}
+
+	parseInt("width", &p.NewWidth)
+	parseInt("height", &p.NewHeight)
+	parseInt("blur", &p.BlurRadius)
+	parseInt("sobel", &p.SobelThreshold)
+	parseBool("perc", &p.Percentage)
+	parseBool("square", &p.Square)
+	parseBool("face", &p.FaceDetect)
+	parseFloat("angle", &p.FaceAngle)
+
+	if s := q.Get("quality"); s != "" && err == nil {
+		if _, err = caire.ParseQuality(s); err != nil {
+			err = fmt.Errorf("invalid quality parameter: %q", s)
+		}
+		p.Quality = s
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// EncodeOptions encodes the processor options as query parameters.
+// This is the counterpart of ParseOptions.
+func EncodeOptions(p *caire.Processor) url.Values {
+	q := url.Values{}
+	q.Set("width", strconv.Itoa(p.NewWidth))
+	q.Set("height", strconv.Itoa(p.NewHeight))
+	q.Set("blur", strconv.Itoa(p.BlurRadius))
+	q.Set("sobel", strconv.Itoa(p.SobelThreshold))
+	q.Set("perc", strconv.FormatBool(p.Percentage))
+	q.Set("square", strconv.FormatBool(p.Square))
+	q.Set("face", strconv.FormatBool(p.FaceDetect))
+	q.Set("angle", strconv.FormatFloat(p.FaceAngle, 'f', -1, 64))
+	if p.Quality != "" {
+		q.Set("quality", p.Quality)
+	}
+
+	return q
+}
@@ -0,0 +1,590 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/esimov/caire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_ShouldRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &caire.Processor{
+		NewWidth:       120,
+		NewHeight:      80,
+		BlurRadius:     2,
+		SobelThreshold: 4,
+		FaceDetect:     true,
+		FaceAngle:      0.5,
+		Quality:        caire.QualityBest,
+	}
+	res, err := ParseOptions(EncodeOptions(p), caire.Processor{})
+	assert.NoError(err)
+	assert.Equal(*p, *res)
+
+	_, err = ParseOptions(url.Values{"width": {"abc"}}, caire.Processor{})
+	assert.Error(err)
+	_, err = ParseOptions(url.Values{"quality": {"ultra"}}, caire.Processor{})
+	assert.Error(err)
+}
+
+func TestDispatcher_ShouldResizeImages(t *testing.T) {
+	assert := assert.New(t)
+
+	src, dst := t.TempDir(), t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	assert.NoError(os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	for _, name := range []string{"a.png", "sub/b.png"} {
+		f, err := os.Create(filepath.Join(src, name))
+		assert.NoError(err)
+		assert.NoError(png.Encode(f, img))
+		f.Close()
+	}
+
+	ts := httptest.NewServer(NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2}))
+	defer ts.Close()
+
+	d := &Dispatcher{
+		// The first worker is unreachable, so the images should be rescheduled.
+		Workers: []string{"127.0.0.1:1", ts.URL},
+		Options: &caire.Processor{NewWidth: 30, BlurRadius: 1, SobelThreshold: 2},
+	}
+	var count int
+	for res := range d.Dispatch(context.Background(), src, dst) {
+		assert.NoError(res.Err)
+		count++
+	}
+	assert.Equal(2, count)
+
+	for _, name := range []string{"a.png", "sub/b.png"} {
+		f, err := os.Open(filepath.Join(dst, name))
+		assert.NoError(err)
+		out, err := png.Decode(f)
+		f.Close()
+		assert.NoError(err)
+		assert.Equal(30, out.Bounds().Dx())
+		assert.Equal(30, out.Bounds().Dy())
+	}
+}
+
+func TestWorker_ShouldRejectOversizedImages(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+
+	srv := httptest.NewServer(NewWorker(caire.Processor{MaxPixels: 1000}))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/resize?width=30&ext=.png", "image/png", bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusRequestEntityTooLarge, res.StatusCode)
+
+	// The requested output size is limited too, the missing side being preserved.
+	srv = httptest.NewServer(NewWorker(caire.Processor{MaxPixels: 2000}))
+	defer srv.Close()
+	for _, query := range []string{"width=60000&height=60000", "width=100"} {
+		res, err = http.Post(srv.URL+"/resize?"+query+"&ext=.png", "image/png", bytes.NewReader(buf.Bytes()))
+		assert.NoError(err)
+		res.Body.Close()
+		assert.Equal(http.StatusRequestEntityTooLarge, res.StatusCode, query)
+	}
+	res, err = http.Post(srv.URL+"/resize?width=60&ext=.png", "image/png", bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+}
+
+func TestWorker_ShouldRejectOversizedUploads(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+
+	wk := NewWorker(caire.Processor{SobelThreshold: 2})
+	wk.MaxUploadSize = int64(buf.Len() - 1)
+	jq, err := OpenJobQueue(filepath.Join(t.TempDir(), "jobs.db"), wk)
+	assert.NoError(err)
+	defer jq.Close()
+
+	srv := httptest.NewServer(wk)
+	defer srv.Close()
+	for _, path := range []string{"/resize", "/jobs"} {
+		res, err := http.Post(srv.URL+path+"?width=30&ext=.png", "image/png", bytes.NewReader(buf.Bytes()))
+		assert.NoError(err)
+		res.Body.Close()
+		assert.Equal(http.StatusRequestEntityTooLarge, res.StatusCode, path)
+	}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/resize?width=30&ext=.png", bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusRequestEntityTooLarge, res.StatusCode)
+
+	wk.MaxUploadSize = int64(buf.Len())
+	res, err = http.Post(srv.URL+"/jobs?width=30&ext=.png", "image/png", bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusAccepted, res.StatusCode)
+}
+
+func TestDaemon_ShouldHandleTheCommands(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	in, out := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.png")
+	f, err := os.Create(in)
+	assert.NoError(err)
+	assert.NoError(png.Encode(f, img))
+	f.Close()
+
+	sock := filepath.Join(dir, "caire.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	d := NewDaemon(caire.Processor{BlurRadius: 1, SobelThreshold: 2})
+	go func() { done <- d.Serve(ctx, l) }()
+
+	conn, err := net.Dial("unix", sock)
+	assert.NoError(err)
+	dec := json.NewDecoder(conn)
+	send := func(cmd Command) Reply {
+		assert.NoError(json.NewEncoder(conn).Encode(cmd))
+		var rep Reply
+		assert.NoError(dec.Decode(&rep))
+		return rep
+	}
+
+	rep := send(Command{ID: "a", Cmd: CmdResize, In: in, Out: out, Options: map[string]string{"width": "32"}})
+	assert.Equal(Reply{ID: "a", OK: true}, rep)
+	f, err = os.Open(out)
+	assert.NoError(err)
+	res, err := png.Decode(f)
+	f.Close()
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+
+	rep = send(Command{Cmd: CmdStatus})
+	assert.True(rep.OK)
+	assert.Equal(&Status{Queued: []string{}, Processed: 1}, rep.Status)
+
+	assert.False(send(Command{ID: "b", Cmd: CmdResize, In: in}).OK)
+	assert.False(send(Command{ID: "x", Cmd: CmdCancel}).OK)
+	assert.False(send(Command{Cmd: "rotate"}).OK)
+
+	conn.Close()
+	cancel()
+	assert.NoError(<-done)
+}
+
+func TestDaemon_ShouldCancelTheQueuedJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewDaemon(caire.Processor{})
+	// Simulate a job in progress, so the next one stays in the queue.
+	d.slot <- struct{}{}
+
+	r, w := io.Pipe()
+	var buf bytes.Buffer
+	handled := make(chan struct{})
+	go func() {
+		d.Handle(context.Background(), r, &buf)
+		close(handled)
+	}()
+
+	enc := json.NewEncoder(w)
+	assert.NoError(enc.Encode(Command{ID: "a", Cmd: CmdResize, In: "in.png", Out: "out.png"}))
+	assert.Eventually(func() bool {
+		return len(d.status().Queued) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(enc.Encode(Command{ID: "a", Cmd: CmdCancel}))
+	w.Close()
+	assert.Eventually(func() bool {
+		return len(d.status().Queued) == 0
+	}, time.Second, time.Millisecond)
+	<-d.slot
+	<-handled
+
+	dec := json.NewDecoder(&buf)
+	var replies []Reply
+	for dec.More() {
+		var rep Reply
+		assert.NoError(dec.Decode(&rep))
+		replies = append(replies, rep)
+	}
+	assert.Equal([]Reply{
+		{ID: "a", OK: true},
+		{ID: "a", Error: context.Canceled.Error()},
+	}, replies)
+	assert.Equal(1, d.status().Failed)
+}
+
+func TestWorker_ShouldStreamTheProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, img))
+
+	srv := httptest.NewServer(NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/resize?width=20&ext=.png", &buf)
+	assert.NoError(err)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	defer res.Body.Close()
+	assert.Equal("text/event-stream", res.Header.Get("Content-Type"))
+
+	var (
+		events   []string
+		progress []ProgressEvent
+		done     ResultEvent
+	)
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch events[len(events)-1] {
+		case EventProgress:
+			var ev ProgressEvent
+			assert.NoError(json.Unmarshal([]byte(data), &ev))
+			progress = append(progress, ev)
+		case EventDone:
+			assert.NoError(json.Unmarshal([]byte(data), &done))
+		}
+	}
+	assert.Equal(EventDone, events[len(events)-1])
+	assert.Len(progress, 20)
+	assert.Equal(ProgressEvent{Percent: 100, Seams: 20, Total: 20}, progress[len(progress)-1])
+
+	out, err := http.Get(srv.URL + done.URL)
+	assert.NoError(err)
+	resized, err := png.Decode(out.Body)
+	out.Body.Close()
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 20, 30), resized.Bounds())
+
+	// The results can be downloaded only once.
+	out, err = http.Get(srv.URL + done.URL)
+	assert.NoError(err)
+	out.Body.Close()
+	assert.Equal(http.StatusNotFound, out.StatusCode)
+}
+
+func TestWorker_ShouldStreamTheErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+
+	srv := httptest.NewServer(NewWorker(caire.Processor{MaxPixels: 1000}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/resize?width=30&ext=.png", &buf)
+	assert.NoError(err)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(err)
+
+	assert.True(strings.HasPrefix(string(body), "event: error\ndata: "))
+	var ev ResultEvent
+	assert.NoError(json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(string(body)), "event: error\ndata: ")), &ev))
+	assert.Equal(http.StatusRequestEntityTooLarge, ev.Status)
+	assert.Empty(ev.URL)
+}
+
+func TestJobQueue_ShouldPersistTheJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, img))
+
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	wk := NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2})
+	jq, err := OpenJobQueue(path, wk)
+	assert.NoError(err)
+
+	srv := httptest.NewServer(wk)
+	submit := func(query string) Job {
+		res, err := http.Post(srv.URL+"/jobs?"+query, "image/png", bytes.NewReader(buf.Bytes()))
+		assert.NoError(err)
+		defer res.Body.Close()
+		assert.Equal(http.StatusAccepted, res.StatusCode)
+
+		var job Job
+		assert.NoError(json.NewDecoder(res.Body).Decode(&job))
+		assert.Equal("/jobs/"+job.ID, res.Header.Get("Location"))
+		return job
+	}
+	low := submit("width=30&ext=.png")
+	high := submit("width=20&ext=.png&priority=5")
+	assert.Equal(JobQueued, low.Status)
+	assert.Equal(map[string]string{"width": "20"}, high.Options)
+	srv.Close()
+
+	// The queue is reopened, as if the worker has been restarted before processing the jobs.
+	assert.NoError(jq.Close())
+	wk = NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2})
+	jq, err = OpenJobQueue(path, wk)
+	assert.NoError(err)
+	defer jq.Close()
+
+	next, _ := jq.next()
+	assert.Equal(high.ID, next.ID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- jq.Run(ctx) }()
+
+	srv = httptest.NewServer(wk)
+	defer srv.Close()
+	status := func(id string) Job {
+		res, err := http.Get(srv.URL + "/jobs/" + id)
+		assert.NoError(err)
+		defer res.Body.Close()
+
+		var job Job
+		assert.NoError(json.NewDecoder(res.Body).Decode(&job))
+		return job
+	}
+	assert.Eventually(func() bool {
+		return status(low.ID).Status == JobDone && status(high.ID).Status == JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(<-done)
+
+	job := status(high.ID)
+	assert.Equal(1, job.Attempts)
+	assert.Equal("/jobs/"+high.ID+"/result", job.URL)
+	res, err := http.Get(srv.URL + job.URL)
+	assert.NoError(err)
+	out, err := png.Decode(res.Body)
+	res.Body.Close()
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 20, 30), out.Bounds())
+
+	res, err = http.Get(srv.URL + "/jobs/unknown")
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func TestJobQueue_ShouldRetryTheFailedJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	wk := NewWorker(caire.Processor{})
+	jq, err := OpenJobQueue(filepath.Join(t.TempDir(), "jobs.db"), wk)
+	assert.NoError(err)
+	defer jq.Close()
+	jq.MaxAttempts, jq.RetryDelay = 2, time.Hour
+
+	job, err := jq.Submit([]byte("not an image"), nil, ".png", 0)
+	assert.NoError(err)
+
+	next, _ := jq.next()
+	assert.NoError(jq.process(context.Background(), next))
+	job, _ = jq.Job(job.ID)
+	assert.Equal(JobQueued, job.Status)
+	assert.NotEmpty(job.Error)
+	assert.WithinDuration(time.Now().Add(time.Hour), *job.RetryAt, time.Minute)
+
+	// The delayed job is not ready yet.
+	next, wait := jq.next()
+	assert.Nil(next)
+	assert.Greater(wait, 59*time.Minute)
+
+	assert.NoError(jq.update(jq.jobs[job.ID], func(j *Job) { j.RetryAt = nil }))
+	next, _ = jq.next()
+	assert.NoError(jq.process(context.Background(), next))
+	job, _ = jq.Job(job.ID)
+	assert.Equal(JobFailed, job.Status)
+	assert.Equal(2, job.Attempts)
+}
+
+func TestWorker_ShouldLockTheOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(os.WriteFile(path, []byte(`
+options:
+  blur: 1
+  sobel: 2
+allowed_options: [width, height, face, quality]
+`), 0644))
+	cfg, err := LoadConfig(path)
+	assert.NoError(err)
+	wk := NewWorker(caire.Processor{BlurRadius: 4})
+	assert.NoError(cfg.Apply(wk))
+	assert.Equal(1, wk.Options.BlurRadius)
+
+	p, err := wk.parseOptions(url.Values{"width": {"30"}, "quality": {"fast"}, "blur": {"1"}})
+	assert.NoError(err)
+	assert.Equal(30, p.NewWidth)
+	assert.Equal(caire.QualityFast, p.Quality)
+
+	_, err = wk.parseOptions(url.Values{"width": {"30"}, "sobel": {"10"}})
+	var locked *LockedOptionError
+	assert.ErrorAs(err, &locked)
+	assert.Equal("sobel", locked.Name)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+	srv := httptest.NewServer(wk)
+	defer srv.Close()
+	res, err := http.Post(srv.URL+"/resize?width=30&blur=8", "image/png", &buf)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+
+	assert.NoError(os.WriteFile(path, []byte("allowed_options: [rotate]"), 0644))
+	_, err = LoadConfig(path)
+	assert.Error(err)
+}
+
+func TestWorker_ShouldAuthenticateTheRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	wk := NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2})
+	wk.APIKeys = []APIKey{{Name: "a", Key: "secret-a", RateLimit: 2}, {Name: "b", Key: "secret-b"}}
+	srv := httptest.NewServer(wk)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 30))))
+	post := func(header, key string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/resize?width=30&ext=.png", bytes.NewReader(buf.Bytes()))
+		assert.NoError(err)
+		if header != "" {
+			req.Header.Set(header, key)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		res.Body.Close()
+		return res
+	}
+
+	res, err := http.Get(srv.URL + "/health")
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	assert.Equal(http.StatusUnauthorized, post("", "").StatusCode)
+	assert.Equal(http.StatusUnauthorized, post("X-API-Key", "secret-c").StatusCode)
+
+	// The first key allows 2 requests per minute.
+	assert.Equal(http.StatusOK, post("Authorization", "Bearer secret-a").StatusCode)
+	assert.Equal(http.StatusOK, post("X-API-Key", "secret-a").StatusCode)
+	res = post("Authorization", "Bearer secret-a")
+	assert.Equal(http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal("30", res.Header.Get("Retry-After"))
+
+	// The second key is not limited.
+	for i := 0; i < 3; i++ {
+		assert.Equal(http.StatusOK, post("X-API-Key", "secret-b").StatusCode)
+	}
+}
+
+func TestRateLimiter_ShouldRefillTheTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newRateLimiter(60, time.Minute)
+	now := time.Now()
+	for i := 0; i < 60; i++ {
+		_, ok := l.allow(now)
+		assert.True(ok)
+	}
+	wait, ok := l.allow(now)
+	assert.False(ok)
+	assert.Equal(time.Second, wait)
+
+	_, ok = l.allow(now.Add(time.Second))
+	assert.True(ok)
+	// The tokens are not accumulated beyond the burst size.
+	_, ok = l.allow(now.Add(time.Hour))
+	assert.True(ok)
+	assert.InDelta(59, l.tokens, 1e-9)
+}
+
+func TestDemo_ShouldServeThePage(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(NewDemo(NewWorker(caire.Processor{BlurRadius: 1, SobelThreshold: 2})))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/")
+	assert.NoError(err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Contains(string(body), "text/event-stream")
+
+	// The rest of the requests are handled by the worker.
+	res, err = http.Get(srv.URL + "/health")
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	res, err = http.Get(srv.URL + "/index.html")
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+}
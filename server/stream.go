@@ -0,0 +1,224 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/esimov/caire"
+)
+
+// DefaultResultTTL is the default duration the images resized by the streamed requests are kept.
+const DefaultResultTTL = 10 * time.Minute
+
+// The names of the server-sent events streamed by the worker.
+const (
+	EventProgress = "progress"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// ProgressEvent is the payload of the progress events, sent each time
+// the completed percentage of the carving changes.
+type ProgressEvent struct {
+	Percent float64 `json:"percent"`
+	Seams   int     `json:"seams"`
+	Total   int     `json:"total"`
+	// ETA is the estimated remaining time in seconds.
+	ETA float64 `json:"eta"`
+}
+
+// ResultEvent is the payload of the last event of the stream. The done event provides
+// the URL of the resized image, relative to the worker address, while the error event
+// provides the error message and the status code of the equivalent plain request.
+type ResultEvent struct {
+	ID     string `json:"id,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// storedResult is an image resized by a streamed request, waiting to be downloaded.
+type storedResult struct {
+	path  string
+	ext   string
+	timer *time.Timer
+}
+
+// acceptsEventStream reports whether the client asked for the server-sent events.
+func acceptsEventStream(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(v)); err == nil && mt == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// streamResize resizes the image received in the request body, reporting the progress of the
+// carving as server-sent events. The resized image is kept until it's downloaded from the URL
+// sent in the done event, or until the ResultTTL expires.
+func (wk *Worker) streamResize(w http.ResponseWriter, r *http.Request, proc *caire.Processor, ext string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "the response streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The request body can't be read once the response has been started,
+	// so the source image is saved into a temporary file first.
+	src, err := os.CreateTemp("", "caire-src-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	if _, err := io.Copy(src, r.Body); err != nil {
+		http.Error(w, fmt.Sprintf("could not read the image: %v", err), readStatus(err))
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out, err := os.CreateTemp("", "caire-*"+ext)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, data any) {
+		if err := writeEvent(w, event, data); err != nil {
+			log.Printf("could not write the %s event: %v", event, err)
+			return
+		}
+		flusher.Flush()
+	}
+
+	// The seams are reported only when the rounded percentage changes, to limit the number of events.
+	percent := -1
+	proc.OnProgress = func(p caire.Progress) {
+		if int(p.Percent) == percent {
+			return
+		}
+		percent = int(p.Percent)
+		send(EventProgress, ProgressEvent{
+			Percent: p.Percent,
+			Seams:   p.Seams,
+			Total:   p.Total,
+			ETA:     p.ETA.Seconds(),
+		})
+	}
+
+	if err := wk.process(r.Context(), proc, src, out); err != nil {
+		os.Remove(out.Name())
+		send(EventError, ResultEvent{
+			Status: errorStatus(err),
+			Error:  fmt.Sprintf("error resizing the image: %v", err),
+		})
+		return
+	}
+	id, err := wk.storeResult(out.Name(), ext)
+	if err != nil {
+		os.Remove(out.Name())
+		send(EventError, ResultEvent{Status: http.StatusInternalServerError, Error: err.Error()})
+		return
+	}
+	send(EventDone, ResultEvent{ID: id, URL: "/results/" + id})
+}
+
+// newID returns a random identifier of the results and the jobs, which can't be guessed.
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// writeEvent writes a server-sent event having the JSON encoded data.
+func writeEvent(w io.Writer, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
+// storeResult registers the resized image for being downloaded and returns its identifier.
+// The image is removed once the ResultTTL expires.
+func (wk *Worker) storeResult(path, ext string) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	ttl := wk.ResultTTL
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+	wk.rmu.Lock()
+	defer wk.rmu.Unlock()
+
+	wk.results[id] = &storedResult{
+		path: path,
+		ext:  ext,
+		timer: time.AfterFunc(ttl, func() {
+			if res := wk.takeResult(id); res != nil {
+				os.Remove(res.path)
+			}
+		}),
+	}
+	return id, nil
+}
+
+// takeResult removes the result from the registry and returns it, or nil if it's unknown.
+func (wk *Worker) takeResult(id string) *storedResult {
+	wk.rmu.Lock()
+	defer wk.rmu.Unlock()
+
+	res, ok := wk.results[id]
+	if !ok {
+		return nil
+	}
+	res.timer.Stop()
+	delete(wk.results, id)
+	return res
+}
+
+// download responds with the image resized by a streamed request. The image can be downloaded once.
+func (wk *Worker) download(w http.ResponseWriter, r *http.Request) {
+	res := wk.takeResult(r.PathValue("id"))
+	if res == nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer os.Remove(res.path)
+
+	f, err := os.Open(res.path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(res.ext))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("could not write the response: %v", err)
+	}
+}
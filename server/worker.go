@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esimov/caire"
+	"github.com/esimov/caire/utils"
+)
+
+// DefaultMaxUploadSize is the default maximum size in bytes of the images sent to the worker.
+const DefaultMaxUploadSize = 32 << 20
+
+// Worker is an http.Handler which resizes the images received from the dispatcher.
+//
+// The following endpoints are exposed:
+//
+//	POST /resize        resizes the image sent in the request body and responds with the resized image.
+//	GET  /results/{id}  responds with the image resized by a streamed resize request.
+//	GET  /health        reports the worker availability.
+//
+// When a job queue is attached to the worker (see OpenJobQueue), the following endpoints are exposed too:
+//
+//	POST /jobs              submits the image sent in the request body as a new job, the priority
+//	                        parameter defining the order of the jobs.
+//	GET  /jobs/{id}         reports the status of the job.
+//	GET  /jobs/{id}/result  responds with the resized image of the finished job.
+//
+// The resizing options are provided as query parameters (see ParseOptions),
+// while the ext parameter defines the output image format. The images exceeding
+// the MaxPixels limit of the default options, or requested at a size exceeding it,
+// are rejected with 413 status code before the carving, like the request bodies
+// exceeding MaxUploadSize.
+//
+// The resize requests accepting the text/event-stream content type are answered with
+// server-sent events reporting the progress of the carving, the last event providing
+// the URL of the resized image (see ProgressEvent and ResultEvent).
+//
+// A worker exposed to untrusted clients can lock the options the requests are not allowed to
+// override, and can require an API key, sent as a bearer token or in the X-API-Key header.
+// The resize requests exceeding the rate limit of the key are rejected with 429 status code.
+type Worker struct {
+	// Options holds the default processor options, which are overridden by the request parameters.
+	Options caire.Processor
+	// ResultTTL is the duration the images resized by the streamed requests are kept
+	// for being downloaded. If zero, DefaultResultTTL is used.
+	ResultTTL time.Duration
+	// MaxUploadSize is the maximum size in bytes of the images sent to the resize and the job
+	// endpoints. If zero, DefaultMaxUploadSize is used.
+	MaxUploadSize int64
+	// AllowedOptions lists the options the requests are allowed to override, using the query
+	// parameter names. The other options are locked. If empty, all the options can be overridden.
+	AllowedOptions []string
+	// APIKeys lists the keys accepted by the worker. If empty, the requests are not authenticated.
+	APIKeys []APIKey
+
+	mux *http.ServeMux
+	// The processor relies on package level state,
+	// so the images are processed one at a time.
+	mu sync.Mutex
+
+	rmu     sync.Mutex
+	results map[string]*storedResult
+
+	jobs *JobQueue
+
+	lmu      sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+// NewWorker returns a new worker using the provided default processor options.
+func NewWorker(opts caire.Processor) *Worker {
+	wk := &Worker{
+		Options: opts,
+		mux:     http.NewServeMux(),
+		results: make(map[string]*storedResult),
+	}
+	wk.mux.HandleFunc("POST /resize", wk.resize)
+	wk.mux.HandleFunc("GET /results/{id}", wk.download)
+	wk.mux.HandleFunc("POST /jobs", wk.submitJob)
+	wk.mux.HandleFunc("GET /jobs/{id}", wk.jobStatus)
+	wk.mux.HandleFunc("GET /jobs/{id}/result", wk.jobResult)
+	wk.mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return wk
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (wk *Worker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !wk.authorize(w, r) {
+		return
+	}
+	wk.mux.ServeHTTP(w, r)
+}
+
+// resize processes the image received in the request body and writes the resized image into the response.
+func (wk *Worker) resize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	proc, err := wk.parseOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), optionsStatus(err))
+		return
+	}
+	proc.Preview = false
+
+	ext, err := outputExt(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = wk.limitBody(w, r.Body)
+	if acceptsEventStream(r) {
+		wk.streamResize(w, r, proc, ext)
+		return
+	}
+
+	// The output format is defined by the file extension,
+	// this is why the resized image is written into a temporary file.
+	out, err := os.CreateTemp("", "caire-*"+ext)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(out.Name())
+
+	if err := wk.process(r.Context(), proc, r.Body, out); err != nil {
+		http.Error(w, fmt.Sprintf("error resizing the image: %v", err), errorStatus(err))
+		return
+	}
+
+	res, err := os.Open(out.Name())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer res.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(ext))
+	if _, err := io.Copy(w, res); err != nil {
+		log.Printf("could not write the response: %v", err)
+	}
+}
+
+// outputExt returns the extension defining the output image format, provided by the ext parameter.
+func outputExt(q url.Values) (string, error) {
+	ext := strings.ToLower(q.Get("ext"))
+	if ext == "" {
+		ext = ".jpg"
+	}
+	if !utils.Contains(caire.ValidExtensions, ext) {
+		return "", fmt.Errorf("%v file type not supported", ext)
+	}
+	return ext, nil
+}
+
+// limitBody limits the size of the request body to MaxUploadSize.
+func (wk *Worker) limitBody(w http.ResponseWriter, body io.ReadCloser) io.ReadCloser {
+	limit := wk.MaxUploadSize
+	if limit <= 0 {
+		limit = DefaultMaxUploadSize
+	}
+	return http.MaxBytesReader(w, body, limit)
+}
+
+// readStatus returns the HTTP status code reporting the error of reading the request body.
+func readStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// process resizes the source image into the output file, which is closed afterwards.
+func (wk *Worker) process(ctx context.Context, proc *caire.Processor, src io.Reader, out *os.File) error {
+	wk.mu.Lock()
+	err := proc.ProcessContext(ctx, src, out)
+	wk.mu.Unlock()
+
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// errorStatus returns the HTTP status code reporting the resizing error.
+func errorStatus(err error) int {
+	var (
+		tooLarge  *caire.ImageTooLargeError
+		memExceed *caire.DecodeMemoryError
+		bodySize  *http.MaxBytesError
+	)
+	if errors.As(err, &tooLarge) || errors.As(err, &memExceed) || errors.As(err, &bodySize) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusUnprocessableEntity
+}
@@ -0,0 +1,77 @@
+package caire
+
+import (
+	"image"
+	"slices"
+)
+
+// DebugSnapshot records the intermediate state of the carving in memory: the energy map from
+// which each seam has been found and the path of the seam. It's meant for the tests and the
+// tooling which are inspecting the algorithm, so the seam search is always performed at full
+// resolution, which makes the records of the same image and options identical between runs.
+type DebugSnapshot struct {
+	// Steps holds the indices of the recorded steps, starting from zero. If empty, every step
+	// is recorded, which for large images holds a copy of the energy map for each seam.
+	Steps []int
+	// Snapshots holds the records of the last resize operation, in the order of the steps.
+	Snapshots []Snapshot
+
+	step int
+}
+
+// Snapshot is the record of a single seam removal or insertion.
+type Snapshot struct {
+	SeamInfo
+	// Energy is the energy map from which the seam has been found, in the orientation
+	// of the source image. The seam is not yet removed or inserted.
+	Energy *image.Gray
+}
+
+// reset drops the records of the previous resize operation.
+func (d *DebugSnapshot) reset() {
+	if d == nil {
+		return
+	}
+	d.step, d.Snapshots = 0, nil
+}
+
+// clone returns a copy of the snapshot options, without the records.
+func (d *DebugSnapshot) clone() *DebugSnapshot {
+	if d == nil {
+		return nil
+	}
+	return &DebugSnapshot{Steps: slices.Clone(d.Steps)}
+}
+
+// record stores the energy map and the seam of the current step, if the step has been requested.
+// The energy map is copied, since the carver reuses its buffer between the steps.
+func (p *Processor) recordSnapshot(c *Carver, energy *image.NRGBA, seams []Seam, inserted bool) {
+	d := p.DebugSnapshot
+	if d == nil {
+		return
+	}
+	step := d.step
+	d.step++
+	if len(d.Steps) > 0 && !slices.Contains(d.Steps, step) {
+		return
+	}
+
+	info := p.seamInfo(c, seams, inserted)
+	info.Step = step
+	width, height := energy.Bounds().Dx(), energy.Bounds().Dy()
+	if p.vRes {
+		width, height = height, width
+	}
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < energy.Bounds().Dy(); y++ {
+		for x := 0; x < energy.Bounds().Dx(); x++ {
+			// The energy map of the vertical resizing is rotated back like the seam points.
+			dx, dy := x, y
+			if p.vRes {
+				dx, dy = width-y-1, x
+			}
+			gray.Pix[gray.PixOffset(dx, dy)] = energy.Pix[energy.PixOffset(x, y)]
+		}
+	}
+	d.Snapshots = append(d.Snapshots, Snapshot{SeamInfo: info, Energy: gray})
+}
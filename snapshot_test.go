@@ -0,0 +1,70 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugSnapshot_ShouldRecordTheSelectedSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Processor{
+		BlurRadius:     1,
+		SobelThreshold: 4,
+		NewWidth:       25,
+		DebugSnapshot:  &DebugSnapshot{Steps: []int{0, 3}},
+	}
+	_, err := p.Resize(texturedImage(30, 20))
+	assert.NoError(err)
+
+	snaps := p.DebugSnapshot.Snapshots
+	assert.Len(snaps, 2)
+	assert.Equal(0, snaps[0].Step)
+	assert.Equal(3, snaps[1].Step)
+	for _, s := range snaps {
+		assert.Equal("width", s.Axis)
+		assert.False(s.Inserted)
+		assert.Len(s.Seam, 20)
+	}
+	// The energy map is recorded before the seam removal.
+	assert.Equal(image.Rect(0, 0, 30, 20), snaps[0].Energy.Bounds())
+	assert.Equal(image.Rect(0, 0, 27, 20), snaps[1].Energy.Bounds())
+}
+
+func TestDebugSnapshot_ShouldBeDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	record := func() []Snapshot {
+		p := &Processor{
+			BlurRadius:     1,
+			SobelThreshold: 4,
+			NewHeight:      16,
+			FastMode:       true,
+			DebugSnapshot:  &DebugSnapshot{},
+		}
+		_, err := p.Resize(texturedImage(30, 20))
+		assert.NoError(err)
+		return p.DebugSnapshot.Snapshots
+	}
+	first, second := record(), record()
+	assert.Len(first, 4)
+	assert.Equal(first, second)
+
+	// The records of the vertical resizing are in the orientation of the source image.
+	for i, s := range first {
+		assert.Equal("height", s.Axis)
+		assert.Len(s.Seam, 30)
+		assert.Equal(image.Rect(0, 0, 30, 20-i), s.Energy.Bounds())
+	}
+}
+
+func TestDebugSnapshot_ShouldNotShareTheRecordsBetweenClones(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Processor{DebugSnapshot: &DebugSnapshot{Steps: []int{1}}}
+	c := p.Clone()
+	assert.Equal([]int{1}, c.DebugSnapshot.Steps)
+	assert.NotSame(p.DebugSnapshot, c.DebugSnapshot)
+}
@@ -0,0 +1,119 @@
+package caire
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageVariant is one of the images of a responsive image set.
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ResponsiveImage describes the variants of an image generated for different widths,
+// so the static site generators can consume the results directly.
+type ResponsiveImage struct {
+	// Variants are ordered by increasing width.
+	Variants []ImageVariant `json:"variants"`
+	// SrcSet is the value of the srcset attribute listing the variants.
+	SrcSet string `json:"srcset"`
+	// Sizes is the optional value of the sizes attribute (ex. "(max-width: 600px) 100vw, 50vw").
+	Sizes string `json:"sizes,omitempty"`
+	Alt   string `json:"alt,omitempty"`
+}
+
+// ParseWidths parses a comma separated list of image widths, returning them in increasing order.
+func ParseWidths(s string) ([]int, error) {
+	var widths []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		w, err := strconv.Atoi(part)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid image width: %q", part)
+		}
+		if !seen[w] {
+			seen[w] = true
+			widths = append(widths, w)
+		}
+	}
+	if len(widths) == 0 {
+		return nil, fmt.Errorf("no image widths provided in %q", s)
+	}
+	sort.Ints(widths)
+	return widths, nil
+}
+
+// NewResponsiveImage builds the description of the responsive image from its variants.
+func NewResponsiveImage(variants []ImageVariant, sizes, alt string) *ResponsiveImage {
+	variants = append([]ImageVariant(nil), variants...)
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Width < variants[j].Width
+	})
+
+	candidates := make([]string, len(variants))
+	for i, v := range variants {
+		candidates[i] = fmt.Sprintf("%s %dw", v.URL, v.Width)
+	}
+	return &ResponsiveImage{
+		Variants: variants,
+		SrcSet:   strings.Join(candidates, ", "),
+		Sizes:    sizes,
+		Alt:      alt,
+	}
+}
+
+// WriteJSON writes the description of the responsive image as JSON.
+func (r *ResponsiveImage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHTML writes the img element referencing the variants, preceded by the Open Graph
+// meta tags describing the largest variant, which can be pasted into the page head.
+func (r *ResponsiveImage) WriteHTML(w io.Writer) error {
+	if len(r.Variants) == 0 {
+		return fmt.Errorf("the responsive image has no variants")
+	}
+	// The largest variant is the fallback of the browsers not supporting srcset.
+	largest := r.Variants[len(r.Variants)-1]
+	esc := html.EscapeString
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<meta property=\"og:image\" content=\"%s\">\n", esc(largest.URL))
+	fmt.Fprintf(&b, "<meta property=\"og:image:width\" content=\"%d\">\n", largest.Width)
+	fmt.Fprintf(&b, "<meta property=\"og:image:height\" content=\"%d\">\n", largest.Height)
+	if r.Alt != "" {
+		fmt.Fprintf(&b, "<meta property=\"og:image:alt\" content=\"%s\">\n", esc(r.Alt))
+	}
+
+	fmt.Fprintf(&b, "<img src=\"%s\" srcset=\"%s\"", esc(largest.URL), esc(r.SrcSet))
+	if r.Sizes != "" {
+		fmt.Fprintf(&b, " sizes=\"%s\"", esc(r.Sizes))
+	}
+	fmt.Fprintf(&b, " width=\"%d\" height=\"%d\" alt=\"%s\">\n", largest.Width, largest.Height, esc(r.Alt))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteSidecar writes the description of the responsive image in the format
+// given by the file extension: HTML for .html and .htm files, JSON otherwise.
+func (r *ResponsiveImage) WriteSidecar(w io.Writer, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return r.WriteHTML(w)
+	}
+	return r.WriteJSON(w)
+}
@@ -0,0 +1,49 @@
+package caire
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSrcSet_ShouldParseTheWidths(t *testing.T) {
+	assert := assert.New(t)
+
+	widths, err := ParseWidths("1024, 320,640,320")
+	assert.NoError(err)
+	assert.Equal([]int{320, 640, 1024}, widths)
+
+	for _, s := range []string{"", "320,abc", "0", "-10", " , "} {
+		_, err := ParseWidths(s)
+		assert.Error(err, s)
+	}
+}
+
+func TestSrcSet_ShouldWriteTheSidecars(t *testing.T) {
+	assert := assert.New(t)
+
+	img := NewResponsiveImage([]ImageVariant{
+		{URL: "img/photo-640.jpg", Width: 640, Height: 480},
+		{URL: "img/photo-320.jpg", Width: 320, Height: 240},
+	}, "(max-width: 600px) 100vw, 50vw", `A "quoted" photo`)
+	assert.Equal("img/photo-320.jpg 320w, img/photo-640.jpg 640w", img.SrcSet)
+
+	var buf bytes.Buffer
+	assert.NoError(img.WriteSidecar(&buf, "photo.json"))
+	var decoded ResponsiveImage
+	assert.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(*img, decoded)
+
+	buf.Reset()
+	assert.NoError(img.WriteSidecar(&buf, "photo.html"))
+	out := buf.String()
+	assert.Contains(out, `<meta property="og:image" content="img/photo-640.jpg">`)
+	assert.Contains(out, `<meta property="og:image:width" content="640">`)
+	assert.Contains(out, `srcset="img/photo-320.jpg 320w, img/photo-640.jpg 640w"`)
+	assert.Contains(out, `sizes="(max-width: 600px) 100vw, 50vw"`)
+	assert.Contains(out, `width="640" height="480" alt="A &#34;quoted&#34; photo"`)
+
+	assert.Error(NewResponsiveImage(nil, "", "").WriteHTML(&buf))
+}
@@ -0,0 +1,100 @@
+package caire
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// WriteSeamsSVG writes the seams removed and inserted by the last resizing operation
+// as an SVG overlay, using the coordinates of the source image. The seam tracking
+// is activated by the SeamsSVGPath option.
+func (p *Processor) WriteSeamsSVG(w io.Writer) error {
+	t := p.tracker
+	if t == nil {
+		return errors.New("the seams have not been tracked")
+	}
+
+	color := p.SeamColor
+	if color == "" {
+		color = "#ff0000"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		t.origW, t.origH, t.origW, t.origH)
+
+	writeGroup := func(class, attrs string, paths [][]image.Point) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Fprintf(bw, "  <g class=%q fill=\"none\" stroke=%q stroke-width=\"1\"%s>\n", class, color, attrs)
+		for _, path := range paths {
+			bw.WriteString("    <polyline points=\"")
+			for i, pt := range path {
+				if i > 0 {
+					bw.WriteByte(' ')
+				}
+				// Use the pixel centers.
+				fmt.Fprintf(bw, "%d.5,%d.5", pt.X, pt.Y)
+			}
+			bw.WriteString("\"/>\n")
+		}
+		bw.WriteString("  </g>\n")
+	}
+	writeGroup("removed", "", t.removed)
+	writeGroup("inserted", " stroke-dasharray=\"2\"", t.inserted)
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// writeSeamsSVGFile saves the SVG overlay of the seams into the provided file.
+func (p *Processor) writeSeamsSVGFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create the seams SVG file: %w", err)
+	}
+	if err := p.WriteSeamsSVG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ParseSeamsSVG reads the seams from an SVG overlay generated by WriteSeamsSVG.
+// Both the removed and inserted seams are returned.
+func ParseSeamsSVG(r io.Reader) ([][]image.Point, error) {
+	var doc struct {
+		Groups []struct {
+			Polylines []struct {
+				Points string `xml:"points,attr"`
+			} `xml:"polyline"`
+		} `xml:"g"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse the seams SVG file: %w", err)
+	}
+
+	var seams [][]image.Point
+	for _, g := range doc.Groups {
+		for _, pl := range g.Polylines {
+			var path []image.Point
+			for _, pair := range strings.Fields(pl.Points) {
+				var x, y float64
+				if _, err := fmt.Sscanf(pair, "%g,%g", &x, &y); err != nil {
+					return nil, fmt.Errorf("invalid seam point: %q", pair)
+				}
+				path = append(path, image.Pt(int(math.Floor(x)), int(math.Floor(y))))
+			}
+			seams = append(seams, path)
+		}
+	}
+	return seams, nil
+}
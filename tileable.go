@@ -0,0 +1,110 @@
+package caire
+
+import (
+	"image"
+	"math"
+
+	"github.com/esimov/caire/filters"
+)
+
+// TileableSobelDetector is a variant of the sobel filter operator, which samples the pixels
+// across the image borders in a toroidal manner (the left edge connects to the right edge and
+// the top edge connects to the bottom edge), so the image borders do not produce false edges.
+// The gradients of the color channels are combined using the provided weights; the red channel
+// is used in case all the weights are zero.
+func (c *Carver) TileableSobelDetector(img *image.NRGBA, threshold float64, weights [3]float64) *image.NRGBA {
+	return c.tileableSobel(img, threshold, weights, false)
+}
+
+// tileableSobel computes the tileable sobel filter. If fixed is set, the magnitudes are computed
+// with the integer arithmetic, and weighted with the 8.8 fixed point weights, like WeightedSobelFixed.
+// Their sums are exact this way, so they don't depend on the floating point arithmetic.
+func (c *Carver) tileableSobel(img *image.NRGBA, threshold float64, weights [3]float64, fixed bool) *image.NRGBA {
+	dx, dy := img.Bounds().Max.X, img.Bounds().Max.Y
+	magnitudes := make([]uint8, dx*dy)
+	sums := make([]float64, dx*dy)
+
+	if weights == [3]float64{} {
+		weights[0] = 1
+	}
+
+	for ch, w := range weights {
+		if w == 0 {
+			continue
+		}
+		fw := int32(math.Round(w * 256))
+		data := filters.Channel(img, ch)
+		for y := 0; y < dy; y++ {
+			for x := 0; x < dx; x++ {
+				var sumX, sumY int32
+				for ky := -1; ky <= 1; ky++ {
+					row := ((y+ky)%dy + dy) % dy
+					for kx := -1; kx <= 1; kx++ {
+						col := ((x+kx)%dx + dx) % dx
+						v := int32(data[row*dx+col])
+						sumX += v * filters.SobelX[ky+1][kx+1]
+						sumY += v * filters.SobelY[ky+1][kx+1]
+					}
+				}
+				if fixed {
+					sums[y*dx+x] += float64(fw*int32(min(filters.ISqrt(uint32(sumX*sumX+sumY*sumY)), 255))) / 256
+				} else {
+					sums[y*dx+x] += w * math.Min(math.Sqrt(float64(sumX*sumX)+float64(sumY*sumY)), 255)
+				}
+			}
+		}
+	}
+
+	for i, magnitude := range sums {
+		if magnitude > 255 {
+			magnitude = 255
+		}
+		if fixed {
+			magnitude = math.Floor(magnitude)
+		}
+		if magnitude > threshold {
+			magnitudes[i] = uint8(magnitude)
+		}
+	}
+	return filters.EdgeImage(img.Bounds(), magnitudes)
+}
+
+// tileableBlur blurs the image by extending it toroidally with the blur radius
+// on each side, this way the pixels close to the borders are blurred seamlessly.
+func (c *Carver) tileableBlur(backend energyBackend, img *image.NRGBA, radius uint32) *image.NRGBA {
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	r := int(radius)
+
+	padded := image.NewNRGBA(image.Rect(0, 0, dx+2*r, dy+2*r))
+	for y := 0; y < dy+2*r; y++ {
+		sy := ((y-r)%dy + dy) % dy
+		for x := 0; x < dx+2*r; x++ {
+			sx := ((x-r)%dx + dx) % dx
+			copy(padded.Pix[padded.PixOffset(x, y):padded.PixOffset(x, y)+4], img.Pix[img.PixOffset(sx, sy):img.PixOffset(sx, sy)+4])
+		}
+	}
+	blurred := backend.blur(c, padded, radius)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dx, dy))
+	for y := 0; y < dy; y++ {
+		copy(dst.Pix[dst.PixOffset(0, y):dst.PixOffset(dx, y)], blurred.Pix[blurred.PixOffset(r, y+r):blurred.PixOffset(r+dx, y+r)])
+	}
+	return dst
+}
+
+// accumulateEnergyToroidal computes the cumulative minimum energy like accumulateEnergy,
+// but the leftmost and rightmost pixels are considered neighbours.
+func (c *Carver) accumulateEnergyToroidal() {
+	w := c.Width
+	prev := make([]float64, w)
+
+	for y := 1; y < c.Height; y++ {
+		copy(prev, c.Points[(y-1)*w:y*w])
+		for x := 0; x < w; x++ {
+			left := prev[(x-1+w)%w]
+			middle := prev[x]
+			right := prev[(x+1)%w]
+			c.set(x, y, c.get(x, y)+math.Min(math.Min(left, middle), right))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTileable_SobelShouldBeTranslationInvariant(t *testing.T) {
+	assert := assert.New(t)
+
+	const shift = 3
+	img := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	shifted := image.NewNRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	for x := 0; x < imgWidth; x++ {
+		for y := 0; y < imgHeight; y++ {
+			col := color.NRGBA{R: uint8(x * y * 7), A: 255}
+			img.Set(x, y, col)
+			shifted.Set((x+shift)%imgWidth, (y+shift)%imgHeight, col)
+		}
+	}
+	c := NewCarver(imgWidth, imgHeight)
+	s0 := c.TileableSobelDetector(img, 0, [3]float64{})
+	s1 := c.TileableSobelDetector(shifted, 0, [3]float64{})
+
+	for x := 0; x < imgWidth; x++ {
+		for y := 0; y < imgHeight; y++ {
+			assert.Equal(s0.NRGBAAt(x, y), s1.NRGBAAt((x+shift)%imgWidth, (y+shift)%imgHeight))
+		}
+	}
+}
+
+func TestTileable_SeamShouldWrapAround(t *testing.T) {
+	assert := assert.New(t)
+
+	// The low energy path crosses the left and right image edges.
+	c := NewCarver(imgWidth, imgHeight)
+	for x := 0; x < imgWidth; x++ {
+		for y := 0; y < imgHeight; y++ {
+			c.set(x, y, 100)
+		}
+	}
+	for y := 0; y < imgHeight; y++ {
+		if y%2 == 0 {
+			c.set(0, y, 0)
+		} else {
+			c.set(imgWidth-1, y, 0)
+		}
+	}
+	c.accumulateEnergyToroidal()
+
+	seams := c.FindLowestEnergySeams(&Processor{Tileable: true})
+	for _, s := range seams {
+		if s.Y%2 == 0 {
+			assert.Equal(0, s.X)
+		} else {
+			assert.Equal(imgWidth-1, s.X)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package caire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// ErrStalled is returned by the watchdog when the seam search can't make any progress,
+// for example because the energy map contains invalid (NaN or infinite) values.
+var ErrStalled = errors.New("the seam search has stalled")
+
+// TimeoutError is returned when the resizing exceeds the Timeout of the processor.
+// It wraps context.DeadlineExceeded.
+type TimeoutError struct {
+	Timeout time.Duration
+	// Partial is the partially carved image scaled to the requested size,
+	// available only if the PartialOutput option is enabled.
+	Partial image.Image
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("the processing has exceeded the timeout of %v", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// resizeTimeout runs the resizing operation with the deadline defined by the Timeout option.
+// The nested calls of Resize (like the auto tune retries) share the same deadline.
+func (p *Processor) resizeTimeout(img *image.NRGBA) (image.Image, error) {
+	parent := p.ctx
+	ctx, cancel := context.WithTimeout(p.getContext(), p.Timeout)
+	p.ctx, p.deadline, p.partial = ctx, ctx, nil
+	defer func() {
+		cancel()
+		p.ctx, p.deadline = parent, nil
+	}()
+
+	res, err := p.resizeLocked(img)
+	// The cancellation of the parent context is propagated as it is.
+	if errors.Is(err, context.DeadlineExceeded) && (parent == nil || parent.Err() == nil) {
+		return nil, &TimeoutError{Timeout: p.Timeout, Partial: p.partialResult()}
+	}
+	return res, err
+}
+
+// keepPartial retains the image carved so far when the operation is aborted.
+func (p *Processor) keepPartial(c *Carver, img *image.NRGBA) {
+	if !p.PartialOutput || p.deadline == nil {
+		return
+	}
+	// The image is rotated while it's resized vertically.
+	if p.vRes {
+		img = c.RotateImage270(img)
+	}
+	p.partial = img
+}
+
+// partialResult scales the partially carved image to the requested size.
+func (p *Processor) partialResult() image.Image {
+	if p.partial == nil {
+		return nil
+	}
+	width, height := p.partial.Bounds().Dx(), p.partial.Bounds().Dy()
+	if p.NewWidth > 0 {
+		width = p.NewWidth
+	}
+	if p.NewHeight > 0 {
+		height = p.NewHeight
+	}
+	return imaging.Resize(p.partial, width, height, imaging.Lanczos)
+}
+
+// checkEnergy verifies the cumulative energy map, from which the seams are traced back.
+// Since the invalid values are propagated downwards, it's enough to check the last row.
+func (c *Carver) checkEnergy() error {
+	for x := 0; x < c.Width; x++ {
+		if e := c.get(x, c.Height-1); math.IsNaN(e) || math.IsInf(e, 0) {
+			return fmt.Errorf("%w: invalid energy at (%d, %d)", ErrStalled, x, c.Height-1)
+		}
+	}
+	return nil
+}
+
+// checkSeam verifies that the seam is connected and crosses the whole image.
+func (c *Carver) checkSeam(seam []Seam) error {
+	if len(seam) != c.Height {
+		return fmt.Errorf("%w: the seam has %d pixels instead of %d", ErrStalled, len(seam), c.Height)
+	}
+	for i, s := range seam {
+		if s.X < 0 || s.X >= c.Width {
+			return fmt.Errorf("%w: the seam is out of the image bounds at (%d, %d)", ErrStalled, s.X, s.Y)
+		}
+		if i > 0 {
+			if dx := s.X - seam[i-1].X; (dx < -1 || dx > 1) && !(dx == 1-c.Width || dx == c.Width-1) {
+				return fmt.Errorf("%w: the seam is disconnected at (%d, %d)", ErrStalled, s.X, s.Y)
+			}
+		}
+	}
+	return nil
+}
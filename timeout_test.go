@@ -0,0 +1,58 @@
+package caire
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout_ShouldAbortTheProcessing(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+
+	proc := &Processor{SobelThreshold: 2, NewWidth: 30, Timeout: time.Nanosecond}
+	_, err := proc.Resize(img)
+	var timeout *TimeoutError
+	assert.True(errors.As(err, &timeout))
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(timeout.Partial)
+
+	proc = &Processor{SobelThreshold: 2, NewWidth: 30, NewHeight: 30, Timeout: time.Nanosecond, PartialOutput: true}
+	_, err = proc.Resize(img)
+	assert.True(errors.As(err, &timeout))
+	if assert.NotNil(timeout.Partial) {
+		assert.Equal(image.Rect(0, 0, 30, 30), timeout.Partial.Bounds())
+	}
+
+	// The processing completes within the budget.
+	proc = &Processor{SobelThreshold: 2, NewWidth: 50, Timeout: time.Minute, PartialOutput: true}
+	res, err := proc.Resize(img)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 50, 40), res.Bounds())
+	assert.Nil(proc.deadline)
+}
+
+func TestTimeout_ShouldDetectStalledSeams(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCarver(4, 3)
+	assert.NoError(c.checkEnergy())
+	c.set(2, 2, math.NaN())
+	assert.ErrorIs(c.checkEnergy(), ErrStalled)
+	c.set(2, 2, math.Inf(1))
+	assert.ErrorIs(c.checkEnergy(), ErrStalled)
+
+	assert.NoError(c.checkSeam([]Seam{{X: 1, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 0}}))
+	assert.ErrorIs(c.checkSeam([]Seam{{X: 1, Y: 2}, {X: 2, Y: 1}}), ErrStalled)
+	assert.ErrorIs(c.checkSeam([]Seam{{X: 0, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 0}}), ErrStalled)
+	assert.ErrorIs(c.checkSeam([]Seam{{X: 1, Y: 2}, {X: 2, Y: 1}, {X: 4, Y: 0}}), ErrStalled)
+}
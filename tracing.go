@@ -0,0 +1,120 @@
+package caire
+
+import (
+	"context"
+	"time"
+)
+
+// The names of the spans created for each processing stage.
+const (
+	SpanDecode     = "caire.decode"
+	SpanCarve      = "caire.carve"
+	SpanEnergy     = "caire.energy"
+	SpanFaceDetect = "caire.facedetect"
+	SpanEncode     = "caire.encode"
+)
+
+// The attributes of the energy span, which covers all the energy map computations of a resizing
+// operation: the number of the computed energy maps and the total time spent computing them.
+const (
+	AttrEnergyMaps     = "caire.energy.maps"
+	AttrEnergyDuration = "caire.energy.duration_ns"
+)
+
+// Tracer creates the spans of the processing stages. It mirrors the Start method of the
+// OpenTelemetry tracer, without depending on it, so it can be implemented by a thin adapter
+// around the tracer obtained from the TracerProvider of the embedding service.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single timed operation started by the Tracer.
+type Span interface {
+	End()
+}
+
+// SpanAttributes is optionally implemented by the spans accepting the attributes of the stage.
+type SpanAttributes interface {
+	SetAttribute(key string, value int64)
+}
+
+// startSpan starts a new span as a child of the current one, if a tracer is provided.
+// The returned function ends the span and it should be called once the stage is completed.
+func (p *Processor) startSpan(name string) func() {
+	if p.Tracer == nil {
+		return func() {}
+	}
+	span, restore := p.openSpan(name)
+	return func() {
+		span.End()
+		restore()
+	}
+}
+
+// openSpan starts a new span as a child of the current one and makes it the current span.
+// The returned function makes the previous span current again.
+func (p *Processor) openSpan(name string) (Span, func()) {
+	parent := p.traceCtx
+	if parent == nil {
+		parent = p.getContext()
+	}
+	ctx, span := p.Tracer.Start(parent, name)
+
+	prev := p.traceCtx
+	p.traceCtx = ctx
+	return span, func() { p.traceCtx = prev }
+}
+
+// energyTimer accumulates the time spent computing the energy maps of a resizing operation.
+// A single energy span covers the whole operation, since a span for each seam would flood
+// the tracing backends with thousands of tiny spans.
+type energyTimer struct {
+	span    Span
+	restore func()
+	maps    int64
+	total   time.Duration
+}
+
+// timeEnergy sets up the energy timer of a resizing operation, unless an enclosing operation
+// has already set it up. The returned function ends the energy span, reporting the number of
+// the energy maps and their total computation time, if the span accepts the attributes.
+func (p *Processor) timeEnergy() func() {
+	if p.Tracer == nil || p.energyTime != nil {
+		return func() {}
+	}
+	t := &energyTimer{}
+	p.energyTime = t
+	return func() {
+		p.energyTime = nil
+		if t.span == nil {
+			return
+		}
+		if attrs, ok := t.span.(SpanAttributes); ok {
+			attrs.SetAttribute(AttrEnergyMaps, t.maps)
+			attrs.SetAttribute(AttrEnergyDuration, t.total.Nanoseconds())
+		}
+		t.span.End()
+		t.restore()
+	}
+}
+
+// startEnergy times an energy map computation. The energy span of the resizing operation is
+// opened by its first computation, while the energy maps computed outside of a resizing operation
+// are getting their own span. The returned function should be called once the map is computed.
+func (p *Processor) startEnergy() func() {
+	if p.Tracer == nil {
+		return func() {}
+	}
+	t := p.energyTime
+	if t == nil {
+		return p.startSpan(SpanEnergy)
+	}
+	if t.span == nil {
+		t.span, t.restore = p.openSpan(SpanEnergy)
+	}
+	start := time.Now()
+	return func() {
+		t.maps++
+		t.total += time.Since(start)
+	}
+}
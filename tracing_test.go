@@ -0,0 +1,73 @@
+package caire
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type spanKey struct{}
+
+// recorder records the started spans together with the name of their parents.
+type recorder struct {
+	spans []string
+	ended int
+	attrs map[string]int64
+}
+
+func (r *recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	r.spans = append(r.spans, parent+">"+name)
+	return context.WithValue(ctx, spanKey{}, name), r
+}
+
+func (r *recorder) End() { r.ended++ }
+
+func (r *recorder) SetAttribute(key string, value int64) {
+	if r.attrs == nil {
+		r.attrs = make(map[string]int64)
+	}
+	r.attrs[key] = value
+}
+
+func TestTracing_ShouldCreateTheStageSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 12), G: uint8(y * 12), B: 100, A: 255})
+		}
+	}
+	var src, dst bytes.Buffer
+	assert.NoError(png.Encode(&src, img))
+
+	rec := &recorder{}
+	proc := &Processor{SobelThreshold: 4, NewWidth: 15, OutputFormat: FormatPNG, Tracer: rec}
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	assert.NoError(proc.ProcessContext(ctx, &src, &dst))
+
+	assert.Equal([]string{
+		"request>" + SpanDecode,
+		"request>" + SpanCarve,
+		SpanCarve + ">" + SpanEnergy,
+		"request>" + SpanEncode,
+	}, rec.spans)
+	assert.Equal(len(rec.spans), rec.ended)
+
+	// A single energy span accumulates the computations of all the seams.
+	assert.Equal(int64(5), rec.attrs[AttrEnergyMaps])
+	assert.Positive(rec.attrs[AttrEnergyDuration])
+
+	// The energy maps computed outside of a resizing operation are getting their own span.
+	rec = &recorder{}
+	_, err := NewCarver(20, 20).ComputeSeams(&Processor{SobelThreshold: 4, Tracer: rec}, img)
+	assert.NoError(err)
+	assert.Equal([]string{">" + SpanEnergy}, rec.spans)
+	assert.Equal(1, rec.ended)
+}
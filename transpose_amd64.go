@@ -0,0 +1,42 @@
+//go:build !purego
+
+package caire
+
+// transposeBlock is the size of the square blocks transposed by the AVX2 kernel,
+// or zero if the CPU doesn't support the AVX2 instructions.
+var transposeBlock = func() int {
+	if hasAVX2() {
+		return 8
+	}
+	return 0
+}()
+
+// transposeBlocks transposes n blocks of 8x8 pixels. The rows of a block are read from src
+// and written as columns to dst, then src advances by 8 rows and dst by 8 pixels.
+// The strides are in bytes and can be negative for walking the rows backwards.
+//
+//go:noescape
+func transposeBlocks(dst *uint8, dstStride int, src *uint8, srcStride int, n int)
+
+func cpuid(op, op2 uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 reports whether both the CPU and the operating system are supporting AVX2.
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx&osxsave == 0 || ecx&avx == 0 {
+		return false
+	}
+	// The operating system has to save the XMM and YMM registers on context switches.
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+	_, ebx, _, _ := cpuid(7, 0)
+	return ebx&(1<<5) != 0
+}
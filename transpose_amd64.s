@@ -0,0 +1,107 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func transposeBlocks(dst *uint8, dstStride int, src *uint8, srcStride int, n int)
+TEXT ·transposeBlocks(SB), NOSPLIT, $0-40
+	MOVQ dst+0(FP), DI
+	MOVQ dstStride+8(FP), DX
+	MOVQ src+16(FP), SI
+	MOVQ srcStride+24(FP), BX
+	MOVQ n+32(FP), CX
+	TESTQ CX, CX
+	JLE done
+
+loop:
+	// Load the 8 source rows of the block.
+	VMOVDQU (SI), Y0
+	ADDQ BX, SI
+	VMOVDQU (SI), Y1
+	ADDQ BX, SI
+	VMOVDQU (SI), Y2
+	ADDQ BX, SI
+	VMOVDQU (SI), Y3
+	ADDQ BX, SI
+	VMOVDQU (SI), Y4
+	ADDQ BX, SI
+	VMOVDQU (SI), Y5
+	ADDQ BX, SI
+	VMOVDQU (SI), Y6
+	ADDQ BX, SI
+	VMOVDQU (SI), Y7
+	ADDQ BX, SI
+
+	// Interleave the pixels of the row pairs.
+	VUNPCKLPS Y1, Y0, Y8
+	VUNPCKHPS Y1, Y0, Y9
+	VUNPCKLPS Y3, Y2, Y10
+	VUNPCKHPS Y3, Y2, Y11
+	VUNPCKLPS Y5, Y4, Y12
+	VUNPCKHPS Y5, Y4, Y13
+	VUNPCKLPS Y7, Y6, Y14
+	VUNPCKHPS Y7, Y6, Y15
+
+	// Gather the columns of the rows 0-3 and 4-7 within the 128 bit lanes.
+	VSHUFPS $0x44, Y10, Y8, Y0
+	VSHUFPS $0xEE, Y10, Y8, Y1
+	VSHUFPS $0x44, Y11, Y9, Y2
+	VSHUFPS $0xEE, Y11, Y9, Y3
+	VSHUFPS $0x44, Y14, Y12, Y4
+	VSHUFPS $0xEE, Y14, Y12, Y5
+	VSHUFPS $0x44, Y15, Y13, Y6
+	VSHUFPS $0xEE, Y15, Y13, Y7
+
+	// Join the lanes into the full columns.
+	VPERM2F128 $0x20, Y4, Y0, Y8
+	VPERM2F128 $0x20, Y5, Y1, Y9
+	VPERM2F128 $0x20, Y6, Y2, Y10
+	VPERM2F128 $0x20, Y7, Y3, Y11
+	VPERM2F128 $0x31, Y4, Y0, Y12
+	VPERM2F128 $0x31, Y5, Y1, Y13
+	VPERM2F128 $0x31, Y6, Y2, Y14
+	VPERM2F128 $0x31, Y7, Y3, Y15
+
+	// Store the columns as the destination rows.
+	MOVQ DI, R8
+	VMOVDQU Y8, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y9, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y10, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y11, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y12, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y13, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y14, (R8)
+	ADDQ DX, R8
+	VMOVDQU Y15, (R8)
+
+	ADDQ $32, DI
+	DECQ CX
+	JNZ loop
+	VZEROUPPER
+
+done:
+	RET
+
+// func cpuid(op, op2 uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL op+0(FP), AX
+	MOVL op2+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
@@ -0,0 +1,14 @@
+//go:build !purego
+
+package caire
+
+// transposeBlock is the size of the square blocks transposed by the NEON kernel.
+// NEON is part of the base arm64 architecture, so the kernel is always available.
+const transposeBlock = 4
+
+// transposeBlocks transposes n blocks of 4x4 pixels. The rows of a block are read from src
+// and written as columns to dst, then src advances by 4 rows and dst by 4 pixels.
+// The strides are in bytes and can be negative for walking the rows backwards.
+//
+//go:noescape
+func transposeBlocks(dst *uint8, dstStride int, src *uint8, srcStride int, n int)
@@ -0,0 +1,53 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func transposeBlocks(dst *uint8, dstStride int, src *uint8, srcStride int, n int)
+TEXT ·transposeBlocks(SB), NOSPLIT, $0-40
+	MOVD dst+0(FP), R0
+	MOVD dstStride+8(FP), R1
+	MOVD src+16(FP), R2
+	MOVD srcStride+24(FP), R3
+	MOVD n+32(FP), R4
+	CMP  $0, R4
+	BLE  done
+
+loop:
+	// Load the 4 source rows of the block: a, b, c and d.
+	VLD1 (R2), [V0.S4]
+	ADD  R3, R2
+	VLD1 (R2), [V1.S4]
+	ADD  R3, R2
+	VLD1 (R2), [V2.S4]
+	ADD  R3, R2
+	VLD1 (R2), [V3.S4]
+	ADD  R3, R2
+
+	// Interleave the pixels of the row pairs: a0 b0 a2 b2, a1 b1 a3 b3, c0 d0 c2 d2, c1 d1 c3 d3.
+	VTRN1 V1.S4, V0.S4, V4.S4
+	VTRN2 V1.S4, V0.S4, V5.S4
+	VTRN1 V3.S4, V2.S4, V6.S4
+	VTRN2 V3.S4, V2.S4, V7.S4
+
+	// Join the halves into the columns: a0 b0 c0 d0, a1 b1 c1 d1, a2 b2 c2 d2, a3 b3 c3 d3.
+	VTRN1 V6.D2, V4.D2, V0.D2
+	VTRN1 V7.D2, V5.D2, V1.D2
+	VTRN2 V6.D2, V4.D2, V2.D2
+	VTRN2 V7.D2, V5.D2, V3.D2
+
+	// Store the columns as the destination rows.
+	MOVD R0, R5
+	VST1 [V0.S4], (R5)
+	ADD  R1, R5
+	VST1 [V1.S4], (R5)
+	ADD  R1, R5
+	VST1 [V2.S4], (R5)
+	ADD  R1, R5
+	VST1 [V3.S4], (R5)
+
+	ADD  $16, R0
+	SUBS $1, R4
+	BNE  loop
+
+done:
+	RET
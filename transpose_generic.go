@@ -0,0 +1,9 @@
+//go:build (!amd64 && !arm64) || purego
+
+package caire
+
+// transposeBlock is zero, since there is no SIMD kernel for the architecture.
+var transposeBlock = 0
+
+// transposeBlocks is never called, the pixels are copied by the Go loop of the rotation.
+func transposeBlocks(dst *uint8, dstStride int, src *uint8, srcStride int, n int) {}
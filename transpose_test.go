@@ -0,0 +1,93 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotate_ShouldMatchThePixelByPixelRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCarver(0, 0)
+	// The sizes are not multiples of the tile size, so the partial tiles are covered too.
+	for _, size := range []image.Point{{1, 1}, {3, 130}, {150, 70}, {rotateTile, rotateTile + 1}} {
+		img := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i * 7)
+		}
+
+		ccw, cw := c.RotateImage90(img), c.RotateImage270(img)
+		assert.Equal(image.Rect(0, 0, size.Y, size.X), ccw.Bounds())
+		assert.Equal(image.Rect(0, 0, size.Y, size.X), cw.Bounds())
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				assert.Equal(img.NRGBAAt(x, y), ccw.NRGBAAt(y, size.X-x-1))
+				assert.Equal(img.NRGBAAt(x, y), cw.NRGBAAt(size.Y-y-1, x))
+			}
+		}
+		assert.Equal(img, c.RotateImage270(ccw))
+		assert.Equal(rotateColumns(img), ccw)
+	}
+
+	// The sub images are rotated relative to their bounds.
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	sub := img.SubImage(image.Rect(5, 2, 15, 8)).(*image.NRGBA)
+	res := c.RotateImage90(sub)
+	assert.Equal(image.Rect(0, 0, 6, 10), res.Bounds())
+	assert.Equal(sub.NRGBAAt(5, 2), res.NRGBAAt(0, 9))
+
+	// The sub images large enough for the SIMD kernels are rotated relative to their bounds too.
+	img = image.NewNRGBA(image.Rect(0, 0, 130, 100))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	sub = img.SubImage(image.Rect(5, 3, 120, 90)).(*image.NRGBA)
+	ccw, cw := c.RotateImage90(sub), c.RotateImage270(sub)
+	assert.Equal(rotateColumns(sub), ccw)
+	for y := 0; y < 87; y++ {
+		for x := 0; x < 115; x++ {
+			assert.Equal(sub.NRGBAAt(x+5, y+3), ccw.NRGBAAt(y, 115-x-1))
+			assert.Equal(sub.NRGBAAt(x+5, y+3), cw.NRGBAAt(87-y-1, x))
+		}
+	}
+}
+
+// rotateColumns is the column by column rotation replaced by the tiled one, kept as the
+// baseline of the benchmark.
+func rotateColumns(src *image.NRGBA) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for dstY := 0; dstY < b.Dx(); dstY++ {
+		for dstX := 0; dstX < b.Dy(); dstX++ {
+			srcOff := src.PixOffset(b.Max.X-dstY-1, b.Min.Y+dstX)
+			dstOff := dst.PixOffset(dstX, dstY)
+			copy(dst.Pix[dstOff:dstOff+4], src.Pix[srcOff:srcOff+4])
+		}
+	}
+	return dst
+}
+
+// BenchmarkTranspose compares the tiled rotation with the column by column walk. The tiled
+// rotation uses the SIMD kernel of the architecture, run with the purego build tag for the
+// Go loop used as fallback.
+func BenchmarkTranspose(b *testing.B) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2000, 1500))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	b.Run("tiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rotate(img, true)
+		}
+	})
+	b.Run("columns", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rotateColumns(img)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// errHistoryMismatch is returned when the image doesn't match the seam history of the carver.
+var errHistoryMismatch = errors.New("the image doesn't match the seam history of the carver")
+
+// removedSeam is a seam removal retained by the carver, holding the seam
+// coordinates and the pixels removed from the image.
+type removedSeam struct {
+	seam   []Seam
+	pixels []color.NRGBA
+}
+
+// retain records the seam removal prior to removing the seam from the image, discarding
+// the oldest removal above the history size. A new removal clears the reverted ones.
+func (c *Carver) retain(img *image.NRGBA, seams []Seam) {
+	rs := removedSeam{
+		seam:   make([]Seam, len(seams)),
+		pixels: make([]color.NRGBA, len(seams)),
+	}
+	copy(rs.seam, seams)
+	for i, s := range seams {
+		rs.pixels[i] = img.NRGBAAt(s.X, s.Y)
+	}
+	c.undo = append(c.undo, rs)
+	if len(c.undo) > c.HistorySize {
+		c.undo = append(c.undo[:0], c.undo[len(c.undo)-c.HistorySize:]...)
+	}
+	c.redo = nil
+}
+
+// History returns the number of the seam removals which can be undone and redone.
+func (c *Carver) History() (undo, redo int) {
+	return len(c.undo), len(c.redo)
+}
+
+// Undo reverts the last n seam removals retained by the carver, restoring the removed pixels
+// into the image returned by the last RemoveSeam, Undo or Redo call. If fewer removals are
+// retained, all of them are reverted. The reverted removals can be reapplied with Redo.
+func (c *Carver) Undo(img *image.NRGBA, n int) (*image.NRGBA, error) {
+	for ; n > 0 && len(c.undo) > 0; n-- {
+		rs := c.undo[len(c.undo)-1]
+		dst, err := rs.restore(img)
+		if err != nil {
+			return img, err
+		}
+		img = dst
+		c.undo = c.undo[:len(c.undo)-1]
+		c.redo = append(c.redo, rs)
+	}
+	return img, nil
+}
+
+// Redo reapplies the last n seam removals reverted by Undo, without computing the energy map.
+// If fewer removals have been reverted, all of them are reapplied.
+func (c *Carver) Redo(img *image.NRGBA, n int) (*image.NRGBA, error) {
+	for ; n > 0 && len(c.redo) > 0; n-- {
+		rs := c.redo[len(c.redo)-1]
+		if !rs.fits(img, 0) {
+			return img, errHistoryMismatch
+		}
+		// The removal is retained again, while the remaining reverted removals are kept.
+		redo := c.redo[:len(c.redo)-1]
+		img = c.RemoveSeam(img, rs.seam, false)
+		c.redo = redo
+	}
+	return img, nil
+}
+
+// fits reports whether the seam can be removed from the image, which is wider by the provided number of pixels.
+func (rs removedSeam) fits(img *image.NRGBA, extra int) bool {
+	bounds := img.Bounds()
+	if bounds.Min != (image.Point{}) || len(rs.seam) != bounds.Dy() {
+		return false
+	}
+	for _, s := range rs.seam {
+		if s.X < 0 || s.X >= bounds.Dx()+extra || s.Y < 0 || s.Y >= bounds.Dy() {
+			return false
+		}
+	}
+	return true
+}
+
+// restore inserts the removed pixels back into the image, which gets wider by one pixel.
+func (rs removedSeam) restore(img *image.NRGBA) (*image.NRGBA, error) {
+	if !rs.fits(img, 1) {
+		return nil, errHistoryMismatch
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()+1, bounds.Dy()))
+
+	for i, s := range rs.seam {
+		src := img.Pix[s.Y*img.Stride : s.Y*img.Stride+bounds.Dx()*4]
+		row := dst.Pix[s.Y*dst.Stride : s.Y*dst.Stride+dst.Rect.Dx()*4]
+		copy(row[:s.X*4], src[:s.X*4])
+		px := rs.pixels[i]
+		copy(row[s.X*4:], []uint8{px.R, px.G, px.B, px.A})
+		copy(row[(s.X+1)*4:], src[s.X*4:])
+	}
+	return dst, nil
+}
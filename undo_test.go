@@ -0,0 +1,90 @@
+package caire
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// carveSteps removes the seams one by one with the stepwise API of the carver,
+// returning the image obtained after each step, starting with the source image.
+func carveSteps(t *testing.T, c *Carver, img *image.NRGBA, n int) []*image.NRGBA {
+	p := &Processor{SobelThreshold: 2}
+	steps := []*image.NRGBA{img}
+	for i := 0; i < n; i++ {
+		if _, err := c.ComputeSeams(p, img); err != nil {
+			t.Fatal(err)
+		}
+		img = c.RemoveSeam(img, c.FindLowestEnergySeams(p), false)
+		steps = append(steps, img)
+	}
+	return steps
+}
+
+func TestUndo_ShouldRevertTheSeamRemovals(t *testing.T) {
+	assert := assert.New(t)
+
+	src := subjectsImage(120, 60, 10, 70)
+	c := NewCarver(120, 60)
+	c.HistorySize = 10
+	steps := carveSteps(t, c, src, 6)
+	img := steps[6]
+	assert.Equal(114, img.Bounds().Dx())
+
+	undo, redo := c.History()
+	assert.Equal(6, undo)
+	assert.Equal(0, redo)
+
+	img, err := c.Undo(img, 2)
+	assert.NoError(err)
+	assert.Equal(steps[4], img)
+
+	img, err = c.Undo(img, 10)
+	assert.NoError(err)
+	assert.Equal(src, img)
+	undo, redo = c.History()
+	assert.Equal(0, undo)
+	assert.Equal(6, redo)
+
+	img, err = c.Redo(img, 3)
+	assert.NoError(err)
+	assert.Equal(steps[3], img)
+	img, err = c.Redo(img, 10)
+	assert.NoError(err)
+	assert.Equal(steps[6], img)
+
+	// A new removal discards the reverted ones.
+	img, err = c.Undo(img, 1)
+	assert.NoError(err)
+	carveSteps(t, c, img, 1)
+	undo, redo = c.History()
+	assert.Equal(6, undo)
+	assert.Equal(0, redo)
+}
+
+func TestUndo_ShouldBoundTheHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	src := subjectsImage(120, 60, 10, 70)
+	c := NewCarver(120, 60)
+	c.HistorySize = 3
+	steps := carveSteps(t, c, src, 6)
+
+	undo, _ := c.History()
+	assert.Equal(3, undo)
+	_, err := c.Undo(subjectsImage(50, 20), 1)
+	assert.Error(err)
+	img, err := c.Undo(steps[6], 5)
+	assert.NoError(err)
+	assert.Equal(steps[3], img)
+
+	_, err = c.Redo(subjectsImage(120, 40), 1)
+	assert.Error(err)
+
+	// The carver doesn't retain the removals by default.
+	c = NewCarver(120, 60)
+	carveSteps(t, c, src, 2)
+	undo, _ = c.History()
+	assert.Equal(0, undo)
+}
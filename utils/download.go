@@ -1,9 +1,8 @@
 package utils
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -13,38 +12,28 @@ import (
 
 // DownloadImage downloads the image from the internet and saves it into a temporary file.
 func DownloadImage(url string) (*os.File, error) {
-	// Retrieve the url and decode the response body.
-	res, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("unable to download image file from URI: %s, status %v", url, res.Status)
-	}
-	defer res.Body.Close()
-
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %w", err)
-	}
-
-	tmpfile, err := os.CreateTemp("/tmp", "image")
-	if err != nil {
-		return nil, fmt.Errorf("unable to create temporary file: %w", err)
-	}
+	return DownloadImageWith(context.Background(), defaultFetcher, url)
+}
 
-	// Copy the image binary data into the temporary file.
-	_, err = io.Copy(tmpfile, bytes.NewBuffer(data))
+// DownloadImageWith downloads the image using the provided fetcher and
+// checks if the downloaded file is a valid image type. In case of an error
+// the temporary file is removed and no file is returned.
+func DownloadImageWith(ctx context.Context, f *Fetcher, url string) (*os.File, error) {
+	tmpfile, err := f.Fetch(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("unable to copy the source URI into the destination file")
+		return nil, err
 	}
 
 	ctype, err := DetectContentType(tmpfile.Name())
+	if err == nil && !strings.Contains(ctype.(string), "image") {
+		err = fmt.Errorf("the downloaded file is not a valid image type")
+	}
 	if err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
 		return nil, err
 	}
 
-	if !strings.Contains(ctype.(string), "image") {
-		return nil, fmt.Errorf("the downloaded file is not a valid image type")
-	}
-
 	return tmpfile, nil
 }
 
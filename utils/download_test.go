@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -17,6 +21,27 @@ func TestUtils_ShouldDownloadImage(t *testing.T) {
 	}
 }
 
+func TestUtils_ShouldRemoveTheInvalidDownload(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not an image"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	f, err := DownloadImageWith(context.Background(), NewFetcher(), ts.URL)
+	if err == nil {
+		t.Fatalf("the download should have failed for an invalid image type")
+	}
+	if f != nil {
+		t.Errorf("no file should be returned together with the error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("the temporary file should have been removed, got: %v", entries)
+	}
+}
+
 func TestUtils_ShouldBeValidUrl(t *testing.T) {
 	ok := IsValidUrl("https://github.com/esimov/caire/")
 	if !ok {
@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrMaxSizeExceeded is returned when the size of the remote file exceeds the allowed limit.
+var ErrMaxSizeExceeded = errors.New("the remote file exceeds the maximum download size")
+
+// Fetcher downloads remote images. It limits the number of concurrent downloads and
+// the request rate per host, retries the failed requests with exponential backoff
+// and optionally caches the downloaded files using their ETag.
+// A Fetcher is safe for concurrent use by multiple goroutines.
+type Fetcher struct {
+	// Client is the HTTP client used for the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Timeout limits the duration of a single download attempt. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is retried.
+	Retries int
+	// Backoff is the delay before the first retry, doubled on every subsequent attempt.
+	Backoff time.Duration
+	// MaxSize is the maximum allowed size of a downloaded file in bytes. Zero means no limit.
+	MaxSize int64
+	// Concurrency limits the number of simultaneous downloads. Zero means no limit.
+	Concurrency int
+	// Interval is the minimum delay between two requests sent to the same host.
+	Interval time.Duration
+	// CacheDir is the directory where the downloaded files are cached together with their ETag.
+	// The cache is disabled if empty.
+	CacheDir string
+
+	once  sync.Once
+	sem   chan struct{}
+	mu    sync.Mutex
+	hosts map[string]time.Time
+}
+
+// NewFetcher returns a fetcher with sensible defaults.
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Timeout:     time.Minute,
+		Retries:     3,
+		Backoff:     500 * time.Millisecond,
+		MaxSize:     100 << 20,
+		Concurrency: 4,
+	}
+}
+
+// defaultFetcher is used by DownloadImage.
+var defaultFetcher = NewFetcher()
+
+// Fetch downloads the file from the provided URL and saves it into a temporary file.
+// The returned file is positioned at the beginning of its content; it's the
+// caller's responsibility to close and remove it.
+func (f *Fetcher) Fetch(ctx context.Context, uri string) (*os.File, error) {
+	f.once.Do(func() {
+		if f.Concurrency > 0 {
+			f.sem = make(chan struct{}, f.Concurrency)
+		}
+		f.hosts = make(map[string]time.Time)
+	})
+
+	if f.sem != nil {
+		select {
+		case f.sem <- struct{}{}:
+			defer func() { <-f.sem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tmp   *os.File
+		retry bool
+	)
+	backoff := f.Backoff
+	for attempt := 0; ; attempt++ {
+		if err := f.throttle(ctx, u.Host); err != nil {
+			return nil, err
+		}
+
+		tmp, retry, err = f.fetch(ctx, uri)
+		if err == nil || !retry || attempt >= f.Retries {
+			break
+		}
+
+		var delay time.Duration
+		if re, ok := err.(*retryAfterError); ok {
+			delay = re.delay
+		}
+		if delay < backoff {
+			delay = backoff
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to download image file from URI: %s: %w", uri, err)
+	}
+	return tmp, nil
+}
+
+// throttle waits until a new request can be sent to the host.
+func (f *Fetcher) throttle(ctx context.Context, host string) error {
+	if f.Interval <= 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	next := f.hosts[host]
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	f.hosts[host] = next.Add(f.Interval)
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(next)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfterError is returned when the server asks for a delay before the next request.
+type retryAfterError struct {
+	status string
+	delay  time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("status %s", e.status)
+}
+
+// fetch executes a single download attempt. It also reports whether the failed request can be retried.
+func (f *Fetcher) fetch(ctx context.Context, uri string) (*os.File, bool, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cached string
+	if f.CacheDir != "" {
+		sum := sha1.Sum([]byte(uri))
+		cached = filepath.Join(f.CacheDir, hex.EncodeToString(sum[:]))
+
+		if etag, err := os.ReadFile(cached + ".etag"); err == nil {
+			if _, err := os.Stat(cached); err == nil {
+				req.Header.Set("If-None-Match", string(etag))
+			}
+		}
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		// Network errors are retried unless the parent context has been canceled.
+		return nil, ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded), err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != "":
+		src, err := os.Open(cached)
+		if err != nil {
+			return nil, false, err
+		}
+		defer src.Close()
+
+		tmp, err := f.copy(src)
+		return tmp, false, err
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable:
+		var delay time.Duration
+		if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			delay = time.Duration(secs) * time.Second
+		}
+		return nil, true, &retryAfterError{status: res.Status, delay: delay}
+	case res.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("status %s", res.Status)
+	case res.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("status %s", res.Status)
+	}
+
+	if f.MaxSize > 0 && res.ContentLength > f.MaxSize {
+		return nil, false, ErrMaxSizeExceeded
+	}
+
+	tmp, err := f.copy(res.Body)
+	if err != nil {
+		return nil, !errors.Is(err, ErrMaxSizeExceeded), err
+	}
+
+	if etag := res.Header.Get("ETag"); etag != "" && cached != "" {
+		f.store(tmp, cached, etag)
+	}
+	return tmp, false, nil
+}
+
+// copy writes the content of the reader into a temporary file, respecting the maximum size limit.
+func (f *Fetcher) copy(r io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "image")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	if f.MaxSize > 0 {
+		r = io.LimitReader(r, f.MaxSize+1)
+	}
+	n, err := io.Copy(tmp, r)
+	if err == nil && f.MaxSize > 0 && n > f.MaxSize {
+		err = ErrMaxSizeExceeded
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// store saves the downloaded file into the cache directory. The cache is best effort,
+// this is why the errors are ignored.
+func (f *Fetcher) store(tmp *os.File, cached, etag string) {
+	defer tmp.Seek(0, io.SeekStart)
+
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return
+	}
+	dst, err := os.Create(cached)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(dst, tmp)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(cached)
+		return
+	}
+	os.WriteFile(cached+".etag", []byte(etag), 0644)
+}
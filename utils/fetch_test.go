@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFetcher_ShouldRetryFailedRequests(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	f := &Fetcher{Retries: 2, Backoff: time.Millisecond}
+	tmp, err := f.Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("the request should have succeeded after retrying: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+
+	if count != 3 {
+		t.Errorf("expected 3 requests, got: %d", count)
+	}
+}
+
+func TestFetcher_ShouldLimitTheDownloadSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 2048))
+	}))
+	defer ts.Close()
+
+	f := &Fetcher{MaxSize: 1024}
+	if _, err := f.Fetch(context.Background(), ts.URL); !errors.Is(err, ErrMaxSizeExceeded) {
+		t.Errorf("expected ErrMaxSizeExceeded, got: %v", err)
+	}
+}
+
+func TestFetcher_ShouldCacheUsingETag(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		hits++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	f := &Fetcher{CacheDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		tmp, err := f.Fetch(context.Background(), ts.URL)
+		if err != nil {
+			t.Fatalf("could not fetch the file: %v", err)
+		}
+		data, _ := io.ReadAll(tmp)
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		if string(data) != "content" {
+			t.Errorf("unexpected content: %q", data)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("the content should have been served from the cache, got %d full responses", hits)
+	}
+}
@@ -0,0 +1,97 @@
+package caire
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// Supported watermark anchors.
+const (
+	AnchorTopLeft     = "top-left"
+	AnchorTopRight    = "top-right"
+	AnchorBottomLeft  = "bottom-left"
+	AnchorBottomRight = "bottom-right"
+	AnchorCenter      = "center"
+)
+
+// Watermark defines a logo which is composited over the resized image.
+type Watermark struct {
+	// Path is the location of the watermark image, usually a transparent PNG.
+	Path string
+	// Anchor is the position of the watermark: top-left, top-right, bottom-left,
+	// bottom-right or center. It defaults to bottom-right.
+	Anchor string
+	// Margin is the distance in pixels between the watermark and the image edges.
+	Margin int
+	// Opacity of the watermark in the (0, 1] range. Zero means fully opaque.
+	Opacity float64
+}
+
+// load decodes the watermark image.
+func (wm *Watermark) load() (*image.NRGBA, error) {
+	f, err := os.Open(wm.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the watermark file: %v", err)
+	}
+	defer f.Close()
+
+	src, _, err := decode(f, "", DefaultMaxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the watermark file: %v", err)
+	}
+	return imaging.Clone(src), nil
+}
+
+// position returns the top left corner of the watermark of the provided size.
+func (wm *Watermark) position(bounds image.Rectangle, size image.Point) (image.Point, error) {
+	left := bounds.Min.X + wm.Margin
+	right := bounds.Max.X - wm.Margin - size.X
+	top := bounds.Min.Y + wm.Margin
+	bottom := bounds.Max.Y - wm.Margin - size.Y
+
+	switch wm.Anchor {
+	case AnchorTopLeft:
+		return image.Pt(left, top), nil
+	case AnchorTopRight:
+		return image.Pt(right, top), nil
+	case AnchorBottomLeft:
+		return image.Pt(left, bottom), nil
+	case AnchorBottomRight, "":
+		return image.Pt(right, bottom), nil
+	case AnchorCenter:
+		return image.Pt((bounds.Min.X+bounds.Max.X-size.X)/2, (bounds.Min.Y+bounds.Max.Y-size.Y)/2), nil
+	}
+	return image.Point{}, fmt.Errorf("unsupported watermark anchor: %q", wm.Anchor)
+}
+
+// apply composites the watermark over the image. The watermark is scaled down
+// in case it doesn't fit inside the image.
+func (wm *Watermark) apply(dst draw.Image, logo *image.NRGBA) error {
+	bounds := dst.Bounds()
+	maxW, maxH := bounds.Dx()-2*wm.Margin, bounds.Dy()-2*wm.Margin
+	if maxW <= 0 || maxH <= 0 {
+		return nil
+	}
+	if logo.Bounds().Dx() > maxW || logo.Bounds().Dy() > maxH {
+		logo = imaging.Fit(logo, maxW, maxH, imaging.Lanczos)
+	}
+
+	pt, err := wm.position(bounds, logo.Bounds().Size())
+	if err != nil {
+		return err
+	}
+
+	opacity := wm.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	mask := &image.Uniform{color.Alpha{A: uint8(opacity * 0xff)}}
+	draw.DrawMask(dst, image.Rectangle{Min: pt, Max: pt.Add(logo.Bounds().Size())}, logo, image.Point{}, mask, image.Point{}, draw.Over)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatermark_ShouldPlaceTheLogo(t *testing.T) {
+	assert := assert.New(t)
+
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	red := color.NRGBA{R: 0xff, A: 0xff}
+
+	logo := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(logo, logo.Bounds(), &image.Uniform{red}, image.Point{}, draw.Src)
+
+	for anchor, pt := range map[string]image.Point{
+		AnchorTopLeft:     {2, 2},
+		AnchorTopRight:    {28, 2},
+		AnchorBottomLeft:  {2, 28},
+		AnchorBottomRight: {28, 28},
+		AnchorCenter:      {15, 15},
+	} {
+		img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+		draw.Draw(img, img.Bounds(), &image.Uniform{white}, image.Point{}, draw.Src)
+
+		wm := &Watermark{Anchor: anchor, Margin: 2}
+		assert.NoError(wm.apply(img, logo))
+		assert.Equal(red, img.NRGBAAt(pt.X, pt.Y), anchor)
+		assert.Equal(red, img.NRGBAAt(pt.X+9, pt.Y+9), anchor)
+		assert.Equal(white, img.NRGBAAt(pt.X+10, pt.Y+10), anchor)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, img.Bounds(), &image.Uniform{white}, image.Point{}, draw.Src)
+	wm := &Watermark{Opacity: 0.5}
+	assert.NoError(wm.apply(img, logo))
+	c := img.NRGBAAt(35, 35)
+	assert.Equal(uint8(0xff), c.R)
+	assert.InDelta(0x80, int(c.G), 2)
+
+	wm.Anchor = "middle"
+	assert.Error(wm.apply(img, logo))
+}
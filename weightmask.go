@@ -0,0 +1,32 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+)
+
+// mergeWeightMask merges the grayscale weight mask into the bias map. Contrary to the binary masks,
+// the pixel values are mapped continuously to the protection strength: 0 is strongly removable,
+// 128 is neutral and 255 is strongly protected, which allows gradient falloffs around the subjects.
+// The weights are added to the ones of the energy rules, then clamped.
+func mergeWeightMask(bias, mask *image.NRGBA) error {
+	if mask == nil {
+		return nil
+	}
+	if mask.Bounds().Size() != bias.Bounds().Size() {
+		return &MaskSizeError{Mask: "weight mask", MaskSize: mask.Bounds().Size(), ImageSize: bias.Bounds().Size()}
+	}
+
+	b, mb := bias.Bounds(), mask.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			g := color.GrayModel.Convert(mask.NRGBAAt(mb.Min.X+x, mb.Min.Y+y)).(color.Gray).Y
+			i := bias.PixOffset(b.Min.X+x, b.Min.Y+y)
+
+			// The value is clamped to the [1, 255] range, symmetric around the neutral value.
+			v := min(max(int(bias.Pix[i])+int(g)-biasNeutral, 1), 0xff)
+			bias.Pix[i], bias.Pix[i+1], bias.Pix[i+2] = uint8(v), uint8(v), uint8(v)
+		}
+	}
+	return nil
+}
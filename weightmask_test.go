@@ -0,0 +1,62 @@
+package caire
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightMask_ShouldMapTheGrayLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	mask := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	for x, v := range []uint8{0, 64, 128, 255} {
+		mask.Set(x, 0, color.NRGBA{R: v, G: v, B: v, A: 255})
+	}
+	bias := renderBiasMap(nil, nil, mask.Bounds())
+	assert.NoError(mergeWeightMask(bias, mask))
+
+	var got []uint8
+	for x := 0; x < 4; x++ {
+		got = append(got, bias.NRGBAAt(x, 0).R)
+	}
+	assert.Equal([]uint8{1, 64, 128, 255}, got)
+
+	// The weights are added to the ones of the energy rules.
+	bias = renderBiasMap([]EnergyRule{{Shape: "rect", Args: []float64{0, 0, 4, 1}, Weight: 0.5}}, nil, mask.Bounds())
+	assert.NoError(mergeWeightMask(bias, mask))
+	assert.Equal(uint8(128+64-128), bias.NRGBAAt(0, 0).R)
+	assert.Equal(uint8(255), bias.NRGBAAt(3, 0).R)
+
+	assert.Error(mergeWeightMask(bias, image.NewNRGBA(image.Rect(0, 0, 2, 2))))
+}
+
+func TestWeightMask_ShouldProtectTheLighterAreas(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 20))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{R: 80, G: 120, B: 160, A: 255}}, image.Point{}, draw.Src)
+
+	// The weight is decreasing from left to right, the neutral gray being around x=15.
+	mask := image.NewNRGBA(img.Bounds())
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 20; y++ {
+			v := uint8(255 - x*255/29)
+			mask.Set(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	proc := &Processor{SobelThreshold: 4, NewWidth: 26, WeightMask: mask, SeamsSVGPath: "seams.svg"}
+	_, err := proc.Resize(img)
+	assert.NoError(err)
+
+	assert.Len(proc.tracker.removed, 4)
+	for _, seam := range proc.tracker.removed {
+		for _, pt := range seam {
+			assert.GreaterOrEqual(pt.X, 15)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package caire
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// ResizeYCbCr resizes a YCbCr image (as decoded from the JPEG files) without converting it to RGB.
+// The luma and chroma planes are carved together, while the energy map is computed from the luma
+// plane only. The subsampled chroma planes are expanded to the luma resolution while carving, then
+// they are subsampled again, so the resized image keeps the subsample ratio of the source.
+// The options reading the RGB colors of the carved image (see needsRGB) require the conversion.
+func (p *Processor) ResizeYCbCr(src *image.YCbCr) (*image.YCbCr, error) {
+	defer lockCarving()()
+	return p.resizeYCbCrLocked(src)
+}
+
+// resizeYCbCrLocked resizes the YCbCr image while the carving lock is held by the caller.
+func (p *Processor) resizeYCbCrLocked(src *image.YCbCr) (*image.YCbCr, error) {
+	if p.needsRGB() {
+		res, err := p.resizeLocked(p.imgToNRGBA(src))
+		if err != nil {
+			return nil, err
+		}
+		img := p.imgToNRGBA(res)
+		return toYCbCr(img.Bounds(), src.SubsampleRatio, func(i int) (uint8, uint8, uint8) {
+			return color.RGBToYCbCr(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		}), nil
+	}
+
+	// The Y, Cb and Cr components are carried in the R, G and B channels of the carved image.
+	weights := p.ChannelWeights
+	p.ChannelWeights = [3]float64{1, 0, 0}
+	defer func() { p.ChannelWeights = weights }()
+
+	res, err := p.resizeLocked(packYCbCr(src))
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) && timeout.Partial != nil {
+		// The partial result is carrying the packed components too.
+		part := p.imgToNRGBA(timeout.Partial)
+		timeout.Partial = toYCbCr(part.Bounds(), src.SubsampleRatio, func(i int) (uint8, uint8, uint8) {
+			return part.Pix[i], part.Pix[i+1], part.Pix[i+2]
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	img := p.imgToNRGBA(res)
+	return toYCbCr(img.Bounds(), src.SubsampleRatio, func(i int) (uint8, uint8, uint8) {
+		return img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+	}), nil
+}
+
+// packYCbCr stores the Y, Cb and Cr components of each pixel into the R, G and B channels of an
+// opaque NRGBA image, expanding the subsampled chroma planes to the luma resolution.
+func packYCbCr(src *image.YCbCr) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			yi := src.YOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			ci := src.COffset(bounds.Min.X+x, bounds.Min.Y+y)
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = src.Y[yi]
+			dst.Pix[i+1] = src.Cb[ci]
+			dst.Pix[i+2] = src.Cr[ci]
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// toYCbCr builds a YCbCr image of the provided subsample ratio, obtaining the components of
+// each pixel from the at function, which receives the pixel offset in an NRGBA buffer.
+// The chroma samples are the average of the pixels they are covering.
+func toYCbCr(bounds image.Rectangle, ratio image.YCbCrSubsampleRatio, at func(i int) (uint8, uint8, uint8)) *image.YCbCr {
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := image.NewYCbCr(image.Rect(0, 0, width, height), ratio)
+
+	cb := make([]int, len(dst.Cb))
+	cr := make([]int, len(dst.Cr))
+	count := make([]int, len(dst.Cb))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			yy, u, v := at(4 * (x + y*width))
+			dst.Y[dst.YOffset(x, y)] = yy
+
+			ci := dst.COffset(x, y)
+			cb[ci] += int(u)
+			cr[ci] += int(v)
+			count[ci]++
+		}
+	}
+	for i, n := range count {
+		if n > 0 {
+			dst.Cb[i] = uint8((cb[i] + n/2) / n)
+			dst.Cr[i] = uint8((cr[i] + n/2) / n)
+		}
+	}
+	return dst
+}
+
+// needsRGB reports whether the carving reads the RGB colors of the image, which are not available
+// while the Y, Cb and Cr components are carried in the R, G and B channels: the face detection, the
+// luminance used by the forward energy (also tried by the auto tuning) and by the fast mode, the
+// motion relative to the reference image and the segmentation of the color blocks.
+func (p *Processor) needsRGB() bool {
+	return p.FaceDetect || p.BlurFaces || p.useForwardEnergy() || p.AutoTune || p.FastMode || p.preset().fastMode ||
+		p.Reference != nil || p.ReferencePath != "" || p.ColorBlockKeep > 0
+}
+
+// supportsYCbCr reports whether the image can be carved directly in the YCbCr color space.
+// The options operating on the RGB pixels or on the decoded image require the conversion.
+func (p *Processor) supportsYCbCr(src image.Image, format string) bool {
+	_, ok := src.(*image.YCbCr)
+	return ok && format == FormatJPEG && !p.needsRGB() && !p.Preview && !p.Preflight &&
+		p.Rotate == 0 && p.Flip == "" && p.Grid == nil && p.Watermark == nil && p.Adjustments.IsZero() &&
+		p.RemovedPath == "" && p.GhostPath == "" && !p.PartialOutput && !p.Screenshot
+}
+
+// encodeYCbCr resizes the YCbCr image and encodes the result as JPEG.
+func (p *Processor) encodeYCbCr(w io.Writer, img *image.YCbCr) error {
+	endCarve := p.startSpan(SpanCarve)
+	res, err := p.resizeYCbCrLocked(img)
+	endCarve()
+	if err != nil {
+		return err
+	}
+	if err := p.checkSimilarity(img, res); err != nil {
+		return err
+	}
+
+	defer p.startSpan(SpanEncode)()
+	return p.encodeJPEG(w, res)
+}
@@ -0,0 +1,148 @@
+package caire
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYCbCr_ShouldCarveTheImagePlanes(t *testing.T) {
+	assert := assert.New(t)
+
+	src := image.NewYCbCr(image.Rect(0, 0, 40, 30), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			src.Y[src.YOffset(x, y)] = uint8(x * 6)
+			ci := src.COffset(x, y)
+			src.Cb[ci], src.Cr[ci] = uint8(100+x), uint8(150-y)
+		}
+	}
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, ChannelWeights: [3]float64{0, 1, 1}}
+	res, err := p.ResizeYCbCr(src)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+	assert.Equal(image.YCbCrSubsampleRatio420, res.SubsampleRatio)
+	assert.Equal([3]float64{0, 1, 1}, p.ChannelWeights)
+
+	// The result should be close to the one obtained by carving the RGB image.
+	rgb, err := (&Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32}).Resize(p.imgToNRGBA(src))
+	assert.NoError(err)
+	cmp, err := Compare(rgb, res)
+	assert.NoError(err)
+	assert.Greater(cmp.SSIM, 0.9)
+
+	// A uniform image should keep its colors exactly.
+	for i := range src.Y {
+		src.Y[i] = 80
+	}
+	for i := range src.Cb {
+		src.Cb[i], src.Cr[i] = 90, 160
+	}
+	res, err = p.ResizeYCbCr(src)
+	assert.NoError(err)
+	assert.Equal(color.YCbCr{Y: 80, Cb: 90, Cr: 160}, res.YCbCrAt(10, 10))
+}
+
+func TestYCbCr_ShouldProcessTheJpegImages(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	var in, out bytes.Buffer
+	assert.NoError(jpeg.Encode(&in, img, nil))
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, OutputFormat: FormatJPEG}
+	assert.True(p.supportsYCbCr(&image.YCbCr{}, FormatJPEG))
+	assert.NoError(p.Process(&in, &out))
+
+	res, err := jpeg.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+
+	p.Watermark = &Watermark{}
+	assert.False(p.supportsYCbCr(&image.YCbCr{}, FormatJPEG))
+	assert.False(p.supportsYCbCr(&image.YCbCr{}, FormatPNG))
+}
+
+func TestYCbCr_ShouldWriteTheGhostImage(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	var in, out bytes.Buffer
+	assert.NoError(jpeg.Encode(&in, img, nil))
+
+	// The ghost overlay is drawn over the RGB pixels, so the JPEG images are converted.
+	ghost := filepath.Join(t.TempDir(), "ghost.png")
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, OutputFormat: FormatJPEG, GhostPath: ghost}
+	assert.False(p.supportsYCbCr(&image.YCbCr{}, FormatJPEG))
+	assert.NoError(p.Process(&in, &out))
+	assert.FileExists(ghost)
+
+	res, err := jpeg.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+}
+
+func TestYCbCr_ShouldEncodeProgressively(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 0, 255})
+		}
+	}
+	var in, out bytes.Buffer
+	assert.NoError(jpeg.Encode(&in, img, nil))
+
+	p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 32, OutputFormat: FormatJPEG, JPEGProgressive: true}
+	assert.NoError(p.Process(&in, &out))
+	assert.True(bytes.Contains(out.Bytes(), []byte{0xff, 0xc2}))
+
+	res, err := jpeg.Decode(&out)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 32, 30), res.Bounds())
+}
+
+func TestYCbCr_ShouldMatchTheRGBCarvingForEachPreset(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(jpeg.Encode(&buf, texturedImage(96, 80), &jpeg.Options{Quality: 100}))
+	dec, err := jpeg.Decode(&buf)
+	assert.NoError(err)
+	src := dec.(*image.YCbCr)
+
+	for _, quality := range []string{QualityFast, QualityBalanced, QualityBest} {
+		p := &Processor{SobelThreshold: 2, BlurRadius: 1, NewWidth: 70, Quality: quality}
+		res, err := p.ResizeYCbCr(src)
+		assert.NoError(err)
+		want, err := p.Resize(p.imgToNRGBA(src))
+		assert.NoError(err)
+
+		got, exp := p.imgToNRGBA(res), p.imgToNRGBA(want)
+		assert.Equal(exp.Bounds(), got.Bounds(), quality)
+		var diff int
+		for i := range exp.Pix {
+			if d := int(exp.Pix[i]) - int(got.Pix[i]); d > 20 || d < -20 {
+				diff++
+			}
+		}
+		assert.Zero(diff, "%s: %d channels differ", quality, diff)
+	}
+}